@@ -0,0 +1,45 @@
+package cfg
+
+import (
+	"errors"
+)
+
+// Ways the assignees of the GitHub issues are written to their JIRA issues,
+// as configured in `assignee-mode`.
+const (
+	AssigneeFirst      = "first"
+	AssigneeRoundRobin = "round-robin"
+	AssigneeField      = "field"
+)
+
+// GetAssigneeMode returns how the assignees of the GitHub issues are written
+// to their JIRA issues: the first one mapped is assigned, one of those
+// mapped is assigned in turn, or all of them are written to a multi-user
+// custom field. It's empty if they aren't synced.
+func (c Config) GetAssigneeMode() string {
+	return c.cmdConfig.GetString("assignee-mode")
+}
+
+// getAssigneesField returns the name, or the ID, of the multi-user custom
+// field the assignees are written to, with the field `assignee-mode`.
+func (c Config) getAssigneesField() string {
+	return c.cmdConfig.GetString("assignees-field")
+}
+
+// validateAssignees checks the way the assignees are written, and that the
+// multi-user field is set if, and only if, they're written to one.
+func (c Config) validateAssignees() error {
+	switch c.GetAssigneeMode() {
+	case "", AssigneeFirst, AssigneeRoundRobin:
+		if c.getAssigneesField() != "" {
+			return errors.New("assignees-field requires the assignee-mode field")
+		}
+	case AssigneeField:
+		if c.getAssigneesField() == "" {
+			return errors.New("the assignee-mode field requires an assignees-field")
+		}
+	default:
+		return errors.New("assignee mode must be first, round-robin, or field")
+	}
+	return nil
+}
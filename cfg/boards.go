@@ -0,0 +1,88 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+)
+
+// defaultBoardField is the field of GitHub projects holding the status of
+// their items, unless another one is configured.
+const defaultBoardField = "Status"
+
+// ProjectBoard is a GitHub project (v2) whose status of the items of the
+// GitHub issues is synchronized to JIRA, as configured in `project-board`.
+type ProjectBoard struct {
+	// Owner and Number identify the project: Owner is the login of the
+	// organization or user owning it.
+	Owner  string `json:"owner" mapstructure:"owner"`
+	Number int    `json:"number" mapstructure:"number"`
+	// Field is the single select field of the project holding the status of
+	// its items; if it's empty, the "Status" field is used.
+	Field string `json:"field,omitempty" mapstructure:"field"`
+	// Transitions maps each status to the JIRA transition or status the
+	// issues whose item has it are moved to.
+	Transitions map[string]string `json:"transitions,omitempty" mapstructure:"transitions"`
+	// CustomField is the ID of a JIRA text custom field the status of the
+	// items is written to, such as "customfield_10050".
+	CustomField string `json:"custom-field,omitempty" mapstructure:"custom-field"`
+}
+
+// GetField returns the field of the project holding the status of its
+// items.
+func (b ProjectBoard) GetField() string {
+	if b.Field == "" {
+		return defaultBoardField
+	}
+	return b.Field
+}
+
+// GetTransition returns the JIRA transition or status the issues whose item
+// has the status are moved to, if any. Statuses are compared regardless of
+// case, as the keys of the configuration are lowercased.
+func (b ProjectBoard) GetTransition(status string) (string, bool) {
+	for s, target := range b.Transitions {
+		if strings.EqualFold(s, status) {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// GetCustomFieldKey returns the key of the JIRA custom field the status of
+// the items is written to, or an empty key if there's none.
+func (b ProjectBoard) GetCustomFieldKey() string {
+	id, ok := fieldRefID(b.CustomField)
+	if !ok {
+		return ""
+	}
+	return "customfield_" + id
+}
+
+// validate checks that the project is identified, and that its custom
+// field is given by ID.
+func (b ProjectBoard) validate() error {
+	if b.Owner == "" || b.Number <= 0 {
+		return errors.New("project board requires an owner and a number")
+	}
+	if b.CustomField != "" && b.GetCustomFieldKey() == "" {
+		return errors.New("project board custom field must be the ID of a JIRA custom field")
+	}
+	return nil
+}
+
+// GetProjectBoard returns the GitHub project whose status of the items of
+// the GitHub issues of the repo is synchronized: that of its project entry,
+// or else the global `project-board`, if any.
+func (c Config) GetProjectBoard(repo string) (ProjectBoard, bool) {
+	if board, ok := c.boards[repo]; ok {
+		return board, true
+	}
+	if !c.cmdConfig.IsSet("project-board") {
+		return ProjectBoard{}, false
+	}
+	var board ProjectBoard
+	if err := c.cmdConfig.UnmarshalKey("project-board", &board); err != nil || board.Number == 0 {
+		return ProjectBoard{}, false
+	}
+	return board, true
+}
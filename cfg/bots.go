@@ -0,0 +1,53 @@
+package cfg
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ignoredAuthorsKey is the configuration option listing the GitHub users,
+// such as bots, whose comments aren't synced.
+const ignoredAuthorsKey = "ignore-comment-authors"
+
+// defaultIgnoredAuthors are the bots whose comments aren't synced, unless
+// `ignore-comment-authors` is set.
+var defaultIgnoredAuthors = []string{"dependabot[bot]", "github-actions[bot]", "stale[bot]"}
+
+// isOptionSet returns whether an option is given on the command line, in
+// the environment, or in the configuration file. Viper's IsSet can't tell,
+// as it's true of every option with a flag once flags are bound, even if
+// they're left to their default.
+func isOptionSet(cmd *cobra.Command, v *viper.Viper, key string) bool {
+	if f := cmd.Flags().Lookup(key); f != nil && f.Changed {
+		return true
+	}
+	env := strings.NewReplacer("-", "_").Replace(strings.ToUpper("issue-sync_" + key))
+	return os.Getenv(env) != "" || v.InConfig(key)
+}
+
+// getIgnoredAuthors returns the logins of the GitHub users whose comments
+// aren't synced: those of `ignore-comment-authors`, even if it's empty, or
+// else the default bots.
+func (c Config) getIgnoredAuthors() []string {
+	if c.ignoredAuthorsSet {
+		return c.cmdConfig.GetStringSlice(ignoredAuthorsKey)
+	}
+	return defaultIgnoredAuthors
+}
+
+// IgnoresCommentAuthor returns whether the comments of the GitHub user with
+// the login aren't synced. Logins are compared regardless of their case,
+// and bots match with or without their [bot] suffix, such as "renovate".
+func (c Config) IgnoresCommentAuthor(login string) bool {
+	login = strings.ToLower(login)
+	for _, ignored := range c.getIgnoredAuthors() {
+		ignored = strings.ToLower(strings.TrimSpace(ignored))
+		if ignored != "" && (login == ignored || login == ignored+"[bot]") {
+			return true
+		}
+	}
+	return false
+}
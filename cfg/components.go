@@ -0,0 +1,108 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ComponentRule maps the GitHub issues whose path-style labels, or the
+// section of their issue form set in `component-heading`, are under a path
+// of a monorepo to a JIRA component, as configured in `components`.
+type ComponentRule struct {
+	// Path is a path of the monorepo, such as "pkg/storage", which matches
+	// itself and the paths under it, or a glob pattern, such as "pkg/*/db".
+	Path string `json:"path" mapstructure:"path"`
+	// Component is the name of the JIRA component of the issues under the
+	// path, such as "Storage".
+	Component string `json:"component" mapstructure:"component"`
+}
+
+// componentRules returns the rules of `components`.
+func (c Config) componentRules() []ComponentRule {
+	var rules []ComponentRule
+	c.cmdConfig.UnmarshalKey("components", &rules)
+	return rules
+}
+
+// UsesComponents returns whether the JIRA components of the JIRA issues are
+// set from the paths of their GitHub issue.
+func (c Config) UsesComponents() bool {
+	return len(c.componentRules()) > 0
+}
+
+// GetComponentHeading returns the heading of the section of the GitHub
+// issue forms which lists the paths of the component rules, such as
+// "Affected Packages", or an empty heading if only labels are.
+func (c Config) GetComponentHeading() string {
+	return c.cmdConfig.GetString("component-heading")
+}
+
+// cleanPath returns a path of a label or a rule without its surrounding
+// slashes and spaces, in lower case, as paths are compared regardless of
+// their case.
+func cleanPath(p string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(p), "/"))
+}
+
+// matches returns whether a component rule matches a path, and how
+// specific it is: the length of its path.
+func (r ComponentRule) matches(p string) (bool, int) {
+	rule := cleanPath(r.Path)
+	if strings.ContainsAny(rule, "*?[") {
+		ok, _ := path.Match(rule, p)
+		return ok, len(rule)
+	}
+	return p == rule || strings.HasPrefix(p, rule+"/"), len(rule)
+}
+
+// GetComponents returns the names of the JIRA components of a GitHub issue
+// with the paths, such as its labels, in `components`, sorted. Each path
+// is mapped by the most specific rule which matches it; paths which none
+// matches are ignored.
+func (c Config) GetComponents(paths []string) []string {
+	rules := c.componentRules()
+
+	found := make(map[string]bool)
+	for _, p := range paths {
+		p = cleanPath(p)
+		if p == "" {
+			continue
+		}
+		best, length := "", -1
+		for _, rule := range rules {
+			if ok, n := rule.matches(p); ok && n > length {
+				best, length = rule.Component, n
+			}
+		}
+		if best != "" {
+			found[best] = true
+		}
+	}
+
+	components := make([]string, 0, len(found))
+	for name := range found {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+	return components
+}
+
+// validateComponents checks that the rules of `components` have a valid
+// path and a component.
+func (c Config) validateComponents() error {
+	for _, rule := range c.componentRules() {
+		if cleanPath(rule.Path) == "" || strings.TrimSpace(rule.Component) == "" {
+			return errors.New("components requires a path and a component")
+		}
+		if _, err := path.Match(cleanPath(rule.Path), ""); err != nil {
+			return fmt.Errorf("components has a bad path pattern: %s", rule.Path)
+		}
+	}
+	if c.GetComponentHeading() != "" && !c.UsesComponents() {
+		return errors.New("component-heading requires components")
+	}
+	return nil
+}
@@ -1,28 +1,39 @@
 package cfg
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
 	"github.com/dghubble/oauth1"
-	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
-	"github.com/andygrunwald/go-jira"
+	"golang.org/x/oauth2"
 )
 
 // dateFormat is the format used for the `since` configuration parameter
 const dateFormat = "2006-01-02T15:04:05-0700"
 
+// defaultGitHubPageSize is the number of results requested per page of GitHub
+// results if it isn't configured, which is the maximum GitHub allows.
+const defaultGitHubPageSize = 100
+
+// defaultJIRAPageSize is the number of issues requested per page of JIRA
+// search results if it isn't configured, which is the JIRA default.
+const defaultJIRAPageSize = 50
+
 // defaultLogLevel is the level logrus should default to if the configured option can't be parsed
 const defaultLogLevel = logrus.InfoLevel
 
@@ -36,6 +47,12 @@ const (
 	GitHubStatus   fieldKey = iota
 	GitHubReporter fieldKey = iota
 	LastISUpdate   fieldKey = iota
+	GitHubVotes    fieldKey = iota
+	EpicLink       fieldKey = iota
+	EpicName       fieldKey = iota
+	StoryPoints    fieldKey = iota
+	Flagged        fieldKey = iota
+	AssigneesField fieldKey = iota
 )
 
 // fields represents the custom field IDs of the JIRA custom fields we care about
@@ -46,12 +63,75 @@ type fields struct {
 	githubReporter string
 	githubStatus   string
 	lastUpdate     string
+	githubVotes    string
+	// epicLink and epicName are the fields of JIRA Software holding the epic
+	// of an issue and the name of an epic, which only JIRA Server has.
+	epicLink string
+	epicName string
+	// storyPoints is the field of the story points, with `story-points`.
+	storyPoints string
+	// flagged is the "Flagged" field of JIRA Software, with the flag
+	// `pinned-issues`.
+	flagged string
+	// assignees is the multi-user field of the assignees, with the field
+	// `assignee-mode`.
+	assignees string
+	// forms are the fields of the sections of issue forms, by lowercased
+	// heading, with `issue-form-fields`.
+	forms map[string]FormField
 }
 
 // Project represents the project configuration as it exists in the configuration file.
 type Project struct {
+	// Repo is the GitHub repo, in owner/repo form, or a glob pattern of the
+	// repos of the owner, such as "myorg/*", which is resolved to them at
+	// runtime.
 	Repo string `json:"repo" mapstructure:"repo"`
 	Key  string `json:"key" mapstructure:"key"`
+	// Exclude are the names or glob patterns of the repos a repo pattern
+	// doesn't match, such as "legacy-*" or "myorg/website".
+	Exclude []string `json:"exclude,omitempty" mapstructure:"exclude"`
+	// Topics are the topics a repo matched by a repo pattern must carry one
+	// of, such as "jira-sync", so that repos are opted in by their topics.
+	Topics []string `json:"topics,omitempty" mapstructure:"topics"`
+	// Pattern is the repo pattern a project was resolved from; it isn't
+	// part of the configuration file.
+	Pattern string `json:"-" mapstructure:"pattern"`
+	// Since is the time of the last successful sync of this project, as saved
+	// by earlier versions of issue-sync; the time recorded in the state file
+	// takes precedence. If it's empty, the global `since` is used.
+	Since string `json:"since,omitempty" mapstructure:"since"`
+	// StateFilter is the state of the GitHub issues synchronized: "open",
+	// "closed", or "all". If it's empty, the global `state-filter` is used.
+	StateFilter string `json:"state-filter,omitempty" mapstructure:"state-filter"`
+	// Instance is the name of the JIRA instance of `jira-instances` the
+	// project is synchronized to. If it's empty, the top-level JIRA options
+	// are used.
+	Instance string `json:"jira-instance,omitempty" mapstructure:"jira-instance"`
+	// IncludeLabels and ExcludeLabels are the labels the GitHub issues
+	// synchronized must carry one of, and mustn't carry any of. If both are
+	// empty, the global `include-labels` and `exclude-labels` are used.
+	IncludeLabels []string `json:"include-labels,omitempty" mapstructure:"include-labels"`
+	ExcludeLabels []string `json:"exclude-labels,omitempty" mapstructure:"exclude-labels"`
+	// Milestones are the names or glob patterns of the milestones of the
+	// GitHub issues synchronized. If it's empty, the global `milestones` is
+	// used.
+	Milestones []string `json:"milestones,omitempty" mapstructure:"milestones"`
+	// Authors and Assignees are the users, by login or @org/team, whose
+	// GitHub issues are synchronized, and those the issues synchronized
+	// are assigned to. Each one that's empty is taken from the global
+	// `authors` or `assignees`.
+	Authors   []string `json:"authors,omitempty" mapstructure:"authors"`
+	Assignees []string `json:"assignees,omitempty" mapstructure:"assignees"`
+	// JQLFilter is a JQL fragment restricting the JIRA issues of the project
+	// which are managed. If it's empty, the global `jql-filter` is used.
+	JQLFilter string `json:"jql-filter,omitempty" mapstructure:"jql-filter"`
+	// Board is the GitHub project whose status of the items of the issues
+	// is synchronized. If it's empty, the global `project-board` is used.
+	Board *ProjectBoard `json:"project-board,omitempty" mapstructure:"project-board"`
+	// EmailTo are the addresses the email notifications of the project are
+	// sent to, in addition to the global `email-to`.
+	EmailTo []string `json:"email-to,omitempty" mapstructure:"email-to"`
 }
 
 // Config is the root configuration object the application creates.
@@ -64,7 +144,8 @@ type Config struct {
 	// log is a logger set up with the configured log level, app name, etc.
 	log logrus.Entry
 
-	// basicAuth represents whether we're using HTTP Basic authentication or OAuth.
+	// basicAuth represents whether we're using HTTP Basic authentication or OAuth,
+	// unless a personal access token is used.
 	basicAuth bool
 
 	// fieldIDs is the list of custom fields we pulled from the `fields` JIRA endpoint.
@@ -73,15 +154,124 @@ type Config struct {
 	// projects represents the mapping from the GitHub repos to JIRA projects the user configured.
 	projects map[string]jira.Project
 
+	// inferredKeys maps a GitHub repo whose project entry has no key to the
+	// JIRA project key inferred for it. They're not saved to the configuration.
+	inferredKeys map[string]string
+
+	// stateFilters maps a GitHub repo to the state of the issues synchronized from it.
+	stateFilters map[string]string
+
+	// labelFilters maps a GitHub repo to the labels of the issues synchronized
+	// from it, if its project entry has any.
+	labelFilters map[string]labelFilter
+
+	// milestoneFilters maps a GitHub repo to the milestones of the issues
+	// synchronized from it, if its project entry has any.
+	milestoneFilters map[string][]string
+
+	// userFilters maps a GitHub repo to the authors and the assignees of the
+	// issues synchronized from it, if its project entry has any.
+	userFilters map[string]userFilter
+
+	// jqlFilters maps a JIRA project key to the JQL fragment restricting the
+	// issues managed in it, if its project entry has one.
+	jqlFilters map[string]string
+
+	// boards maps a GitHub repo to the GitHub project whose status of the
+	// items of its issues is synchronized, if its project entry has one.
+	boards map[string]ProjectBoard
+
+	// emailRecipients maps a GitHub repo to the addresses its email
+	// notifications are sent to, if its project entry has any.
+	emailRecipients map[string][]string
+
+	// transitions maps a GitHub issue state ("open" or "closed") to the JIRA
+	// transition or status issues in that state should be moved to.
+	transitions map[string]string
+
 	// since is the parsed value of the `since` configuration parameter, which is the earliest that
 	// a GitHub issue can have been updated to be retrieved.
 	since time.Time
+
+	// sinceOverridden is set when `since` was overridden for this run, in which
+	// case it applies to every project regardless of their own `since` times.
+	sinceOverridden bool
+
+	// ignoredAuthorsSet is whether `ignore-comment-authors` is set, rather
+	// than left to its default.
+	ignoredAuthorsSet bool
+
+	// resync is set when every GitHub issue is synchronized again, as by
+	// `issue-sync resync`, even those which didn't change since they were
+	// last synchronized.
+	resync bool
+
+	// projectSince holds the time of the last successful sync of each GitHub repo.
+	projectSince map[string]time.Time
+	// projectSinceLock protects projectSince, which is shared by all copies of the Config.
+	projectSinceLock *sync.Mutex
+
+	// issueSlots is the pool of `concurrency` slots the issues of all repos
+	// take while they're synchronized; it's shared by all copies of the Config.
+	issueSlots chan struct{}
+
+	// appTokens holds the token source of the GitHub App installation, which
+	// is shared by all copies of the Config. A reloaded configuration starts
+	// without one, so it uses the app and key it is configured with.
+	appTokens *appTokens
+
+	// configuredProjects is the list of projects as configured, once their
+	// repo patterns are resolved, and nil before; it's shared by all copies
+	// of the Config.
+	configuredProjects *[]Project
+
+	// state is the persistent local state, or nil if no `state-file` is configured.
+	state *State
+
+	// ctx is cancelled when issue-sync is asked to shut down, which cancels
+	// the API calls in flight. It's nil until a context is set.
+	ctx context.Context
+
+	// cipher holds the passphrase the secrets of the configuration file are
+	// encrypted with, if they are.
+	cipher *configCipher
+
+	// vaultRefs maps the options whose value was read from Vault to their
+	// references, which are saved instead of the secrets.
+	vaultRefs map[string]string
+
+	// instance is the name of the JIRA instance this configuration targets,
+	// or empty for the default instance of the top-level options.
+	instance string
+	// projectInstances maps each GitHub repo to the name of the JIRA instance
+	// its project is synchronized to.
+	projectInstances map[string]string
+	// instanceFieldIDs holds the custom field IDs of each JIRA instance,
+	// which is shared by all copies of the Config.
+	instanceFieldIDs map[string]fields
+	// deployments holds the deployment of each JIRA instance, Cloud or
+	// Server, which is shared by all copies of the Config.
+	deployments map[string]jiraDeployment
 }
 
 // NewConfig creates a new, immutable configuration object. This object
 // holds the Viper configuration and the logger, and is validated. The
 // JIRA configuration is not yet initialized.
 func NewConfig(cmd *cobra.Command) (Config, error) {
+	config := LoadConfig(cmd)
+
+	if err := config.validateConfig(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// LoadConfig creates a configuration object from the command line and
+// configuration file, like NewConfig, but without validating it. It is
+// meant for commands which set up the credentials a valid configuration
+// requires.
+func LoadConfig(cmd *cobra.Command) Config {
 	config := Config{}
 
 	var err error
@@ -95,27 +285,60 @@ func NewConfig(cmd *cobra.Command) (Config, error) {
 
 	config.cmdFile = config.cmdConfig.ConfigFileUsed()
 
-	config.log = *newLogger("issue-sync", config.cmdConfig.GetString("log-level"))
+	config.log = *newLogger("issue-sync", config.cmdConfig.GetString("log-level"), config.cmdConfig.GetString("log-format"))
 	config.projects = make(map[string]jira.Project)
+	config.projectSince = make(map[string]time.Time)
+	config.stateFilters = make(map[string]string)
+	config.labelFilters = make(map[string]labelFilter)
+	config.milestoneFilters = make(map[string][]string)
+	config.userFilters = make(map[string]userFilter)
+	config.jqlFilters = make(map[string]string)
+	config.boards = make(map[string]ProjectBoard)
+	config.emailRecipients = make(map[string][]string)
+	config.inferredKeys = make(map[string]string)
+	config.vaultRefs = make(map[string]string)
+	config.projectInstances = make(map[string]string)
+	config.instanceFieldIDs = make(map[string]fields)
+	config.deployments = make(map[string]jiraDeployment)
+	config.cipher = &configCipher{}
+	config.projectSinceLock = &sync.Mutex{}
+	config.configuredProjects = new([]Project)
+	config.issueSlots = make(chan struct{}, config.GetConcurrency())
+	config.appTokens = &appTokens{}
+
+	if config.UsesKeychain() {
+		config.loadKeychainSecrets()
+	}
 
-	if err := config.validateConfig(); err != nil {
-		return Config{}, err
+	// A `since` given on the command line applies to every project.
+	if f := cmd.Flags().Lookup("since"); f != nil && f.Changed {
+		config.sinceOverridden = true
 	}
+	config.ignoredAuthorsSet = isOptionSet(cmd, &config.cmdConfig, ignoredAuthorsKey)
 
-	return config, nil
+	return config
 }
 
 // LoadJIRAConfig loads the JIRA configuration (project key,
-// custom field IDs) from a remote JIRA server.
+// custom field IDs) from a remote JIRA server. Only the projects of the
+// JIRA instance of the configuration are loaded. The projects and fields
+// are read from the state instead if they were cached less than
+// `jira-metadata-ttl` ago.
 func (c *Config) LoadJIRAConfig(client jira.Client) error {
-	var projects []Project
-
-	c.cmdConfig.UnmarshalKey("projects", &projects)
+	projects := c.instanceProjects()
 
-	for _, project := range projects {
-		proj, res, err := client.Project.Get(project.Key)
+	for i, project := range projects {
+		if project.Key == "" {
+			project.Key = c.inferredKeys[project.Repo]
+			projects[i].Key = project.Key
+		}
+		proj := new(jira.Project)
+		res, err := c.getMetadata(client, "rest/api/2/project/"+project.Key, proj)
 		if err != nil {
 			c.log.Errorf("Error retrieving JIRA project; check key and credentials. Error: %v", err)
+			if res == nil {
+				return err
+			}
 			defer res.Body.Close()
 			body, err := ioutil.ReadAll(res.Body)
 			if err != nil {
@@ -128,11 +351,24 @@ func (c *Config) LoadJIRAConfig(client jira.Client) error {
 		c.projects[project.Repo] = *proj
 	}
 
+	if err := c.detectDeployment(client); err != nil {
+		return err
+	}
+
 	var err error
 	c.fieldIDs, err = c.getFieldIDs(client)
 	if err != nil {
 		return err
 	}
+	c.instanceFieldIDs[c.instance] = c.fieldIDs
+
+	if len(c.transitions) > 0 {
+		for _, project := range projects {
+			if err := c.validateTransitions(client, project.Key); err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
@@ -142,8 +378,20 @@ func (c Config) GetConfigFile() string {
 	return c.cmdFile
 }
 
-// GetConfigString returns a string value from the Viper configuration.
+// SetConfigFile sets the file SaveConfig writes the configuration to, for
+// when no configuration file was loaded.
+func (c *Config) SetConfigFile(path string) {
+	c.cmdFile = path
+	c.cmdConfig.SetConfigFile(path)
+}
+
+// GetConfigString returns a string value from the Viper configuration. The
+// options of the JIRA server and credentials are those of the JIRA instance
+// of the configuration.
 func (c Config) GetConfigString(key string) string {
+	if c.instance != "" && isInstanceKey(key) {
+		return c.cmdConfig.GetString(instanceOption(c.instance, key))
+	}
 	return c.cmdConfig.GetString(key)
 }
 
@@ -153,19 +401,129 @@ func (c Config) IsBasicAuth() bool {
 	return c.basicAuth
 }
 
+// IsPATAuth is true if we're authenticating to JIRA with a personal access
+// token, sent as a bearer token; it takes precedence over the other methods.
+func (c Config) IsPATAuth() bool {
+	return c.GetConfigString("jira-pat") != ""
+}
+
 // GetSinceParam returns the `since` configuration parameter, parsed as a time.Time.
 func (c Config) GetSinceParam() time.Time {
 	return c.since
 }
 
+// SetSinceParam overrides the `since` configuration parameter for this run,
+// for every project. It must be called before the clients are created from
+// the configuration.
+func (c *Config) SetSinceParam(since time.Time) {
+	c.since = since
+	c.sinceOverridden = true
+}
+
+// SetResync makes this run synchronize every GitHub issue again, comparing
+// it to its JIRA issue, even if the state records that it didn't change.
+func (c *Config) SetResync() {
+	c.resync = true
+}
+
+// IsResync returns whether this run synchronizes every GitHub issue again.
+func (c Config) IsResync() bool {
+	return c.resync
+}
+
+// GetLastSync returns the time of the last successful sync of a GitHub repo,
+// as recorded in the state, and whether it was ever synchronized.
+func (c Config) GetLastSync(repo string) (time.Time, bool) {
+	return c.state.getProjectSince(repo)
+}
+
+// GetProjectSince returns the time of the last successful sync of a GitHub
+// repo, which is the earliest that a GitHub issue of the repo can have been
+// updated to be retrieved. If the repo has never been synced, it returns the
+// `since` configuration parameter.
+func (c Config) GetProjectSince(repo string) time.Time {
+	if c.sinceOverridden {
+		return c.since
+	}
+	if t, ok := c.state.getProjectSince(repo); ok {
+		return t
+	}
+
+	c.projectSinceLock.Lock()
+	defer c.projectSinceLock.Unlock()
+
+	if t, ok := c.projectSince[repo]; ok {
+		return t
+	}
+	return c.since
+}
+
+// SetProjectSince records the time a successful sync of a GitHub repo
+// started, to be saved in the state file and used by the next run.
+func (c Config) SetProjectSince(repo string, since time.Time) {
+	c.state.setProjectSince(repo, since)
+
+	c.projectSinceLock.Lock()
+	defer c.projectSinceLock.Unlock()
+
+	c.projectSince[repo] = since
+}
+
+// GetReportFile returns the path of the file the report of each run is
+// saved to, or an empty path if none is configured.
+func (c Config) GetReportFile() string {
+	return os.ExpandEnv(c.cmdConfig.GetString("report-file"))
+}
+
+// GetAuditLog returns the path of the file every change made to GitHub and
+// JIRA is appended to, or an empty path if none is configured.
+func (c Config) GetAuditLog() string {
+	return os.ExpandEnv(c.cmdConfig.GetString("audit-log"))
+}
+
+// GetState returns the persistent local state, which is nil if no
+// `state-file` is configured.
+func (c Config) GetState() *State {
+	return c.state
+}
+
+// WithContext returns a copy of the configuration whose API calls, and
+// synchronizations, are cancelled along with the context.
+func (c Config) WithContext(ctx context.Context) Config {
+	c.ctx = ctx
+	return c
+}
+
+// Context returns the context of the configuration, which is cancelled when
+// issue-sync shuts down, or a context which never is if none was set.
+func (c Config) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // GetLogger returns the configured application logger.
 func (c Config) GetLogger() logrus.Entry {
 	return c.log
 }
 
 // IsDryRun returns whether the application is running in dry-run mode or not.
+// Writing a plan implies a dry run.
 func (c Config) IsDryRun() bool {
-	return c.cmdConfig.GetBool("dry-run")
+	return c.cmdConfig.GetBool("dry-run") || c.GetPlanFile() != ""
+}
+
+// GetPlanFile returns the file the actions of a dry run are written to, as
+// given by `--plan-out`, or an empty path if none are.
+func (c Config) GetPlanFile() string {
+	return c.cmdConfig.GetString("plan-out")
+}
+
+// GetApplyFile returns the plan to execute instead of synchronizing, as
+// given by `--apply`, or an empty path if there's none.
+func (c Config) GetApplyFile() string {
+	return c.cmdConfig.GetString("apply")
 }
 
 // IsDaemon returns whether the application is running as a daemon
@@ -183,24 +541,296 @@ func (c Config) GetTimeout() time.Duration {
 	return c.cmdConfig.GetDuration("timeout")
 }
 
+// GetStateFilter returns the state of the GitHub issues of the repo which
+// are synchronized: "open", "closed", or "all". It's requested from GitHub,
+// so issues in other states aren't even listed.
+func (c Config) GetStateFilter(repo string) string {
+	if filter, ok := c.stateFilters[repo]; ok {
+		return filter
+	}
+	if filter := c.cmdConfig.GetString("state-filter"); filter != "" {
+		return filter
+	}
+	return "all"
+}
+
+// GetListedStates returns the state of the GitHub issues of the repo which
+// are listed: that of GetStateFilter, except that with "open", the issues
+// updated since the last sync are listed whatever their state, so that those
+// closed since get their JIRA issue closed. On the first sync, whose `since`
+// time is the epoch, only the open issues are listed.
+func (c Config) GetListedStates(repo string) string {
+	filter := c.GetStateFilter(repo)
+	if filter == "open" && c.GetProjectSince(repo).After(time.Unix(0, 0)) {
+		return "all"
+	}
+	return filter
+}
+
+// isStateFilter reports whether the string is a valid state filter.
+func isStateFilter(filter string) bool {
+	return filter == "open" || filter == "closed" || filter == "all"
+}
+
+// GetGitHubPageSize returns the number of issues or comments requested per
+// page of GitHub results.
+func (c Config) GetGitHubPageSize() int {
+	if n := c.cmdConfig.GetInt("github-page-size"); n > 0 {
+		return n
+	}
+	return defaultGitHubPageSize
+}
+
+// UsesGitHubGraphQL returns whether the GitHub issues, with their comments
+// and the status of their project items, are listed through the GitHub
+// GraphQL API rather than the REST API.
+func (c Config) UsesGitHubGraphQL() bool {
+	return c.cmdConfig.GetBool("github-graphql")
+}
+
+// UsesETagCache returns whether the responses of the GitHub list calls are
+// kept in the state, so that the next runs make them conditionally.
+func (c Config) UsesETagCache() bool {
+	return c.cmdConfig.GetBool("github-etag-cache")
+}
+
+// CreatesMissingFields returns whether the required custom fields which
+// don't exist are created when the JIRA configuration is loaded.
+func (c Config) CreatesMissingFields() bool {
+	return c.cmdConfig.GetBool("create-fields")
+}
+
+// GetJIRAMetadataTTL returns how long the JIRA projects and fields are
+// cached in the state; 0 disables the cache.
+func (c Config) GetJIRAMetadataTTL() time.Duration {
+	return c.cmdConfig.GetDuration("jira-metadata-ttl")
+}
+
+// GetJIRAPageSize returns the maximum number of issues requested per page
+// of JIRA search results.
+func (c Config) GetJIRAPageSize() int {
+	if n := c.cmdConfig.GetInt("jira-page-size"); n > 0 {
+		return n
+	}
+	return defaultJIRAPageSize
+}
+
+// GetRetryMaxAttempts returns the maximum number of attempts of an API call
+// which fails with a transient error, or 0 if it's only bounded by `timeout`.
+func (c Config) GetRetryMaxAttempts() int {
+	return c.cmdConfig.GetInt("retry-max-attempts")
+}
+
+// GetConcurrency returns the maximum number of repos, and of issues across
+// all of them, which are synchronized at the same time.
+func (c Config) GetConcurrency() int {
+	if n := c.cmdConfig.GetInt("concurrency"); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// IssueSlots returns the pool of `concurrency` slots which bounds the number
+// of issues synchronized at the same time, whichever their repo.
+func (c Config) IssueSlots() chan struct{} {
+	if c.issueSlots == nil {
+		return make(chan struct{}, c.GetConcurrency())
+	}
+	return c.issueSlots
+}
+
+// GetCommentConcurrency returns the maximum number of comments of an issue
+// which are compared and translated at the same time.
+func (c Config) GetCommentConcurrency() int {
+	if n := c.cmdConfig.GetInt("comment-concurrency"); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// UseTranslationFallback returns whether GitHub bodies whose translation to
+// JIRA markup looks incorrect are sent verbatim in a {noformat} macro instead.
+func (c Config) UseTranslationFallback() bool {
+	return c.cmdConfig.GetBool("translation-fallback")
+}
+
+// UseMilestoneVersions returns whether GitHub milestones are mapped to
+// JIRA versions, which are set as the fix version of the synced issues.
+func (c Config) UseMilestoneVersions() bool {
+	return c.cmdConfig.GetBool("milestone-versions")
+}
+
+// UseMilestoneEpics returns whether GitHub milestones are mapped to JIRA
+// epics, which the synced issues are added to.
+func (c Config) UseMilestoneEpics() bool {
+	return c.cmdConfig.GetBool("milestone-epics")
+}
+
+// SyncsReactions returns whether the number of thumbs up reactions to the
+// GitHub issues is written to the "GitHub Votes" custom field of the synced issues.
+func (c Config) SyncsReactions() bool {
+	return c.cmdConfig.GetBool("sync-reactions")
+}
+
+// Actions taken on the JIRA issues whose GitHub issue was deleted or
+// transferred, as configured in `orphaned-issues`.
+const (
+	OrphanClose  = "close"
+	OrphanFlag   = "flag"
+	OrphanRelink = "relink"
+)
+
+// GetOrphanAction returns the action taken on the JIRA issues whose GitHub
+// issue was deleted or transferred: close, flag, or relink, or an empty
+// action if they aren't looked for.
+func (c Config) GetOrphanAction() string {
+	return c.cmdConfig.GetString("orphaned-issues")
+}
+
+// GetOrphanCheckInterval returns how long the JIRA issues of a repo aren't
+// checked for orphans again after they were, or 0 to check them on every
+// run.
+func (c Config) GetOrphanCheckInterval() time.Duration {
+	return c.cmdConfig.GetDuration("orphan-check-interval")
+}
+
+// Strategies resolving the conflicts between a GitHub issue and its JIRA
+// issue when both changed since they were last synchronized, as configured
+// in `conflict-strategy`.
+const (
+	ConflictGitHubWins = "github-wins"
+	ConflictJIRAWins   = "jira-wins"
+	ConflictNewestWins = "newest-wins"
+	ConflictManual     = "manual"
+)
+
+// GetConflictStrategy returns the strategy resolving the conflicts between
+// a GitHub issue and its JIRA issue; GitHub wins unless another one is
+// configured.
+func (c Config) GetConflictStrategy() string {
+	if strategy := c.cmdConfig.GetString("conflict-strategy"); strategy != "" {
+		return strategy
+	}
+	return ConflictGitHubWins
+}
+
+// How the sub-issues of GitHub issues are reflected in JIRA, as configured
+// in `sub-issues`.
+const (
+	SubIssueEpics = "epic"
+	SubIssueLinks = "link"
+)
+
+// GetSubIssues returns how the sub-issues of GitHub issues are reflected in
+// JIRA: by putting their JIRA issues in the epic of (on JIRA Cloud, under)
+// the JIRA issue of their parent, or by linking them to it, or an empty
+// string if they aren't.
+func (c Config) GetSubIssues() string {
+	return c.cmdConfig.GetString("sub-issues")
+}
+
+// SetsEpics returns whether the epic of the synced issues is set, from
+// their milestone or from their parent issue.
+func (c Config) SetsEpics() bool {
+	return c.UseMilestoneEpics() || c.GetSubIssues() == SubIssueEpics
+}
+
+// SyncsWatchers returns whether the JIRA users which the participants of
+// the GitHub issues are mapped to are added to the watchers of the synced
+// issues.
+func (c Config) SyncsWatchers() bool {
+	return c.cmdConfig.GetBool("sync-watchers")
+}
+
+// UseTaskSubtasks returns whether the top-level tasks of the task lists of
+// GitHub issues are synced as JIRA sub-tasks of the synced issues.
+func (c Config) UseTaskSubtasks() bool {
+	return c.cmdConfig.GetBool("task-subtasks")
+}
+
+// UseNativeStatus returns whether the state of GitHub issues is kept in sync
+// with the JIRA status of the synced issues only, rather than being written
+// to the "GitHub Status" custom field, which isn't required then.
+func (c Config) UseNativeStatus() bool {
+	return c.cmdConfig.GetBool("native-status")
+}
+
+// UseEmojis returns whether the GitHub emoji shortcodes of the translated
+// bodies, such as :rocket:, are replaced by their Unicode emoji.
+func (c Config) UseEmojis() bool {
+	return c.cmdConfig.GetBool("emoji")
+}
+
+// RehostImages returns whether the images which issue bodies embed from
+// GitHub are attached to the synced JIRA issues, and referenced as
+// attachments, so restricted JIRA instances can show them.
+func (c Config) RehostImages() bool {
+	return c.cmdConfig.GetBool("rehost-images")
+}
+
+// UseRemoteLinks returns whether the synced JIRA issues get a remote link to
+// their GitHub issue, which shows its state.
+func (c Config) UseRemoteLinks() bool {
+	return c.cmdConfig.GetBool("remote-links")
+}
+
+// UseReferenceKeys returns whether the references to synced GitHub issues,
+// such as #123, are replaced by the keys of their JIRA issues in the JIRA
+// descriptions and comments.
+func (c Config) UseReferenceKeys() bool {
+	return c.cmdConfig.GetBool("reference-keys")
+}
+
+// UseReferenceLinks returns whether the synced JIRA issues are linked to
+// the JIRA issues of the synced GitHub issues they reference.
+func (c Config) UseReferenceLinks() bool {
+	return c.cmdConfig.GetBool("reference-links")
+}
+
+// UseNativeLabels returns whether the labels of GitHub issues are mirrored
+// into the labels of the synced JIRA issues.
+func (c Config) UseNativeLabels() bool {
+	return c.cmdConfig.GetBool("native-labels")
+}
+
+// GetLabelPrefix returns the prefix of the JIRA labels mirroring GitHub
+// labels, which tells them apart from the other labels of JIRA issues.
+func (c Config) GetLabelPrefix() string {
+	return c.cmdConfig.GetString("label-prefix")
+}
+
+// GetTransition returns the JIRA transition or status configured for
+// a GitHub issue state, and whether one is configured.
+func (c Config) GetTransition(state string) (string, bool) {
+	t, ok := c.transitions[state]
+	return t, ok
+}
+
+// resolutionsKey is the configuration option mapping the reasons GitHub
+// issues are closed for to the resolutions of their JIRA issues.
+const resolutionsKey = "resolutions"
+
+// UsesResolutions returns whether the resolution of the JIRA issues is set
+// from the reason their GitHub issue was closed for.
+func (c Config) UsesResolutions() bool {
+	return len(c.cmdConfig.GetStringMap(resolutionsKey)) > 0
+}
+
+// GetResolution returns the JIRA resolution configured in `resolutions`
+// for the reason a GitHub issue was closed for, such as completed or
+// not_planned, and whether one is configured.
+func (c Config) GetResolution(reason string) (string, bool) {
+	for r, resolution := range c.cmdConfig.GetStringMapString(resolutionsKey) {
+		if strings.EqualFold(r, reason) && resolution != "" {
+			return resolution, true
+		}
+	}
+	return "", false
+}
+
 // GetFieldID returns the customfield ID of a JIRA custom field.
 func (c Config) GetFieldID(key fieldKey) string {
-	switch key {
-	case GitHubID:
-		return c.fieldIDs.githubID
-	case GitHubNumber:
-		return c.fieldIDs.githubNumber
-	case GitHubLabels:
-		return c.fieldIDs.githubLabels
-	case GitHubReporter:
-		return c.fieldIDs.githubReporter
-	case GitHubStatus:
-		return c.fieldIDs.githubStatus
-	case LastISUpdate:
-		return c.fieldIDs.lastUpdate
-	default:
-		return ""
-	}
+	return c.fieldIDs.get(key)
 }
 
 // GetFieldKey returns customfield_XXXXX, where XXXXX is the custom field ID (see GetFieldID).
@@ -224,6 +854,39 @@ func (c Config) GetProjectKey(repo string) string {
 	return c.projects[repo].Key
 }
 
+// InfersProjectKeys returns whether the JIRA project key of the projects
+// which don't have one is inferred, from a mapping service or repo topics.
+func (c Config) InfersProjectKeys() bool {
+	return c.cmdConfig.GetString("project-mapping-url") != "" || c.UsesProjectKeyTopics()
+}
+
+// UsesProjectKeyTopics returns whether the JIRA project key of a project
+// which doesn't have one may be inferred from a `jira-KEY` repo topic.
+func (c Config) UsesProjectKeyTopics() bool {
+	return c.cmdConfig.GetBool("project-key-topics")
+}
+
+// GetUnmappedRepos returns the GitHub repos of the configured projects which
+// have no JIRA project key, and for which none was inferred yet.
+func (c Config) GetUnmappedRepos() []string {
+	var projects []Project
+	c.cmdConfig.UnmarshalKey("projects", &projects)
+
+	var repos []string
+	for _, project := range projects {
+		if _, ok := c.inferredKeys[project.Repo]; project.Key == "" && !ok {
+			repos = append(repos, project.Repo)
+		}
+	}
+	return repos
+}
+
+// SetProjectKey sets the JIRA project key inferred for a GitHub repo whose
+// project has none. It must be called before LoadJIRAConfig.
+func (c Config) SetProjectKey(repo, key string) {
+	c.inferredKeys[repo] = key
+}
+
 // GetRepoList returns the list of GitHub repo names provided.
 func (c Config) GetRepoList() []string {
 	keys := make([]string, len(c.projects))
@@ -251,42 +914,221 @@ func (c Config) SetJIRAToken(token *oauth1.Token) {
 	c.cmdConfig.Set("jira-secret", token.TokenSecret)
 }
 
+// UsesGitHubApp returns whether issue-sync authenticates to GitHub as an
+// installation of a GitHub App, rather than with the `github-token`.
+func (c Config) UsesGitHubApp() bool {
+	return c.GetGitHubAppID() != 0
+}
+
+// GetGitHubAppID returns the ID of the GitHub App issue-sync authenticates as.
+func (c Config) GetGitHubAppID() int {
+	return c.cmdConfig.GetInt("github-app-id")
+}
+
+// GetGitHubAppInstallationID returns the ID of the installation of the
+// GitHub App whose tokens issue-sync uses.
+func (c Config) GetGitHubAppInstallationID() int {
+	return c.cmdConfig.GetInt("github-app-installation-id")
+}
+
+// appTokens holds the token source of a GitHub App installation once it's
+// created.
+type appTokens struct {
+	mu     sync.Mutex
+	source oauth2.TokenSource
+}
+
+// GitHubAppTokenSource returns the token source of the GitHub App
+// installation, which is created by newSource the first time, and then
+// shared by every GitHub client of this configuration so installation tokens
+// are only requested when the previous one is about to expire.
+func (c Config) GitHubAppTokenSource(newSource func() (oauth2.TokenSource, error)) (oauth2.TokenSource, error) {
+	if c.appTokens == nil {
+		return newSource()
+	}
+
+	c.appTokens.mu.Lock()
+	defer c.appTokens.mu.Unlock()
+
+	if c.appTokens.source == nil {
+		source, err := newSource()
+		if err != nil {
+			return nil, err
+		}
+		c.appTokens.source = source
+	}
+	return c.appTokens.source, nil
+}
+
+// SetGitHubToken sets the GitHub access token in the Viper configuration,
+// ensuring that it is saved for future runs.
+func (c Config) SetGitHubToken(token string) {
+	c.cmdConfig.Set("github-token", token)
+}
+
 // configFile is a serializable representation of the current Viper configuration.
 type configFile struct {
-	LogLevel    string        `json:"log-level" mapstructure:"log-level"`
-	GithubToken string        `json:"github-token" mapstructure:"github-token"`
-	JIRAUser    string        `json:"jira-user" mapstructure:"jira-user"`
-	JIRAToken   string        `json:"jira-token" mapstructure:"jira-token"`
-	JIRASecret  string        `json:"jira-secret" mapstructure:"jira-secret"`
-	JIRAKey     string        `json:"jira-private-key-path" mapstructure:"jira-private-key-path"`
-	JIRACKey    string        `json:"jira-consumer-key" mapstructure:"jira-consumer-key"`
-	RepoName    string        `json:"repo-name" mapstructure:"repo-name"`
-	JIRAURI     string        `json:"jira-uri" mapstructure:"jira-uri"`
-	JIRAProject string        `json:"jira-project" mapstructure:"jira-project"`
-	Projects    []Project 	  `json:"projects" mapstructure:"projects"`
-	Since       string        `json:"since" mapstructure:"since"`
-	Timeout     time.Duration `json:"timeout" mapstructure:"timeout"`
-}
-
-// SaveConfig updates the `since` parameter to now, then saves the configuration file.
+	LogLevel    string            `json:"log-level" mapstructure:"log-level"`
+	LogFormat   string            `json:"log-format,omitempty" mapstructure:"log-format"`
+	GithubToken string            `json:"github-token" mapstructure:"github-token"`
+	JIRAUser    string            `json:"jira-user" mapstructure:"jira-user"`
+	JIRAPass    string            `json:"jira-pass,omitempty" mapstructure:"jira-pass"`
+	JIRAPAT     string            `json:"jira-pat,omitempty" mapstructure:"jira-pat"`
+	JIRAToken   string            `json:"jira-token" mapstructure:"jira-token"`
+	JIRASecret  string            `json:"jira-secret" mapstructure:"jira-secret"`
+	JIRAKey     string            `json:"jira-private-key-path" mapstructure:"jira-private-key-path"`
+	JIRACKey    string            `json:"jira-consumer-key" mapstructure:"jira-consumer-key"`
+	RepoName    string            `json:"repo-name" mapstructure:"repo-name"`
+	JIRAURI     string            `json:"jira-uri" mapstructure:"jira-uri"`
+	JIRAProject string            `json:"jira-project" mapstructure:"jira-project"`
+	Projects    []Project         `json:"projects" mapstructure:"projects"`
+	Transitions map[string]string `json:"transitions,omitempty" mapstructure:"transitions"`
+	Fields      map[string]string `json:"custom-fields,omitempty" mapstructure:"custom-fields"`
+	UserMap     map[string]string `json:"user-map,omitempty" mapstructure:"user-map"`
+	Milestones  bool              `json:"milestone-versions,omitempty" mapstructure:"milestone-versions"`
+	Epics       bool              `json:"milestone-epics,omitempty" mapstructure:"milestone-epics"`
+	Subtasks    bool              `json:"task-subtasks,omitempty" mapstructure:"task-subtasks"`
+	SubIssues   string            `json:"sub-issues,omitempty" mapstructure:"sub-issues"`
+	Estimates   bool              `json:"estimate-labels,omitempty" mapstructure:"estimate-labels"`
+	EstPattern  string            `json:"estimate-pattern,omitempty" mapstructure:"estimate-pattern"`
+	EstUnit     string            `json:"estimate-unit,omitempty" mapstructure:"estimate-unit"`
+	Reactions   bool              `json:"sync-reactions,omitempty" mapstructure:"sync-reactions"`
+	Watchers    bool              `json:"sync-watchers,omitempty" mapstructure:"sync-watchers"`
+	AssignMode  string            `json:"assignee-mode,omitempty" mapstructure:"assignee-mode"`
+	Orphans     string            `json:"orphaned-issues,omitempty" mapstructure:"orphaned-issues"`
+	OrphanCheck time.Duration     `json:"orphan-check-interval,omitempty" mapstructure:"orphan-check-interval"`
+	Pinned      string            `json:"pinned-issues,omitempty" mapstructure:"pinned-issues"`
+	PinnedLabel string            `json:"pinned-label,omitempty" mapstructure:"pinned-label"`
+	Conflicts   string            `json:"conflict-strategy,omitempty" mapstructure:"conflict-strategy"`
+	RefKeys     bool              `json:"reference-keys,omitempty" mapstructure:"reference-keys"`
+	RefLinks    bool              `json:"reference-links,omitempty" mapstructure:"reference-links"`
+	Native      bool              `json:"native-status,omitempty" mapstructure:"native-status"`
+	Labels      bool              `json:"native-labels,omitempty" mapstructure:"native-labels"`
+	LabelPrefix string            `json:"label-prefix,omitempty" mapstructure:"label-prefix"`
+	Fallback    bool              `json:"translation-fallback,omitempty" mapstructure:"translation-fallback"`
+	Emoji       *bool             `json:"emoji,omitempty" mapstructure:"emoji"`
+	Rehost      bool              `json:"rehost-images,omitempty" mapstructure:"rehost-images"`
+	RemoteLinks bool              `json:"remote-links,omitempty" mapstructure:"remote-links"`
+	Comments    string            `json:"comment-template,omitempty" mapstructure:"comment-template"`
+	Oversized   string            `json:"oversized-text,omitempty" mapstructure:"oversized-text"`
+	Summary     string            `json:"summary-template,omitempty" mapstructure:"summary-template"`
+	Description string            `json:"description-template,omitempty" mapstructure:"description-template"`
+	Since       string            `json:"since" mapstructure:"since"`
+	Timeout     time.Duration     `json:"timeout" mapstructure:"timeout"`
+	Period      time.Duration     `json:"period,omitempty" mapstructure:"period"`
+	MaxAttempts int               `json:"retry-max-attempts,omitempty" mapstructure:"retry-max-attempts"`
+	Concurrency int               `json:"concurrency,omitempty" mapstructure:"concurrency"`
+	CommentJobs int               `json:"comment-concurrency,omitempty" mapstructure:"comment-concurrency"`
+	GHPageSize  int               `json:"github-page-size,omitempty" mapstructure:"github-page-size"`
+	GraphQL     bool              `json:"github-graphql,omitempty" mapstructure:"github-graphql"`
+	MetadataTTL time.Duration     `json:"jira-metadata-ttl,omitempty" mapstructure:"jira-metadata-ttl"`
+	PageSize    int               `json:"jira-page-size,omitempty" mapstructure:"jira-page-size"`
+	StateFilter string            `json:"state-filter,omitempty" mapstructure:"state-filter"`
+	Include     []string          `json:"include-labels,omitempty" mapstructure:"include-labels"`
+	Exclude     []string          `json:"exclude-labels,omitempty" mapstructure:"exclude-labels"`
+	InMilestone []string          `json:"milestones,omitempty" mapstructure:"milestones"`
+	Authors     []string          `json:"authors,omitempty" mapstructure:"authors"`
+	IgnoredBots []string          `json:"ignore-comment-authors,omitempty" mapstructure:"ignore-comment-authors"`
+	Assignees   []string          `json:"assignees,omitempty" mapstructure:"assignees"`
+	JQLFilter   string            `json:"jql-filter,omitempty" mapstructure:"jql-filter"`
+	FormFields  []FormSection     `json:"issue-form-fields,omitempty" mapstructure:"issue-form-fields"`
+	Components  []ComponentRule   `json:"components,omitempty" mapstructure:"components"`
+	Board       *ProjectBoard     `json:"project-board,omitempty" mapstructure:"project-board"`
+	EmailTo     []string          `json:"email-to,omitempty" mapstructure:"email-to"`
+
+	GitHubClientID string `json:"github-client-id,omitempty" mapstructure:"github-client-id"`
+	CompHeading    string `json:"component-heading,omitempty" mapstructure:"component-heading"`
+	GitHubURI      string `json:"github-uri,omitempty" mapstructure:"github-uri"`
+	GitHubUpload   string `json:"github-upload-uri,omitempty" mapstructure:"github-upload-uri"`
+	ETagCache      bool   `json:"github-etag-cache,omitempty" mapstructure:"github-etag-cache"`
+	CreateFields   bool   `json:"create-fields,omitempty" mapstructure:"create-fields"`
+	PointsField    string `json:"story-points-field,omitempty" mapstructure:"story-points-field"`
+	AssigneesField string `json:"assignees-field,omitempty" mapstructure:"assignees-field"`
+	AppID          int    `json:"github-app-id,omitempty" mapstructure:"github-app-id"`
+	AppInstallID   int    `json:"github-app-installation-id,omitempty" mapstructure:"github-app-installation-id"`
+	AppKey         string `json:"github-app-private-key-path,omitempty" mapstructure:"github-app-private-key-path"`
+	StateFile      string `json:"state-file,omitempty" mapstructure:"state-file"`
+	LockFile       string `json:"lock-file,omitempty" mapstructure:"lock-file"`
+	ReportFile     string `json:"report-file,omitempty" mapstructure:"report-file"`
+	AuditLog       string `json:"audit-log,omitempty" mapstructure:"audit-log"`
+	VaultAddress   string `json:"vault-address,omitempty" mapstructure:"vault-address"`
+	Keychain       bool   `json:"keychain,omitempty" mapstructure:"keychain"`
+	MappingURL     string `json:"project-mapping-url,omitempty" mapstructure:"project-mapping-url"`
+	KeyTopics      bool   `json:"project-key-topics,omitempty" mapstructure:"project-key-topics"`
+	HealthAddress  string `json:"health-address,omitempty" mapstructure:"health-address"`
+	ListenAddress  string `json:"listen-address,omitempty" mapstructure:"listen-address"`
+	WebhookSecret  string `json:"github-webhook-secret,omitempty" mapstructure:"github-webhook-secret"`
+	JIRAHookSecret string `json:"jira-webhook-secret,omitempty" mapstructure:"jira-webhook-secret"`
+	SlackURL       string `json:"slack-webhook-url,omitempty" mapstructure:"slack-webhook-url"`
+	SlackNotify    string `json:"slack-notify,omitempty" mapstructure:"slack-notify"`
+	SMTPAddress    string `json:"smtp-address,omitempty" mapstructure:"smtp-address"`
+	SMTPUser       string `json:"smtp-user,omitempty" mapstructure:"smtp-user"`
+	SMTPPass       string `json:"smtp-pass,omitempty" mapstructure:"smtp-pass"`
+	EmailFrom      string `json:"email-from,omitempty" mapstructure:"email-from"`
+	EmailNotify    string `json:"email-notify,omitempty" mapstructure:"email-notify"`
+	EventURL       string `json:"event-webhook-url,omitempty" mapstructure:"event-webhook-url"`
+	EventSecret    string `json:"event-webhook-secret,omitempty" mapstructure:"event-webhook-secret"`
+
+	StoryPoints   map[string]float64       `json:"story-points,omitempty" mapstructure:"story-points"`
+	IssueTypes    map[string]string        `json:"issue-types,omitempty" mapstructure:"issue-types"`
+	Resolutions   map[string]string        `json:"resolutions,omitempty" mapstructure:"resolutions"`
+	JIRAInstances map[string]*JIRAInstance `json:"jira-instances,omitempty" mapstructure:"jira-instances"`
+}
+
+// stringFields maps the keys of the string options of the configuration
+// file to its fields, so they can be rewritten before it's saved. The
+// options of the JIRA instances are keyed by their full path, such as
+// `jira-instances.NAME.jira-pass`.
+func (cf *configFile) stringFields() map[string]*string {
+	fields := make(map[string]*string)
+	addStringFields(fields, "", reflect.ValueOf(cf).Elem())
+	for name, instance := range cf.JIRAInstances {
+		if instance != nil {
+			addStringFields(fields, instancesKey+"."+name+".", reflect.ValueOf(instance).Elem())
+		}
+	}
+	return fields
+}
+
+// addStringFields adds the string fields of a struct to the map, keyed by
+// the prefix and their JSON name.
+func addStringFields(fields map[string]*string, prefix string, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if v.Field(i).Kind() == reflect.String {
+			fields[prefix+name] = v.Field(i).Addr().Interface().(*string)
+		}
+	}
+}
+
+// SaveConfig saves the configuration file. It's only called by the commands
+// which set up credentials; the runtime state, such as the `since` time of
+// each project, is kept in the state file instead.
 func (c *Config) SaveConfig() error {
-	c.cmdConfig.Set("since", time.Now().Format(dateFormat))
+	path := c.cmdConfig.ConfigFileUsed()
+	if path == "" {
+		c.log.Debug("No configuration file loaded so do not save settings")
+		return nil
+	}
 
 	var cf configFile
 	c.cmdConfig.Unmarshal(&cf)
+	cf.Projects = c.getConfiguredProjects()
 
-	b, err := json.MarshalIndent(cf, "", "  ")
-	if err != nil {
+	if c.UsesKeychain() {
+		c.storeKeychainSecrets(&cf)
+	}
+	c.restoreVaultRefs(&cf)
+	if err := c.encryptSecretFields(&cf); err != nil {
 		return err
 	}
 
-	configFile := c.cmdConfig.ConfigFileUsed()
-	if configFile == "" {
-		c.log.Debug("No configuration file loaded so do not save settings")
-		return nil
+	b, err := marshalConfigFile(cf, path)
+	if err != nil {
+		return err
 	}
 
-	f, err := os.OpenFile(configFile, os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0644)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
@@ -297,6 +1139,19 @@ func (c *Config) SaveConfig() error {
 	return nil
 }
 
+// ParseDate parses a date given on the command line, either in the ISO-8601
+// format used by the `since` parameter, or as a plain YYYY-MM-DD date.
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(dateFormat, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q must be in ISO-8601 or YYYY-MM-DD format", s)
+	}
+	return t, nil
+}
+
 // newViper generates a viper configuration object which
 // merges (in order from highest to lowest priority) the
 // command line options, configuration file options, and
@@ -316,15 +1171,10 @@ func newViper(appName, cfgFile string) *viper.Viper {
 		v.AddConfigPath("$HOME/")
 		v.AddConfigPath(".")
 		v.SetConfigName(".issue-sync")
-		v.SetConfigType("json")
 	}
 
 	if err := v.ReadInConfig(); err == nil {
 		log.WithField("file", v.ConfigFileUsed()).Infof("config file loaded")
-		v.WatchConfig()
-		v.OnConfigChange(func(e fsnotify.Event) {
-			log.WithField("file", e.Name).Info("config file changed")
-		})
 	} else {
 		if cfgFile != "" {
 			log.WithError(err).Warningf("Error reading config file: %v", cfgFile)
@@ -354,12 +1204,28 @@ func parseLogLevel(level string) logrus.Level {
 	return ll
 }
 
-// newLogger uses the log level provided in the configuration
-// to create a new logrus logger and set fields on it to make
-// it easy to use.
-func newLogger(app, level string) *logrus.Entry {
+// parseLogFormat is a helper function to parse the log format passed in the
+// configuration into a logrus Formatter, or to use the default text format
+// if the log format can't be parsed.
+func parseLogFormat(format string) logrus.Formatter {
+	switch format {
+	case "", "text":
+		return &logrus.TextFormatter{}
+	case "json":
+		return &logrus.JSONFormatter{}
+	default:
+		fmt.Printf("Failed to parse log format %q, using text.\n", format)
+		return &logrus.TextFormatter{}
+	}
+}
+
+// newLogger uses the log level and format provided in the
+// configuration to create a new logrus logger and set fields
+// on it to make it easy to use.
+func newLogger(app, level, format string) *logrus.Entry {
 	logger := logrus.New()
 	logger.Level = parseLogLevel(level)
+	logger.Formatter = parseLogFormat(format)
 	logEntry := logrus.NewEntry(logger).WithFields(logrus.Fields{
 		"app": app,
 	})
@@ -367,6 +1233,59 @@ func newLogger(app, level string) *logrus.Entry {
 	return logEntry
 }
 
+// loadProjects validates the options of each project, and records them by
+// repo.
+func (c *Config) loadProjects(projects []Project) error {
+	for i, project := range projects {
+		if filter := project.StateFilter; filter != "" {
+			if !isStateFilter(filter) {
+				return fmt.Errorf("project number %d has bad state filter; must be open, closed, or all", i)
+			}
+			c.stateFilters[project.Repo] = filter
+		}
+		if len(project.IncludeLabels) > 0 || len(project.ExcludeLabels) > 0 {
+			c.labelFilters[project.Repo] = labelFilter{
+				include: project.IncludeLabels,
+				exclude: project.ExcludeLabels,
+			}
+		}
+		if len(project.Milestones) > 0 {
+			if err := validateMilestonePatterns(project.Milestones); err != nil {
+				return fmt.Errorf("project number %d has a %v", i, err)
+			}
+			c.milestoneFilters[project.Repo] = project.Milestones
+		}
+		if len(project.Authors) > 0 || len(project.Assignees) > 0 {
+			c.userFilters[project.Repo] = userFilter{
+				authors:   project.Authors,
+				assignees: project.Assignees,
+			}
+		}
+		if project.JQLFilter != "" && project.Key != "" {
+			c.jqlFilters[project.Key] = project.JQLFilter
+		}
+		if project.Board != nil {
+			if err := project.Board.validate(); err != nil {
+				return fmt.Errorf("project number %d: %v", i, err)
+			}
+			c.boards[project.Repo] = *project.Board
+		}
+		if len(project.EmailTo) > 0 {
+			c.emailRecipients[project.Repo] = project.EmailTo
+		}
+		if project.Since == "" {
+			continue
+		}
+		since, err := time.Parse(dateFormat, project.Since)
+		if err != nil {
+			return fmt.Errorf("project number %d has a since date which isn't in ISO-8601 format", i)
+		}
+		c.projectSince[project.Repo] = since
+	}
+
+	return nil
+}
+
 // validateConfig checks the values provided to all of the configuration
 // options, ensuring that e.g. `since` is a valid date, `jira-uri` is a
 // real URI, etc. This is the first level of checking. It does not confirm
@@ -376,14 +1295,46 @@ func (c *Config) validateConfig() error {
 	// Log level and config file location are validated already
 
 	c.log.Debug("Checking config variables...")
-	token := c.cmdConfig.GetString("github-token")
-	if token == "" {
+	if err := c.decryptSecrets(); err != nil {
+		return err
+	}
+	if err := c.resolveVaultSecrets(); err != nil {
+		return err
+	}
+
+	if c.UsesGitHubApp() {
+		c.log.Debug("Authenticating to GitHub as a GitHub App installation")
+
+		if c.GetGitHubAppInstallationID() == 0 {
+			return errors.New("GitHub App installation ID required")
+		}
+		keyPath := c.cmdConfig.GetString("github-app-private-key-path")
+		if keyPath == "" {
+			return errors.New("GitHub App private key required")
+		}
+		if _, err := os.Stat(keyPath); err != nil {
+			return errors.New("GitHub App private key must point to existing PEM file")
+		}
+	} else if c.cmdConfig.GetString("github-token") == "" {
 		return errors.New("GitHub token required")
 	}
 
-	c.basicAuth = (c.cmdConfig.GetString("jira-user") != "") && (c.cmdConfig.GetString("jira-pass") != "")
+	for _, key := range []string{"github-uri", "github-upload-uri"} {
+		if uri := c.cmdConfig.GetString(key); uri != "" {
+			if _, err := url.ParseRequestURI(uri); err != nil {
+				return fmt.Errorf("%s must be valid URI", key)
+			}
+		}
+	}
+	if c.cmdConfig.GetString("github-upload-uri") != "" && c.cmdConfig.GetString("github-uri") == "" {
+		return errors.New("GitHub upload URI requires a GitHub URI")
+	}
+
+	c.basicAuth = !c.IsPATAuth() && (c.cmdConfig.GetString("jira-user") != "") && (c.cmdConfig.GetString("jira-pass") != "")
 
-	if c.basicAuth {
+	if c.IsPATAuth() {
+		c.log.Debug("Using a JIRA personal access token")
+	} else if c.basicAuth {
 		c.log.Debug("Using HTTP Basic Authentication")
 
 		jUser := c.cmdConfig.GetString("jira-user")
@@ -457,7 +1408,26 @@ func (c *Config) validateConfig() error {
 		projects := make([]Project, 1)
 		projects[0] = Project{
 			Repo: repo,
-			Key: project,
+			Key:  project,
+		}
+
+		// Keep the time of the last sync and the options of the project if
+		// it's already in the file.
+		var existing []Project
+		c.cmdConfig.UnmarshalKey("projects", &existing)
+		for _, p := range existing {
+			if p.Repo == repo && p.Key == project {
+				projects[0].Since = p.Since
+				projects[0].StateFilter = p.StateFilter
+				projects[0].Instance = p.Instance
+				projects[0].IncludeLabels = p.IncludeLabels
+				projects[0].ExcludeLabels = p.ExcludeLabels
+				projects[0].Milestones = p.Milestones
+				projects[0].Authors = p.Authors
+				projects[0].Assignees = p.Assignees
+				projects[0].JQLFilter = p.JQLFilter
+				projects[0].Board = p.Board
+			}
 		}
 
 		c.cmdConfig.Set("projects", projects)
@@ -477,15 +1447,111 @@ func (c *Config) validateConfig() error {
 			if !strings.Contains(project.Repo, "/") || len(strings.Split(project.Repo, "/")) != 2 {
 				return fmt.Errorf("project number %d has bad repo; must be user/repo or org/repo", i)
 			}
-			if project.Key == "" {
+			if err := validateRepoPattern(project); err != nil {
+				return fmt.Errorf("project number %d %v", i, err)
+			}
+			if project.Key == "" && !c.InfersProjectKeys() {
 				return fmt.Errorf("project number %d is missing JIRA project key", i)
 			}
 		}
 	}
 
+	var projects []Project
+	c.cmdConfig.UnmarshalKey("projects", &projects)
+	if err := c.loadProjects(projects); err != nil {
+		return err
+	}
+
+	if err := c.validateInstances(projects); err != nil {
+		return err
+	}
+
+	if err := c.validateCustomFields(); err != nil {
+		return err
+	}
+
+	if err := c.validateStoryPoints(); err != nil {
+		return err
+	}
+
+	if err := c.validateFormSections(); err != nil {
+		return err
+	}
+	if err := c.validateComponents(); err != nil {
+		return err
+	}
+	if err := c.validateEstimates(); err != nil {
+		return err
+	}
+
+	if err := c.validateTemplates(); err != nil {
+		return err
+	}
+
+	if filter := c.cmdConfig.GetString("state-filter"); filter != "" && !isStateFilter(filter) {
+		return errors.New("state filter must be open, closed, or all")
+	}
+	switch c.GetConflictStrategy() {
+	case ConflictGitHubWins, ConflictJIRAWins, ConflictNewestWins, ConflictManual:
+	default:
+		return errors.New("conflict strategy must be github-wins, jira-wins, newest-wins, or manual")
+	}
+	switch c.GetSubIssues() {
+	case "", SubIssueEpics, SubIssueLinks:
+	default:
+		return errors.New("sub issues must be epic or link")
+	}
+	if c.GetPlanFile() != "" && c.GetApplyFile() != "" {
+		return errors.New("plan-out and apply can't be used together")
+	}
+	if (c.GetPlanFile() != "" || c.GetApplyFile() != "") && c.IsDaemon() {
+		return errors.New("plan-out and apply can't be used in daemon mode")
+	}
+	if !isNotifyMode(c.GetSlackNotify()) {
+		return errors.New("slack notify must be all or errors")
+	}
+	if err := c.validateEmail(); err != nil {
+		return err
+	}
+	switch c.GetOrphanAction() {
+	case "", OrphanClose, OrphanFlag, OrphanRelink:
+	default:
+		return errors.New("orphaned issues must be close, flag, or relink")
+	}
+	if interval := c.GetOrphanCheckInterval(); interval < 0 {
+		return errors.New("orphan-check-interval can't be negative")
+	} else if interval > 0 && c.cmdConfig.GetString("state-file") == "" {
+		return errors.New("orphan-check-interval requires a state-file")
+	}
+	if err := c.validatePinned(); err != nil {
+		return err
+	}
+	if err := c.validateAssignees(); err != nil {
+		return err
+	}
+	if err := c.validateOversized(); err != nil {
+		return err
+	}
+	if err := validateMilestonePatterns(c.cmdConfig.GetStringSlice("milestones")); err != nil {
+		return err
+	}
+	if board, ok := c.GetProjectBoard(""); ok {
+		if err := board.validate(); err != nil {
+			return err
+		}
+	}
+
+	c.transitions = c.cmdConfig.GetStringMapString("transitions")
+	for state := range c.transitions {
+		if state != "open" && state != "closed" {
+			return fmt.Errorf("transitions can only be configured for the open and closed states; got %q", state)
+		}
+	}
+
 	sinceStr := c.cmdConfig.GetString("since")
 	if sinceStr == "" {
-		c.cmdConfig.Set("since", "1970-01-01T00:00:00+0000")
+		sinceStr = "1970-01-01T00:00:00+0000"
+		c.cmdConfig.Set("since", sinceStr)
 	}
 
 	since, err := time.Parse(dateFormat, sinceStr)
@@ -494,6 +1560,14 @@ func (c *Config) validateConfig() error {
 	}
 	c.since = since
 
+	if path := c.cmdConfig.GetString("state-file"); path != "" {
+		state, err := OpenState(os.ExpandEnv(path))
+		if err != nil {
+			return err
+		}
+		c.state = state
+	}
+
 	c.log.Debug("All config variables are valid!")
 
 	return nil
@@ -519,55 +1593,219 @@ type jiraField struct {
 	} `json:"schema,omitempty"`
 }
 
-// getFieldIDs requests the metadata of every issue field in the JIRA
-// project, and saves the IDs of the custom fields used by issue-sync.
-func (c Config) getFieldIDs(client jira.Client) (fields, error) {
-	c.log.Debug("Collecting field IDs.")
-	req, err := client.NewRequest("GET", "/rest/api/2/field", nil)
-	if err != nil {
-		return fields{}, err
+// isRequiredField returns whether a custom field is required. The "GitHub
+// Status" field isn't with native status, nor is the "GitHub Labels" field
+// with native labels; they're still written if they exist. The "GitHub
+// Votes" field is only required, and written, if reactions are synced.
+func (c Config) isRequiredField(key fieldKey) bool {
+	switch key {
+	case GitHubStatus:
+		return !c.UseNativeStatus()
+	case GitHubLabels:
+		return !c.UseNativeLabels()
+	case GitHubVotes:
+		return c.SyncsReactions()
+	}
+	return true
+}
+
+// missingFields returns the names or IDs, as configured, of the required
+// custom fields whose IDs weren't found.
+func (c Config) missingFields(f fields) []string {
+	var refs []string
+	for _, field := range customFields {
+		if f.get(field.key) == "" && c.isRequiredField(field.key) {
+			refs = append(refs, c.customFieldRef(field))
+		}
 	}
+	// JIRA Cloud adds issues to epics by their parent instead.
+	if c.SetsEpics() && !c.IsJIRACloud() && f.epicLink == "" {
+		refs = append(refs, "Epic Link")
+	}
+	if c.UsesStoryPoints() && f.storyPoints == "" {
+		refs = append(refs, c.getStoryPointsField())
+	}
+	if c.GetPinnedAction() == PinnedFlag && f.flagged == "" {
+		refs = append(refs, flaggedFieldName)
+	}
+	if c.GetAssigneeMode() == AssigneeField && f.assignees == "" {
+		refs = append(refs, c.getAssigneesField())
+	}
+	refs = append(refs, c.missingFormFields(f.forms)...)
+	return refs
+}
+
+// listFieldIDs requests the metadata of every issue field in the JIRA
+// project, and returns the IDs of the custom fields used by issue-sync
+// which were found, by the names or IDs configured in `custom-fields`.
+func (c Config) listFieldIDs(client jira.Client) (fields, error) {
 	jFields := new([]jiraField)
+	if _, err := c.getMetadata(client, "/rest/api/2/field", jFields); err != nil {
+		return fields{}, err
+	}
+
+	fieldIDs := fields{}
 
-	_, err = client.Do(req, jFields)
+	for _, custom := range customFields {
+		ref := c.customFieldRef(custom)
+		for _, field := range *jFields {
+			if field.Custom && field.matches(ref) {
+				fieldIDs.set(custom.key, fmt.Sprint(field.Schema.CustomID))
+				break
+			}
+		}
+	}
+
+	forms, err := c.listFormFields(*jFields)
 	if err != nil {
 		return fields{}, err
 	}
+	fieldIDs.forms = forms
+
+	if c.UsesStoryPoints() {
+		ref := c.getStoryPointsField()
+		for _, field := range *jFields {
+			if field.Custom && field.matches(ref) {
+				fieldIDs.set(StoryPoints, fmt.Sprint(field.Schema.CustomID))
+				break
+			}
+		}
+	}
 
-	fieldIDs := fields{}
+	if c.GetPinnedAction() == PinnedFlag {
+		for _, field := range *jFields {
+			if field.Custom && field.matches(flaggedFieldName) {
+				fieldIDs.set(Flagged, fmt.Sprint(field.Schema.CustomID))
+				break
+			}
+		}
+	}
+
+	if c.GetAssigneeMode() == AssigneeField {
+		ref := c.getAssigneesField()
+		for _, field := range *jFields {
+			if field.Custom && field.matches(ref) {
+				fieldIDs.set(AssigneesField, fmt.Sprint(field.Schema.CustomID))
+				break
+			}
+		}
+	}
 
 	for _, field := range *jFields {
-		switch field.Name {
-		case "GitHub ID":
-			fieldIDs.githubID = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Number":
-			fieldIDs.githubNumber = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Labels":
-			fieldIDs.githubLabels = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Status":
-			fieldIDs.githubStatus = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Reporter":
-			fieldIDs.githubReporter = fmt.Sprint(field.Schema.CustomID)
-		case "Last Issue-Sync Update":
-			fieldIDs.lastUpdate = fmt.Sprint(field.Schema.CustomID)
-		}
-	}
-
-	if fieldIDs.githubID == "" {
-		return fieldIDs, errors.New("could not find ID of 'GitHub ID' custom field; check that it is named correctly")
-	} else if fieldIDs.githubNumber == "" {
-		return fieldIDs, errors.New("could not find ID of 'GitHub Number' custom field; check that it is named correctly")
-	} else if fieldIDs.githubLabels == "" {
-		return fieldIDs, errors.New("could not find ID of 'Github Labels' custom field; check that it is named correctly")
-	} else if fieldIDs.githubStatus == "" {
-		return fieldIDs, errors.New("could not find ID of 'Github Status' custom field; check that it is named correctly")
-	} else if fieldIDs.githubReporter == "" {
-		return fieldIDs, errors.New("could not find ID of 'Github Reporter' custom field; check that it is named correctly")
-	} else if fieldIDs.lastUpdate == "" {
-		return fieldIDs, errors.New("could not find ID of 'Last Issue-Sync Update' custom field; check that it is named correctly")
+		switch field.Schema.Custom {
+		case epicLinkFieldType:
+			fieldIDs.set(EpicLink, fmt.Sprint(field.Schema.CustomID))
+		case epicNameFieldType:
+			fieldIDs.set(EpicName, fmt.Sprint(field.Schema.CustomID))
+		}
+	}
+
+	return fieldIDs, nil
+}
+
+// getMetadata decodes the response of a JIRA metadata request into the
+// result. It's read from the state if it was cached less than
+// `jira-metadata-ttl` ago, in which case there's no response; otherwise it's
+// requested, and cached. Responses are cached by JIRA server.
+func (c Config) getMetadata(client jira.Client, path string, result interface{}) (*jira.Response, error) {
+	ttl := c.GetJIRAMetadataTTL()
+	key := c.GetConfigString("jira-uri") + " " + strings.TrimPrefix(path, "/")
+	if ttl > 0 {
+		if data, ok := c.state.getMetadata(key, ttl); ok && json.Unmarshal(data, result) == nil {
+			c.log.Debugf("Using the cached JIRA metadata %s", path)
+			return nil, nil
+		}
+	}
+
+	req, err := client.NewRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data json.RawMessage
+	res, err := client.Do(req, &data)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return res, err
+	}
+	if ttl > 0 {
+		c.state.setMetadata(key, data)
+	}
+
+	return res, nil
+}
+
+// getFieldIDs requests the metadata of every issue field in the JIRA
+// project, and saves the IDs of the custom fields used by issue-sync. With
+// `create-fields`, the missing ones are created first, as by SetupFields,
+// unless this is a dry run.
+func (c Config) getFieldIDs(client jira.Client) (fields, error) {
+	c.log.Debug("Collecting field IDs.")
+
+	fieldIDs, err := c.listFieldIDs(client)
+	if err != nil {
+		return fields{}, err
+	}
+
+	if missing := c.missingFields(fieldIDs); len(missing) > 0 && c.CreatesMissingFields() && !c.IsDryRun() {
+		c.log.Infof("Creating the missing custom fields: %s", strings.Join(missing, ", "))
+		if err := c.SetupFields(client, nil); err != nil {
+			return fieldIDs, fmt.Errorf("could not create the missing custom fields: %v", err)
+		}
+		if fieldIDs, err = c.listFieldIDs(client); err != nil {
+			return fields{}, err
+		}
+	}
+	if missing := c.missingFields(fieldIDs); len(missing) > 0 {
+		return fieldIDs, fmt.Errorf("could not find ID of '%s' custom field; check that it is named correctly", missing[0])
 	}
 
 	c.log.Debug("All fields have been checked.")
 
 	return fieldIDs, nil
 }
+
+// jiraStatus represents a status of a JIRA workflow.
+type jiraStatus struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// validateTransitions checks the configured transitions against the statuses
+// used by the workflows of a JIRA project. Statuses are returned in the language
+// of the JIRA user, so localized names can be used. A value which matches no
+// status is assumed to be a transition ID or name; the JIRA API only lists the
+// transitions available from the current status of an issue, so those can't be
+// checked at startup, and are only warned about. When an issue is transitioned,
+// a value which matches none of its transitions is logged as a warning too (see
+// findTransition). An error is only returned if the statuses can't be retrieved.
+func (c Config) validateTransitions(client jira.Client, key string) error {
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/project/%s/statuses", key), nil)
+	if err != nil {
+		return err
+	}
+	issueTypes := new([]struct {
+		Name     string       `json:"name"`
+		Statuses []jiraStatus `json:"statuses"`
+	})
+	if _, err := client.Do(req, issueTypes); err != nil {
+		return fmt.Errorf("could not retrieve the workflow statuses of JIRA project %s: %v", key, err)
+	}
+
+	for state, target := range c.transitions {
+		found := false
+		for _, issueType := range *issueTypes {
+			for _, status := range issueType.Statuses {
+				if status.ID == target || strings.EqualFold(status.Name, target) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			c.log.Warnf("Transition %q for GitHub state %s matches no status of project %s; assuming it is a transition name or ID", target, state, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// cloudDeploymentType is the deployment type JIRA Cloud reports in its
+// server info; JIRA Server and Data Center report "Server".
+const cloudDeploymentType = "Cloud"
+
+// cloudTextLimit is the maximum number of characters of the text fields of
+// JIRA Cloud, such as descriptions and comment bodies.
+const cloudTextLimit = 32767
+
+// defaultTextLimit is the maximum number of characters of the text fields
+// of JIRA Server, unless its administrators changed it.
+const defaultTextLimit = 32767
+
+// textLimitProperty is the application property of JIRA Server holding the
+// maximum number of characters of text fields.
+const textLimitProperty = "jira.text.field.character.limit"
+
+// jiraDeployment is what issue-sync adapts to of the deployment of a JIRA
+// instance.
+type jiraDeployment struct {
+	cloud     bool
+	textLimit int
+}
+
+// detectDeployment finds out whether the JIRA instance of the configuration
+// is JIRA Cloud or JIRA Server (or Data Center) from its server info, along
+// with the limit of the length of its text fields.
+func (c Config) detectDeployment(client jira.Client) error {
+	req, err := client.NewRequest("GET", "rest/api/2/serverInfo", nil)
+	if err != nil {
+		return err
+	}
+	info := new(struct {
+		Version        string `json:"version"`
+		DeploymentType string `json:"deploymentType"`
+	})
+	if _, err := client.Do(req, info); err != nil {
+		return fmt.Errorf("could not retrieve the JIRA server info: %v", err)
+	}
+
+	d := jiraDeployment{
+		cloud:     info.DeploymentType == cloudDeploymentType,
+		textLimit: cloudTextLimit,
+	}
+	if d.cloud {
+		c.log.Debugf("JIRA instance is JIRA Cloud")
+		if c.IsPATAuth() {
+			c.log.Warning("JIRA Cloud doesn't accept personal access tokens; use an API token as jira-pass, with the email of the user as jira-user")
+		}
+	} else {
+		c.log.Debugf("JIRA instance is JIRA Server %s", info.Version)
+		d.textLimit = c.serverTextLimit(client)
+	}
+
+	c.deployments[c.instance] = d
+	return nil
+}
+
+// serverTextLimit returns the limit of the length of the text fields of a
+// JIRA Server instance. Only administrators can read it, so the default is
+// assumed if it can't be.
+func (c Config) serverTextLimit(client jira.Client) int {
+	req, err := client.NewRequest("GET", "rest/api/2/application-properties?key="+textLimitProperty, nil)
+	if err != nil {
+		return defaultTextLimit
+	}
+	property := new(struct {
+		Value string `json:"value"`
+	})
+	if _, err := client.Do(req, property); err != nil {
+		c.log.Debugf("Could not read %s; assuming %d characters: %v", textLimitProperty, defaultTextLimit, err)
+		return defaultTextLimit
+	}
+	limit, err := strconv.Atoi(property.Value)
+	if err != nil {
+		return defaultTextLimit
+	}
+	return limit
+}
+
+// IsJIRACloud returns whether the JIRA instance of the configuration is JIRA
+// Cloud. It's only known once the JIRA configuration has been loaded.
+func (c Config) IsJIRACloud() bool {
+	return c.deployments[c.instance].cloud
+}
+
+// GetJIRATextLimit returns the maximum number of characters of the text
+// fields of the JIRA instance of the configuration, such as descriptions
+// and comment bodies, or 0 if they're unlimited.
+func (c Config) GetJIRATextLimit() int {
+	if d, ok := c.deployments[c.instance]; ok {
+		return d.textLimit
+	}
+	return defaultTextLimit
+}
+
+// LimitJIRAText truncates a text to the maximum number of characters of the
+// text fields of the JIRA instance of the configuration, without splitting
+// any character.
+func (c Config) LimitJIRAText(text string) string {
+	limit := c.GetJIRATextLimit()
+	if limit <= 0 || len(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit])
+}
@@ -0,0 +1,195 @@
+package cfg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// encryptedPrefix is the prefix of the values of configuration options which
+// are encrypted with the passphrase of the configuration file.
+const encryptedPrefix = "enc:v1:"
+
+// passphraseEnv is the environment variable the passphrase of the
+// configuration file is read from; if it isn't set, it's prompted for.
+const passphraseEnv = "ISSUE_SYNC_PASSPHRASE"
+
+// saltLength is the length of the random salt the key of each encrypted
+// value is derived with.
+const saltLength = 16
+
+// Parameters of the derivation of keys from the passphrase with scrypt.
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+)
+
+// configCipher holds the passphrase the secrets of the configuration file
+// are encrypted with. It's shared by all copies of the Config: once set,
+// SaveConfig encrypts the secrets it writes.
+type configCipher struct {
+	passphrase string
+}
+
+// deriveKey derives an AES-256 key from the passphrase and salt.
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptValue encrypts a value with AES-GCM, using a key derived from
+// the passphrase and a random salt, which is stored along with the nonce
+// and the ciphertext.
+func encryptValue(passphrase, plaintext string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+	aead, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := append(salt, nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(plaintext), nil)
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptValue decrypts a value encrypted by encryptValue.
+func decryptValue(passphrase, value string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < saltLength {
+		return "", errors.New("malformed encrypted value")
+	}
+	aead, err := deriveKey(passphrase, sealed[:saltLength])
+	if err != nil {
+		return "", err
+	}
+	sealed = sealed[saltLength:]
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase, or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+// ReadPassphrase returns the passphrase of the configuration file, from the
+// ISSUE_SYNC_PASSPHRASE environment variable, or else prompted for on the
+// terminal, twice if confirm is set.
+func ReadPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Print("Enter the passphrase of the configuration file: ")
+	b, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil || len(b) == 0 {
+		return "", fmt.Errorf("passphrase required; set %s or run interactively", passphraseEnv)
+	}
+
+	if confirm {
+		fmt.Print("Enter the passphrase again: ")
+		again, err := terminal.ReadPassword(int(syscall.Stdin))
+		fmt.Println()
+		if err != nil || string(again) != string(b) {
+			return "", errors.New("the passphrases don't match")
+		}
+	}
+
+	return string(b), nil
+}
+
+// decryptSecrets replaces every encrypted option of the configuration by its
+// decrypted value. The passphrase is only read if there's any, and is kept
+// so that SaveConfig encrypts the secrets again.
+func (c Config) decryptSecrets() error {
+	var encrypted []string
+	for _, key := range c.cmdConfig.AllKeys() {
+		if value, ok := c.cmdConfig.Get(key).(string); ok && strings.HasPrefix(value, encryptedPrefix) {
+			encrypted = append(encrypted, key)
+		}
+	}
+	if len(encrypted) == 0 {
+		return nil
+	}
+
+	passphrase := c.cipher.passphrase
+	if passphrase == "" {
+		var err error
+		if passphrase, err = ReadPassphrase(false); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range encrypted {
+		value, err := decryptValue(passphrase, c.cmdConfig.GetString(key))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt %s: %v", key, err)
+		}
+		c.cmdConfig.Set(key, value)
+	}
+	c.cipher.passphrase = passphrase
+
+	return nil
+}
+
+// EncryptSecrets sets the passphrase the secrets of the configuration file
+// are encrypted with by SaveConfig. Secrets which are encrypted already must
+// have been encrypted with the same passphrase.
+func (c Config) EncryptSecrets(passphrase string) error {
+	c.cipher.passphrase = passphrase
+	return c.decryptSecrets()
+}
+
+// encryptSecretFields encrypts the credentials of the configuration file
+// about to be written, if a passphrase is set. Credentials which reference
+// Vault secrets are left as they are.
+func (c Config) encryptSecretFields(cf *configFile) error {
+	if c.cipher.passphrase == "" {
+		return nil
+	}
+
+	fields := cf.stringFields()
+	for _, key := range c.secretOptions() {
+		field, ok := fields[key]
+		if !ok || *field == "" || strings.HasPrefix(*field, vaultPrefix) {
+			continue
+		}
+		value, err := encryptValue(c.cipher.passphrase, *field)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt %s: %v", key, err)
+		}
+		*field = value
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultEstimatePattern matches the estimate labels, such as estimate/2d,
+// unless another pattern is configured in `estimate-pattern`.
+const defaultEstimatePattern = `^estimate/(.+)$`
+
+// defaultEstimateUnit is the unit of the estimates which are bare numbers,
+// such as estimate/4, unless another one is configured in `estimate-unit`.
+const defaultEstimateUnit = "h"
+
+// estimateUnits maps the units of the estimates of labels to those of JIRA
+// time tracking: weeks, days, hours, and minutes.
+var estimateUnits = map[string]string{
+	"w": "w", "wk": "w", "week": "w", "weeks": "w",
+	"d": "d", "day": "d", "days": "d",
+	"h": "h", "hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+	"m": "m", "min": "m", "mins": "m", "minute": "m", "minutes": "m",
+}
+
+// regexEstimatePart matches a number of an estimate, with its unit, if any.
+var regexEstimatePart = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-z]*)`)
+
+// UsesEstimateLabels returns whether the original estimate of the time
+// tracking of the JIRA issues is set from the labels of their GitHub issue
+// when they're created.
+func (c Config) UsesEstimateLabels() bool {
+	return c.cmdConfig.GetBool("estimate-labels")
+}
+
+// getEstimatePattern returns the regular expression of the estimate labels,
+// whose first group is the estimate.
+func (c Config) getEstimatePattern() (*regexp.Regexp, error) {
+	pattern := c.cmdConfig.GetString("estimate-pattern")
+	if pattern == "" {
+		pattern = defaultEstimatePattern
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// getEstimateUnit returns the unit of the estimates which are bare numbers.
+func (c Config) getEstimateUnit() string {
+	if unit := c.cmdConfig.GetString("estimate-unit"); unit != "" {
+		return estimateUnits[strings.ToLower(unit)]
+	}
+	return defaultEstimateUnit
+}
+
+// parseEstimate returns an estimate, such as "2d", "1 day 4 hours", or
+// "1.5", in the JIRA time tracking format, such as "1d 4h", and whether
+// it's one. Bare numbers are in the unit given.
+func parseEstimate(estimate, unit string) (string, bool) {
+	s := strings.ToLower(strings.TrimSpace(estimate))
+	var parts []string
+	for s != "" {
+		m := regexEstimatePart.FindStringSubmatch(s)
+		if m == nil {
+			return "", false
+		}
+		u := unit
+		if m[2] != "" {
+			var ok bool
+			if u, ok = estimateUnits[m[2]]; !ok {
+				return "", false
+			}
+		}
+		parts = append(parts, m[1]+u)
+		s = strings.TrimLeft(s[len(m[0]):], " ,")
+	}
+	return strings.Join(parts, " "), len(parts) > 0
+}
+
+// GetEstimate returns the original estimate of a GitHub issue with the
+// labels, in the JIRA time tracking format, and whether one of them is an
+// estimate label. The first label which is one is used; labels matching
+// the pattern whose estimate can't be read are ignored.
+func (c Config) GetEstimate(labels []string) (string, bool) {
+	pattern, err := c.getEstimatePattern()
+	if err != nil {
+		return "", false
+	}
+	for _, label := range labels {
+		m := pattern.FindStringSubmatch(label)
+		if len(m) < 2 {
+			continue
+		}
+		if estimate, ok := parseEstimate(m[1], c.getEstimateUnit()); ok {
+			return estimate, true
+		}
+	}
+	return "", false
+}
+
+// validateEstimates checks that the pattern of the estimate labels is a
+// valid regular expression with a group, and that the unit is known.
+func (c Config) validateEstimates() error {
+	pattern, err := c.getEstimatePattern()
+	if err != nil {
+		return fmt.Errorf("estimate-pattern is invalid: %v", err)
+	}
+	if pattern.NumSubexp() < 1 {
+		return errors.New("estimate-pattern must have a group matching the estimate")
+	}
+	if c.getEstimateUnit() == "" {
+		return errors.New("estimate-unit must be w, d, h, or m")
+	}
+	return nil
+}
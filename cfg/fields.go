@@ -0,0 +1,146 @@
+package cfg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// customFieldsKey is the configuration option mapping the custom fields used
+// by issue-sync to the names or IDs of the JIRA fields to use instead of
+// those of their default names.
+const customFieldsKey = "custom-fields"
+
+// fieldIDRegex matches a reference to a JIRA custom field by its ID, such as
+// "customfield_10042" or "10042", rather than by its name.
+var fieldIDRegex = regexp.MustCompile(`^(?:customfield_)?([0-9]+)$`)
+
+// customFieldRef returns the name or ID of the JIRA field used for a custom
+// field of issue-sync: that configured in the `custom-fields` of the JIRA
+// instance, or in the top-level `custom-fields`, or else its default name.
+func (c Config) customFieldRef(field customField) string {
+	if c.instance != "" {
+		if ref := c.cmdConfig.GetString(instanceOption(c.instance, customFieldsKey+"."+field.option)); ref != "" {
+			return ref
+		}
+	}
+	if ref := c.cmdConfig.GetString(customFieldsKey + "." + field.option); ref != "" {
+		return ref
+	}
+	return field.name
+}
+
+// fieldRefID returns the custom field ID of a reference to a JIRA field, and
+// whether it's a reference by ID.
+func fieldRefID(ref string) (string, bool) {
+	m := fieldIDRegex.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// matches reports whether a JIRA field is the one a reference designates,
+// by its ID or by its name.
+func (f jiraField) matches(ref string) bool {
+	if id, ok := fieldRefID(ref); ok {
+		return f.ID == "customfield_"+id
+	}
+	return f.Name == ref
+}
+
+// validateCustomFields checks that `custom-fields`, at the top level and in
+// each JIRA instance, only maps custom fields used by issue-sync.
+func (c Config) validateCustomFields() error {
+	prefixes := []string{customFieldsKey + "."}
+	for _, name := range c.GetJIRAInstances() {
+		prefixes = append(prefixes, instanceOption(name, customFieldsKey+"."))
+	}
+
+	for _, key := range c.cmdConfig.AllKeys() {
+		for _, prefix := range prefixes {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			option := strings.TrimPrefix(key, prefix)
+			if _, ok := customFieldOption(option); !ok {
+				return fmt.Errorf("%s has no custom field named %s", strings.TrimSuffix(prefix, "."), option)
+			}
+		}
+	}
+
+	return nil
+}
+
+// customFieldOption returns the custom field of issue-sync whose key in
+// `custom-fields` is given.
+func customFieldOption(option string) (customField, bool) {
+	for _, field := range customFields {
+		if field.option == option {
+			return field, true
+		}
+	}
+	return customField{}, false
+}
+
+// get returns the ID of a custom field.
+func (f fields) get(key fieldKey) string {
+	switch key {
+	case GitHubID:
+		return f.githubID
+	case GitHubNumber:
+		return f.githubNumber
+	case GitHubLabels:
+		return f.githubLabels
+	case GitHubReporter:
+		return f.githubReporter
+	case GitHubStatus:
+		return f.githubStatus
+	case LastISUpdate:
+		return f.lastUpdate
+	case GitHubVotes:
+		return f.githubVotes
+	case EpicLink:
+		return f.epicLink
+	case EpicName:
+		return f.epicName
+	case StoryPoints:
+		return f.storyPoints
+	case Flagged:
+		return f.flagged
+	case AssigneesField:
+		return f.assignees
+	default:
+		return ""
+	}
+}
+
+// set sets the ID of a custom field.
+func (f *fields) set(key fieldKey, id string) {
+	switch key {
+	case GitHubID:
+		f.githubID = id
+	case GitHubNumber:
+		f.githubNumber = id
+	case GitHubLabels:
+		f.githubLabels = id
+	case GitHubReporter:
+		f.githubReporter = id
+	case GitHubStatus:
+		f.githubStatus = id
+	case LastISUpdate:
+		f.lastUpdate = id
+	case GitHubVotes:
+		f.githubVotes = id
+	case EpicLink:
+		f.epicLink = id
+	case EpicName:
+		f.epicName = id
+	case StoryPoints:
+		f.storyPoints = id
+	case Flagged:
+		f.flagged = id
+	case AssigneesField:
+		f.assignees = id
+	}
+}
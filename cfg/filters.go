@@ -0,0 +1,144 @@
+package cfg
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// labelFilter is the labels the GitHub issues of a repo must carry to be
+// synchronized, and those they mustn't. Labels are compared regardless of
+// case, as GitHub does.
+type labelFilter struct {
+	include []string
+	exclude []string
+}
+
+// hasLabel reports whether a label is in the list, regardless of case.
+func hasLabel(list []string, label string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// getLabelFilter returns the label filter of the repo: that of its project
+// entry, or else the global `include-labels` and `exclude-labels`.
+func (c Config) getLabelFilter(repo string) labelFilter {
+	if filter, ok := c.labelFilters[repo]; ok {
+		return filter
+	}
+	return labelFilter{
+		include: c.cmdConfig.GetStringSlice("include-labels"),
+		exclude: c.cmdConfig.GetStringSlice("exclude-labels"),
+	}
+}
+
+// MatchesLabels reports whether a GitHub issue of the repo with the labels
+// is synchronized: it has to carry one of the included labels, if any are
+// configured, and none of the excluded ones.
+func (c Config) MatchesLabels(repo string, labels []string) bool {
+	filter := c.getLabelFilter(repo)
+
+	included := len(filter.include) == 0
+	for _, label := range labels {
+		if hasLabel(filter.exclude, label) {
+			return false
+		}
+		included = included || hasLabel(filter.include, label)
+	}
+	return included
+}
+
+// getMilestoneFilter returns the names or glob patterns of the milestones of
+// the GitHub issues of the repo which are synchronized: those of its project
+// entry, or else the global `milestones`.
+func (c Config) getMilestoneFilter(repo string) []string {
+	if patterns, ok := c.milestoneFilters[repo]; ok {
+		return patterns
+	}
+	return c.cmdConfig.GetStringSlice("milestones")
+}
+
+// validateMilestonePatterns checks that milestone patterns are valid globs.
+func validateMilestonePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad milestone pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// MatchesMilestone reports whether a GitHub issue of the repo in the
+// milestone (whose title is empty if the issue has none) is synchronized: if
+// milestones are configured, its milestone has to match one of them, by name
+// or glob pattern, regardless of case.
+func (c Config) MatchesMilestone(repo, milestone string) bool {
+	patterns := c.getMilestoneFilter(repo)
+	if len(patterns) == 0 {
+		return true
+	}
+	if milestone == "" {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(milestone)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// userFilter is the users whose GitHub issues of a repo are synchronized:
+// the authors, and the assignees of the issues. Users are given by login, or
+// as the members of a team, by @org/team.
+type userFilter struct {
+	authors   []string
+	assignees []string
+}
+
+// GetAuthorFilter returns the users whose GitHub issues of the repo are
+// synchronized, by login or @org/team: those of its project entry, or else
+// the global `authors`. If it's empty, issues aren't filtered by author.
+func (c Config) GetAuthorFilter(repo string) []string {
+	if filter, ok := c.userFilters[repo]; ok && len(filter.authors) > 0 {
+		return filter.authors
+	}
+	return c.cmdConfig.GetStringSlice("authors")
+}
+
+// GetAssigneeFilter returns the users the GitHub issues of the repo which
+// are synchronized are assigned to, by login or @org/team: those of its
+// project entry, or else the global `assignees`. If it's empty, issues
+// aren't filtered by assignee.
+func (c Config) GetAssigneeFilter(repo string) []string {
+	if filter, ok := c.userFilters[repo]; ok && len(filter.assignees) > 0 {
+		return filter.assignees
+	}
+	return c.cmdConfig.GetStringSlice("assignees")
+}
+
+// ParseTeam returns the organization and the slug of a team given as
+// @org/team (or org/team) in a user filter, and whether it's a team rather
+// than a login.
+func ParseTeam(user string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(user, "@"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// GetJQLFilter returns the JQL fragment restricting the issues of the JIRA
+// project which are managed: that of its project entry, or else the global
+// `jql-filter`.
+func (c Config) GetJQLFilter(key string) string {
+	if filter, ok := c.jqlFilters[key]; ok {
+		return filter
+	}
+	return c.cmdConfig.GetString("jql-filter")
+}
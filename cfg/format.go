@@ -0,0 +1,124 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// configFormat returns the format of a configuration file from its
+// extension: "yaml", "toml", or "json", which is the default.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// normalize converts a value decoded from any of the configuration formats
+// to the types JSON decodes to, with integers kept as int64 rather than
+// float64. Null values are dropped from maps, as TOML can't represent them.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item != nil {
+				m[key] = normalize(item)
+			}
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if item != nil {
+				m[fmt.Sprint(key)] = normalize(item)
+			}
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = normalize(item)
+		}
+		return s
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// marshalConfigFile encodes the configuration file in the format of the
+// file at the path.
+func marshalConfigFile(cf configFile, path string) ([]byte, error) {
+	b, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	format := configFormat(path)
+	if format == "json" {
+		return b, nil
+	}
+
+	// The configuration file is encoded through a map, so that the YAML and
+	// TOML keys and omitted options are the same as those of JSON.
+	decoder := json.NewDecoder(strings.NewReader(string(b)))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	m = normalize(m).(map[string]interface{})
+
+	if format == "yaml" {
+		return yaml.Marshal(m)
+	}
+
+	tree, err := toml.TreeFromMap(m)
+	if err != nil {
+		return nil, err
+	}
+	s, err := tree.ToTomlString()
+	return []byte(s), err
+}
+
+// readConfigFile reads the configuration file at the path, in its format,
+// and returns it as JSON, so it can be checked against the schema of the
+// configuration regardless of its format.
+func readConfigFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m interface{}
+	switch configFormat(path) {
+	case "yaml":
+		err = yaml.Unmarshal(b, &m)
+	case "toml":
+		var tree *toml.Tree
+		if tree, err = toml.LoadBytes(b); err == nil {
+			m = tree.ToMap()
+		}
+	default:
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s is not valid %s: %v", path, configFormat(path), err)
+	}
+
+	return json.Marshal(normalize(m))
+}
@@ -0,0 +1,111 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormSection maps a section of the bodies of the GitHub issues created
+// from issue forms, which is titled by a level 3 heading named after the
+// field of the form, to a JIRA field, as configured in `issue-form-fields`.
+type FormSection struct {
+	// Heading is the heading of the section, such as "Steps to Reproduce".
+	Heading string `json:"heading" mapstructure:"heading"`
+	// Field is the name or ID of the JIRA field the section is written to,
+	// such as "Environment", "versions", or "customfield_10060".
+	Field string `json:"field" mapstructure:"field"`
+}
+
+// FormField is a JIRA field a section of issue forms is written to, with
+// the type of its values, as told by the JIRA field metadata: such as
+// "string", "number", "option", or "array" of Items.
+type FormField struct {
+	ID    string
+	Type  string
+	Items string
+}
+
+// formSections returns the sections of `issue-form-fields`.
+func (c Config) formSections() []FormSection {
+	var sections []FormSection
+	c.cmdConfig.UnmarshalKey("issue-form-fields", &sections)
+	return sections
+}
+
+// UsesIssueForms returns whether sections of the bodies of GitHub issues
+// are written to JIRA fields.
+func (c Config) UsesIssueForms() bool {
+	return len(c.formSections()) > 0
+}
+
+// GetFormFields returns the JIRA fields of the sections of issue forms, by
+// lowercased heading. They're only known once the JIRA configuration is
+// loaded.
+func (c Config) GetFormFields() map[string]FormField {
+	return c.fieldIDs.forms
+}
+
+// unformableFields are the JIRA fields issue-sync writes otherwise, which
+// sections of issue forms can't be written to.
+var unformableFields = map[string]bool{
+	"summary":     true,
+	"description": true,
+	"labels":      true,
+	"fixVersions": true,
+	"issuetype":   true,
+	"project":     true,
+	"parent":      true,
+	"status":      true,
+}
+
+// listFormFields returns the JIRA fields of the sections of issue forms,
+// by lowercased heading, among the fields of JIRA.
+func (c Config) listFormFields(jFields []jiraField) (map[string]FormField, error) {
+	forms := make(map[string]FormField)
+	for _, section := range c.formSections() {
+		for _, field := range jFields {
+			if field.ID != section.Field && !strings.EqualFold(field.Name, section.Field) && !(field.Custom && field.matches(section.Field)) {
+				continue
+			}
+			if unformableFields[field.ID] || (field.ID == "components" && c.UsesComponents()) {
+				return nil, fmt.Errorf("issue-form-fields can't write section %q to field %s, which issue-sync already writes", section.Heading, field.ID)
+			}
+			forms[strings.ToLower(section.Heading)] = FormField{
+				ID:    field.ID,
+				Type:  field.Schema.Type,
+				Items: field.Schema.Items,
+			}
+			break
+		}
+	}
+	return forms, nil
+}
+
+// missingFormFields returns the names or IDs of the JIRA fields of the
+// sections of issue forms which weren't found.
+func (c Config) missingFormFields(forms map[string]FormField) []string {
+	var refs []string
+	for _, section := range c.formSections() {
+		if _, ok := forms[strings.ToLower(section.Heading)]; !ok {
+			refs = append(refs, section.Field)
+		}
+	}
+	return refs
+}
+
+// validateFormSections checks that the sections of `issue-form-fields` have
+// a heading and a field, and that no heading is mapped twice.
+func (c Config) validateFormSections() error {
+	headings := make(map[string]bool)
+	for _, section := range c.formSections() {
+		if strings.TrimSpace(section.Heading) == "" || strings.TrimSpace(section.Field) == "" {
+			return fmt.Errorf("issue-form-fields requires a heading and a field")
+		}
+		heading := strings.ToLower(section.Heading)
+		if headings[heading] {
+			return fmt.Errorf("issue-form-fields maps section %q twice", section.Heading)
+		}
+		headings[heading] = true
+	}
+	return nil
+}
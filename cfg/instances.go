@@ -0,0 +1,196 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// instancesKey is the configuration option holding the JIRA instances which
+// projects may be synchronized to, besides the one of the top-level options.
+const instancesKey = "jira-instances"
+
+// instanceKeys are the options of the JIRA server and of its credentials,
+// which are set for each JIRA instance.
+var instanceKeys = []string{
+	"jira-uri", "jira-user", "jira-pass", "jira-pat", "jira-token", "jira-secret",
+	"jira-consumer-key", "jira-private-key-path",
+}
+
+// JIRAInstance is a JIRA server, and the credentials to authenticate to it,
+// as configured in `jira-instances`.
+type JIRAInstance struct {
+	URI            string `json:"jira-uri" mapstructure:"jira-uri"`
+	User           string `json:"jira-user,omitempty" mapstructure:"jira-user"`
+	Pass           string `json:"jira-pass,omitempty" mapstructure:"jira-pass"`
+	PAT            string `json:"jira-pat,omitempty" mapstructure:"jira-pat"`
+	Token          string `json:"jira-token,omitempty" mapstructure:"jira-token"`
+	Secret         string `json:"jira-secret,omitempty" mapstructure:"jira-secret"`
+	ConsumerKey    string `json:"jira-consumer-key,omitempty" mapstructure:"jira-consumer-key"`
+	PrivateKeyPath string `json:"jira-private-key-path,omitempty" mapstructure:"jira-private-key-path"`
+	// CustomFields overrides the top-level `custom-fields` for the instance.
+	CustomFields map[string]string `json:"custom-fields,omitempty" mapstructure:"custom-fields"`
+	// UserMap overrides the top-level `user-map` for the instance.
+	UserMap map[string]string `json:"user-map,omitempty" mapstructure:"user-map"`
+}
+
+// isInstanceKey returns whether an option is set for each JIRA instance.
+func isInstanceKey(key string) bool {
+	for _, k := range instanceKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// instanceOption returns the key of an option of a JIRA instance.
+func instanceOption(instance, key string) string {
+	return instancesKey + "." + instance + "." + key
+}
+
+// GetJIRAInstances returns the names of the configured JIRA instances, in
+// alphabetical order. The default instance, of the top-level options, isn't
+// included.
+func (c Config) GetJIRAInstances() []string {
+	// The names are found from the keys of the options, as the map of the
+	// instances only has those which were set if any option of an instance
+	// was set after it was read, such as a decrypted password.
+	found := make(map[string]bool)
+	for _, key := range c.cmdConfig.AllKeys() {
+		path := strings.Split(key, ".")
+		if len(path) > 2 && path[0] == instancesKey {
+			found[path[1]] = true
+		}
+	}
+
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetJIRAInstance returns the name of the JIRA instance the configuration
+// targets, which is empty for the default instance.
+func (c Config) GetJIRAInstance() string {
+	return c.instance
+}
+
+// GetProjectInstance returns the name of the JIRA instance the project of a
+// GitHub repo is synchronized to, which is empty for the default instance.
+func (c Config) GetProjectInstance(repo string) string {
+	return c.projectInstances[repo]
+}
+
+// ForInstance returns a copy of the configuration whose JIRA server and
+// credentials are those of the named JIRA instance, or of the top-level
+// options if the name is empty.
+func (c Config) ForInstance(instance string) Config {
+	if instance == c.instance {
+		return c
+	}
+
+	c.instance = instance
+	if ids, ok := c.instanceFieldIDs[instance]; ok {
+		c.fieldIDs = ids
+	} else {
+		c.fieldIDs = fields{}
+	}
+	c.basicAuth = !c.IsPATAuth() && c.GetConfigString("jira-user") != "" && c.GetConfigString("jira-pass") != ""
+	if instance != "" {
+		c.log = *c.log.WithField("jira-instance", instance)
+	}
+
+	return c
+}
+
+// ForProject returns a copy of the configuration for the JIRA instance the
+// project of a GitHub repo is synchronized to.
+func (c Config) ForProject(repo string) Config {
+	return c.ForInstance(c.projectInstances[repo])
+}
+
+// instanceProjects returns the configured projects which are synchronized to
+// the JIRA instance of the configuration.
+func (c Config) instanceProjects() []Project {
+	var projects []Project
+	c.cmdConfig.UnmarshalKey("projects", &projects)
+
+	var filtered []Project
+	for _, project := range projects {
+		if strings.ToLower(project.Instance) == c.instance {
+			filtered = append(filtered, project)
+		}
+	}
+	return filtered
+}
+
+// validateInstances checks the JIRA server and credentials of each JIRA
+// instance, and that the projects only reference configured instances.
+// Unlike those of the default instance, missing passwords aren't prompted
+// for.
+func (c Config) validateInstances(projects []Project) error {
+	instances := make(map[string]bool)
+	for _, name := range c.GetJIRAInstances() {
+		instances[name] = true
+		if err := c.ForInstance(name).validateInstance(); err != nil {
+			return fmt.Errorf("JIRA instance %s: %v", name, err)
+		}
+	}
+
+	for i, project := range projects {
+		instance := strings.ToLower(project.Instance)
+		if instance != "" && !instances[instance] {
+			return fmt.Errorf("project number %d references unknown JIRA instance %s", i, project.Instance)
+		}
+		c.projectInstances[project.Repo] = instance
+	}
+
+	return nil
+}
+
+// validateInstance checks the JIRA server and credentials of the JIRA
+// instance of the configuration.
+func (c Config) validateInstance() error {
+	uri := c.GetConfigString("jira-uri")
+	if uri == "" {
+		return errors.New("JIRA URI required")
+	}
+	if _, err := url.ParseRequestURI(uri); err != nil {
+		return errors.New("JIRA URI must be valid URI")
+	}
+
+	switch {
+	case c.IsPATAuth():
+		return nil
+	case c.GetConfigString("jira-user") != "":
+		if c.GetConfigString("jira-pass") == "" {
+			return errors.New("JIRA password required")
+		}
+		return nil
+	}
+
+	if c.GetConfigString("jira-token") == "" {
+		return errors.New("JIRA access token required")
+	}
+	if c.GetConfigString("jira-secret") == "" {
+		return errors.New("JIRA access token secret required")
+	}
+	if c.GetConfigString("jira-consumer-key") == "" {
+		return errors.New("JIRA consumer key required for OAuth handshake")
+	}
+	privateKey := c.GetConfigString("jira-private-key-path")
+	if privateKey == "" {
+		return errors.New("JIRA private key required for OAuth handshake")
+	}
+	if _, err := os.Stat(privateKey); err != nil {
+		return errors.New("JIRA private key must point to existing PEM file")
+	}
+
+	return nil
+}
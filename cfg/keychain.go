@@ -0,0 +1,74 @@
+package cfg
+
+// keychainService is the service name the credentials of issue-sync are
+// stored under in the OS keychain; each is stored as an account named after
+// its configuration option.
+const keychainService = "issue-sync"
+
+// secretKeys are the configuration options holding credentials, which are
+// kept in the OS keychain rather than in the configuration file if
+// `keychain` is set.
+var secretKeys = []string{"github-token", "jira-pass", "jira-token", "jira-secret", "jira-pat", "smtp-pass"}
+
+// secretOptions returns the configuration options holding credentials: the
+// secretKeys, and those of each JIRA instance.
+func (c Config) secretOptions() []string {
+	options := append([]string(nil), secretKeys...)
+	for _, name := range c.GetJIRAInstances() {
+		for _, key := range secretKeys {
+			if isInstanceKey(key) {
+				options = append(options, instanceOption(name, key))
+			}
+		}
+	}
+	return options
+}
+
+// UsesKeychain returns whether credentials are kept in the OS keychain.
+func (c Config) UsesKeychain() bool {
+	return c.cmdConfig.GetBool("keychain")
+}
+
+// loadKeychainSecrets sets the credentials which aren't given on the command
+// line or in the configuration file from the OS keychain. A keychain which
+// can't be read is only reported, as the credentials may not be needed.
+func (c Config) loadKeychainSecrets() {
+	for _, key := range c.secretOptions() {
+		if c.cmdConfig.GetString(key) != "" {
+			continue
+		}
+		secret, ok, err := keychainGet(keychainService, key)
+		if err != nil {
+			c.log.Warnf("Unable to read %s from the OS keychain: %v", key, err)
+			continue
+		}
+		if ok {
+			c.log.Debugf("Read %s from the OS keychain", key)
+			c.cmdConfig.Set(key, secret)
+		}
+	}
+}
+
+// storeKeychainSecrets saves the credentials of the configuration to the OS
+// keychain, and removes them from the configuration file about to be written.
+// This migrates the credentials of an existing configuration file to the
+// keychain the first time it's saved with `keychain` set. A credential which
+// can't be stored is left in the file, so it isn't lost; one read from Vault
+// is left to restoreVaultRefs.
+func (c Config) storeKeychainSecrets(cf *configFile) {
+	fields := cf.stringFields()
+
+	for _, key := range c.secretOptions() {
+		secret := c.cmdConfig.GetString(key)
+		if _, ok := c.vaultRefs[key]; ok || secret == "" {
+			continue
+		}
+		if err := keychainSet(keychainService, key, secret); err != nil {
+			c.log.Errorf("Unable to store %s in the OS keychain: %v", key, err)
+			continue
+		}
+		if field, ok := fields[key]; ok {
+			*field = ""
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errItemNotFound is the exit status of `security` when no item matches.
+const errItemNotFound = 44
+
+// keychainGet reads a password from the macOS Keychain, and returns whether
+// it was found.
+func keychainGet(service, account string) (string, bool, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == errItemNotFound {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+// keychainSet stores a password in the macOS Keychain, replacing the
+// previous one. Given `-w` last, without a value, `security` prompts for the
+// password, and then for it again, so it's passed twice on the standard
+// input rather than in the arguments of the process.
+func keychainSet(service, account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", account, "-w")
+	cmd.Stdin = strings.NewReader(secret + "\n" + secret + "\n")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychainGet reads a secret from the Secret Service (GNOME Keyring, KWallet,
+// etc.) with libsecret's `secret-tool`, and returns whether it was found.
+func keychainGet(service, account string) (string, bool, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits with 1 and prints nothing if no secret matches.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), true, nil
+}
+
+// keychainSet stores a secret in the Secret Service with `secret-tool`,
+// replacing the previous one. The secret is passed on the standard input,
+// so it doesn't appear in the arguments of the process.
+func keychainSet(service, account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store", "--label", fmt.Sprintf("%s %s", service, account),
+		"service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
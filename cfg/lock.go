@@ -0,0 +1,77 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("lock is held by another process")
+
+// Lock is the exclusive lock on the `lock-file` held by a single issue-sync
+// process at a time, so that two runs, such as one started by cron and a
+// daemon, don't synchronize the same projects concurrently and create
+// duplicate JIRA issues. A nil Lock is valid, and locks nothing.
+type Lock struct {
+	file *os.File
+}
+
+// GetLockFile returns the path of the lock file: the `lock-file`, or else
+// the state file with a ".lock" extension. It's empty, and nothing is
+// locked, if neither is configured.
+func (c Config) GetLockFile() string {
+	if path := c.cmdConfig.GetString("lock-file"); path != "" {
+		return os.ExpandEnv(path)
+	}
+	if path := c.cmdConfig.GetString("state-file"); path != "" {
+		return os.ExpandEnv(path) + ".lock"
+	}
+	return ""
+}
+
+// AcquireLock takes the lock of the configuration, failing rather than
+// waiting if another issue-sync process holds it. The lock is released when
+// the process exits, even if it crashes. Dry runs don't lock, as they don't
+// write anything.
+func (c Config) AcquireLock() (*Lock, error) {
+	path := c.GetLockFile()
+	if path == "" || c.IsDryRun() {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open lock file %s: %v", path, err)
+	}
+	if err := lockFile(f); err == errLocked {
+		f.Close()
+		return nil, fmt.Errorf("another issue-sync is already running (%s is locked)", path)
+	} else if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to lock %s: %v", path, err)
+	}
+
+	// The PID is only informative, for users looking for the process
+	// holding the lock.
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+
+	c.log.Debugf("Acquired lock %s", path)
+	return &Lock{file: f}, nil
+}
+
+// Release releases the lock. The lock file itself is left in place, as
+// removing it would let another process lock a new file while a third one
+// still holds the old one.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package cfg
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on the file without waiting, returning
+// errLocked if another process holds it.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errLocked
+	}
+	return err
+}
+
+// unlockFile releases the flock on the file.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,103 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+)
+
+// The modes of the notifications of the runs: every run is notified, only
+// those with failures, or, by email, a daily digest of the runs.
+const (
+	NotifyAll    = "all"
+	NotifyErrors = "errors"
+	NotifyDaily  = "daily"
+)
+
+// isNotifyMode returns whether the mode is one of the notification modes.
+func isNotifyMode(mode string) bool {
+	return mode == NotifyAll || mode == NotifyErrors
+}
+
+// GetSlackWebhookURL returns the URL of the Slack incoming webhook the
+// summary of each run is posted to, or an empty URL if none is configured.
+func (c Config) GetSlackWebhookURL() string {
+	return c.cmdConfig.GetString("slack-webhook-url")
+}
+
+// GetSlackNotify returns which runs are posted to Slack: all of them, unless
+// only those with failures are configured.
+func (c Config) GetSlackNotify() string {
+	if mode := c.cmdConfig.GetString("slack-notify"); mode != "" {
+		return mode
+	}
+	return NotifyAll
+}
+
+// GetEventWebhookURL returns the URL the sync events are posted to, or an
+// empty URL if none is configured.
+func (c Config) GetEventWebhookURL() string {
+	return c.cmdConfig.GetString("event-webhook-url")
+}
+
+// GetSMTPAddress returns the address, as host:port, of the SMTP server the
+// email notifications are sent through.
+func (c Config) GetSMTPAddress() string {
+	return c.cmdConfig.GetString("smtp-address")
+}
+
+// GetEmailFrom returns the sender address of the email notifications.
+func (c Config) GetEmailFrom() string {
+	return c.cmdConfig.GetString("email-from")
+}
+
+// GetEmailRecipients returns the addresses all of the email notifications
+// are sent to.
+func (c Config) GetEmailRecipients() []string {
+	return c.cmdConfig.GetStringSlice("email-to")
+}
+
+// GetProjectEmailRecipients returns the addresses the email notifications
+// about the repo are sent to, in addition to the GetEmailRecipients.
+func (c Config) GetProjectEmailRecipients(repo string) []string {
+	return c.emailRecipients[repo]
+}
+
+// GetEmailNotify returns which runs are notified by email: all of them,
+// unless only those with failures, or a daily digest, are configured.
+func (c Config) GetEmailNotify() string {
+	if mode := c.cmdConfig.GetString("email-notify"); mode != "" {
+		return mode
+	}
+	return NotifyAll
+}
+
+// SendsEmail returns whether any email notification is configured.
+func (c Config) SendsEmail() bool {
+	return len(c.GetEmailRecipients()) > 0 || len(c.emailRecipients) > 0
+}
+
+// validateEmail checks the mode of the email notifications, and, if any is
+// sent, that the SMTP server and valid addresses are configured.
+func (c Config) validateEmail() error {
+	if mode := c.GetEmailNotify(); !isNotifyMode(mode) && mode != NotifyDaily {
+		return errors.New("email notify must be all, errors, or daily")
+	}
+	if !c.SendsEmail() {
+		return nil
+	}
+	if c.GetSMTPAddress() == "" || c.GetEmailFrom() == "" {
+		return errors.New("email notifications require an smtp-address and an email-from address")
+	}
+
+	addresses := append([]string{c.GetEmailFrom()}, c.GetEmailRecipients()...)
+	for _, recipients := range c.emailRecipients {
+		addresses = append(addresses, recipients...)
+	}
+	for _, address := range addresses {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("invalid email address %s: %v", address, err)
+		}
+	}
+	return nil
+}
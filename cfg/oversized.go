@@ -0,0 +1,205 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ways the GitHub comments whose JIRA comment would be longer than the limit
+// of the JIRA instance are synced, as configured in `oversized-text`.
+const (
+	OversizedTruncate = "truncate"
+	OversizedSplit    = "split"
+)
+
+// truncationNotice ends the texts truncated to the limit of the JIRA
+// instance, with the URL of the GitHub issue or comment they come from.
+const truncationNotice = "\n\n_(Truncated; continued on [GitHub|%s].)_"
+
+// regexTruncationNotice matches the truncation notice at the end of a text,
+// along with the macro closed before it, if any.
+var regexTruncationNotice = regexp.MustCompile(`(?:\n\{(?:code|noformat)\})?\n\n_\(Truncated; continued on \[GitHub\|[^\]\n]*\]\.\)_$`)
+
+// continuedNotice ends each part of a comment split across several JIRA
+// comments but the last, and continuationHeader starts each part but the
+// first.
+const (
+	continuedNotice    = "\n\n_(Continued in the next comment.)_"
+	continuationHeader = "_(Continued from the previous comment.)_\n\n"
+)
+
+// regexMacro matches the tags of the JIRA macros whose content isn't markup,
+// which a text mustn't be cut in.
+var regexMacro = regexp.MustCompile(`\{(code|noformat)(?::[^}\n]*)?\}`)
+
+// GetOversizedText returns how the GitHub comments too long for JIRA are
+// synced: truncated, with a link to the rest on GitHub, or split across
+// several JIRA comments.
+func (c Config) GetOversizedText() string {
+	if mode := c.cmdConfig.GetString("oversized-text"); mode != "" {
+		return mode
+	}
+	return OversizedTruncate
+}
+
+// SplitsComments returns whether the GitHub comments too long for JIRA are
+// split across several JIRA comments.
+func (c Config) SplitsComments() bool {
+	return c.GetOversizedText() == OversizedSplit
+}
+
+// openMacro returns the name of the code or noformat macro left open at the
+// end of a text, its opening tag, such as {code:java}, and the index of the
+// rune the tag starts at. The name is empty if none is.
+func openMacro(text string) (string, string, int) {
+	name, open, start := "", "", 0
+	for _, m := range regexMacro.FindAllStringSubmatchIndex(text, -1) {
+		tag := text[m[2]:m[3]]
+		if name == "" {
+			name, open, start = tag, text[m[0]:m[1]], utf8.RuneCountInString(text[:m[0]])
+		} else if tag == name {
+			name = ""
+		}
+	}
+	return name, open, start
+}
+
+// safeBoundary returns how many of the runes of a text to keep so that at
+// most n are: the text is cut after the last paragraph break, or else line
+// break, or else space, in the second half of those n runes, and before a
+// code or noformat macro left open there.
+func safeBoundary(runes []rune, n int) int {
+	if len(runes) <= n {
+		return len(runes)
+	}
+
+	cut := n
+	window := string(runes[n/2 : n])
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(window, sep); i >= 0 {
+			cut = n/2 + utf8.RuneCountInString(window[:i]) + len(sep)
+			break
+		}
+	}
+	if name, _, start := openMacro(string(runes[:cut])); name != "" && start >= n/2 {
+		cut = start
+	}
+	return cut
+}
+
+// TruncateJIRAText truncates a text, such as a description or a comment
+// body, so that it fits the text fields of the JIRA instance of the
+// configuration with a notice linking to the rest of it on GitHub, at the
+// URL. It's cut at a safe boundary, and the code or noformat macro left open
+// is closed. Without a URL, it's merely truncated, like LimitJIRAText.
+func (c Config) TruncateJIRAText(text, url string) string {
+	limit := c.GetJIRATextLimit()
+	if limit <= 0 || len(text) <= limit || len([]rune(text)) <= limit {
+		return text
+	}
+
+	notice := fmt.Sprintf(truncationNotice, url)
+	n := limit - len([]rune(notice)) - len("\n{noformat}")
+	if url == "" || n < 2 {
+		return c.LimitJIRAText(text)
+	}
+
+	runes := []rune(text)
+	kept := strings.TrimRight(string(runes[:safeBoundary(runes, n)]), " \t\n")
+	if name, _, _ := openMacro(kept); name != "" {
+		kept += "\n{" + name + "}"
+	}
+	return kept + notice
+}
+
+// TrimTruncationNotice returns a text without the notice TruncateJIRAText
+// ends it with, and whether it had one.
+func TrimTruncationNotice(text string) (string, bool) {
+	loc := regexTruncationNotice.FindStringIndex(text)
+	if loc == nil {
+		return text, false
+	}
+	return text[:loc[0]], true
+}
+
+// SplitJIRAText splits a text, such as a comment body, into parts which fit
+// the text fields of the JIRA instance of the configuration, cut at safe
+// boundaries. Each part but the last ends with a notice that it's continued,
+// and each part but the first starts with a header; a code or noformat
+// macro cut in two is closed at the end of a part and opened again at the
+// beginning of the next one. JoinJIRAText puts them back together.
+func (c Config) SplitJIRAText(text string) []string {
+	limit := c.GetJIRATextLimit()
+	if limit <= 0 || len(text) <= limit || len([]rune(text)) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	reopen := ""
+	for runes := []rune(text); len(runes) > 0; {
+		part := ""
+		if len(parts) > 0 {
+			part = continuationHeader + reopen
+		}
+		n := limit - len([]rune(part)) - len(continuedNotice) - len("\n{noformat}")
+		if n < 2 {
+			return []string{c.LimitJIRAText(text)}
+		}
+
+		cut := safeBoundary(runes, n)
+		part += string(runes[:cut])
+		runes = runes[cut:]
+		if len(runes) > 0 {
+			name, open, _ := openMacro(strings.TrimPrefix(part, continuationHeader))
+			if reopen = ""; name != "" {
+				part += "\n{" + name + "}"
+				reopen = open + "\n"
+			}
+			part += continuedNotice
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// regexReopenedMacro matches the macro opened again at the beginning of a
+// part of a split text.
+var regexReopenedMacro = regexp.MustCompile(`^\{(?:code|noformat)(?::[^}\n]*)?\}\n`)
+
+// regexClosedMacro matches the macro closed at the end of a part of a split
+// text, before its notice.
+var regexClosedMacro = regexp.MustCompile(`\n\{(?:code|noformat)\}$`)
+
+// JoinJIRAText returns the text which SplitJIRAText split into the parts.
+func JoinJIRAText(parts []string) string {
+	var text strings.Builder
+	closed := false
+	for _, part := range parts {
+		part = strings.TrimPrefix(part, continuationHeader)
+		if closed {
+			part = regexReopenedMacro.ReplaceAllString(part, "")
+		}
+		closed = false
+		if strings.HasSuffix(part, continuedNotice) {
+			part = strings.TrimSuffix(part, continuedNotice)
+			if loc := regexClosedMacro.FindStringIndex(part); loc != nil {
+				part, closed = part[:loc[0]], true
+			}
+		}
+		text.WriteString(part)
+	}
+	return text.String()
+}
+
+// validateOversized checks the way the GitHub comments too long for JIRA
+// are synced.
+func (c Config) validateOversized() error {
+	switch c.GetOversizedText() {
+	case OversizedTruncate, OversizedSplit:
+		return nil
+	}
+	return errors.New("oversized text must be truncate or split")
+}
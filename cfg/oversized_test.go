@@ -0,0 +1,128 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// textLimitConfig returns a configuration whose JIRA instance limits text
+// fields to the number of characters.
+func textLimitConfig(limit int) Config {
+	return Config{deployments: map[string]jiraDeployment{"": {textLimit: limit}}}
+}
+
+// TestSplitJIRAText checks that the parts of a split text fit the limit,
+// don't leave a macro open, and are joined back into the text.
+func TestSplitJIRAText(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		text  string
+	}{
+		{"short", 100, "a short comment"},
+		{"paragraphs", 100, strings.Repeat("A paragraph of a long comment.\n\n", 12)},
+		{"single line", 100, strings.Repeat("word ", 80)},
+		{"no spaces", 100, strings.Repeat("x", 350)},
+		{"code macro", 120, "Before the code:\n\n{code:java}\n" + strings.Repeat("int x = 1;\n", 40) + "{code}\n\nAfter the code."},
+		{"noformat macro", 120, "{noformat}\n" + strings.Repeat("raw text\n", 40) + "{noformat}"},
+		{"multi-byte runes", 100, strings.Repeat("é", 99) + " " + strings.Repeat("日本語 ", 60)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parts := textLimitConfig(test.limit).SplitJIRAText(test.text)
+			for i, part := range parts {
+				if n := utf8.RuneCountInString(part); n > test.limit {
+					t.Errorf("part %d has %d characters, more than %d:\n%s", i, n, test.limit, part)
+				}
+				if !utf8.ValidString(part) {
+					t.Errorf("part %d isn't valid UTF-8: %q", i, part)
+				}
+				if name, _, _ := openMacro(strings.TrimPrefix(part, continuationHeader)); name != "" {
+					t.Errorf("part %d leaves {%s} open:\n%s", i, name, part)
+				}
+				if i > 0 && !strings.HasPrefix(part, continuationHeader) {
+					t.Errorf("part %d doesn't start with the continuation header:\n%s", i, part)
+				}
+				if i < len(parts)-1 && !strings.HasSuffix(part, continuedNotice) {
+					t.Errorf("part %d doesn't end with the continued notice:\n%s", i, part)
+				}
+			}
+			if joined := JoinJIRAText(parts); joined != test.text {
+				t.Errorf("JoinJIRAText(SplitJIRAText(text)) =\n%q\nwant\n%q", joined, test.text)
+			}
+		})
+	}
+}
+
+// TestSplitJIRATextReopensMacro checks that a code macro cut in two is
+// closed at the end of a part, and opened again with its parameters at the
+// beginning of the next one.
+func TestSplitJIRATextReopensMacro(t *testing.T) {
+	text := "{code:java}\n" + strings.Repeat("int x = 1;\n", 40) + "{code}"
+	parts := textLimitConfig(120).SplitJIRAText(text)
+	if len(parts) < 2 {
+		t.Fatalf("SplitJIRAText returned %d part, want several", len(parts))
+	}
+
+	for i, part := range parts {
+		if i < len(parts)-1 && !strings.HasSuffix(part, "\n{code}"+continuedNotice) {
+			t.Errorf("part %d doesn't close the code macro:\n%s", i, part)
+		}
+		if i > 0 && !strings.HasPrefix(part, continuationHeader+"{code:java}\n") {
+			t.Errorf("part %d doesn't open the code macro again:\n%s", i, part)
+		}
+	}
+}
+
+// TestTruncateJIRAText checks that a truncated text fits the limit, isn't
+// left in a macro, and is a prefix of the text once its notice is trimmed.
+func TestTruncateJIRAText(t *testing.T) {
+	const url = "https://github.com/coreos/issue-sync/issues/1"
+
+	tests := []struct {
+		name      string
+		limit     int
+		text      string
+		truncated bool
+	}{
+		{"short", 200, "a short description", false},
+		{"multi-byte runes at the limit", 200, strings.Repeat("日", 200), false},
+		{"paragraphs", 200, strings.Repeat("A paragraph of a long description.\n\n", 12), true},
+		{"multi-byte runes over the limit", 200, strings.Repeat("日本語 ", 100), true},
+		{"code macro", 200, "Some code:\n\n{code:java}\n" + strings.Repeat("int x = 1;\n", 40) + "{code}", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			truncated := textLimitConfig(test.limit).TruncateJIRAText(test.text, url)
+			if n := utf8.RuneCountInString(truncated); n > test.limit {
+				t.Errorf("truncated text has %d characters, more than %d:\n%s", n, test.limit, truncated)
+			}
+			if !utf8.ValidString(truncated) {
+				t.Errorf("truncated text isn't valid UTF-8: %q", truncated)
+			}
+
+			kept, ok := TrimTruncationNotice(truncated)
+			if ok != test.truncated {
+				t.Fatalf("TrimTruncationNotice found a notice: %v, want %v:\n%s", ok, test.truncated, truncated)
+			}
+			if !ok {
+				if truncated != test.text {
+					t.Errorf("TruncateJIRAText changed a text which fits:\n%q", truncated)
+				}
+				return
+			}
+			if name, _, _ := openMacro(truncated); name != "" {
+				t.Errorf("truncated text leaves {%s} open:\n%s", name, truncated)
+			}
+			if !strings.HasSuffix(truncated, url+"].)_") {
+				t.Errorf("truncated text doesn't link to %s:\n%s", url, truncated)
+			}
+			if kept == "" || !strings.HasPrefix(test.text, kept) {
+				t.Errorf("TrimTruncationNotice returned %q, which doesn't start the text", kept)
+			}
+		})
+	}
+}
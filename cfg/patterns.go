@@ -0,0 +1,184 @@
+package cfg
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// isRepoPattern returns whether the repo of a project is a glob pattern of
+// the repos of its owner, such as "myorg/*".
+func isRepoPattern(repo string) bool {
+	return strings.ContainsAny(repo, "*?[")
+}
+
+// validateRepoPattern checks that only the name of the repo of a project is
+// a pattern, that its patterns are valid, and that only a pattern has
+// exclusions or topics.
+func validateRepoPattern(project Project) error {
+	parts := strings.Split(project.Repo, "/")
+	if isRepoPattern(parts[0]) {
+		return errors.New("has a pattern for the owner of its repo; only the repo name may be one")
+	}
+	if !isRepoPattern(project.Repo) {
+		if len(project.Exclude) > 0 || len(project.Topics) > 0 {
+			return errors.New("excludes repos or selects them by topic, but its repo isn't a pattern")
+		}
+		return nil
+	}
+	for _, pattern := range append([]string{parts[1]}, project.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New("has a bad repo pattern: " + pattern)
+		}
+	}
+	return nil
+}
+
+// matchesRepo returns whether a repo, in owner/repo form, with the topics,
+// is matched by the repo pattern of the project, carries one of its topics,
+// if any, and isn't matched by one of its exclusions. Repos are compared
+// regardless of case, like GitHub does; topics are lower case.
+func (p Project) matchesRepo(repo string, topics []string) bool {
+	if len(p.Topics) > 0 && !hasTopic(topics, p.Topics) {
+		return false
+	}
+	pattern := strings.ToLower(p.Repo)
+	repo = strings.ToLower(repo)
+	if ok, _ := path.Match(pattern, repo); !ok {
+		return false
+	}
+	owner := strings.SplitN(pattern, "/", 2)[0]
+	for _, exclude := range p.Exclude {
+		exclude = strings.ToLower(exclude)
+		if !strings.Contains(exclude, "/") {
+			exclude = owner + "/" + exclude
+		}
+		if ok, _ := path.Match(exclude, repo); ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTopic returns whether one of the topics is one of those wanted.
+func hasTopic(topics, wanted []string) bool {
+	for _, topic := range topics {
+		for _, w := range wanted {
+			if strings.EqualFold(topic, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getConfiguredProjects returns the projects as configured, with their repo
+// patterns rather than the repos they were resolved to.
+func (c Config) getConfiguredProjects() []Project {
+	if c.configuredProjects != nil && *c.configuredProjects != nil {
+		return *c.configuredProjects
+	}
+	var projects []Project
+	c.cmdConfig.UnmarshalKey("projects", &projects)
+	return projects
+}
+
+// GetRepoPatternOwners returns the owners of the repos matched by the repo
+// patterns of the projects, such as "myorg" for "myorg/*".
+func (c Config) GetRepoPatternOwners() []string {
+	seen := make(map[string]bool)
+	var owners []string
+	for _, project := range c.getConfiguredProjects() {
+		if !isRepoPattern(project.Repo) {
+			continue
+		}
+		owner := strings.ToLower(strings.SplitN(project.Repo, "/", 2)[0])
+		if !seen[owner] {
+			seen[owner] = true
+			owners = append(owners, owner)
+		}
+	}
+	return owners
+}
+
+// resolveRepoPatterns returns the projects, with those whose repo is a
+// pattern replaced by a copy for each of the repos matching it, in order. A
+// repo which has a project of its own, or matches an earlier pattern, isn't
+// added again. The repos are mapped to their topics.
+func (c Config) resolveRepoPatterns(repos map[string][]string) []Project {
+	projects := c.getConfiguredProjects()
+
+	names := make([]string, 0, len(repos))
+	for repo := range repos {
+		names = append(names, repo)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]bool)
+	for _, project := range projects {
+		if !isRepoPattern(project.Repo) {
+			seen[strings.ToLower(project.Repo)] = true
+		}
+	}
+
+	var resolved []Project
+	for _, project := range projects {
+		if !isRepoPattern(project.Repo) {
+			resolved = append(resolved, project)
+			continue
+		}
+		for _, repo := range names {
+			if seen[strings.ToLower(repo)] || !project.matchesRepo(repo, repos[repo]) {
+				continue
+			}
+			seen[strings.ToLower(repo)] = true
+			p := project
+			p.Repo = repo
+			p.Pattern = project.Repo
+			p.Exclude = nil
+			p.Topics = nil
+			resolved = append(resolved, p)
+		}
+	}
+	return resolved
+}
+
+// ResolveRepoPatterns replaces the projects whose repo is a pattern by a
+// project for each of the repos given, mapped to their topics, which match
+// it, with the same options. It must be called before the JIRA project keys are inferred and
+// LoadJIRAConfig, and again to pick up the repos created since; the
+// configuration file keeps the patterns when it's saved.
+func (c *Config) ResolveRepoPatterns(repos map[string][]string) error {
+	configured := c.getConfiguredProjects()
+	resolved := c.resolveRepoPatterns(repos)
+
+	if err := c.loadProjects(resolved); err != nil {
+		return err
+	}
+	if err := c.validateInstances(resolved); err != nil {
+		return err
+	}
+
+	*c.configuredProjects = configured
+	c.cmdConfig.Set("projects", resolved)
+	return nil
+}
+
+// RepoPatternsChanged returns whether the repos given, which are those of
+// the owners of the repo patterns mapped to their topics, would resolve the
+// patterns to other repos than those they were resolved to.
+func (c Config) RepoPatternsChanged(repos map[string][]string) bool {
+	var current []Project
+	c.cmdConfig.UnmarshalKey("projects", &current)
+	resolved := c.resolveRepoPatterns(repos)
+	if len(current) != len(resolved) {
+		return true
+	}
+	for i := range current {
+		if !strings.EqualFold(current[i].Repo, resolved[i].Repo) {
+			return true
+		}
+	}
+	return false
+}
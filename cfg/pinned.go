@@ -0,0 +1,59 @@
+package cfg
+
+import (
+	"errors"
+	"strings"
+)
+
+// Ways the JIRA issues of the GitHub issues pinned to their repo are marked,
+// as configured in `pinned-issues`.
+const (
+	PinnedFlag  = "flag"
+	PinnedLabel = "label"
+)
+
+// defaultPinnedLabel is the JIRA label of the JIRA issues of pinned GitHub
+// issues, unless another one is configured in `pinned-label`.
+const defaultPinnedLabel = "github-pinned"
+
+// flaggedFieldName is the name of the "Flagged" field of JIRA Software,
+// which marks issues as impediments on the boards.
+const flaggedFieldName = "Flagged"
+
+// FlaggedValue is the value of the "Flagged" field of flagged issues.
+const FlaggedValue = "Impediment"
+
+// GetPinnedAction returns how the JIRA issues of the pinned GitHub issues are
+// marked: with the "Flagged" field, or with a label, or an empty action if
+// they aren't.
+func (c Config) GetPinnedAction() string {
+	return c.cmdConfig.GetString("pinned-issues")
+}
+
+// GetPinnedLabel returns the JIRA label of the JIRA issues of the pinned
+// GitHub issues, with the label `pinned-issues`.
+func (c Config) GetPinnedLabel() string {
+	if label := strings.TrimSpace(c.cmdConfig.GetString("pinned-label")); label != "" {
+		return label
+	}
+	return defaultPinnedLabel
+}
+
+// validatePinned checks the way the JIRA issues of the pinned GitHub issues
+// are marked, that the issues marked can be remembered in the state file,
+// and that their label is a valid JIRA label.
+func (c Config) validatePinned() error {
+	switch c.GetPinnedAction() {
+	case "":
+	case PinnedFlag, PinnedLabel:
+		if c.cmdConfig.GetString("state-file") == "" {
+			return errors.New("pinned-issues requires a state-file")
+		}
+	default:
+		return errors.New("pinned issues must be flag or label")
+	}
+	if strings.ContainsAny(c.GetPinnedLabel(), " \t") {
+		return errors.New("pinned-label can't contain spaces")
+	}
+	return nil
+}
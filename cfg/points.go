@@ -0,0 +1,69 @@
+package cfg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// storyPointsKey is the configuration option mapping GitHub labels to the
+// story points of their JIRA issues.
+const storyPointsKey = "story-points"
+
+// defaultStoryPointsField is the name of the story points field of JIRA
+// Software.
+const defaultStoryPointsField = "Story Points"
+
+// storyPoints returns the story points of `story-points`, by lowercased
+// label. The values are validated by validateStoryPoints.
+func (c Config) storyPoints() map[string]float64 {
+	points := make(map[string]float64)
+	for label, value := range c.cmdConfig.GetStringMap(storyPointsKey) {
+		if p, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(value)), 64); err == nil {
+			points[strings.ToLower(label)] = p
+		}
+	}
+	return points
+}
+
+// UsesStoryPoints returns whether the story points of the JIRA issues are
+// set from the labels of their GitHub issue.
+func (c Config) UsesStoryPoints() bool {
+	return len(c.cmdConfig.GetStringMap(storyPointsKey)) > 0
+}
+
+// GetStoryPoints returns the story points of a GitHub issue with the
+// labels, in `story-points`, and whether one of them has any. The labels
+// are compared regardless of their case; if several have story points,
+// the highest are returned.
+func (c Config) GetStoryPoints(labels []string) (float64, bool) {
+	points := c.storyPoints()
+
+	max, ok := 0.0, false
+	for _, label := range labels {
+		if p, found := points[strings.ToLower(label)]; found && (!ok || p > max) {
+			max, ok = p, true
+		}
+	}
+	return max, ok
+}
+
+// getStoryPointsField returns the name or ID of the JIRA field the story
+// points are written to.
+func (c Config) getStoryPointsField() string {
+	if ref := c.cmdConfig.GetString("story-points-field"); ref != "" {
+		return ref
+	}
+	return defaultStoryPointsField
+}
+
+// validateStoryPoints checks that the story points of `story-points` are
+// numbers.
+func (c Config) validateStoryPoints() error {
+	for label, value := range c.cmdConfig.GetStringMap(storyPointsKey) {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(value)), 64); err != nil {
+			return fmt.Errorf("story-points of label %s must be a number; got %v", label, value)
+		}
+	}
+	return nil
+}
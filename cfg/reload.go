@@ -0,0 +1,94 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// WatchConfigFile watches the configuration file, and returns a channel
+// which receives a value when it has been written since the last value was
+// received; several writes in a row are thus reported once.
+func (c Config) WatchConfigFile() (<-chan struct{}, error) {
+	if c.cmdFile == "" {
+		return nil, errors.New("no configuration file to watch")
+	}
+	path := filepath.Clean(c.cmdFile)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// The directory is watched rather than the file, so that the file is
+	// still watched when an editor replaces it instead of writing to it.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events:
+				if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				c.log.WithField("file", event.Name).Info("config file changed")
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case err := <-watcher.Errors:
+				c.log.Errorf("Error watching config file: %v", err)
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// Reload creates a new configuration object from the command line and the
+// current content of the configuration file, and validates it. The
+// passphrase of the configuration file and the `since` times recorded by
+// this configuration carry over to the new one. The JIRA configuration is
+// not yet initialized.
+// Unlike at startup, a configuration file which can't be read or parsed,
+// such as one still being written by an editor, is an error, rather than
+// leaving only the command line and the environment.
+func (c Config) Reload(cmd *cobra.Command) (Config, error) {
+	var keys []string
+	if c.cmdFile != "" {
+		v := viper.New()
+		v.SetConfigFile(c.cmdFile)
+		if err := v.ReadInConfig(); err != nil {
+			return Config{}, fmt.Errorf("unable to read config file %s: %v", c.cmdFile, err)
+		}
+		for key := range v.AllSettings() {
+			keys = append(keys, key)
+		}
+	}
+
+	config := LoadConfig(cmd)
+
+	// The file may have changed again since it was read above.
+	for _, key := range keys {
+		if !config.cmdConfig.InConfig(key) {
+			return Config{}, fmt.Errorf("config file %s changed while it was reloaded", c.cmdFile)
+		}
+	}
+	config.cipher = c.cipher
+	config.projectSince = c.projectSince
+	config.projectSinceLock = c.projectSinceLock
+	config.ctx = c.ctx
+
+	if err := config.validateConfig(); err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
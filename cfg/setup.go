@@ -0,0 +1,273 @@
+package cfg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// customField describes a custom field issue-sync requires, as it is created
+// by SetupFields.
+type customField struct {
+	key fieldKey
+	// option is the key of the field in `custom-fields`, and name is its
+	// default name.
+	option      string
+	name        string
+	description string
+	// fieldType and searcher are the keys of the JIRA custom field type and
+	// search template of the field.
+	fieldType string
+	searcher  string
+}
+
+// Keys of the JIRA custom field types and searchers used by issue-sync.
+const (
+	numberFieldType   = "com.atlassian.jira.plugin.system.customfieldtypes:float"
+	numberSearcher    = "com.atlassian.jira.plugin.system.customfieldtypes:exactnumber"
+	textFieldType     = "com.atlassian.jira.plugin.system.customfieldtypes:textfield"
+	textSearcher      = "com.atlassian.jira.plugin.system.customfieldtypes:textsearcher"
+	dateTimeFieldType = "com.atlassian.jira.plugin.system.customfieldtypes:datetime"
+	dateTimeSearcher  = "com.atlassian.jira.plugin.system.customfieldtypes:datetimerange"
+)
+
+// Keys of the custom field types of JIRA Software (on JIRA Server) holding
+// the epic of an issue and the name of an epic.
+const (
+	epicLinkFieldType = "com.pyxis.greenhopper.jira:gh-epic-link"
+	epicNameFieldType = "com.pyxis.greenhopper.jira:gh-epic-label"
+)
+
+// customFields is the list of the custom fields required by issue-sync.
+var customFields = []customField{
+	{GitHubID, "github-id", "GitHub ID", "ID of the GitHub issue synchronized by issue-sync", numberFieldType, numberSearcher},
+	{GitHubNumber, "github-number", "GitHub Number", "Number of the GitHub issue synchronized by issue-sync", numberFieldType, numberSearcher},
+	{GitHubLabels, "github-labels", "GitHub Labels", "Labels of the GitHub issue synchronized by issue-sync", textFieldType, textSearcher},
+	{GitHubStatus, "github-status", "GitHub Status", "State of the GitHub issue synchronized by issue-sync", textFieldType, textSearcher},
+	{GitHubReporter, "github-reporter", "GitHub Reporter", "Author of the GitHub issue synchronized by issue-sync", textFieldType, textSearcher},
+	{LastISUpdate, "last-update", "Last Issue-Sync Update", "Time issue-sync last updated the issue", dateTimeFieldType, dateTimeSearcher},
+	{GitHubVotes, "github-votes", "GitHub Votes", "Number of thumbs up reactions to the GitHub issue synchronized by issue-sync", numberFieldType, numberSearcher},
+}
+
+// jiraScreen represents a JIRA screen, or a tab of a screen.
+type jiraScreen struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// do sends an API request to JIRA, decoding the response into v if it isn't
+// nil. If the request fails, the error includes the body of the response,
+// which explains why JIRA rejected it.
+func do(client jira.Client, req *http.Request, v interface{}) error {
+	res, err := client.Do(req, v)
+	if res != nil && (v == nil || err != nil) {
+		defer res.Body.Close()
+	}
+	if err != nil && res != nil {
+		if body, rerr := ioutil.ReadAll(res.Body); rerr == nil && len(body) > 0 {
+			return fmt.Errorf("%v: %s", err, body)
+		}
+	}
+	return err
+}
+
+// SetupFields creates the custom fields required by issue-sync which don't
+// exist yet, using the JIRA administration API, and adds every one of them
+// to the screens given by ID. If no screen is given, they're added to the
+// screens of the projects of the JIRA instance, which are named after their key; if
+// those can't be found, they're added to the default screen.
+func (c Config) SetupFields(client jira.Client, screens []int) error {
+	jFields := new([]jiraField)
+	req, err := client.NewRequest("GET", "rest/api/2/field", nil)
+	if err != nil {
+		return err
+	}
+	if err := do(client, req, jFields); err != nil {
+		return fmt.Errorf("could not retrieve the JIRA fields: %v", err)
+	}
+
+	var ids []string
+	created := false
+	for _, field := range customFields {
+		if !c.isRequiredField(field.key) {
+			continue
+		}
+		// Fields are created with their configured name; fields configured
+		// by ID must exist already.
+		field.name = c.customFieldRef(field)
+		id, ok := "", false
+		for _, f := range *jFields {
+			if f.Custom && f.matches(field.name) {
+				id, ok = f.ID, true
+				break
+			}
+		}
+		if ok {
+			c.log.Infof("Custom field %s already exists (ID %s)", field.name, id)
+		} else if _, byID := fieldRefID(field.name); byID {
+			return fmt.Errorf("custom field %s doesn't exist", field.name)
+		} else {
+			id, err = c.createField(client, field)
+			if err != nil {
+				return err
+			}
+			c.log.Infof("Created custom field %s (ID %s)", field.name, id)
+			created = true
+		}
+		ids = append(ids, id)
+	}
+
+	// The cached list of fields doesn't have the new ones.
+	if created {
+		c.state.clearMetadata()
+		if err := c.state.Save(); err != nil {
+			return err
+		}
+	}
+
+	if len(screens) == 0 {
+		screens, err = c.projectScreens(client)
+		if err != nil {
+			c.log.Warnf("Could not list the JIRA screens: %v", err)
+		}
+	}
+	if len(screens) == 0 {
+		c.log.Info("No screen of the projects was found; adding the fields to the default screen")
+		for _, id := range ids {
+			req, err := client.NewRequest("POST", fmt.Sprintf("rest/api/2/screens/addToDefault/%s", id), nil)
+			if err != nil {
+				return err
+			}
+			if err := do(client, req, nil); err != nil {
+				return fmt.Errorf("could not add field %s to the default screen: %v", id, err)
+			}
+		}
+		return nil
+	}
+
+	for _, screen := range screens {
+		if err := c.addToScreen(client, screen, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createField creates a custom field, and returns its ID.
+func (c Config) createField(client jira.Client, field customField) (string, error) {
+	req, err := client.NewRequest("POST", "rest/api/2/field", struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Type        string `json:"type"`
+		SearcherKey string `json:"searcherKey"`
+	}{field.name, field.description, field.fieldType, field.searcher})
+	if err != nil {
+		return "", err
+	}
+	created := new(jiraField)
+	if err := do(client, req, created); err != nil {
+		return "", fmt.Errorf("could not create custom field %s; check that the JIRA user is an administrator: %v", field.name, err)
+	}
+	return created.ID, nil
+}
+
+// projectScreens returns the IDs of the screens named after the key of one
+// of the projects of the JIRA instance, as JIRA names the screens it creates along
+// with a project (e.g. "ABC: Scrum Default Issue Screen").
+func (c Config) projectScreens(client jira.Client) ([]int, error) {
+	var prefixes []string
+	for _, project := range c.instanceProjects() {
+		key := project.Key
+		if key == "" {
+			key = c.inferredKeys[project.Repo]
+		}
+		if key != "" {
+			prefixes = append(prefixes, key+":")
+		}
+	}
+
+	var screens []int
+	for startAt := 0; ; {
+		req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/screens?startAt=%d", startAt), nil)
+		if err != nil {
+			return nil, err
+		}
+		page := new(struct {
+			IsLast bool         `json:"isLast"`
+			Values []jiraScreen `json:"values"`
+		})
+		if err := do(client, req, page); err != nil {
+			return nil, err
+		}
+
+		for _, screen := range page.Values {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(screen.Name, prefix) {
+					c.log.Debugf("Found screen %s (ID %d)", screen.Name, screen.ID)
+					screens = append(screens, screen.ID)
+					break
+				}
+			}
+		}
+
+		if page.IsLast || len(page.Values) == 0 {
+			break
+		}
+		startAt += len(page.Values)
+	}
+
+	return screens, nil
+}
+
+// addToScreen adds the fields which aren't on the screen yet to its first tab.
+func (c Config) addToScreen(client jira.Client, screen int, ids []string) error {
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/screens/%d/tabs", screen), nil)
+	if err != nil {
+		return err
+	}
+	tabs := new([]jiraScreen)
+	if err := do(client, req, tabs); err != nil {
+		return fmt.Errorf("could not retrieve the tabs of screen %d: %v", screen, err)
+	}
+	if len(*tabs) == 0 {
+		return fmt.Errorf("screen %d has no tab to add the fields to", screen)
+	}
+	tab := (*tabs)[0].ID
+
+	req, err = client.NewRequest("GET", fmt.Sprintf("rest/api/2/screens/%d/tabs/%d/fields", screen, tab), nil)
+	if err != nil {
+		return err
+	}
+	onTab := new([]struct {
+		ID string `json:"id"`
+	})
+	if err := do(client, req, onTab); err != nil {
+		return fmt.Errorf("could not retrieve the fields of screen %d: %v", screen, err)
+	}
+	present := make(map[string]bool)
+	for _, f := range *onTab {
+		present[f.ID] = true
+	}
+
+	for _, id := range ids {
+		if present[id] {
+			continue
+		}
+		req, err := client.NewRequest("POST", fmt.Sprintf("rest/api/2/screens/%d/tabs/%d/fields", screen, tab), struct {
+			FieldID string `json:"fieldId"`
+		}{id})
+		if err != nil {
+			return err
+		}
+		if err := do(client, req, nil); err != nil {
+			return fmt.Errorf("could not add field %s to screen %d: %v", id, screen, err)
+		}
+		c.log.Infof("Added field %s to screen %d", id, screen)
+	}
+
+	return nil
+}
@@ -0,0 +1,615 @@
+package cfg
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IssueState is what issue-sync remembers about a GitHub issue it has synchronized.
+type IssueState struct {
+	// JIRAKey and JIRAID identify the JIRA issue the GitHub issue is synchronized to.
+	JIRAKey string `json:"jira-key"`
+	JIRAID  string `json:"jira-id"`
+	// Repo is the GitHub repo of the issue; it isn't recorded by earlier
+	// versions of issue-sync.
+	Repo string `json:"repo,omitempty"`
+	// Hash is a hash of the content of the GitHub issue when it was last synchronized.
+	Hash string `json:"hash"`
+	// Pushed is a hash of the summary, the description, and the labels last
+	// written to the JIRA issue.
+	Pushed string `json:"pushed,omitempty"`
+	// Synced is the time the GitHub issue was last synchronized.
+	Synced time.Time `json:"synced"`
+	// Conflict is whether the JIRA issue was flagged for a conflict to be
+	// resolved by a user, under the manual `conflict-strategy`.
+	Conflict bool `json:"conflict,omitempty"`
+	// Parent is the key of the JIRA issue of the parent of the GitHub issue
+	// which the JIRA issue is linked to, with the link `sub-issues`.
+	Parent string `json:"parent,omitempty"`
+	// Pinned is whether the GitHub issue was pinned to its repo when the
+	// JIRA issue was last flagged or labeled for it, with `pinned-issues`.
+	Pinned bool `json:"pinned,omitempty"`
+	// Assignee is the JIRA user the JIRA issue was last assigned to, with
+	// the `assignee-mode` first or round-robin, as the JIRA library we use
+	// doesn't decode the account ID of the assignee of an issue.
+	Assignee string `json:"assignee,omitempty"`
+}
+
+// CachedResponse is a response of a GitHub list call, kept with its
+// validators so that the call can be made conditionally by the next runs.
+type CachedResponse struct {
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last-modified,omitempty"`
+	Link         string          `json:"link,omitempty"`
+	Body         json.RawMessage `json:"body"`
+	// Used is the time the response was last stored or served.
+	Used time.Time `json:"used"`
+}
+
+// cachedResponseTTL is how long a cached response which isn't used anymore,
+// such as that of a page which no longer exists, is kept.
+const cachedResponseTTL = 30 * 24 * time.Hour
+
+// CachedUser is the profile of a GitHub user, as shown in the JIRA comments
+// and issues synced from GitHub.
+type CachedUser struct {
+	ID        int       `json:"id,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	HTMLURL   string    `json:"html-url,omitempty"`
+	AvatarURL string    `json:"avatar-url,omitempty"`
+	Fetched   time.Time `json:"fetched"`
+}
+
+// CachedUserTTL is how long the profile of a GitHub user is used before
+// it's looked up again.
+const CachedUserTTL = 24 * time.Hour
+
+// CachedMetadata is the response of a JIRA metadata request, such as the
+// list of fields, cached with `jira-metadata-ttl`.
+type CachedMetadata struct {
+	Data    json.RawMessage `json:"data"`
+	Fetched time.Time       `json:"fetched"`
+}
+
+// State is the persistent local state of issue-sync, which is kept in the
+// `state-file` between runs. It maps GitHub issues to their JIRA issues, so
+// that they are found even if their custom fields are lost, and records the
+// time of the last successful sync of each repo, the profiles of the GitHub
+// users looked up, the JIRA metadata with `jira-metadata-ttl`, the
+// responses of the GitHub list calls with `github-etag-cache`, and the
+// GitHub comments of the JIRA comments whose entity property couldn't be
+// set. A nil State is valid, and remembers nothing.
+//
+// The state is a single JSON file rather than a bbolt or SQLite database:
+// it is small enough to be read whole at startup, it is written atomically
+// by renaming a temporary file, it can be read and fixed by hand, and it
+// needs no new dependency nor cgo.
+type State struct {
+	path string
+	// fingerprint is the fingerprint of the state as it was opened.
+	fingerprint string
+
+	mu        sync.Mutex
+	Issues    map[int]IssueState        `json:"issues"`
+	Projects  map[string]time.Time      `json:"projects"`
+	Users     map[string]CachedUser     `json:"users,omitempty"`
+	Metadata  map[string]CachedMetadata `json:"jira-metadata,omitempty"`
+	Responses map[string]CachedResponse `json:"responses,omitempty"`
+	// Comments are the IDs of the GitHub comments of the JIRA comments
+	// whose entity property couldn't be set, by JIRA comment ID.
+	Comments map[string]int `json:"comments,omitempty"`
+	// CommentParts are the parts of those of the Comments which are the
+	// continuations of a GitHub comment split with the `oversized-text`
+	// split, by JIRA comment ID.
+	CommentParts map[string]int `json:"comment-parts,omitempty"`
+	// Orphans are the times the JIRA issues of each repo were last checked
+	// for orphans, with `orphan-check-interval`.
+	Orphans map[string]time.Time `json:"orphans,omitempty"`
+}
+
+// OpenState reads the state file at the path, returning an empty state if
+// it doesn't exist yet.
+func OpenState(path string) (*State, error) {
+	s := &State{
+		path:         path,
+		Issues:       make(map[int]IssueState),
+		Projects:     make(map[string]time.Time),
+		Users:        make(map[string]CachedUser),
+		Metadata:     make(map[string]CachedMetadata),
+		Responses:    make(map[string]CachedResponse),
+		Comments:     make(map[string]int),
+		CommentParts: make(map[string]int),
+		Orphans:      make(map[string]time.Time),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("unable to parse state file %s: %v", path, err)
+	}
+	if s.Issues == nil {
+		s.Issues = make(map[int]IssueState)
+	}
+	if s.Projects == nil {
+		s.Projects = make(map[string]time.Time)
+	}
+	if s.Users == nil {
+		s.Users = make(map[string]CachedUser)
+	}
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]CachedMetadata)
+	}
+	if s.Responses == nil {
+		s.Responses = make(map[string]CachedResponse)
+	}
+	if s.Comments == nil {
+		s.Comments = make(map[string]int)
+	}
+	if s.CommentParts == nil {
+		s.CommentParts = make(map[string]int)
+	}
+	if s.Orphans == nil {
+		s.Orphans = make(map[string]time.Time)
+	}
+	s.fingerprint = s.hashMapping()
+	return s, nil
+}
+
+// Fingerprint returns a hash of the issue mapping and of the `since` times
+// of the state as it was opened or last saved, which changes whenever a
+// synchronization is saved, so that a plan made against an older state can be told apart.
+func (s *State) Fingerprint() string {
+	if s == nil {
+		return ""
+	}
+	return s.fingerprint
+}
+
+// hashMapping returns a hash of the issue mapping and of the `since` times
+// of the state. Maps are marshalled with sorted keys, so the hash doesn't
+// depend on their order.
+func (s *State) hashMapping() string {
+	b, _ := json.Marshal(struct {
+		Issues   map[int]IssueState   `json:"issues"`
+		Projects map[string]time.Time `json:"projects"`
+	}{s.Issues, s.Projects})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// GetIssue returns the state of the GitHub issue with the ID, and whether
+// the issue was ever synchronized.
+func (s *State) GetIssue(id int) (IssueState, bool) {
+	if s == nil {
+		return IssueState{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	issue, ok := s.Issues[id]
+	return issue, ok
+}
+
+// SetIssue records the state of the GitHub issue with the ID.
+func (s *State) SetIssue(id int, issue IssueState) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Issues[id] = issue
+}
+
+// ListIssues returns a copy of the state of every GitHub issue which was
+// synchronized, by ID.
+func (s *State) ListIssues() map[int]IssueState {
+	issues := make(map[int]IssueState)
+	if s == nil {
+		return issues
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, issue := range s.Issues {
+		issues[id] = issue
+	}
+	return issues
+}
+
+// DeleteIssue forgets the GitHub issue with the ID, for instance because
+// its JIRA issue no longer exists.
+func (s *State) DeleteIssue(id int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Issues, id)
+}
+
+// GetComment returns the ID of the GitHub comment a JIRA comment is synced
+// from, if it's recorded in the state rather than in its entity property.
+func (s *State) GetComment(jiraID string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.Comments[jiraID]
+	return id, ok
+}
+
+// GetCommentPart returns the ID of the GitHub comment a JIRA comment is
+// synced from, if it's recorded in the state, and which part of it the JIRA
+// comment is: 0 for the comment, and then 1, 2, and so on for its
+// continuations.
+func (s *State) GetCommentPart(jiraID string) (int, int, bool) {
+	if s == nil {
+		return 0, 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.Comments[jiraID]
+	return id, s.CommentParts[jiraID], ok
+}
+
+// SetComment records the ID of the GitHub comment a JIRA comment is synced
+// from.
+func (s *State) SetComment(jiraID string, githubID int) {
+	s.SetCommentPart(jiraID, githubID, 0)
+}
+
+// SetCommentPart records the ID of the GitHub comment a JIRA comment is
+// synced from, and which part of it the JIRA comment is.
+func (s *State) SetCommentPart(jiraID string, githubID, part int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Comments[jiraID] = githubID
+	if part > 0 {
+		s.CommentParts[jiraID] = part
+	} else {
+		delete(s.CommentParts, jiraID)
+	}
+}
+
+// DeleteComment forgets the GitHub comment a JIRA comment is synced from,
+// once the JIRA comment was deleted.
+func (s *State) DeleteComment(jiraID string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.Comments, jiraID)
+	delete(s.CommentParts, jiraID)
+}
+
+// GetOrphansChecked returns the time the JIRA issues of the repo were last
+// checked for orphans, and whether they ever were.
+func (s *State) GetOrphansChecked(repo string) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.Orphans[repo]
+	return t, ok
+}
+
+// SetOrphansChecked records the time the JIRA issues of the repo were
+// checked for orphans.
+func (s *State) SetOrphansChecked(repo string, t time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Orphans[repo] = t
+}
+
+// getProjectSince returns the time of the last successful sync of the repo.
+func (s *State) getProjectSince(repo string) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.Projects[repo]
+	return t, ok
+}
+
+// setProjectSince records the time of the last successful sync of the repo.
+func (s *State) setProjectSince(repo string, since time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Projects[repo] = since
+}
+
+// GetUser returns the profile of the GitHub user with the login, if it was
+// looked up less than CachedUserTTL ago.
+func (s *State) GetUser(login string) (CachedUser, bool) {
+	if s == nil {
+		return CachedUser{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.Users[strings.ToLower(login)]
+	if !ok || time.Since(u.Fetched) > CachedUserTTL {
+		return CachedUser{}, false
+	}
+	return u, true
+}
+
+// SetUser records the profile of the GitHub user with the login.
+func (s *State) SetUser(login string, u CachedUser) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Users[strings.ToLower(login)] = u
+}
+
+// getMetadata returns the cached response of the JIRA metadata request with
+// the key, if it was fetched less than ttl ago.
+func (s *State) getMetadata(key string, ttl time.Duration) (json.RawMessage, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.Metadata[key]
+	if !ok || time.Since(m.Fetched) > ttl {
+		return nil, false
+	}
+	return m.Data, true
+}
+
+// setMetadata caches the response of the JIRA metadata request with the key.
+func (s *State) setMetadata(key string, data json.RawMessage) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Metadata[key] = CachedMetadata{Data: data, Fetched: time.Now()}
+}
+
+// clearMetadata forgets the cached JIRA metadata, such as after the custom
+// fields were created.
+func (s *State) clearMetadata() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Metadata = make(map[string]CachedMetadata)
+}
+
+// ClearCache forgets everything the state caches: the JIRA metadata, the
+// profiles of the GitHub users, and the responses of the GitHub list calls.
+// The issue mappings and the since times are kept.
+func (s *State) ClearCache() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Users = make(map[string]CachedUser)
+	s.Metadata = make(map[string]CachedMetadata)
+	s.Responses = make(map[string]CachedResponse)
+}
+
+// GetResponse returns the cached response of the GitHub list call with
+// the key, and marks it as used.
+func (s *State) GetResponse(key string) (CachedResponse, bool) {
+	if s == nil {
+		return CachedResponse{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.Responses[key]
+	if ok {
+		r.Used = time.Now()
+		s.Responses[key] = r
+	}
+	return r, ok
+}
+
+// SetResponse caches the response of the GitHub list call with the key.
+func (s *State) SetResponse(key string, r CachedResponse) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.Used = time.Now()
+	s.Responses[key] = r
+}
+
+// Save writes the state to the state file. The file is replaced atomically,
+// so an interrupted run can't leave a truncated state behind. The cached
+// responses which weren't used for cachedResponseTTL, and the profiles
+// which are out of date, are dropped.
+func (s *State) Save() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	for key, r := range s.Responses {
+		if time.Since(r.Used) > cachedResponseTTL {
+			delete(s.Responses, key)
+		}
+	}
+	for login, u := range s.Users {
+		if time.Since(u.Fetched) > CachedUserTTL {
+			delete(s.Users, login)
+		}
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	s.fingerprint = s.hashMapping()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".issue-sync-state")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// stateArchiveVersion is the version of the format of state archives.
+const stateArchiveVersion = 1
+
+// stateArchive is the portable representation of a State written by Export.
+type stateArchive struct {
+	Version  int                  `json:"version"`
+	Exported time.Time            `json:"exported"`
+	Issues   map[int]IssueState   `json:"issues"`
+	Projects map[string]time.Time `json:"projects"`
+	Comments map[string]int       `json:"comments,omitempty"`
+	Parts    map[string]int       `json:"comment-parts,omitempty"`
+}
+
+// Export writes the whole state to w as a gzip-compressed JSON archive,
+// which can be restored on another host with Import.
+func (s *State) Export(w io.Writer) error {
+	s.mu.Lock()
+	archive := stateArchive{
+		Version:  stateArchiveVersion,
+		Exported: time.Now(),
+		Issues:   s.Issues,
+		Projects: s.Projects,
+		Comments: s.Comments,
+		Parts:    s.CommentParts,
+	}
+	b, err := json.MarshalIndent(archive, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import reads an archive written by Export into the state. If merge is
+// false, the state is replaced by the archive; otherwise the contents of
+// the archive are added to the state, taking precedence over it. The state
+// still has to be saved.
+func (s *State) Import(r io.Reader, merge bool) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("unable to read state archive: %v", err)
+	}
+	defer gz.Close()
+
+	var archive stateArchive
+	if err := json.NewDecoder(gz).Decode(&archive); err != nil {
+		return fmt.Errorf("unable to parse state archive: %v", err)
+	}
+	if archive.Version != stateArchiveVersion {
+		return fmt.Errorf("unsupported state archive version %d", archive.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !merge {
+		s.Issues = make(map[int]IssueState)
+		s.Projects = make(map[string]time.Time)
+		s.Comments = make(map[string]int)
+		s.CommentParts = make(map[string]int)
+	}
+	for id, issue := range archive.Issues {
+		s.Issues[id] = issue
+	}
+	for repo, since := range archive.Projects {
+		s.Projects[repo] = since
+	}
+	for jiraID, githubID := range archive.Comments {
+		s.Comments[jiraID] = githubID
+		delete(s.CommentParts, jiraID)
+	}
+	for jiraID, part := range archive.Parts {
+		s.CommentParts[jiraID] = part
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package cfg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Options holding the templates of what is synced to JIRA.
+const (
+	commentTemplateKey     = "comment-template"
+	summaryTemplateKey     = "summary-template"
+	descriptionTemplateKey = "description-template"
+)
+
+// Default templates: the header of JIRA comments links to the GitHub comment
+// and user, and JIRA issues get the title and the (translated) body of their
+// GitHub issue.
+const (
+	defaultCommentTemplate     = `[Comment|{{.URL}}] from GitHub user [{{.User.Login}}|{{.User.URL}}]{{with .User.Name}} ({{.}}){{end}} at {{.Created.Format "15:04 PM, January 2 2006"}}:`
+	defaultSummaryTemplate     = `{{.Title}}`
+	defaultDescriptionTemplate = `{{.Body}}`
+)
+
+// titlePlaceholder and bodyPlaceholder stand for the title and the body of
+// an issue when a summary or a description is rendered to find them in it.
+const (
+	titlePlaceholder = "\x00"
+	bodyPlaceholder  = "\x01"
+)
+
+// GitHubUser is the GitHub user who authored an issue or a comment.
+type GitHubUser struct {
+	Login     string
+	Name      string
+	URL       string
+	AvatarURL string
+}
+
+// CommentHeader is what the header of a JIRA comment is rendered from: the
+// GitHub comment, and its author.
+type CommentHeader struct {
+	ID      int
+	URL     string
+	Created time.Time
+	Updated time.Time
+	User    GitHubUser
+}
+
+// IssueData is what the summary and the description of a JIRA issue are
+// rendered from: the GitHub issue, with its body translated to JIRA markup.
+type IssueData struct {
+	Repo      string
+	Number    int
+	Title     string
+	Body      string
+	URL       string
+	State     string
+	Labels    []string
+	Milestone string
+	Created   time.Time
+	Updated   time.Time
+	User      GitHubUser
+}
+
+// parseTemplate parses the template of an option, or its default template
+// if the option isn't set.
+func (c Config) parseTemplate(key, defaultText string) (*template.Template, error) {
+	text := c.cmdConfig.GetString(key)
+	if text == "" {
+		text = defaultText
+	}
+	return template.New(key).Parse(text)
+}
+
+// renderTemplate renders the template of an option with the data.
+func (c Config) renderTemplate(key, defaultText string, data interface{}) (string, error) {
+	t, err := c.parseTemplate(key, defaultText)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// validateTemplates checks that the templates parse, and that they only
+// reference the fields of the data they're rendered with.
+func (c Config) validateTemplates() error {
+	templates := []struct {
+		key, defaultText string
+		data             interface{}
+	}{
+		{commentTemplateKey, defaultCommentTemplate, CommentHeader{}},
+		{summaryTemplateKey, defaultSummaryTemplate, IssueData{}},
+		{descriptionTemplateKey, defaultDescriptionTemplate, IssueData{}},
+	}
+	for _, t := range templates {
+		if _, err := c.renderTemplate(t.key, t.defaultText, t.data); err != nil {
+			return fmt.Errorf("invalid %s: %v", t.key, err)
+		}
+	}
+	return nil
+}
+
+// RenderCommentHeader returns the header of the JIRA comment of a GitHub
+// comment, rendered with the `comment-template`.
+func (c Config) RenderCommentHeader(header CommentHeader) (string, error) {
+	return c.renderTemplate(commentTemplateKey, defaultCommentTemplate, header)
+}
+
+// RenderSummary returns the summary of the JIRA issue of a GitHub issue,
+// rendered with the `summary-template`.
+func (c Config) RenderSummary(issue IssueData) (string, error) {
+	return c.renderTemplate(summaryTemplateKey, defaultSummaryTemplate, issue)
+}
+
+// RenderDescription returns the description of the JIRA issue of a GitHub
+// issue, rendered with the `description-template`.
+func (c Config) RenderDescription(issue IssueData) (string, error) {
+	return c.renderTemplate(descriptionTemplateKey, defaultDescriptionTemplate, issue)
+}
+
+// SummaryTitle returns the title of a GitHub issue which a JIRA summary
+// renders to, so that a summary edited in JIRA can be copied back to GitHub
+// without what the `summary-template` adds to the title, such as a prefix.
+// If the summary doesn't match the template, it's returned as it is.
+func (c Config) SummaryTitle(issue IssueData, summary string) string {
+	issue.Title = titlePlaceholder
+	rendered, err := c.RenderSummary(issue)
+	if err != nil {
+		return summary
+	}
+	if title, ok := placeholderText(rendered, titlePlaceholder, summary); ok {
+		return title
+	}
+	return summary
+}
+
+// DescriptionBody returns the (translated) body of a GitHub issue which a
+// JIRA description renders to, without what the `description-template` adds
+// to the body, and whether the description matches the template.
+func (c Config) DescriptionBody(issue IssueData, description string) (string, bool) {
+	issue.Body = bodyPlaceholder
+	rendered, err := c.RenderDescription(issue)
+	if err != nil {
+		return "", false
+	}
+	return placeholderText(rendered, bodyPlaceholder, description)
+}
+
+// placeholderText returns the text which stands at the placeholder of a
+// rendered template, and whether the text matches the rest of the template.
+func placeholderText(rendered, placeholder, text string) (string, bool) {
+	if strings.Count(rendered, placeholder) != 1 {
+		return "", false
+	}
+
+	parts := strings.Split(rendered, placeholder)
+	if len(text) < len(parts[0])+len(parts[1]) ||
+		!strings.HasPrefix(text, parts[0]) || !strings.HasSuffix(text, parts[1]) {
+		return "", false
+	}
+	return text[len(parts[0]) : len(text)-len(parts[1])], true
+}
@@ -0,0 +1,50 @@
+package cfg
+
+import (
+	"strings"
+)
+
+// issueTypesKey is the configuration option mapping GitHub issue types and
+// labels to the JIRA issue types of their JIRA issues.
+const issueTypesKey = "issue-types"
+
+// DefaultIssueType is the JIRA issue type of the JIRA issues of GitHub
+// issues which `issue-types` doesn't map.
+const DefaultIssueType = "Task"
+
+// issueTypes returns the JIRA issue types of `issue-types`, by lowercased
+// GitHub issue type or label.
+func (c Config) issueTypes() map[string]string {
+	types := make(map[string]string)
+	for name, value := range c.cmdConfig.GetStringMapString(issueTypesKey) {
+		if value = strings.TrimSpace(value); value != "" {
+			types[strings.ToLower(name)] = value
+		}
+	}
+	return types
+}
+
+// UsesIssueTypes returns whether the JIRA issue types of the JIRA issues are
+// mapped from the type or the labels of their GitHub issue.
+func (c Config) UsesIssueTypes() bool {
+	return len(c.cmdConfig.GetStringMap(issueTypesKey)) > 0
+}
+
+// GetIssueType returns the JIRA issue type of a GitHub issue of the native
+// GitHub issue type, such as Bug or Feature, and with the labels, in
+// `issue-types`. The native type takes precedence over the labels, which
+// are tried in order; names are compared regardless of their case. Issues
+// which aren't mapped are of the DefaultIssueType.
+func (c Config) GetIssueType(ghType string, labels []string) string {
+	types := c.issueTypes()
+
+	if t, ok := types[strings.ToLower(ghType)]; ok && ghType != "" {
+		return t
+	}
+	for _, label := range labels {
+		if t, ok := types[strings.ToLower(label)]; ok {
+			return t
+		}
+	}
+	return DefaultIssueType
+}
@@ -0,0 +1,64 @@
+package cfg
+
+import (
+	"net/url"
+	"strings"
+)
+
+// userMapKey is the configuration option mapping GitHub logins to JIRA users.
+const userMapKey = "user-map"
+
+// userMap returns the users of `user-map`, by lowercased GitHub login: those
+// of the top level, overridden by those of the JIRA instance of the
+// configuration, as users differ between instances.
+func (c Config) userMap() map[string]string {
+	users := make(map[string]string)
+	for login, user := range c.cmdConfig.GetStringMapString(userMapKey) {
+		users[strings.ToLower(login)] = user
+	}
+	if c.instance != "" {
+		for login, user := range c.cmdConfig.GetStringMapString(instanceOption(c.instance, userMapKey)) {
+			users[strings.ToLower(login)] = user
+		}
+	}
+	return users
+}
+
+// GetJIRAUser returns the JIRA user of a GitHub login in `user-map`: the
+// account ID of a JIRA Cloud user, or the username of a JIRA Server user.
+func (c Config) GetJIRAUser(login string) (string, bool) {
+	user, ok := c.userMap()[strings.ToLower(login)]
+	return user, ok && user != ""
+}
+
+// GetGitHubLogin returns the GitHub login a JIRA user (an account ID or a
+// username) is mapped to in `user-map`.
+func (c Config) GetGitHubLogin(user string) (string, bool) {
+	for login, u := range c.userMap() {
+		if u == user {
+			return login, true
+		}
+	}
+	return "", false
+}
+
+// GetGitHubWebURL returns the URL of the web host of GitHub, or of GitHub
+// Enterprise Server, with a trailing slash.
+func (c Config) GetGitHubWebURL() string {
+	if uri := c.cmdConfig.GetString("github-uri"); uri != "" {
+		if u, err := url.Parse(uri); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host + "/"
+		}
+	}
+	return "https://github.com/"
+}
+
+// GetJIRAProfileURL returns the URL of the profile of a JIRA user, given by
+// account ID on JIRA Cloud, or by username on JIRA Server.
+func (c Config) GetJIRAProfileURL(user string) string {
+	uri := strings.TrimSuffix(c.GetConfigString("jira-uri"), "/")
+	if c.IsJIRACloud() {
+		return uri + "/jira/people/" + url.PathEscape(user)
+	}
+	return uri + "/secure/ViewProfile.jspa?name=" + url.QueryEscape(user)
+}
@@ -0,0 +1,221 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/spf13/cobra"
+)
+
+// jsonFields maps the JSON keys of the fields of a struct type to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	keys := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = f.Type
+		}
+	}
+	return keys
+}
+
+// checkValue reports whether a JSON value can be decoded as the type.
+// Durations may also be given as strings, such as "30s".
+func checkValue(raw json.RawMessage, t reflect.Type) error {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			_, err := time.ParseDuration(s)
+			return err
+		}
+	}
+	return json.Unmarshal(raw, reflect.New(t).Interface())
+}
+
+// CheckConfigFile checks the configuration file loaded against the schema of
+// the configuration: every key must be a known option or command line flag,
+// and the values of the saved options must have the right type. It returns
+// every problem found, rather than just the first.
+func (c Config) CheckConfigFile(cmd *cobra.Command) []error {
+	if c.cmdFile == "" {
+		return nil
+	}
+
+	b, err := readConfigFile(c.cmdFile)
+	if err != nil {
+		return []error{err}
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(b, &file); err != nil {
+		return []error{fmt.Errorf("config file %s is not a valid object: %v", c.cmdFile, err)}
+	}
+
+	var problems []error
+
+	known := jsonFields(reflect.TypeOf(configFile{}))
+	projectKeys := jsonFields(reflect.TypeOf(Project{}))
+	instanceOptions := jsonFields(reflect.TypeOf(JIRAInstance{}))
+
+	keys := make([]string, 0, len(file))
+	for key := range file {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		t, ok := known[key]
+		if !ok {
+			if cmd.Flags().Lookup(key) == nil {
+				problems = append(problems, fmt.Errorf("unknown option %q", key))
+			}
+			continue
+		}
+		if key == "projects" {
+			problems = append(problems, checkProjects(file[key], projectKeys)...)
+			continue
+		}
+		if key == instancesKey {
+			problems = append(problems, checkInstances(file[key], instanceOptions)...)
+			continue
+		}
+		if err := checkValue(file[key], t); err != nil {
+			problems = append(problems, fmt.Errorf("option %q has a bad value: %v", key, err))
+		}
+	}
+
+	return problems
+}
+
+// checkProjects checks the list of projects of the configuration file against
+// the schema of a project.
+func checkProjects(raw json.RawMessage, known map[string]reflect.Type) []error {
+	var projects []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &projects); err != nil {
+		return []error{fmt.Errorf("option \"projects\" must be a list of objects: %v", err)}
+	}
+
+	var problems []error
+	for i, project := range projects {
+		keys := make([]string, 0, len(project))
+		for key := range project {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			t, ok := known[key]
+			if !ok {
+				problems = append(problems, fmt.Errorf("project number %d has unknown option %q", i, key))
+				continue
+			}
+			if err := checkValue(project[key], t); err != nil {
+				problems = append(problems, fmt.Errorf("project number %d has a bad value for %q: %v", i, key, err))
+			}
+		}
+	}
+	return problems
+}
+
+// checkInstances checks the JIRA instances of the configuration file against
+// the schema of an instance.
+func checkInstances(raw json.RawMessage, known map[string]reflect.Type) []error {
+	var instances map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &instances); err != nil {
+		return []error{fmt.Errorf("option %q must be an object of objects: %v", instancesKey, err)}
+	}
+
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []error
+	for _, name := range names {
+		keys := make([]string, 0, len(instances[name]))
+		for key := range instances[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			t, ok := known[key]
+			if !ok {
+				problems = append(problems, fmt.Errorf("JIRA instance %s has unknown option %q", name, key))
+				continue
+			}
+			if err := checkValue(instances[name][key], t); err != nil {
+				problems = append(problems, fmt.Errorf("JIRA instance %s has a bad value for %q: %v", name, key, err))
+			}
+		}
+	}
+	return problems
+}
+
+// CheckJIRA checks the configuration against the JIRA server of its JIRA
+// instance: the credentials must be accepted, each JIRA project of the
+// instance must exist, the custom fields
+// used by issue-sync must exist, and the configured transitions must match the
+// workflows of the projects. It returns every problem found, rather than just
+// the first.
+func (c Config) CheckJIRA(client jira.Client) []error {
+	var problems []error
+
+	req, err := client.NewRequest("GET", "rest/api/2/myself", nil)
+	if err != nil {
+		return []error{err}
+	}
+	res, err := client.Do(req, nil)
+	if err != nil {
+		// Without valid credentials, every other check fails as well.
+		return []error{fmt.Errorf("could not connect to JIRA with the configured credentials: %v", err)}
+	}
+	res.Body.Close()
+
+	if err := c.detectDeployment(client); err != nil {
+		problems = append(problems, err)
+	}
+
+	projects := c.instanceProjects()
+	var keys []string
+	for i, project := range projects {
+		key := project.Key
+		if key == "" {
+			key = c.inferredKeys[project.Repo]
+		}
+		if key == "" {
+			problems = append(problems, fmt.Errorf("no JIRA project key could be found for project number %d (%s)", i, project.Repo))
+			continue
+		}
+		if _, _, err := client.Project.Get(key); err != nil {
+			problems = append(problems, fmt.Errorf("JIRA project %s of %s could not be retrieved: %v", key, project.Repo, err))
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	fieldIDs, err := c.listFieldIDs(client)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("could not retrieve the JIRA fields: %v", err))
+	} else {
+		for _, name := range c.missingFields(fieldIDs) {
+			problems = append(problems, fmt.Errorf("could not find ID of '%s' custom field; check that it is named correctly", name))
+		}
+	}
+
+	if len(c.transitions) > 0 {
+		for _, key := range keys {
+			if err := c.validateTransitions(client, key); err != nil {
+				problems = append(problems, err)
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,152 @@
+package cfg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// vaultPrefix is the prefix of the values of configuration options which
+// reference a secret in HashiCorp Vault, such as
+// `vault:secret/data/issue-sync#github-token`.
+const vaultPrefix = "vault:"
+
+// resolveVaultSecrets replaces every string option of the configuration
+// which references a Vault secret by the value of the secret. The
+// references are remembered, so that SaveConfig writes them back instead
+// of the secrets.
+func (c Config) resolveVaultSecrets() error {
+	keys := c.cmdConfig.AllKeys()
+	sort.Strings(keys)
+
+	var client *vaultClient
+	secrets := make(map[string]map[string]interface{})
+
+	for _, key := range keys {
+		value, ok := c.cmdConfig.Get(key).(string)
+		if !ok || !strings.HasPrefix(value, vaultPrefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(value, vaultPrefix), "#", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("%s must reference a Vault secret as vault:<path>#<field>", key)
+		}
+		path, field := parts[0], parts[1]
+
+		if client == nil {
+			var err error
+			if client, err = c.newVaultClient(); err != nil {
+				return err
+			}
+		}
+
+		data, ok := secrets[path]
+		if !ok {
+			var err error
+			if data, err = client.read(path); err != nil {
+				return fmt.Errorf("unable to read %s from Vault: %v", key, err)
+			}
+			secrets[path] = data
+		}
+
+		secret, ok := data[field].(string)
+		if !ok {
+			return fmt.Errorf("Vault secret %s has no string field %s for %s", path, field, key)
+		}
+
+		c.log.Debugf("Read %s from Vault secret %s", key, path)
+		c.vaultRefs[key] = value
+		c.cmdConfig.Set(key, secret)
+	}
+
+	return nil
+}
+
+// restoreVaultRefs sets the options of the configuration file about to be
+// written which were resolved from Vault back to their references.
+func (c Config) restoreVaultRefs(cf *configFile) {
+	fields := cf.stringFields()
+	for key, ref := range c.vaultRefs {
+		if field, ok := fields[key]; ok {
+			*field = ref
+		}
+	}
+}
+
+// vaultClient reads secrets from the HTTP API of a Vault server.
+type vaultClient struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+// newVaultClient creates a client for the Vault server at `vault-address`,
+// or VAULT_ADDR, authenticated with the token in VAULT_TOKEN, or else in the
+// ~/.vault-token file written by `vault login`.
+func (c Config) newVaultClient() (*vaultClient, error) {
+	address := c.cmdConfig.GetString("vault-address")
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, errors.New("Vault address required to resolve Vault secrets; set vault-address or VAULT_ADDR")
+	}
+
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		b, err := ioutil.ReadFile(filepath.Join(os.Getenv("HOME"), ".vault-token"))
+		if err != nil {
+			return nil, errors.New("Vault token required to resolve Vault secrets; set VAULT_TOKEN or run vault login")
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	return &vaultClient{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: c.GetTimeout()},
+	}, nil
+}
+
+// read returns the data of the secret at the path. Secrets of version 2 of
+// the key-value engine, whose data is nested along with their metadata, are
+// unwrapped.
+func (v *vaultClient) read(path string) (map[string]interface{}, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s", v.address, strings.TrimPrefix(path, "/")), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	secret := new(struct {
+		Data map[string]interface{} `json:"data"`
+	})
+	if err := json.NewDecoder(res.Body).Decode(secret); err != nil {
+		return nil, err
+	}
+
+	if data, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, ok := secret.Data["metadata"]; ok {
+			return data, nil
+		}
+	}
+	return secret.Data, nil
+}
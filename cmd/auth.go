@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// authCmd groups the commands which obtain credentials for issue-sync.
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Obtain credentials for GitHub or JIRA",
+}
+
+// authGitHubCmd obtains a GitHub token with the OAuth device flow.
+var authGitHubCmd = &cobra.Command{
+	Use:   "github",
+	Short: "Obtain a GitHub token by authorizing issue-sync in a browser",
+	Long: "Perform the GitHub OAuth device flow: open the printed URL, enter the code, " +
+		"and authorize the OAuth App. The resulting token is saved as `github-token` " +
+		"in the configuration file, and reused by every following run.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := cfg.LoadConfig(cmd)
+
+		log := config.GetLogger()
+
+		clientID := config.GetConfigString("github-client-id")
+		if clientID == "" {
+			return errors.New("GitHub OAuth App client ID required")
+		}
+		scopes, _ := cmd.Flags().GetStringSlice("scopes")
+
+		token, err := clients.GitHubTokenFromDevice(config, clientID, scopes)
+		if err != nil {
+			return err
+		}
+		config.SetGitHubToken(token)
+
+		if config.GetConfigFile() == "" {
+			config.SetConfigFile(os.ExpandEnv("$HOME/.issue-sync.json"))
+		}
+		if err := config.SaveConfig(); err != nil {
+			return err
+		}
+
+		log.Infof("GitHub token saved to %s", config.GetConfigFile())
+
+		return nil
+	},
+}
+
+// authJIRAOAuthCmd obtains a JIRA OAuth access token with the OAuth 1.0a handshake.
+var authJIRAOAuthCmd = &cobra.Command{
+	Use:   "jira-oauth",
+	Short: "Obtain a JIRA OAuth access token by authorizing issue-sync in a browser",
+	Long: "Perform the JIRA OAuth 1.0a handshake with the application link of the " +
+		"consumer key: open the printed URL, authorize issue-sync, and enter the " +
+		"verification code. The resulting token and secret are saved as `jira-token` " +
+		"and `jira-secret` in the configuration file, and reused by every following run.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := cfg.LoadConfig(cmd)
+
+		log := config.GetLogger()
+
+		if config.GetConfigString("jira-uri") == "" {
+			return errors.New("JIRA URI required")
+		}
+		if config.GetConfigString("jira-consumer-key") == "" {
+			return errors.New("JIRA consumer key required for OAuth handshake")
+		}
+		if config.GetConfigString("jira-private-key-path") == "" {
+			return errors.New("JIRA private key required for OAuth handshake")
+		}
+
+		token, err := clients.JIRATokenFromWeb(config)
+		if err != nil {
+			return err
+		}
+		config.SetJIRAToken(token)
+
+		if config.GetConfigFile() == "" {
+			config.SetConfigFile(os.ExpandEnv("$HOME/.issue-sync.json"))
+		}
+		if err := config.SaveConfig(); err != nil {
+			return err
+		}
+
+		log.Infof("JIRA access token saved to %s", config.GetConfigFile())
+
+		return nil
+	},
+}
+
+func init() {
+	authGitHubCmd.Flags().String("github-client-id", "", "Set the client ID of the GitHub OAuth App to authorize")
+	authGitHubCmd.Flags().StringSlice("scopes", []string{"repo"}, "Set the scopes the GitHub token is granted")
+
+	authJIRAOAuthCmd.Flags().String("jira-consumer-key", "", "Set the consumer key of the JIRA application link")
+	authJIRAOAuthCmd.Flags().String("jira-private-key-path", "", "Set the path to the RSA private key of the consumer")
+
+	authCmd.AddCommand(authGitHubCmd)
+	authCmd.AddCommand(authJIRAOAuthCmd)
+	RootCmd.AddCommand(authCmd)
+}
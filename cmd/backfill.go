@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// backfillCmd performs an initial historical import of one or all configured repos.
+var backfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Import the historical issues of a repository in batches",
+	Long: "Perform an initial import of the GitHub issues created within a date range, " +
+		"in batches separated by pauses. Progress is saved after every batch so an " +
+		"interrupted backfill resumes where it stopped, and a verification pass " +
+		"checks that every issue was imported. The `since` date is not updated.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		opts := lib.BackfillOptions{}
+
+		from, _ := cmd.Flags().GetString("from")
+		if opts.From, err = cfg.ParseDate(from); err != nil {
+			return err
+		}
+		if to, _ := cmd.Flags().GetString("to"); to != "" {
+			if opts.To, err = cfg.ParseDate(to); err != nil {
+				return err
+			}
+		}
+		if opts.Batch, _ = cmd.Flags().GetInt("batch"); opts.Batch <= 0 {
+			return errors.New("batch size must be positive")
+		}
+		opts.Pause, _ = cmd.Flags().GetDuration("pause")
+		checkpoint, _ := cmd.Flags().GetString("checkpoint")
+		opts.Checkpoint = os.ExpandEnv(checkpoint)
+
+		lock, err := config.AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		config.SetSinceParam(opts.From)
+		config = config.WithContext(shutdownContext(config.GetLogger()))
+
+		rootJCli, err := clients.NewJIRAClient(config, jira.Project{})
+		if err != nil {
+			return err
+		}
+		if err := clients.InferProjectKeys(config); err != nil {
+			return err
+		}
+		if err := config.LoadJIRAConfig(rootJCli.GetClient()); err != nil {
+			return err
+		}
+		if err := clients.LoadJIRAInstances(config); err != nil {
+			return err
+		}
+
+		repos := config.GetRepoList()
+		if repo, _ := cmd.Flags().GetString("repo"); repo != "" {
+			if _, ok := config.GetProjects()[repo]; !ok {
+				return fmt.Errorf("repo %s is not configured", repo)
+			}
+			repos = []string{repo}
+		}
+
+		// The report is saved, and notified, even if a repo fails.
+		lib.StartReport()
+		defer finishRun(config, nil)
+
+		for _, repo := range repos {
+			config := config.ForProject(repo)
+			ghClient, err := clients.NewGitHubClient(config, repo)
+			if err != nil {
+				return err
+			}
+			jiraClient, err := clients.NewJIRAClient(config, config.GetProject(repo))
+			if err != nil {
+				return err
+			}
+
+			if err := lib.Backfill(config, opts, ghClient, jiraClient); err != nil {
+				return err
+			}
+		}
+
+		clients.LogUsage(config)
+
+		return nil
+	},
+}
+
+func init() {
+	backfillCmd.Flags().String("repo", "", "Backfill only this configured repo (should be form owner/repo)")
+	backfillCmd.Flags().String("from", "1970-01-01", "Import issues created on or after this date")
+	backfillCmd.Flags().String("to", "", "Import issues created on or before this date")
+	backfillCmd.Flags().Int("batch", 200, "Number of issues to synchronize between pauses")
+	backfillCmd.Flags().Duration("pause", 30*time.Second, "How long to pause between batches")
+	backfillCmd.Flags().String("checkpoint", "$HOME/.issue-sync-backfill.json", "File the backfill progress is saved to")
+
+	RootCmd.AddCommand(backfillCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/spf13/cobra"
+)
+
+// encryptConfigCmd encrypts the credentials saved in the configuration file.
+var encryptConfigCmd = &cobra.Command{
+	Use:   "encrypt-config",
+	Short: "Encrypt the credentials in the configuration file",
+	Long: "Encrypt the credentials saved in the configuration file with a passphrase, " +
+		"read from the ISSUE_SYNC_PASSPHRASE environment variable or prompted for. " +
+		"They are decrypted transparently when the configuration is loaded, with the " +
+		"same passphrase, and encrypted again whenever it is saved.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := cfg.LoadConfig(cmd)
+
+		log := config.GetLogger()
+
+		if config.GetConfigFile() == "" {
+			return errors.New("no configuration file to encrypt")
+		}
+
+		passphrase, err := cfg.ReadPassphrase(true)
+		if err != nil {
+			return err
+		}
+		if err := config.EncryptSecrets(passphrase); err != nil {
+			return err
+		}
+		if err := config.SaveConfig(); err != nil {
+			return err
+		}
+
+		log.Infof("Credentials in %s encrypted", config.GetConfigFile())
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(encryptConfigCmd)
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// mappingCmd groups the commands which export and import the links between
+// the GitHub issues and their JIRA issues.
+var mappingCmd = &cobra.Command{
+	Use:   "mapping",
+	Short: "Export or import the GitHub issue to JIRA issue mapping",
+}
+
+// mappingExportCmd writes the mapping of the JIRA issues of every project.
+var mappingExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the mapping of the GitHub issues to their JIRA issues",
+	Long: "Write the repo, number, and ID of the GitHub issue of every JIRA issue linked to " +
+		"one, as told by their custom fields, to a JSON or CSV file, or to the standard " +
+		"output if no file or `-` is given.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("at most one file can be given")
+		}
+		format, _ := cmd.Flags().GetString("format")
+
+		config, err := loadMappingConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		// The issues of a project shared by several repos are only listed
+		// once.
+		var mappings []lib.Mapping
+		projects := make(map[string]bool)
+		repos := config.GetRepoList()
+		sort.Strings(repos)
+		for _, repo := range repos {
+			id := config.GetProjectInstance(repo) + "/" + config.GetProjectKey(repo)
+			if projects[id] {
+				continue
+			}
+			projects[id] = true
+
+			ghClient, jiraClient, err := mappingClients(config, repo)
+			if err != nil {
+				return err
+			}
+			m, err := lib.ExportMappings(config.ForProject(repo), ghClient, jiraClient)
+			if err != nil {
+				return fmt.Errorf("error exporting the mapping of %s: %v", repo, err)
+			}
+			mappings = append(mappings, m...)
+		}
+
+		var w io.Writer = os.Stdout
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		return lib.WriteMappings(w, format, mappings)
+	},
+}
+
+// mappingImportCmd links the JIRA issues to the GitHub issues of a mapping.
+var mappingImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import a mapping of GitHub issues to JIRA issues",
+	Long: "Link the JIRA issues of a JSON or CSV file, such as one written by `mapping export` " +
+		"or by another sync tool, to their GitHub issues, by setting their custom fields, and " +
+		"record them in the state. The file is read from the standard input if no file or " +
+		"`-` is given. It fails while another issue-sync holds the lock.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("at most one file can be given")
+		}
+		format, _ := cmd.Flags().GetString("format")
+
+		var r io.Reader = os.Stdin
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		mappings, err := lib.ReadMappings(r, format)
+		if err != nil {
+			return err
+		}
+
+		config, err := loadMappingConfig(cmd)
+		if err != nil {
+			return err
+		}
+		log := config.GetLogger()
+
+		lock, err := config.AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		// A failure to link an issue doesn't prevent linking the others.
+		failed := 0
+		ghClients := make(map[string]clients.GitHubClient)
+		jiraClients := make(map[string]clients.JIRAClient)
+		for _, m := range mappings {
+			if _, ok := config.GetProjects()[m.Repo]; !ok {
+				log.Errorf("Error importing the mapping of %s#%d to %s: repo is not configured", m.Repo, m.GitHubNumber, m.JIRAKey)
+				failed++
+				continue
+			}
+			if err := config.Context().Err(); err != nil {
+				return err
+			}
+			var err error
+			ghClient, ok := ghClients[m.Repo]
+			jiraClient := jiraClients[m.Repo]
+			if !ok {
+				if ghClient, jiraClient, err = mappingClients(config, m.Repo); err == nil {
+					ghClients[m.Repo], jiraClients[m.Repo] = ghClient, jiraClient
+				}
+			}
+			if err == nil {
+				err = lib.ImportMapping(config.ForProject(m.Repo), m, ghClient, jiraClient)
+			}
+			if err != nil {
+				log.Errorf("Error importing the mapping of %s#%d to %s: %v", m.Repo, m.GitHubNumber, m.JIRAKey, err)
+				failed++
+			}
+		}
+
+		if !config.IsDryRun() {
+			if err := config.GetState().Save(); err != nil {
+				return err
+			}
+		}
+
+		log.Infof("Imported %d of %d mappings", len(mappings)-failed, len(mappings))
+		if failed > 0 {
+			return fmt.Errorf("failed to import %d mappings", failed)
+		}
+		return nil
+	},
+}
+
+// loadMappingConfig loads the configuration, along with the JIRA
+// configuration of its projects.
+func loadMappingConfig(cmd *cobra.Command) (cfg.Config, error) {
+	config, err := cfg.NewConfig(cmd)
+	if err != nil {
+		return config, err
+	}
+	config = config.WithContext(shutdownContext(config.GetLogger()))
+
+	rootJCli, err := clients.NewJIRAClient(config, jira.Project{})
+	if err != nil {
+		return config, err
+	}
+	if err := clients.InferProjectKeys(config); err != nil {
+		return config, err
+	}
+	if err := config.LoadJIRAConfig(rootJCli.GetClient()); err != nil {
+		return config, err
+	}
+	return config, clients.LoadJIRAInstances(config)
+}
+
+// mappingClients returns the GitHub and JIRA clients of a configured repo.
+func mappingClients(config cfg.Config, repo string) (clients.GitHubClient, clients.JIRAClient, error) {
+	config = config.ForProject(repo)
+	ghClient, err := clients.NewGitHubClient(config, repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	jiraClient, err := clients.NewJIRAClient(config, config.GetProject(repo))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ghClient, jiraClient, nil
+}
+
+func init() {
+	mappingExportCmd.Flags().String("format", "json", "Format of the mapping (json or csv)")
+	mappingImportCmd.Flags().String("format", "json", "Format of the mapping (json or csv)")
+
+	mappingCmd.AddCommand(mappingExportCmd)
+	mappingCmd.AddCommand(mappingImportCmd)
+	RootCmd.AddCommand(mappingCmd)
+}
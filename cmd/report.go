@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd renders the report of the last run as HTML or CSV.
+var reportCmd = &cobra.Command{
+	Use:   "report [report-file]",
+	Short: "Render the report of the last run as HTML or CSV",
+	Long: "Render the report saved to the `report-file` by the last run, or the given report, " +
+		"listing the created, updated, skipped, and failed issues with links to GitHub and " +
+		"JIRA. The HTML report is self-contained, so that it can be sent by email.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("at most one report can be given")
+		}
+
+		path := cfg.LoadConfig(cmd).GetReportFile()
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			return errors.New("no report file is configured")
+		}
+
+		r, err := lib.LoadReport(path)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if output, _ := cmd.Flags().GetString("output"); output != "" && output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		switch format, _ := cmd.Flags().GetString("format"); format {
+		case "html":
+			return r.WriteHTML(w)
+		case "csv":
+			return r.WriteCSV(w)
+		default:
+			return fmt.Errorf("unknown report format %q; expected html or csv", format)
+		}
+	},
+}
+
+func init() {
+	reportCmd.Flags().String("format", "html", "Format of the report (html or csv)")
+	reportCmd.Flags().StringP("output", "o", "", "File to write the report to (default standard output)")
+
+	RootCmd.AddCommand(reportCmd)
+}
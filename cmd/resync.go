@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// resyncCmd synchronizes every GitHub issue of the configured repos again.
+var resyncCmd = &cobra.Command{
+	Use:   "resync",
+	Short: "Synchronize every GitHub issue again, ignoring since",
+	Long: "Walk every GitHub issue of one or all configured repos, regardless of `since` " +
+		"and of the time of the last sync, and reconcile each of them with its JIRA issue, " +
+		"even if the state records that it didn't change. It's meant to be run after the " +
+		"field mappings change, or after data was lost in JIRA.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		lock, err := config.AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		config.SetSinceParam(time.Time{})
+		config.SetResync()
+		log := config.GetLogger()
+		config = config.WithContext(shutdownContext(log))
+
+		rootJCli, err := clients.NewJIRAClient(config, jira.Project{})
+		if err != nil {
+			return err
+		}
+		if err := clients.InferProjectKeys(config); err != nil {
+			return err
+		}
+		if err := config.LoadJIRAConfig(rootJCli.GetClient()); err != nil {
+			return err
+		}
+		if err := clients.LoadJIRAInstances(config); err != nil {
+			return err
+		}
+
+		repos := config.GetRepoList()
+		if repo, _ := cmd.Flags().GetString("repo"); repo != "" {
+			if _, ok := config.GetProjects()[repo]; !ok {
+				return fmt.Errorf("repo %s is not configured", repo)
+			}
+			repos = []string{repo}
+		}
+		sort.Strings(repos)
+
+		lib.StartReport()
+
+		// As for sync, a failure to resync a repo doesn't prevent resyncing
+		// the others, and only those which succeed have their `since` time
+		// updated.
+		var failed []string
+		for _, repo := range repos {
+			if config.Context().Err() != nil {
+				break
+			}
+			start := time.Now()
+			log.Infof("Resynchronizing %s", repo)
+			if err := syncRepo(config, repo); err != nil {
+				log.Errorf("Error resynchronizing %s: %v", repo, err)
+				lib.EmitSyncFailed(config, repo, err)
+				failed = append(failed, repo)
+				continue
+			}
+			config.SetProjectSince(repo, start)
+		}
+
+		clients.LogUsage(config)
+		lib.LogConflicts(config)
+		finishRun(config, failed)
+		if !config.IsDryRun() {
+			if err := config.GetState().Save(); err != nil {
+				return err
+			}
+		}
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to resynchronize %s", strings.Join(failed, ", "))
+		}
+		return config.Context().Err()
+	},
+}
+
+func init() {
+	resyncCmd.Flags().String("repo", "", "Resync only this configured repo (should be form owner/repo)")
+
+	RootCmd.AddCommand(resyncCmd)
+}
@@ -4,10 +4,6 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/andygrunwald/go-jira"
-	"github.com/coreos/issue-sync/cfg"
-	"github.com/coreos/issue-sync/lib"
-	"github.com/coreos/issue-sync/lib/clients"
 	"github.com/spf13/cobra"
 )
 
@@ -20,67 +16,97 @@ func Execute() {
 	}
 }
 
-// RootCmd represents the command itself and configures it.
+// RootCmd represents the command itself and configures it. It only prints
+// the help; the synchronization is run by syncCmd. The flags of the
+// configuration are persistent, so that they're shared by every command.
 var RootCmd = &cobra.Command{
-	Use:   "issue-sync [options]",
+	Use:   "issue-sync [command]",
 	Short: "A tool to synchronize GitHub and JIRA issues",
 	Long:  "Full docs coming later; see https://github.com/coreos/issue-sync",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		config, err := cfg.NewConfig(cmd)
-		if err != nil {
-			return err
-		}
-
-		log := config.GetLogger()
-
-		// Create a temporary JIRA client which we can use to populate the
-		// configuration object with all of the JIRA settings (projects,
-		// field IDs, etc.)
-		rootJCli, err := clients.NewJIRAClient(config, jira.Project{})
-		if err != nil {
-			return err
-		}
-		config.LoadJIRAConfig(rootJCli.GetClient())
-
-		for {
-			for _, repo := range config.GetRepoList() {
-				ghClient, err := clients.NewGitHubClient(config, repo)
-				if err != nil {
-					return err
-				}
-				jiraClient, err := clients.NewJIRAClient(config, config.GetProject(repo))
-				if err != nil {
-					return err
-				}
-
-				if err := lib.CompareIssues(config, ghClient, jiraClient); err != nil {
-					return err
-				}
-			}
-			if !config.IsDryRun() {
-				if err := config.SaveConfig(); err != nil {
-					log.Error(err)
-				}
-			}
-			if !config.IsDaemon() {
-				return nil
-			}
-			<-time.After(config.GetDaemonPeriod())
-		}
-	},
 }
 
 func init() {
 	RootCmd.PersistentFlags().String("log-level", logrus.InfoLevel.String(), "Set the global log level")
+	RootCmd.PersistentFlags().String("log-format", "text", "Set the log output format (text or json)")
 	RootCmd.PersistentFlags().String("config", "", "Config file (default is $HOME/.issue-sync.json)")
 	RootCmd.PersistentFlags().StringP("github-token", "t", "", "Set the API Token used to access the GitHub repo")
+	RootCmd.PersistentFlags().String("github-uri", "", "Set the base URL of the API of a GitHub Enterprise Server instance")
+	RootCmd.PersistentFlags().String("github-upload-uri", "", "Set the base URL of the upload API of a GitHub Enterprise Server instance")
+	RootCmd.PersistentFlags().Int("github-app-id", 0, "Set the ID of the GitHub App to authenticate as, instead of a token")
+	RootCmd.PersistentFlags().Int("github-app-installation-id", 0, "Set the ID of the installation of the GitHub App")
+	RootCmd.PersistentFlags().String("github-app-private-key-path", "", "Set the path to the private key of the GitHub App")
 	RootCmd.PersistentFlags().StringP("jira-user", "u", "", "Set the JIRA username to authenticate with")
 	RootCmd.PersistentFlags().StringP("jira-pass", "p", "", "Set the JIRA password to authenticate with")
+	RootCmd.PersistentFlags().String("jira-pat", "", "Set the JIRA personal access token to authenticate with")
+	RootCmd.PersistentFlags().Bool("keychain", false, "Keep the GitHub and JIRA credentials in the OS keychain instead of the config file")
+	RootCmd.PersistentFlags().String("vault-address", "", "Set the address of the Vault server credentials are read from (default $VAULT_ADDR)")
 	RootCmd.PersistentFlags().StringP("repo-name", "r", "", "Set the repository path (should be form owner/repo)")
 	RootCmd.PersistentFlags().StringP("jira-uri", "U", "", "Set the base uri of the JIRA instance")
 	RootCmd.PersistentFlags().StringP("jira-project", "P", "", "Set the key of the JIRA project")
 	RootCmd.PersistentFlags().StringP("since", "s", "1970-01-01T00:00:00+0000", "Set the day that the update should run forward from")
 	RootCmd.PersistentFlags().BoolP("dry-run", "d", false, "Print out actions to be taken, but do not execute them")
 	RootCmd.PersistentFlags().DurationP("timeout", "T", time.Minute, "Set the maximum timeout on all API calls")
+	RootCmd.PersistentFlags().Int("retry-max-attempts", 0, "Set the maximum number of attempts of failing API calls; 0 for no limit but the timeout")
 	RootCmd.PersistentFlags().Duration("period", 0, "How often to synchronize; set to 0 for one-shot mode")
+	RootCmd.PersistentFlags().String("state-filter", "all", "Set the state of the GitHub issues to synchronize (open, closed, or all)")
+	RootCmd.PersistentFlags().StringSlice("include-labels", nil, "Only synchronize the GitHub issues carrying one of these labels")
+	RootCmd.PersistentFlags().StringSlice("exclude-labels", nil, "Don't synchronize the GitHub issues carrying any of these labels")
+	RootCmd.PersistentFlags().StringSlice("authors", nil, "Only synchronize the GitHub issues opened by these users (logins or @org/team)")
+	RootCmd.PersistentFlags().StringSlice("assignees", nil, "Only synchronize the GitHub issues assigned to these users (logins or @org/team)")
+	RootCmd.PersistentFlags().StringSlice("milestones", nil, "Only synchronize the GitHub issues in these milestones (names or glob patterns)")
+	RootCmd.PersistentFlags().String("jql-filter", "", "JQL fragment restricting the JIRA issues which are managed")
+	RootCmd.PersistentFlags().Int("github-page-size", 100, "Set the number of issues or comments requested per page of GitHub results")
+	RootCmd.PersistentFlags().Bool("github-graphql", false, "List the GitHub issues and their comments through the GitHub GraphQL API")
+	RootCmd.PersistentFlags().Bool("github-etag-cache", false, "Keep the GitHub list responses in the state file and request them conditionally")
+	RootCmd.PersistentFlags().Bool("create-fields", false, "Create the missing JIRA custom fields, as setup-fields does, instead of failing")
+	RootCmd.PersistentFlags().Duration("jira-metadata-ttl", 0, "How long the JIRA projects and fields are cached in the state file; 0 disables the cache")
+	RootCmd.PersistentFlags().Int("jira-page-size", 50, "Set the number of issues requested per page of JIRA search results")
+	RootCmd.PersistentFlags().Int("concurrency", 1, "Maximum number of repos, and of issues per repo, synchronized in parallel")
+	RootCmd.PersistentFlags().Int("comment-concurrency", 4, "Maximum number of comments per issue compared and translated in parallel")
+	RootCmd.PersistentFlags().Bool("milestone-versions", false, "Map GitHub milestones to JIRA fix versions")
+	RootCmd.PersistentFlags().Bool("milestone-epics", false, "Map GitHub milestones to JIRA epics")
+	RootCmd.PersistentFlags().Bool("task-subtasks", false, "Sync the task list items of GitHub issues as JIRA sub-tasks")
+	RootCmd.PersistentFlags().String("sub-issues", "", "Reflect GitHub sub-issues in JIRA with epic or link")
+	RootCmd.PersistentFlags().Bool("estimate-labels", false, "Set the original estimate of new JIRA issues from estimate labels")
+	RootCmd.PersistentFlags().String("estimate-pattern", "", "Regular expression of the estimate labels, whose group is the estimate")
+	RootCmd.PersistentFlags().String("estimate-unit", "", "Unit of the estimates which are bare numbers: w, d, h, or m (default h)")
+	RootCmd.PersistentFlags().Bool("sync-reactions", false, "Sync the thumbs up reactions of GitHub issues to the GitHub Votes field")
+	RootCmd.PersistentFlags().Bool("sync-watchers", false, "Add the mapped JIRA users of the participants of GitHub issues as watchers")
+	RootCmd.PersistentFlags().String("assignee-mode", "", "Assign the first mapped assignee of GitHub issues, or one in turn (round-robin), or write them all to the assignees-field (field)")
+	RootCmd.PersistentFlags().String("orphaned-issues", "", "Close, flag, or relink the JIRA issues whose GitHub issue was deleted or transferred")
+	RootCmd.PersistentFlags().Duration("orphan-check-interval", 0, "How long the JIRA issues of a repo aren't checked for orphans again; 0 checks them on every run")
+	RootCmd.PersistentFlags().String("pinned-issues", "", "Flag or label the JIRA issues of the GitHub issues pinned to their repo")
+	RootCmd.PersistentFlags().String("pinned-label", "", "The JIRA label of the JIRA issues of pinned GitHub issues, with the label pinned-issues")
+	RootCmd.PersistentFlags().String("conflict-strategy", "github-wins", "Resolve changes to both sides with github-wins, jira-wins, newest-wins, or manual")
+	RootCmd.PersistentFlags().Bool("native-status", false, "Sync the GitHub state to the JIRA status only, without the GitHub Status field")
+	RootCmd.PersistentFlags().Bool("native-labels", false, "Mirror GitHub labels into the labels of JIRA issues")
+	RootCmd.PersistentFlags().String("label-prefix", "github:", "Set the prefix of the JIRA labels mirroring GitHub labels")
+	RootCmd.PersistentFlags().Bool("translation-fallback", false, "Send bodies whose markup translation looks incorrect verbatim")
+	RootCmd.PersistentFlags().Bool("emoji", true, "Replace GitHub emoji shortcodes, such as :rocket:, by Unicode emoji")
+	RootCmd.PersistentFlags().Bool("rehost-images", false, "Attach the images embedded from GitHub to the JIRA issues")
+	RootCmd.PersistentFlags().String("summary-template", "", "Go template of the summaries of the JIRA issues")
+	RootCmd.PersistentFlags().String("description-template", "", "Go template of the descriptions of the JIRA issues")
+	RootCmd.PersistentFlags().String("comment-template", "", "Go template of the headers of the JIRA comments synced from GitHub")
+	RootCmd.PersistentFlags().String("oversized-text", "", "Truncate (truncate) or split (split) the GitHub comments too long for JIRA")
+	RootCmd.PersistentFlags().Bool("remote-links", false, "Link the JIRA issues to their GitHub issue")
+	RootCmd.PersistentFlags().Bool("reference-keys", false, "Replace references to synced GitHub issues by their JIRA keys")
+	RootCmd.PersistentFlags().Bool("reference-links", false, "Link the JIRA issues of GitHub issues which reference each other")
+	RootCmd.PersistentFlags().String("project-mapping-url", "", "URL of a service mapping GitHub repos to JIRA project keys")
+	RootCmd.PersistentFlags().Bool("project-key-topics", false, "Infer JIRA project keys from jira-KEY repo topics")
+	RootCmd.PersistentFlags().String("state-file", "$HOME/.issue-sync-state.json", "File the runtime state (since times, issue mappings) is kept in")
+	RootCmd.PersistentFlags().String("report-file", "$HOME/.issue-sync-report.json", "File the report of the issues synchronized by each run is saved to")
+	RootCmd.PersistentFlags().String("audit-log", "", "File every change made to GitHub and JIRA is appended to, as JSON lines")
+	RootCmd.PersistentFlags().String("lock-file", "", "File locked to prevent concurrent runs (default: the state file with .lock)")
+	RootCmd.PersistentFlags().String("slack-webhook-url", "", "URL of a Slack incoming webhook the summary of each run is posted to")
+	RootCmd.PersistentFlags().String("slack-notify", "all", "Post every run to Slack (all), or only those with failures (errors)")
+	RootCmd.PersistentFlags().String("smtp-address", "", "Address (host:port) of the SMTP server email notifications are sent through")
+	RootCmd.PersistentFlags().String("smtp-user", "", "Set the username to authenticate to the SMTP server with")
+	RootCmd.PersistentFlags().String("smtp-pass", "", "Set the password to authenticate to the SMTP server with")
+	RootCmd.PersistentFlags().String("email-from", "", "Sender address of the email notifications")
+	RootCmd.PersistentFlags().StringSlice("ignore-comment-authors", nil, "Don't synchronize the comments of these GitHub users (default dependabot[bot], github-actions[bot], stale[bot])")
+	RootCmd.PersistentFlags().StringSlice("email-to", nil, "Addresses the report of every project is emailed to")
+	RootCmd.PersistentFlags().String("email-notify", "all", "Email every run (all), only those with failures (errors), or a daily digest (daily)")
+	RootCmd.PersistentFlags().String("event-webhook-url", "", "URL the sync events (issues created and updated, comments synced, failures) are posted to")
+	RootCmd.PersistentFlags().String("event-webhook-secret", "", "Set the secret the payloads of the sync events are signed with")
+	RootCmd.PersistentFlags().String("health-address", "", "Address to serve /healthz and /readyz on in daemon mode")
 }
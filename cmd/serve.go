@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// serverShutdownTimeout is how long the webhook server waits for the
+// deliveries being received to be handled when it shuts down.
+const serverShutdownTimeout = 30 * time.Second
+
+// serveCmd runs issue-sync as an HTTP server which receives GitHub webhooks.
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Sync issues as GitHub and JIRA webhooks are received",
+	Long: "Run an HTTP server which receives GitHub `issues` and `issue_comment` webhooks " +
+		"and synchronizes only the affected issue, instead of polling every repository. " +
+		"JIRA issue-updated webhooks are also accepted, and reflected onto GitHub.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		lock, err := config.AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		log := config.GetLogger()
+		config = config.WithContext(shutdownContext(log))
+
+		for _, secret := range []string{"github-webhook-secret", "jira-webhook-secret"} {
+			if config.GetConfigString(secret) == "" {
+				log.Warnf("%s is not set; anyone who can reach the server can trigger syncs", secret)
+			}
+		}
+
+		rootJCli, err := clients.NewJIRAClient(config, jira.Project{})
+		if err != nil {
+			return err
+		}
+		if err := clients.InferProjectKeys(config); err != nil {
+			return err
+		}
+		if err := config.LoadJIRAConfig(rootJCli.GetClient()); err != nil {
+			return err
+		}
+		if err := clients.LoadJIRAInstances(config); err != nil {
+			return err
+		}
+
+		repoClients := make(map[string]lib.RepoClients)
+		for _, repo := range config.GetRepoList() {
+			ghClient, err := clients.NewGitHubClient(config, repo)
+			if err != nil {
+				return err
+			}
+			jiraClient, err := clients.NewJIRAClient(config.ForProject(repo), config.GetProject(repo))
+			if err != nil {
+				return err
+			}
+			repoClients[repo] = lib.RepoClients{
+				GitHub: ghClient,
+				JIRA:   jiraClient,
+			}
+		}
+
+		queue := lib.NewSyncQueue(config)
+
+		mux := http.NewServeMux()
+		mux.Handle("/github", lib.NewGitHubWebhookHandler(config, repoClients, queue))
+		mux.Handle("/jira", lib.NewJIRAWebhookHandler(config, repoClients, queue))
+
+		ghClient, err := clients.NewGitHubClient(config, "")
+		if err != nil {
+			return err
+		}
+		lib.NewHealth(config, ghClient, rootJCli).Register(mux)
+
+		addr := config.GetConfigString("listen-address")
+		server := &http.Server{Addr: addr, Handler: mux}
+
+		// On shutdown, no delivery is accepted anymore, and the sync in
+		// progress is finished before exiting; the syncs still queued are
+		// dropped.
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			<-config.Context().Done()
+			ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+			defer cancel()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Errorf("Error shutting down the webhook server: %v", err)
+			}
+		}()
+
+		log.Infof("Listening for webhooks on %s", addr)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		<-stopped
+		queue.Drain()
+
+		if !config.IsDryRun() {
+			if err := config.GetState().Save(); err != nil {
+				log.Errorf("Error saving state: %v", err)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().String("listen-address", ":8080", "Set the address the webhook server listens on")
+	serveCmd.Flags().String("github-webhook-secret", "", "Set the secret used to validate GitHub webhook signatures")
+	serveCmd.Flags().String("jira-webhook-secret", "", "Set the secret expected in the query string of JIRA webhooks")
+
+	RootCmd.AddCommand(serveCmd)
+}
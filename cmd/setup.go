@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// setupFieldsCmd creates the JIRA custom fields issue-sync requires.
+var setupFieldsCmd = &cobra.Command{
+	Use:   "setup-fields",
+	Short: "Create the JIRA custom fields issue-sync requires",
+	Long: "Create the six custom fields issue-sync requires which don't exist yet, using " +
+		"the JIRA administration API, and add them to the screens of the configured " +
+		"projects, or to the screens given with --screen. The JIRA user must be a JIRA " +
+		"administrator. With --jira-instance, the fields are created on that JIRA " +
+		"instance rather than on the default one.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		instance, _ := cmd.Flags().GetString("jira-instance")
+		if instance != "" {
+			if !hasJIRAInstance(config, instance) {
+				return fmt.Errorf("JIRA instance %s is not configured", instance)
+			}
+			config = config.ForInstance(strings.ToLower(instance))
+		}
+
+		jiraClient, err := clients.NewJIRAClient(config, jira.Project{})
+		if err != nil {
+			return err
+		}
+		if err := clients.InferProjectKeys(config); err != nil {
+			return err
+		}
+
+		screens, _ := cmd.Flags().GetIntSlice("screen")
+
+		return config.SetupFields(jiraClient.GetClient(), screens)
+	},
+}
+
+// hasJIRAInstance returns whether a JIRA instance is configured in
+// `jira-instances`.
+func hasJIRAInstance(config cfg.Config, name string) bool {
+	for _, instance := range config.GetJIRAInstances() {
+		if instance == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	setupFieldsCmd.Flags().IntSlice("screen", nil, "ID of a screen to add the fields to; may be repeated")
+	setupFieldsCmd.Flags().String("jira-instance", "", "Name of the JIRA instance of jira-instances to set up, instead of the default one")
+
+	RootCmd.AddCommand(setupFieldsCmd)
+}
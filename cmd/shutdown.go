@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// shutdownContext returns a context which is cancelled when issue-sync
+// receives SIGINT or SIGTERM, so that it stops cleanly: the API calls in
+// flight are cancelled, no other issue is started, and the state is saved
+// before it exits. A second signal exits right away.
+func shutdownContext(log logrus.Entry) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Infof("Received %v; shutting down", sig)
+		cancel()
+
+		sig = <-signals
+		log.Errorf("Received %v again; exiting", sig)
+		os.Exit(1)
+	}()
+
+	return ctx
+}
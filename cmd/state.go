@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/spf13/cobra"
+)
+
+// stateCmd groups the commands which manage the state file.
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export, import, or clear the caches of the sync state",
+}
+
+// stateExportCmd writes the state to a portable archive.
+var stateExportCmd = &cobra.Command{
+	Use:   "export [archive]",
+	Short: "Export the sync state to an archive",
+	Long: "Write the whole state file (issue mappings, content hashes, and the time of " +
+		"the last sync of each project) to a gzip-compressed archive, or to the standard " +
+		"output if no archive or `-` is given.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("at most one archive can be given")
+		}
+
+		state, err := openStateFile(cmd)
+		if err != nil {
+			return err
+		}
+
+		var w io.Writer = os.Stdout
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+
+		return state.Export(w)
+	},
+}
+
+// stateImportCmd restores the state from an archive written by stateExportCmd.
+var stateImportCmd = &cobra.Command{
+	Use:   "import [archive]",
+	Short: "Import the sync state from an archive",
+	Long: "Restore the state file from an archive written by `state export`, read from " +
+		"the standard input if no archive or `-` is given. The state is replaced, " +
+		"unless --merge is given. It fails while another issue-sync holds the lock.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("at most one archive can be given")
+		}
+
+		lock, err := cfg.LoadConfig(cmd).AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		state, err := openStateFile(cmd)
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = os.Stdin
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+
+		merge, _ := cmd.Flags().GetBool("merge")
+		if err := state.Import(r, merge); err != nil {
+			return err
+		}
+
+		return state.Save()
+	},
+}
+
+// stateClearCacheCmd forgets what the state caches, so that it's fetched
+// again by the next run.
+var stateClearCacheCmd = &cobra.Command{
+	Use:   "clear-cache",
+	Short: "Clear the caches of the sync state",
+	Long: "Forget the JIRA projects and fields cached with `jira-metadata-ttl`, the profiles " +
+		"of the GitHub users, and the GitHub responses cached with `github-etag-cache`, so " +
+		"that the next run fetches them again. The issue mappings and the time of the last " +
+		"sync of each project are kept. It fails while another issue-sync holds the lock.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lock, err := cfg.LoadConfig(cmd).AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		state, err := openStateFile(cmd)
+		if err != nil {
+			return err
+		}
+
+		state.ClearCache()
+
+		return state.Save()
+	},
+}
+
+// openStateFile opens the configured `state-file`. Credentials aren't needed
+// to manage the state, so the rest of the configuration isn't validated.
+func openStateFile(cmd *cobra.Command) (*cfg.State, error) {
+	config := cfg.LoadConfig(cmd)
+
+	path := config.GetConfigString("state-file")
+	if path == "" {
+		return nil, errors.New("no state file is configured")
+	}
+
+	return cfg.OpenState(os.ExpandEnv(path))
+}
+
+func init() {
+	stateImportCmd.Flags().Bool("merge", false, "Add the archive to the existing state instead of replacing it")
+
+	stateCmd.AddCommand(stateExportCmd)
+	stateCmd.AddCommand(stateImportCmd)
+	stateCmd.AddCommand(stateClearCacheCmd)
+	RootCmd.AddCommand(stateCmd)
+}
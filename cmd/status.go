@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd shows the synchronization status of each configured repo.
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the synchronization status of each project",
+	Long: "Show, for each configured repo, the number of GitHub issues linked to a JIRA issue, " +
+		"the issues pending creation, the time of the last sync, and the issues which changed " +
+		"since they were last synchronized. It's read from the state and GitHub; nothing is " +
+		"written, to JIRA, GitHub, or the state.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if config.GetState() == nil {
+			return errors.New("no state file is configured")
+		}
+		if err := clients.InferProjectKeys(config); err != nil {
+			return err
+		}
+
+		repos := config.GetRepoList()
+		statuses := make([]lib.ProjectStatus, len(repos))
+		errs := make([]error, len(repos))
+		lib.ForEach(config.GetConcurrency(), len(repos), func(i int) {
+			project := config.ForProject(repos[i])
+			ghClient, err := clients.NewGitHubClient(project, repos[i])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			statuses[i], errs[i] = lib.GetProjectStatus(project, ghClient)
+		})
+
+		var failed []string
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "REPO\tKEY\tLINKED\tPENDING\tDRIFT\tLAST SYNC")
+		for i, status := range statuses {
+			if errs[i] != nil {
+				fmt.Fprintf(w, "%s\t%s\terror: %v\n", repos[i], config.GetProjectKey(repos[i]), errs[i])
+				failed = append(failed, repos[i])
+				continue
+			}
+			lastSync := "never"
+			if status.Synced {
+				lastSync = status.LastSync.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", status.Repo, config.GetProjectKey(repos[i]),
+				status.Linked, status.Pending, status.Drift, lastSync)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to get the status of %s", strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(statusCmd)
+}
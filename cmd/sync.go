@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd synchronizes the configured repos once, or on a period in daemon
+// mode.
+var syncCmd = &cobra.Command{
+	Use:   "sync [options]",
+	Short: "Synchronize the GitHub issues of the configured repos to JIRA",
+	Long: "Synchronize the GitHub issues, and their comments, of every configured repo to " +
+		"its JIRA project, once, or every `period` in daemon mode. With --plan-out, the " +
+		"changes of a dry run are written to a plan, which --apply makes later.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		lock, err := config.AcquireLock()
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+
+		log := config.GetLogger()
+		config = config.WithContext(shutdownContext(log))
+
+		// Create a temporary JIRA client which we can use to populate the
+		// configuration object with all of the JIRA settings (projects,
+		// field IDs, etc.)
+		rootJCli, err := clients.NewJIRAClient(config, jira.Project{})
+		if err != nil {
+			return err
+		}
+		if err := clients.InferProjectKeys(config); err != nil {
+			return err
+		}
+		config.LoadJIRAConfig(rootJCli.GetClient())
+		if err := clients.LoadJIRAInstances(config); err != nil {
+			return err
+		}
+
+		if config.GetApplyFile() != "" {
+			return clients.ApplyPlan(config)
+		}
+
+		var health *lib.Health
+		if addr := config.GetConfigString("health-address"); config.IsDaemon() && addr != "" {
+			ghClient, err := clients.NewGitHubClient(config, "")
+			if err != nil {
+				return err
+			}
+			health = lib.NewHealth(config, ghClient, rootJCli)
+			lib.ServeHealth(config, addr, health)
+		}
+
+		// In daemon mode, changes to the configuration file are applied at
+		// the start of the next cycle.
+		var changes <-chan struct{}
+		if config.IsDaemon() && config.GetConfigFile() != "" {
+			if changes, err = config.WatchConfigFile(); err != nil {
+				log.Warningf("Unable to watch config file; changes will require a restart: %v", err)
+			}
+		}
+
+		// When the daemon runs as a systemd service, systemd is told when
+		// it's ready, and the result of each cycle.
+		var systemd *lib.Systemd
+		if config.IsDaemon() {
+			systemd = lib.NewSystemd(config)
+			systemd.Ready()
+		}
+
+		reload := false
+		for {
+			select {
+			case <-changes:
+				reload = true
+			default:
+			}
+			if reload {
+				config = reloadConfig(cmd, config)
+				log = config.GetLogger()
+				if health != nil {
+					health.SetConfig(config)
+				}
+				systemd.SetConfig(config)
+				reload = false
+			}
+			systemd.Status("Synchronizing")
+			lib.StartReport()
+
+			// A failure to sync a repo doesn't prevent syncing the others; only
+			// the repos which succeed have their `since` time updated.
+			var failed []string
+			var failedLock sync.Mutex
+			repos := config.GetRepoList()
+			lib.ForEach(config.GetConcurrency(), len(repos), func(i int) {
+				repo := repos[i]
+				start := time.Now()
+				if config.Context().Err() != nil {
+					return
+				}
+
+				if err := syncRepo(config, repo); err != nil {
+					log.Errorf("Error synchronizing %s: %v", repo, err)
+					lib.EmitSyncFailed(config, repo, err)
+					failedLock.Lock()
+					failed = append(failed, repo)
+					failedLock.Unlock()
+					return
+				}
+
+				config.SetProjectSince(repo, start)
+			})
+			sort.Strings(failed)
+			clients.LogUsage(config)
+			clients.ResetUsage()
+			lib.LogConflicts(config)
+			finishRun(config, failed)
+			if err := clients.SavePlan(config); err != nil {
+				return err
+			}
+			if !config.IsDryRun() {
+				if err := config.GetState().Save(); err != nil {
+					log.Errorf("Error saving state: %v", err)
+				}
+			}
+			if !config.IsDaemon() {
+				if len(failed) > 0 {
+					return fmt.Errorf("failed to synchronize %s", strings.Join(failed, ", "))
+				}
+				return nil
+			}
+			if health != nil {
+				health.MarkCycle()
+			}
+			systemd.MarkCycle(cycleStatus(repos, failed))
+			select {
+			case <-time.After(config.GetDaemonPeriod()):
+			case <-config.Context().Done():
+				systemd.Stopping()
+				return nil
+			}
+
+			// The repos created since the repo patterns were resolved are
+			// picked up by reloading the configuration.
+			reload = patternReposChanged(config)
+		}
+	},
+}
+
+// patternReposChanged returns whether repos matching the repo patterns of
+// the projects were created, or removed, since they were resolved.
+func patternReposChanged(config cfg.Config) bool {
+	log := config.GetLogger()
+
+	repos, err := clients.ListPatternRepos(config)
+	if err != nil {
+		log.Errorf("Error listing the repos of the repo patterns: %v", err)
+		return false
+	}
+	return repos != nil && config.RepoPatternsChanged(repos)
+}
+
+// cycleStatus returns the status of the daemon after a cycle, such as
+// "Last sync at 15:04:05: 3 repos, 1 failed (org/repo)".
+func cycleStatus(repos, failed []string) string {
+	status := fmt.Sprintf("Last sync at %s: %d repos", time.Now().Format("15:04:05"), len(repos))
+	if len(failed) > 0 {
+		status += fmt.Sprintf(", %d failed (%s)", len(failed), strings.Join(failed, ", "))
+	}
+	return status
+}
+
+// finishRun saves the report of the run, notifies it, and waits for its
+// events to be posted, logging rather than returning errors, as the issues were synchronized nonetheless.
+func finishRun(config cfg.Config, failed []string) {
+	log := config.GetLogger()
+
+	r := lib.FinishReport(failed)
+	if err := lib.SaveReport(config, r); err != nil {
+		log.Errorf("Error saving report: %v", err)
+	}
+	if err := lib.NotifySlack(config, r); err != nil {
+		log.Errorf("Error notifying Slack: %v", err)
+	}
+	if err := lib.NotifyEmail(config, r); err != nil {
+		log.Errorf("Error sending the email notifications: %v", err)
+	}
+	lib.FlushEvents(config)
+}
+
+// reloadConfig reloads the configuration after the configuration file has
+// changed, along with the JIRA configuration of its projects. If the new
+// configuration is invalid, the current one is kept.
+func reloadConfig(cmd *cobra.Command, config cfg.Config) cfg.Config {
+	log := config.GetLogger()
+
+	newConfig, err := config.Reload(cmd)
+	if err != nil {
+		log.Errorf("Error reloading config file; keeping the current configuration: %v", err)
+		return config
+	}
+
+	jiraClient, err := clients.NewJIRAClient(newConfig, jira.Project{})
+	if err == nil {
+		err = clients.InferProjectKeys(newConfig)
+	}
+	if err == nil {
+		err = newConfig.LoadJIRAConfig(jiraClient.GetClient())
+	}
+	if err == nil {
+		err = clients.LoadJIRAInstances(newConfig)
+	}
+	if err != nil {
+		log.Errorf("Error loading the JIRA configuration of the reloaded config file; keeping the current configuration: %v", err)
+		return config
+	}
+
+	log = newConfig.GetLogger()
+	log.Info("Config file reloaded")
+	return newConfig
+}
+
+// syncRepo synchronizes the issues of a GitHub repo with its JIRA project.
+func syncRepo(config cfg.Config, repo string) error {
+	config = config.ForProject(repo)
+
+	ghClient, err := clients.NewGitHubClient(config, repo)
+	if err != nil {
+		return err
+	}
+	jiraClient, err := clients.NewJIRAClient(config, config.GetProject(repo))
+	if err != nil {
+		return err
+	}
+
+	return lib.CompareIssues(config, ghClient, jiraClient)
+}
+
+func init() {
+	syncCmd.Flags().String("plan-out", "", "Do a dry run, and write the changes it would make to a plan file")
+	syncCmd.Flags().String("apply", "", "Make the changes of a plan file written by --plan-out, instead of synchronizing")
+	RootCmd.AddCommand(syncCmd)
+}
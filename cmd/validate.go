@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd checks the configuration without synchronizing anything.
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration, credentials, and JIRA projects",
+	Long: "Check the configuration file against its schema, verify the GitHub and JIRA " +
+		"credentials, and confirm that each JIRA project exists and that all of the " +
+		"custom fields issue-sync requires are present. Every problem found is reported, " +
+		"rather than just the first.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems := cfg.LoadConfig(cmd).CheckConfigFile(cmd)
+
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			// The remaining checks need a valid configuration.
+			problems = append(problems, err)
+			return reportProblems(problems)
+		}
+
+		if _, err := clients.NewGitHubClient(config, ""); err != nil {
+			problems = append(problems, fmt.Errorf("could not connect to GitHub with the configured token: %v", err))
+		}
+
+		if err := clients.InferProjectKeys(config); err != nil {
+			problems = append(problems, err)
+		}
+
+		// Each JIRA instance is checked with its own client.
+		instances := append([]string{""}, config.GetJIRAInstances()...)
+		for _, name := range instances {
+			instance := config.ForInstance(name)
+			var found []error
+			if jiraClient, err := clients.NewJIRAClient(instance, jira.Project{}); err != nil {
+				found = []error{err}
+			} else {
+				found = instance.CheckJIRA(jiraClient.GetClient())
+			}
+			for _, problem := range found {
+				if name != "" {
+					problem = fmt.Errorf("JIRA instance %s: %v", name, problem)
+				}
+				problems = append(problems, problem)
+			}
+		}
+
+		return reportProblems(problems)
+	},
+}
+
+// reportProblems prints out every problem found by validateCmd, and returns
+// an error if there is any.
+func reportProblems(problems []error) error {
+	if len(problems) == 0 {
+		fmt.Println("The configuration is valid.")
+		return nil
+	}
+
+	for _, problem := range problems {
+		fmt.Printf("- %v\n", problem)
+	}
+	if len(problems) == 1 {
+		return errors.New("found 1 problem")
+	}
+	return fmt.Errorf("found %d problems", len(problems))
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+}
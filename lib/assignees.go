@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"sort"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// assigneeLogins returns the logins of the assignees of a GitHub issue, in
+// order.
+func assigneeLogins(ghIssue github.Issue) []string {
+	var logins []string
+	for _, a := range ghIssue.Assignees {
+		logins = append(logins, a.GetLogin())
+	}
+	if len(logins) == 0 && ghIssue.Assignee != nil {
+		logins = append(logins, ghIssue.Assignee.GetLogin())
+	}
+	return logins
+}
+
+// mappedAssignees returns the JIRA users, in `user-map`, of the assignees of
+// a GitHub issue, in order. Assignees who aren't mapped are left out.
+func mappedAssignees(config cfg.Config, ghIssue github.Issue) []string {
+	found := make(map[string]bool)
+	var users []string
+	for _, login := range assigneeLogins(ghIssue) {
+		if user, ok := config.GetJIRAUser(login); ok && !found[user] {
+			found[user] = true
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// jiraUserRef returns the reference to a JIRA user in the fields of issues:
+// by account ID on JIRA Cloud, and by username on JIRA Server.
+func jiraUserRef(config cfg.Config, user string) map[string]string {
+	if config.IsJIRACloud() {
+		return map[string]string{"accountId": user}
+	}
+	return map[string]string{"name": user}
+}
+
+// issueAssignee returns the JIRA user the JIRA issue of a GitHub issue is
+// assigned to, with the `assignee-mode` first or round-robin, or an empty
+// user if the GitHub issue is unassigned, and whether it's set. The first
+// mapped assignee wins, or, with round-robin, one of them is picked by the
+// number of the issue, so that the issues shared by the same people are
+// spread among them. The JIRA issue is left alone if no assignee is mapped.
+func issueAssignee(config cfg.Config, ghIssue TranslatedIssue) (string, bool) {
+	mode := config.GetAssigneeMode()
+	if mode != cfg.AssigneeFirst && mode != cfg.AssigneeRoundRobin {
+		return "", false
+	}
+	if len(assigneeLogins(ghIssue.Issue)) == 0 {
+		return "", true
+	}
+
+	users := mappedAssignees(config, ghIssue.Issue)
+	if len(users) == 0 {
+		return "", false
+	}
+	if mode == cfg.AssigneeRoundRobin {
+		return users[ghIssue.GetNumber()%len(users)], true
+	}
+	return users[0], true
+}
+
+// assigneeValue returns the value of the assignee field of a JIRA issue
+// assigned to the user, or nil, which unassigns it, for an empty user.
+func assigneeValue(config cfg.Config, user string) interface{} {
+	if user == "" {
+		return nil
+	}
+	return jiraUserRef(config, user)
+}
+
+// assigneeChanged reports whether the JIRA issue of a GitHub issue isn't
+// assigned to the JIRA user of its assignee. The user is compared to the
+// one last written, as recorded in the state, or else to the assignee of
+// the JIRA issue: by username on JIRA Server, and on JIRA Cloud, whose
+// account IDs aren't decoded, only by whether it's assigned.
+func assigneeChanged(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue) bool {
+	user, ok := issueAssignee(config, ghIssue)
+	if !ok {
+		return false
+	}
+
+	if state, found := config.GetState().GetIssue(ghIssue.GetID()); found && state.JIRAKey == jIssue.Key && state.Assignee != "" {
+		return state.Assignee != user
+	}
+	current := jIssue.Fields.Assignee
+	if config.IsJIRACloud() {
+		return (current != nil) != (user != "")
+	}
+	name := ""
+	if current != nil {
+		name = current.Name
+	}
+	return name != user
+}
+
+// recordedAssignee returns the JIRA user to record in the state as the
+// assignee of the JIRA issue of a GitHub issue, once it's synchronized, given
+// what was recorded before.
+func recordedAssignee(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, previous cfg.IssueState) string {
+	if user, ok := issueAssignee(config, ghIssue); ok {
+		return user
+	}
+	if previous.JIRAKey != jIssue.Key {
+		return ""
+	}
+	return previous.Assignee
+}
+
+// assigneeList returns the value of the multi-user field of the JIRA issue
+// of a GitHub issue, with the `assignee-mode` field: the JIRA users of all
+// its mapped assignees, sorted. It also returns whether it's set.
+func assigneeList(config cfg.Config, ghIssue TranslatedIssue) ([]map[string]string, bool) {
+	if config.GetAssigneeMode() != cfg.AssigneeField {
+		return nil, false
+	}
+
+	users := mappedAssignees(config, ghIssue.Issue)
+	sort.Strings(users)
+	list := make([]map[string]string, len(users))
+	for i, user := range users {
+		list[i] = jiraUserRef(config, user)
+	}
+	return list, true
+}
+
+// listedUsers returns the JIRA users of the value of a multi-user field, as
+// decoded from a JIRA issue, or as set by assigneeList, sorted.
+func listedUsers(value interface{}) []string {
+	var users []string
+	switch v := value.(type) {
+	case []map[string]string:
+		for _, u := range v {
+			users = append(users, u["accountId"]+u["name"])
+		}
+	case []interface{}:
+		for _, item := range v {
+			u, _ := item.(map[string]interface{})
+			if id, ok := u["accountId"].(string); ok && id != "" {
+				users = append(users, id)
+			} else if name, ok := u["name"].(string); ok {
+				users = append(users, name)
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// assigneeListChanged reports whether the multi-user field of the JIRA issue
+// of a GitHub issue doesn't list the JIRA users of its mapped assignees.
+func assigneeListChanged(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue) bool {
+	list, ok := assigneeList(config, ghIssue)
+	if !ok {
+		return false
+	}
+	return labelsDiffer(listedUsers(list), listedUsers(jIssue.Fields.Unknowns[config.GetFieldKey(cfg.AssigneesField)]))
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// BackfillOptions controls a historical import performed by Backfill.
+type BackfillOptions struct {
+	// From and To bound the creation date of the GitHub issues imported.
+	// A zero To means there is no upper bound.
+	From time.Time
+	To   time.Time
+
+	// Batch is the number of issues synchronized before pausing.
+	Batch int
+	// Pause is how long to wait between batches.
+	Pause time.Duration
+
+	// Checkpoint is the path of the file the progress of the import is
+	// saved to after every batch, so an interrupted backfill can resume.
+	Checkpoint string
+}
+
+// backfillCheckpoint maps a GitHub repo to the number of the last issue
+// which was successfully backfilled.
+type backfillCheckpoint map[string]int
+
+// loadCheckpoint reads the checkpoint file, returning an empty checkpoint
+// if it doesn't exist yet.
+func loadCheckpoint(path string) (backfillCheckpoint, error) {
+	cp := make(backfillCheckpoint)
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("unable to parse backfill checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint file.
+func (cp backfillCheckpoint) save(path string) error {
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Backfill performs an initial import of the GitHub issues of a repository
+// created within the range in the options. Issues are synchronized in batches,
+// pausing between each one to spread the load on both APIs, and the progress is
+// saved so that an interrupted import resumes where it stopped. Once all batches
+// are done, a verification pass checks that every issue has a JIRA counterpart.
+func Backfill(config cfg.Config, opts BackfillOptions, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
+	log := config.GetLogger()
+	repo := ghClient.GetRepo()
+
+	cp, err := loadCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// The `since` parameter is the update date, which can't be earlier than the
+	// creation date, so the client has already dropped issues created before From.
+	all, err := ghClient.ListIssues()
+	if err != nil {
+		return err
+	}
+
+	var ghIssues []github.Issue
+	for _, issue := range all {
+		created := issue.GetCreatedAt()
+		if created.Before(opts.From) || (!opts.To.IsZero() && created.After(opts.To)) {
+			continue
+		}
+		ghIssues = append(ghIssues, issue)
+	}
+
+	log.Infof("Backfilling %d issues of %s", len(ghIssues), repo)
+
+	if last, ok := cp[repo]; ok {
+		log.Infof("Resuming backfill of %s after #%d", repo, last)
+	}
+
+	var batch []github.Issue
+	for i, issue := range ghIssues {
+		// Issues are listed in ascending order of creation, so their numbers increase.
+		if issue.GetNumber() <= cp[repo] {
+			continue
+		}
+		batch = append(batch, issue)
+
+		if len(batch) < opts.Batch && i != len(ghIssues)-1 {
+			continue
+		}
+
+		if err := syncIssues(config, batch, ghClient, jiraClient); err != nil {
+			return err
+		}
+
+		last := batch[len(batch)-1].GetNumber()
+		log.Infof("Backfilled %s up to #%d", repo, last)
+
+		if !config.IsDryRun() {
+			cp[repo] = last
+			if err := cp.save(opts.Checkpoint); err != nil {
+				log.Errorf("Error saving backfill checkpoint: %v", err)
+			}
+		}
+
+		batch = nil
+		if i != len(ghIssues)-1 && opts.Pause > 0 {
+			log.Debugf("Pausing for %v", opts.Pause)
+			select {
+			case <-time.After(opts.Pause):
+			case <-config.Context().Done():
+				return config.Context().Err()
+			}
+		}
+	}
+
+	if config.IsDryRun() {
+		return nil
+	}
+
+	return verifyBackfill(config, ghIssues, jiraClient)
+}
+
+// verifyBackfill checks that every GitHub issue in the list has a JIRA
+// issue with a matching GitHub ID, and returns an error listing the issues
+// which are missing.
+func verifyBackfill(config cfg.Config, ghIssues []github.Issue, jiraClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	log.Info("Verifying backfill")
+
+	ids := make([]int, len(ghIssues))
+	for i, v := range ghIssues {
+		ids[i] = v.GetID()
+	}
+
+	jiraIssues, err := jiraClient.ListIssues(ids)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[int64]bool)
+	for _, jIssue := range jiraIssues {
+		if id, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID)); err == nil {
+			found[id] = true
+		}
+	}
+
+	var missing []int
+	for _, issue := range ghIssues {
+		if !found[int64(issue.GetID())] {
+			missing = append(missing, issue.GetNumber())
+		}
+	}
+
+	if len(missing) > 0 {
+		log.Errorf("%d issues have no JIRA issue: %v", len(missing), missing)
+		return fmt.Errorf("backfill verification failed: %d issues are missing from JIRA", len(missing))
+	}
+
+	log.Infof("All %d issues are present in JIRA", len(ghIssues))
+
+	return nil
+}
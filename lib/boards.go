@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// syncProjectStatus synchronizes the status of the item of the GitHub issue
+// in the GitHub project configured for its repo, if any, to the JIRA issue:
+// it's written to the custom field of the project board, if configured, and
+// the issue is moved to the transition or status the status is mapped to.
+// It returns whether the status is mapped, in which case it takes precedence
+// over the transitions of the state of the issue.
+func syncProjectStatus(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (bool, error) {
+	board, ok := config.GetProjectBoard(ghClient.GetRepo())
+	if !ok {
+		return false, nil
+	}
+
+	status, err := ghClient.GetProjectStatus(ghIssue.GetNumber(), board)
+	if err != nil {
+		return false, err
+	}
+
+	if key := board.GetCustomFieldKey(); key != "" {
+		if current, _ := jIssue.Fields.Unknowns.String(key); current != status {
+			_, err := jClient.UpdateIssue(jira.Issue{
+				Fields: &jira.IssueFields{
+					Type:     jIssue.Fields.Type,
+					Unknowns: map[string]interface{}{key: status},
+				},
+				Key: jIssue.Key,
+				ID:  jIssue.ID,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	target, ok := board.GetTransition(status)
+	if !ok {
+		return false, nil
+	}
+
+	return true, jClient.TransitionIssue(jIssue, target)
+}
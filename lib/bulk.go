@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"sort"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// pendingIssue is a JIRA issue to create from a GitHub issue in bulk, with
+// what its synchronization is completed with once it's created.
+type pendingIssue struct {
+	ghIssue TranslatedIssue
+	synced  TranslatedIssue
+	refs    *referenceResolver
+	jIssue  jira.Issue
+}
+
+// createIssues creates the JIRA issues of GitHub issues which don't have
+// one yet. Several issues, such as in an initial import, are created in
+// bulk, in batches of clients.MaxBulkIssues; a single one is created on its
+// own. Issues are created in the order of their GitHub numbers. Errors on
+// individual issues are logged rather than returned.
+func createIssues(config cfg.Config, ghIssues []TranslatedIssue, ghClient clients.GitHubClient, jClient clients.JIRAClient) {
+	if len(ghIssues) == 1 {
+		if err := CreateIssue(config, ghIssues[0], ghClient, jClient); err != nil {
+			issueLogger(config, ghClient.GetRepo(), ghIssues[0].GetNumber(), "").
+				Errorf("Error creating issue for #%d. Error: %v", ghIssues[0].GetNumber(), err)
+		}
+		return
+	}
+
+	sort.Slice(ghIssues, func(i, j int) bool {
+		return ghIssues[i].GetNumber() < ghIssues[j].GetNumber()
+	})
+
+	prepared := make([]*pendingIssue, len(ghIssues))
+	ForEachSlot(config.IssueSlots(), len(ghIssues), func(i int) {
+		if config.Context().Err() != nil {
+			return
+		}
+		jIssue, synced, refs, err := newJIRAIssue(config, ghIssues[i], ghClient, jClient)
+		if err != nil {
+			issueLogger(config, ghClient.GetRepo(), ghIssues[i].GetNumber(), "").
+				Errorf("Error creating issue for #%d. Error: %v", ghIssues[i].GetNumber(), err)
+			recordOutcome(config, ghClient.GetRepo(), ghIssues[i], "", OutcomeFailed, err)
+			return
+		}
+		prepared[i] = &pendingIssue{ghIssues[i], synced, refs, jIssue}
+	})
+
+	var pending []*pendingIssue
+	for _, p := range prepared {
+		if p != nil {
+			pending = append(pending, p)
+		}
+	}
+
+	for start := 0; start < len(pending) && config.Context().Err() == nil; start += clients.MaxBulkIssues {
+		end := start + clients.MaxBulkIssues
+		if end > len(pending) {
+			end = len(pending)
+		}
+		batch := pending[start:end]
+
+		jIssues := make([]jira.Issue, len(batch))
+		for i, p := range batch {
+			jIssues[i] = p.jIssue
+		}
+		created, errs := jClient.CreateIssues(jIssues)
+
+		ForEachSlot(config.IssueSlots(), len(batch), func(i int) {
+			p := batch[i]
+			log := issueLogger(config, ghClient.GetRepo(), p.ghIssue.GetNumber(), "")
+			if errs[i] != nil {
+				log.Errorf("Error creating issue for #%d. Error: %v", p.ghIssue.GetNumber(), errs[i])
+				recordOutcome(config, ghClient.GetRepo(), p.ghIssue, "", OutcomeFailed, errs[i])
+				return
+			}
+			if err := syncCreatedIssue(config, p.ghIssue, p.synced, p.refs, created[i], ghClient, jClient); err != nil {
+				log.Errorf("Error creating issue for #%d. Error: %v", p.ghIssue.GetNumber(), err)
+			}
+		})
+	}
+}
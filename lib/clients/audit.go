@@ -0,0 +1,267 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// maxAuditBody is the size above which the bodies of the requests and the
+// responses are summarized in the audit log rather than recorded.
+const maxAuditBody = 64 * 1024
+
+// jiraIssuePath matches the path of a JIRA issue, whose current fields are
+// recorded before it's edited.
+var jiraIssuePath = regexp.MustCompile(`/rest/api/2/issue/[^/]+$`)
+
+// auditRecord is an entry of the `audit-log`: a request which changed
+// something on GitHub or JIRA, who made it, the resource before it, for
+// edits, and the response.
+type auditRecord struct {
+	Time     time.Time       `json:"time"`
+	System   string          `json:"system"`
+	Actor    string          `json:"actor"`
+	Host     string          `json:"host"`
+	PID      int             `json:"pid"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Before   json.RawMessage `json:"before,omitempty"`
+	Status   int             `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// audit is the audit log the records of all of the clients are appended to.
+var audit = struct {
+	sync.Mutex
+	path string
+	file *os.File
+}{}
+
+// auditTransport is an http.RoundTripper which appends the requests made
+// through it which change something, as opposed to reading, to the audit
+// log.
+type auditTransport struct {
+	base   http.RoundTripper
+	config cfg.Config
+	system string
+	actor  string
+}
+
+// newAuditTransport wraps a transport so that the changes made through it
+// are audited, as made to the system ("github" or "jira") by the actor, if
+// an `audit-log` is configured. A nil base is the default transport.
+func newAuditTransport(config cfg.Config, base http.RoundTripper, system, actor string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if config.GetAuditLog() == "" {
+		return base
+	}
+	return auditTransport{base: base, config: config, system: system, actor: actor}
+}
+
+// jiraActor returns who issue-sync changes JIRA as: the `jira-user`, or else
+// how it authenticates.
+func jiraActor(config cfg.Config) string {
+	if config.IsPATAuth() {
+		return "personal access token"
+	}
+	if user := config.GetConfigString("jira-user"); user != "" {
+		return user
+	}
+	return "oauth " + config.GetConfigString("jira-consumer-key")
+}
+
+// githubActor returns who issue-sync changes GitHub as: the GitHub App, or
+// the owner of the `github-token`.
+func githubActor(config cfg.Config) string {
+	if config.UsesGitHubApp() {
+		return fmt.Sprintf("app %d", config.GetGitHubAppID())
+	}
+	return "token"
+}
+
+// RoundTrip performs the request with the base transport, auditing it
+// unless it only reads. GitHub GraphQL requests are only audited if they're
+// mutations.
+func (t auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "GET" || req.Method == "HEAD" {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(req.URL.Path, "/graphql") && !isMutation(body) {
+		return t.base.RoundTrip(req)
+	}
+
+	rec := auditRecord{
+		Time:    time.Now(),
+		System:  t.system,
+		Actor:   t.actor,
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Request: auditBody(body, req.Header.Get("Content-Type")),
+	}
+	if req.Method == "PUT" || req.Method == "PATCH" {
+		rec.Before = t.before(req, body)
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = ioutil.NopCloser(bytes.NewReader(b))
+		if err != nil {
+			rec.Error = err.Error()
+		}
+		rec.Status = res.StatusCode
+		rec.Response = auditBody(b, res.Header.Get("Content-Type"))
+	}
+
+	writeAudit(t.config, rec)
+	return res, err
+}
+
+// before returns the resource a request is about to edit, as returned by
+// a GET of its URL. Only the fields of a JIRA issue which are edited are
+// requested. Nothing is returned if it can't be retrieved.
+func (t auditTransport) before(req *http.Request, body []byte) json.RawMessage {
+	u := *req.URL
+	if t.system == "jira" && jiraIssuePath.MatchString(u.Path) {
+		var edit struct {
+			Fields map[string]json.RawMessage `json:"fields"`
+		}
+		if err := json.Unmarshal(body, &edit); err == nil && len(edit.Fields) > 0 {
+			keys := make([]string, 0, len(edit.Fields))
+			for key := range edit.Fields {
+				keys = append(keys, key)
+			}
+			q := u.Query()
+			q.Set("fields", strings.Join(keys, ","))
+			u.RawQuery = q.Encode()
+		}
+	}
+
+	get, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil
+	}
+	get = get.WithContext(req.Context())
+	for name, values := range req.Header {
+		if name != "Content-Type" && name != "Content-Length" {
+			get.Header[name] = values
+		}
+	}
+
+	res, err := t.base.RoundTrip(get)
+	if err != nil {
+		return nil
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil || res.StatusCode != http.StatusOK {
+		return nil
+	}
+	return auditBody(b, res.Header.Get("Content-Type"))
+}
+
+// requestBody returns the body of a request, leaving it to be sent.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// isMutation returns whether the body of a GitHub GraphQL request is a
+// mutation.
+func isMutation(body []byte) bool {
+	var request graphQLRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimSpace(request.Query), "mutation")
+}
+
+// auditBody returns a body as it's recorded in the audit log: as it is if
+// it's JSON, and otherwise, or if it's too large, as a summary such as
+// "2048 bytes of image/png".
+func auditBody(b []byte, contentType string) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	if len(b) <= maxAuditBody && json.Valid(b) {
+		return json.RawMessage(b)
+	}
+	if contentType == "" {
+		contentType = "unknown content"
+	}
+	summary, _ := json.Marshal(fmt.Sprintf("%d bytes of %s", len(b), contentType))
+	return summary
+}
+
+// writeAudit appends the record to the `audit-log`, as a line of JSON. The
+// file is only ever appended to.
+func writeAudit(config cfg.Config, rec auditRecord) {
+	log := config.GetLogger()
+
+	rec.Host, _ = os.Hostname()
+	rec.PID = os.Getpid()
+	b, err := json.Marshal(rec)
+	if err != nil {
+		log.Errorf("Error encoding audit record: %v", err)
+		return
+	}
+
+	audit.Lock()
+	defer audit.Unlock()
+
+	path := config.GetAuditLog()
+	if audit.file == nil || audit.path != path {
+		if audit.file != nil {
+			audit.file.Close()
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			audit.file = nil
+			log.Errorf("Error opening audit log %s: %v", path, err)
+			return
+		}
+		audit.path, audit.file = path, f
+	}
+	if _, err := audit.file.Write(append(b, '\n')); err != nil {
+		log.Errorf("Error writing audit log %s: %v", path, err)
+	}
+}
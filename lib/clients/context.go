@@ -0,0 +1,25 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextTransport is an http.RoundTripper which sends the requests made
+// through it with a context, so that they're cancelled along with it. The
+// JIRA library doesn't take contexts, unlike the GitHub one.
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+// newContextTransport wraps a transport so that its requests are cancelled
+// along with the context.
+func newContextTransport(ctx context.Context, base http.RoundTripper) http.RoundTripper {
+	return contextTransport{base: base, ctx: ctx}
+}
+
+// RoundTrip performs the request with the base transport, with the context.
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
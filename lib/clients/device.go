@@ -0,0 +1,110 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// githubComLoginURL is the base URL of the OAuth endpoints of github.com.
+const githubComLoginURL = "https://github.com/login/"
+
+// deviceCode is the response of GitHub to a device authorization request.
+type deviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// deviceToken is the response of GitHub while polling for an access token.
+// Until the user has authorized the device, Error is set instead of AccessToken.
+type deviceToken struct {
+	AccessToken string `json:"access_token"`
+	Scope       string `json:"scope"`
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// postLoginForm posts a form to one of the GitHub OAuth endpoints, and
+// decodes the JSON response into v.
+func postLoginForm(config cfg.Config, path string, form url.Values, v interface{}) error {
+	req, err := http.NewRequest("POST", githubLoginURL(config)+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub returned %s", res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// GitHubTokenFromDevice performs the OAuth device flow with the client ID
+// of a GitHub OAuth App: it prints the code the user must enter on GitHub,
+// then polls until the user authorizes the device, and returns the access
+// token, which has the requested scopes.
+func GitHubTokenFromDevice(config cfg.Config, clientID string, scopes []string) (string, error) {
+	log := config.GetLogger()
+
+	code := new(deviceCode)
+	err := postLoginForm(config, "device/code", url.Values{
+		"client_id": {clientID},
+		"scope":     {strings.Join(scopes, " ")},
+	}, code)
+	if err != nil {
+		return "", fmt.Errorf("unable to get device code: %v", err)
+	}
+
+	fmt.Printf("Please go to the following URL in your browser:\n%v\n\n", code.VerificationURI)
+	fmt.Printf("And enter the code: %s\n\n", code.UserCode)
+
+	interval := time.Duration(code.Interval) * time.Second
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	for time.Now().Before(deadline) {
+		<-time.After(interval)
+
+		token := new(deviceToken)
+		err := postLoginForm(config, "oauth/access_token", url.Values{
+			"client_id":   {clientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, token)
+		if err != nil {
+			return "", fmt.Errorf("unable to get access token: %v", err)
+		}
+
+		switch token.Error {
+		case "":
+			log.Debugf("Authorized with scopes: %s", token.Scope)
+			return token.AccessToken, nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			// GitHub asks for the interval to be increased by 5 seconds.
+			interval += 5 * time.Second
+			continue
+		default:
+			return "", fmt.Errorf("unable to get access token: %s", token.Description)
+		}
+	}
+
+	return "", errors.New("the device code expired before it was authorized")
+}
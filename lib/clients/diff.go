@@ -0,0 +1,196 @@
+package clients
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxDiffLines is the number of lines above which two texts aren't compared
+// line by line, but shown as entirely replaced, to bound the cost of the diff.
+const maxDiffLines = 2000
+
+// ANSI escape sequences used to colorize diffs.
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorCyan   = "\x1b[36m"
+	colorYellow = "\x1b[33m"
+)
+
+// fieldDiff is the current and new value of a field of a JIRA issue or comment.
+type fieldDiff struct {
+	name     string
+	old, new string
+}
+
+// diffLine is a line of a unified diff: ' ' for context, '-' for removed,
+// and '+' for added lines.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// splitLines splits a text in lines; the empty text has none.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines compares two texts line by line, using their longest common
+// subsequence, and returns every line of both marked as kept, removed, or added.
+func diffLines(old, new string) []diffLine {
+	a, b := splitLines(old), splitLines(new)
+
+	var lines []diffLine
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		for _, l := range a {
+			lines = append(lines, diffLine{'-', l})
+		}
+		for _, l := range b {
+			lines = append(lines, diffLine{'+', l})
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+
+	return lines
+}
+
+// hunks drops the unchanged lines of a diff which are further than
+// diffContext lines from a change, marking each gap with a nil entry.
+func hunks(lines []diffLine) []*diffLine {
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		for k := i - diffContext; k <= i+diffContext; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+
+	var out []*diffLine
+	gap := false
+	for i := range lines {
+		if !keep[i] {
+			gap = true
+			continue
+		}
+		if gap && len(out) > 0 {
+			out = append(out, nil)
+		}
+		gap = false
+		out = append(out, &lines[i])
+	}
+	return out
+}
+
+// useColor reports whether diffs written to standard output are colorized:
+// only when it's a terminal, and NO_COLOR isn't set.
+func useColor() bool {
+	return os.Getenv("NO_COLOR") == "" && terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// diffLock serializes the writes of diffs, so that the diffs of the issues
+// synchronized at the same time don't interleave.
+var diffLock sync.Mutex
+
+// printDiff writes a unified, field-by-field diff of the changes described
+// by the header to w. Fields which don't change are omitted. The diff is
+// built in full first, and then written at once.
+func printDiff(w io.Writer, header string, fields []fieldDiff) {
+	var buf bytes.Buffer
+	color := useColor()
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	fmt.Fprintln(&buf, paint(colorBold, header))
+
+	changed := false
+	for _, f := range fields {
+		if f.old == f.new {
+			continue
+		}
+		changed = true
+
+		fmt.Fprintln(&buf, paint(colorCyan, fmt.Sprintf("@@ %s @@", f.name)))
+		for _, l := range hunks(diffLines(f.old, f.new)) {
+			switch {
+			case l == nil:
+				fmt.Fprintln(&buf, paint(colorYellow, "  ..."))
+			case l.op == '-':
+				fmt.Fprintln(&buf, paint(colorRed, "-"+l.text))
+			case l.op == '+':
+				fmt.Fprintln(&buf, paint(colorGreen, "+"+l.text))
+			default:
+				fmt.Fprintln(&buf, " "+l.text)
+			}
+		}
+	}
+	if !changed {
+		fmt.Fprintln(&buf, "  (no changes)")
+	}
+
+	fmt.Fprintln(&buf)
+
+	diffLock.Lock()
+	defer diffLock.Unlock()
+	w.Write(buf.Bytes())
+}
@@ -1,12 +1,11 @@
 package clients
 
 import (
-	"context"
 	"fmt"
-
+	"net/http"
+	"net/url"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/coreos/issue-sync/cfg"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
@@ -17,9 +16,21 @@ import (
 // clients, or mock clients for testing.
 type GitHubClient interface {
 	ListIssues() ([]github.Issue, error)
+	GetIssue(number int) (github.Issue, error)
+	GetRepoIssue(repo string, number int) (github.Issue, error)
+	EditIssue(number int, issue github.IssueRequest) (github.Issue, error)
 	ListComments(issue github.Issue) ([]*github.IssueComment, error)
 	GetUser(login string) (github.User, error)
 	GetRateLimits() (github.RateLimits, error)
+	ListTopics() ([]string, error)
+	ListOwnerRepos(owner string) (map[string][]string, error)
+	DownloadImage(uri string) ([]byte, string, error)
+	ListTeamMembers(org, team string) ([]string, error)
+	GetProjectStatus(number int, board cfg.ProjectBoard) (string, error)
+	GetParentIssue(number int) (*github.Issue, error)
+	GetIssueType(number int) (string, error)
+	GetStateReason(number int) (string, error)
+	ListPinnedIssues() ([]int, error)
 	GetRepo() string
 	GetRepoSplit() (string, string)
 }
@@ -28,35 +39,38 @@ type GitHubClient interface {
 // requests against the GitHub REST API. It is the canonical implementation
 // of GitHubClient.
 type realGHClient struct {
-	config cfg.Config
-	client github.Client
-	repo string
+	config   cfg.Config
+	client   *github.Client
+	repo     string
+	throttle *rateThrottle
+	teams    *teamCache
 }
 
-// ListIssues returns the list of GitHub issues since the last run of the tool.
+// ListIssues returns the list of GitHub issues since the last successful sync
+// of the repository.
 func (g realGHClient) ListIssues() ([]github.Issue, error) {
 	log := g.config.GetLogger()
 
-	ctx := context.Background()
+	ctx := conditional(g.config.Context())
 
 	user, repo := g.GetRepoSplit()
 
-	// Set it so that it will run the loop once, and it'll be updated in the loop.
-	pages := 1
+	opts := &github.IssueListByRepoOptions{
+		Since:     g.config.GetProjectSince(g.repo),
+		State:     g.config.GetListedStates(g.repo),
+		Sort:      "created",
+		Direction: "asc",
+		ListOptions: github.ListOptions{
+			PerPage: g.config.GetGitHubPageSize(),
+		},
+	}
+
 	var issues []github.Issue
 
-	for page := 0; page < pages; page++ {
+	// Walk the pages until GitHub doesn't report a next one.
+	for {
 		is, res, err := g.request(func() (interface{}, *github.Response, error) {
-			return g.client.Issues.ListByRepo(ctx, user, repo, &github.IssueListByRepoOptions{
-				Since:     g.config.GetSinceParam(),
-				State:     "all",
-				Sort:      "created",
-				Direction: "asc",
-				ListOptions: github.ListOptions{
-					Page:    page,
-					PerPage: 100,
-				},
-			})
+			return g.client.Issues.ListByRepo(ctx, user, repo, opts)
 		})
 		if err != nil {
 			return nil, err
@@ -67,16 +81,17 @@ func (g realGHClient) ListIssues() ([]github.Issue, error) {
 			return nil, fmt.Errorf("get GitHub issues failed: expected []*github.Issue; got %T", is)
 		}
 
-		var issuePage []github.Issue
 		for _, v := range issuePointers {
 			// If PullRequestLinks is not nil, it's a Pull Request
 			if v.PullRequestLinks == nil {
-				issuePage = append(issuePage, *v)
+				issues = append(issues, *v)
 			}
 		}
 
-		pages = res.LastPage
-		issues = append(issues, issuePage...)
+		if res.NextPage == 0 {
+			break
+		}
+		opts.Page = res.NextPage
 	}
 
 	log.Debug("Collected all GitHub issues")
@@ -84,38 +99,125 @@ func (g realGHClient) ListIssues() ([]github.Issue, error) {
 	return issues, nil
 }
 
-// ListComments returns the list of all comments on a GitHub issue in
-// ascending order of creation.
-func (g realGHClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+// GetIssue returns a single GitHub issue from the configured repository
+// according to its number.
+func (g realGHClient) GetIssue(number int) (github.Issue, error) {
+	return g.GetRepoIssue(g.repo, number)
+}
+
+// GetRepoIssue returns a single GitHub issue of any repo, such as one which
+// an issue references, by its number.
+func (g realGHClient) GetRepoIssue(repo string, number int) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	ctx := g.config.Context()
+	user, name := g.config.GetRepo(repo)
+	i, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.Get(ctx, user, name, number)
+	})
+	if IsGone(err) {
+		log.Debugf("GitHub issue %s#%d doesn't exist. Error: %v", repo, number, err)
+		return github.Issue{}, err
+	} else if err != nil {
+		log.Errorf("Error retrieving GitHub issue %s#%d. Error: %v", repo, number, err)
+		return github.Issue{}, err
+	}
+	issue, ok := i.(*github.Issue)
+	if !ok {
+		log.Errorf("Get GitHub issue did not return issue! Got: %v", i)
+		return github.Issue{}, fmt.Errorf("Get GitHub issue failed: expected *github.Issue; got %T", i)
+	}
+
+	return *issue, nil
+}
+
+// IsGone returns whether an error of the GitHub API is that the object
+// requested doesn't exist (anymore), such as a deleted issue.
+func IsGone(err error) bool {
+	e, ok := err.(*github.ErrorResponse)
+	if !ok || e.Response == nil {
+		return false
+	}
+	return e.Response.StatusCode == http.StatusNotFound || e.Response.StatusCode == http.StatusGone
+}
+
+// EditIssue updates the fields set on the request on a GitHub issue, and
+// returns the issue as it exists after the update.
+func (g realGHClient) EditIssue(number int, issue github.IssueRequest) (github.Issue, error) {
 	log := g.config.GetLogger()
 
-	ctx := context.Background()
+	ctx := g.config.Context()
 	user, repo := g.GetRepoSplit()
-	c, _, err := g.request(func() (interface{}, *github.Response, error) {
-		return g.client.Issues.ListComments(ctx, user, repo, issue.GetNumber(), &github.IssueListCommentsOptions{
-			Sort:      "created",
-			Direction: "asc",
-		})
+	i, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.Edit(ctx, user, repo, number, &issue)
 	})
 	if err != nil {
-		log.Errorf("Error retrieving GitHub comments for issue #%d. Error: %v.", issue.GetNumber(), err)
-		return nil, err
+		log.Errorf("Error updating GitHub issue #%d. Error: %v", number, err)
+		return github.Issue{}, err
 	}
-	comments, ok := c.([]*github.IssueComment)
+	is, ok := i.(*github.Issue)
 	if !ok {
-		log.Errorf("Get GitHub comments did not return comments! Got: %v", c)
-		return nil, fmt.Errorf("Get GitHub comments failed: expected []*github.IssueComment; got %T", c)
+		log.Errorf("Edit GitHub issue did not return issue! Got: %v", i)
+		return github.Issue{}, fmt.Errorf("Edit GitHub issue failed: expected *github.Issue; got %T", i)
+	}
+
+	return *is, nil
+}
+
+// ListComments returns the list of all comments on a GitHub issue in
+// ascending order of creation.
+func (g realGHClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+	log := g.config.GetLogger()
+
+	ctx := conditional(g.config.Context())
+	user, repo := g.GetRepoSplit()
+	opts := &github.IssueListCommentsOptions{
+		Sort:      "created",
+		Direction: "asc",
+		ListOptions: github.ListOptions{
+			PerPage: g.config.GetGitHubPageSize(),
+		},
+	}
+
+	var comments []*github.IssueComment
+
+	// Walk the pages until GitHub doesn't report a next one.
+	for {
+		c, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.Issues.ListComments(ctx, user, repo, issue.GetNumber(), opts)
+		})
+		if err != nil {
+			log.Errorf("Error retrieving GitHub comments for issue #%d. Error: %v.", issue.GetNumber(), err)
+			return nil, err
+		}
+		page, ok := c.([]*github.IssueComment)
+		if !ok {
+			log.Errorf("Get GitHub comments did not return comments! Got: %v", c)
+			return nil, fmt.Errorf("Get GitHub comments failed: expected []*github.IssueComment; got %T", c)
+		}
+
+		comments = append(comments, page...)
+
+		if res.NextPage == 0 {
+			break
+		}
+		opts.Page = res.NextPage
 	}
 
 	return comments, nil
 }
 
-// GetUser returns a GitHub user from its login.
+// GetUser returns a GitHub user from its login. The users are cached by
+// the process and in the state, for cfg.CachedUserTTL.
 func (g realGHClient) GetUser(login string) (github.User, error) {
 	log := g.config.GetLogger()
 
+	if user, ok := cachedUser(g.config, login); ok {
+		return user, nil
+	}
+
 	u, _, err := g.request(func() (interface{}, *github.Response, error) {
-		return g.client.Users.Get(context.Background(), login)
+		return g.client.Users.Get(g.config.Context(), login)
 	})
 
 	if err != nil {
@@ -127,6 +229,7 @@ func (g realGHClient) GetUser(login string) (github.User, error) {
 		log.Errorf("Get GitHub user did not return user! Got: %v", u)
 		return github.User{}, fmt.Errorf("Get GitHub user failed: expected *github.User; got %T", u)
 	}
+	cacheUser(g.config, *user)
 
 	return *user, nil
 }
@@ -136,11 +239,17 @@ func (g realGHClient) GetUser(login string) (github.User, error) {
 func (g realGHClient) GetRateLimits() (github.RateLimits, error) {
 	log := g.config.GetLogger()
 
-	ctx := context.Background()
+	ctx := g.config.Context()
 
 	rl, _, err := g.request(func() (interface{}, *github.Response, error) {
 		return g.client.RateLimits(ctx)
 	})
+	if e, ok := err.(*github.ErrorResponse); ok && isGitHubEnterprise(g.config) && e.Response != nil && e.Response.StatusCode == http.StatusNotFound {
+		// GitHub Enterprise Server responds with a 404 when rate limiting
+		// is disabled, in which case there's no limit to report.
+		log.Debug("Rate limiting is disabled on GitHub Enterprise Server")
+		return github.RateLimits{}, nil
+	}
 	if err != nil {
 		log.Errorf("Error connecting to GitHub; check your token. Error: %v", err)
 		return github.RateLimits{}, err
@@ -154,7 +263,35 @@ func (g realGHClient) GetRateLimits() (github.RateLimits, error) {
 	return *rate, nil
 }
 
-const retryBackoffRoundRatio = time.Millisecond / time.Nanosecond
+// ListTopics returns the topics of the configured repository. The version of
+// the GitHub library we use doesn't support topics, so the request is built
+// here, with the preview media type topics require.
+func (g realGHClient) ListTopics() ([]string, error) {
+	log := g.config.GetLogger()
+
+	ctx := conditional(g.config.Context())
+	user, repo := g.GetRepoSplit()
+
+	req, err := g.client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/topics", user, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.mercy-preview+json")
+
+	topics := new(struct {
+		Names []string `json:"names"`
+	})
+	_, _, err = g.request(func() (interface{}, *github.Response, error) {
+		res, err := g.client.Do(ctx, req, topics)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving topics of GitHub repo %s. Error: %v", g.repo, err)
+		return nil, err
+	}
+
+	return topics.Names, nil
+}
 
 // GetRepo returns the user/repo form name of the GitHub repository the client
 // has been configured with.
@@ -171,34 +308,69 @@ func (g realGHClient) GetRepoSplit() (string, string) {
 }
 
 // request takes an API function from the GitHub library
-// and calls it with exponential backoff. If the function succeeds, it
-// returns the expected value and the GitHub API response, as well as a nil
-// error. If it continues to fail until a maximum time is reached, it returns
-// a nil result as well as the returned HTTP response and a timeout error.
+// and calls it with exponential backoff (see retry). If the function succeeds,
+// it returns the expected value and the GitHub API response, as well as a nil
+// error. If it fails with an error which isn't transient, or continues to fail
+// until the retries are exhausted, it returns a nil result as well as the
+// returned HTTP response and the last error.
+// Requests are slowed down when the rate limit budget is low, and wait
+// for the rate limit to be reset (or for the Retry-After delay, for
+// secondary rate limits) when it is hit.
 func (g realGHClient) request(f func() (interface{}, *github.Response, error)) (interface{}, *github.Response, error) {
 	log := g.config.GetLogger()
 
 	var ret interface{}
 	var res *github.Response
 
-	op := func() error {
+	err := retry(g.config, func() retryResult {
 		var err error
-		ret, res, err = f()
-		return err
-	}
+		for waits := 0; ; waits++ {
+			g.throttle.wait(g.config, log)
 
-	b := backoff.NewExponentialBackOff()
-	b.MaxElapsedTime = g.config.GetTimeout()
+			ret, res, err = f()
+			g.throttle.update(res)
 
-	backoffErr := backoff.RetryNotify(op, b, func(err error, duration time.Duration) {
-		// Round to a whole number of milliseconds
-		duration /= retryBackoffRoundRatio // Convert nanoseconds to milliseconds
-		duration *= retryBackoffRoundRatio // Convert back so it appears correct
-
-		log.Errorf("Error performing operation; retrying in %v: %v", duration, err)
+			d, limited := rateLimitDelay(err)
+			if !limited || waits == maxRateLimitWaits {
+				return githubRetryResult(res, err)
+			}
+			log.Warningf("GitHub rate limit hit; retrying in %v: %v", d.Round(time.Second), err)
+			if !sleep(g.config, d) {
+				return githubRetryResult(res, err)
+			}
+		}
 	})
 
-	return ret, res, backoffErr
+	return ret, res, err
+}
+
+// dryrunGHClient is an implementation of GitHubClient which performs all
+// GET requests the same as the realGHClient, but does not perform any
+// unsafe requests which may modify server data, instead printing out the
+// actions it is asked to perform without making the request.
+type dryrunGHClient struct {
+	realGHClient
+}
+
+// EditIssue prints out the fields that would be set on the GitHub issue
+// were it to be updated according to the request. It returns the issue
+// as it currently exists.
+func (g dryrunGHClient) EditIssue(number int, issue github.IssueRequest) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Update GitHub issue #%d:", number)
+	if issue.Title != nil {
+		log.Infof("  Title: %s", issue.GetTitle())
+	}
+	if issue.State != nil {
+		log.Infof("  State: %s", issue.GetState())
+	}
+	log.Info("")
+
+	recordPlan(g.config, PlanAction{Action: PlanEditGitHub, Repo: g.repo, Number: number, Request: &issue})
+
+	return g.GetIssue(number)
 }
 
 // NewGitHubClient creates a GitHubClient and returns it; which
@@ -206,27 +378,50 @@ func (g realGHClient) request(f func() (interface{}, *github.Response, error)) (
 // run. For example, a dry-run clients may be created which does
 // not make any requests that would change anything on the server,
 // but instead simply prints out the actions that it's asked to take.
+// With `github-graphql`, the issues are listed through the GraphQL API.
 func NewGitHubClient(config cfg.Config, repo string) (GitHubClient, error) {
 	var ret GitHubClient
 
 	log := config.GetLogger()
 
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: config.GetConfigString("github-token")},
-	)
+	ctx := config.Context()
+	ts, err := githubTokenSource(config)
+	if err != nil {
+		return realGHClient{}, err
+	}
 	tc := oauth2.NewClient(ctx, ts)
+	tc.Transport = newETagTransport(config, newAuditTransport(config, newUsageTransport(tc.Transport, true), "github", githubActor(config)))
 
 	client := github.NewClient(tc)
+	if isGitHubEnterprise(config) {
+		if client.BaseURL, err = url.Parse(githubURL(config)); err != nil {
+			return realGHClient{}, err
+		}
+		if client.UploadURL, err = url.Parse(githubUploadURL(config)); err != nil {
+			return realGHClient{}, err
+		}
+		log.Debugf("Using GitHub Enterprise Server at %s", client.BaseURL)
+	}
 
-	ret = realGHClient{
-		config: config,
-		client: *client,
-		repo: repo,
+	real := realGHClient{
+		config:   config,
+		client:   client,
+		repo:     repo,
+		throttle: newRateThrottle(),
+		teams:    newTeamCache(),
+	}
+
+	if config.IsDryRun() {
+		ret = dryrunGHClient{real}
+	} else {
+		ret = real
+	}
+	if config.UsesGitHubGraphQL() {
+		ret = newGraphQLGHClient(ret, real)
 	}
 
 	// Make a request so we can check that we can connect fine.
-	_, err := ret.GetRateLimits()
+	_, err = ret.GetRateLimits()
 	if err != nil {
 		return realGHClient{}, err
 	}
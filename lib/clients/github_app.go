@@ -0,0 +1,163 @@
+package clients
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"golang.org/x/oauth2"
+)
+
+// githubAPIURL is the base URL of the REST API of github.com.
+const githubAPIURL = "https://api.github.com/"
+
+// appTokenMargin is how long before their expiry installation tokens are
+// refreshed, so that a token doesn't expire while a request is in flight.
+const appTokenMargin = 5 * time.Minute
+
+// githubTokenSource returns the source of the tokens GitHub clients
+// authenticate with: installation tokens of the GitHub App if one is
+// configured, or else the `github-token`. The installation token source is
+// kept by the configuration, so a reloaded configuration gets a new one.
+func githubTokenSource(config cfg.Config) (oauth2.TokenSource, error) {
+	if !config.UsesGitHubApp() {
+		return oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: config.GetConfigString("github-token")},
+		), nil
+	}
+
+	return config.GitHubAppTokenSource(func() (oauth2.TokenSource, error) {
+		key, err := appPrivateKey(config.GetConfigString("github-app-private-key-path"))
+		if err != nil {
+			return nil, err
+		}
+		return oauth2.ReuseTokenSource(nil, appTokenSource{
+			config:         config,
+			appID:          config.GetGitHubAppID(),
+			installationID: config.GetGitHubAppInstallationID(),
+			key:            key,
+		}), nil
+	})
+}
+
+// appPrivateKey reads the PEM-encoded RSA private key of a GitHub App.
+func appPrivateKey(path string) (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read GitHub App private key: %v", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("unable to decode GitHub App private key PEM block")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub App private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// appTokenSource requests installation tokens of a GitHub App, authenticating
+// as the app with a JSON Web Token signed by its private key.
+type appTokenSource struct {
+	config         cfg.Config
+	appID          int
+	installationID int
+	key            *rsa.PrivateKey
+}
+
+// jwt returns a JSON Web Token authenticating as the app, valid for a few
+// minutes. It's backdated by a minute to allow for clock drift.
+func (s appTokenSource) jwt() (string, error) {
+	now := time.Now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": int64(s.appID),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token requests a new installation token. It's called by the reusing token
+// source whenever the previous token is about to expire.
+func (s appTokenSource) Token() (*oauth2.Token, error) {
+	log := s.config.GetLogger()
+
+	jwt, err := s.jwt()
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign GitHub App token: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%sapp/installations/%d/access_tokens", githubURL(s.config), s.installationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	client := &http.Client{
+		Transport: newUsageTransport(nil, true),
+		Timeout:   s.config.GetTimeout(),
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to request GitHub App installation token: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("GitHub refused an installation token for installation %d: %s: %s", s.installationID, res.Status, body)
+	}
+
+	token := new(struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	})
+	if err := json.NewDecoder(res.Body).Decode(token); err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub App installation token: %v", err)
+	}
+
+	log.Debugf("Obtained GitHub App installation token expiring at %v", token.ExpiresAt)
+
+	return &oauth2.Token{
+		AccessToken: token.Token,
+		Expiry:      token.ExpiresAt.Add(-appTokenMargin),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package clients
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// enterpriseAPIPath and enterpriseUploadPath are the paths of the REST API
+// and of the upload API of a GitHub Enterprise Server instance.
+const (
+	enterpriseAPIPath    = "/api/v3/"
+	enterpriseUploadPath = "/api/uploads/"
+)
+
+// enterpriseGraphQLPath is the path of the GraphQL API of a GitHub
+// Enterprise Server instance.
+const enterpriseGraphQLPath = "/api/graphql"
+
+// githubURL returns the base URL of the GitHub REST API: `github-uri`, for
+// GitHub Enterprise Server, or else that of github.com.
+func githubURL(config cfg.Config) string {
+	if uri := config.GetConfigString("github-uri"); uri != "" {
+		return withTrailingSlash(uri)
+	}
+	return githubAPIURL
+}
+
+// githubUploadURL returns the base URL of the GitHub upload API. For GitHub
+// Enterprise Server, it's `github-upload-uri`, or else derived from the REST
+// API URL, as both are served by the same host.
+func githubUploadURL(config cfg.Config) string {
+	if uri := config.GetConfigString("github-upload-uri"); uri != "" {
+		return withTrailingSlash(uri)
+	}
+	uri := githubURL(config)
+	if strings.HasSuffix(uri, enterpriseAPIPath) {
+		return strings.TrimSuffix(uri, enterpriseAPIPath) + enterpriseUploadPath
+	}
+	return uri
+}
+
+// githubGraphQLURL returns the URL of the GitHub GraphQL API, which GitHub
+// Enterprise Server serves next to its REST API.
+func githubGraphQLURL(config cfg.Config) string {
+	uri := githubURL(config)
+	if strings.HasSuffix(uri, enterpriseAPIPath) {
+		return strings.TrimSuffix(uri, enterpriseAPIPath) + enterpriseGraphQLPath
+	}
+	return uri + "graphql"
+}
+
+// githubLoginURL returns the base URL of the GitHub OAuth endpoints, which
+// are served by the web host of GitHub Enterprise Server rather than by its
+// API.
+func githubLoginURL(config cfg.Config) string {
+	if !isGitHubEnterprise(config) {
+		return githubComLoginURL
+	}
+	u, err := url.Parse(githubURL(config))
+	if err != nil {
+		return githubComLoginURL
+	}
+	return u.Scheme + "://" + u.Host + "/login/"
+}
+
+// isGitHubEnterprise returns whether issue-sync is configured to use a GitHub
+// Enterprise Server instance.
+func isGitHubEnterprise(config cfg.Config) bool {
+	return config.GetConfigString("github-uri") != ""
+}
+
+// withTrailingSlash returns the URL with a trailing slash, which the GitHub
+// library requires of its base URLs.
+func withTrailingSlash(uri string) string {
+	if strings.HasSuffix(uri, "/") {
+		return uri
+	}
+	return uri + "/"
+}
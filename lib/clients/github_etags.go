@@ -0,0 +1,123 @@
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// conditionalKey marks the contexts of the requests of the GitHub list
+// calls whose responses are cached.
+type conditionalKey struct{}
+
+// conditional returns a context whose requests are made conditionally with
+// `github-etag-cache`.
+func conditional(ctx context.Context) context.Context {
+	return context.WithValue(ctx, conditionalKey{}, true)
+}
+
+// etagTransport is an http.RoundTripper which caches the responses of the
+// GitHub list calls in the state, with their ETag and Last-Modified
+// validators, and makes the calls conditionally. GitHub answers with a 304
+// when the response didn't change, which doesn't count against the rate
+// limit, and the cached response is returned instead.
+type etagTransport struct {
+	config cfg.Config
+	base   http.RoundTripper
+}
+
+// newETagTransport wraps a transport so that the list calls are cached, if
+// `github-etag-cache` is set. A nil base is the default transport.
+func newETagTransport(config cfg.Config, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !config.UsesETagCache() {
+		return base
+	}
+	return etagTransport{config: config, base: base}
+}
+
+// responseKey returns the key of the cached response of a request: its URL,
+// without the since parameter, which changes with every successful sync.
+// Responses are only served when their validators match, which means the
+// response to the new request is the same.
+func responseKey(u *url.URL) string {
+	k := *u
+	q := k.Query()
+	q.Del("since")
+	k.RawQuery = q.Encode()
+	return k.String()
+}
+
+// RoundTrip performs the request with the base transport, conditionally if
+// it's that of a list call whose response is cached.
+func (t etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" || req.Context().Value(conditionalKey{}) == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	state := t.config.GetState()
+	key := responseKey(req.URL)
+	cached, ok := state.GetResponse(key)
+	if ok {
+		// The request must not be modified, so its headers are copied.
+		r := new(http.Request)
+		*r = *req
+		r.Header = make(http.Header, len(req.Header)+2)
+		for k, v := range req.Header {
+			r.Header[k] = v
+		}
+		if cached.ETag != "" {
+			r.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			r.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+		req = r
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && ok:
+		res.Body.Close()
+		res.StatusCode = http.StatusOK
+		res.Status = "200 OK"
+		res.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		res.ContentLength = int64(len(cached.Body))
+		res.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if cached.Link != "" {
+			res.Header.Set("Link", cached.Link)
+		}
+
+		usage.Lock()
+		usage.GitHubNotModified++
+		usage.Unlock()
+	case res.StatusCode == http.StatusOK && (res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""):
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if json.Valid(body) {
+			state.SetResponse(key, cfg.CachedResponse{
+				ETag:         res.Header.Get("ETag"),
+				LastModified: res.Header.Get("Last-Modified"),
+				Link:         res.Header.Get("Link"),
+				Body:         body,
+			})
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,137 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// graphQLRequest is a query to the GitHub GraphQL API, with its variables.
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLResponse is a response of the GitHub GraphQL API. Errors are
+// reported in the response rather than by its status.
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// graphql runs a query against the GitHub GraphQL API, retrying it like
+// the REST API calls, and decodes its data into the result.
+func (g realGHClient) graphql(query string, variables map[string]interface{}, result interface{}) error {
+	ctx := g.config.Context()
+
+	req, err := g.client.NewRequest("POST", githubGraphQLURL(g.config), graphQLRequest{query, variables})
+	if err != nil {
+		return err
+	}
+
+	response := new(graphQLResponse)
+	_, _, err = g.request(func() (interface{}, *github.Response, error) {
+		res, err := g.client.Do(ctx, req, response)
+		return nil, res, err
+	})
+	if err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 {
+		messages := make([]string, len(response.Errors))
+		for i, e := range response.Errors {
+			messages[i] = e.Message
+		}
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	return json.Unmarshal(response.Data, result)
+}
+
+// projectStatusQuery lists the items of an issue in GitHub projects (v2),
+// with the value of a single select field of each.
+const projectStatusQuery = `query($owner: String!, $name: String!, $number: Int!, $field: String!) {
+  repository(owner: $owner, name: $name) {
+    issue(number: $number) {
+      projectItems(first: 50) {
+        nodes {
+          project {
+            number
+            owner {
+              ... on Organization { login }
+              ... on User { login }
+            }
+          }
+          fieldValueByName(name: $field) {
+            ... on ProjectV2ItemFieldSingleSelectValue { name }
+          }
+        }
+      }
+    }
+  }
+}`
+
+// projectItems are the items of an issue in GitHub projects (v2), with the
+// value of the status field of each, as selected by projectStatusQuery.
+type projectItems struct {
+	Nodes []struct {
+		Project struct {
+			Number int `json:"number"`
+			Owner  struct {
+				Login string `json:"login"`
+			} `json:"owner"`
+		} `json:"project"`
+		FieldValueByName *struct {
+			Name string `json:"name"`
+		} `json:"fieldValueByName"`
+	} `json:"nodes"`
+}
+
+// status returns the status of the item of the GitHub project, or an empty
+// status if the issue isn't in the project, or its item has none.
+func (items projectItems) status(board cfg.ProjectBoard) string {
+	for _, item := range items.Nodes {
+		if item.Project.Number != board.Number || !strings.EqualFold(item.Project.Owner.Login, board.Owner) {
+			continue
+		}
+		if item.FieldValueByName == nil {
+			return ""
+		}
+		return item.FieldValueByName.Name
+	}
+	return ""
+}
+
+// GetProjectStatus returns the status of the item of a GitHub issue in the
+// GitHub project (v2), which is the value of the status field of the
+// project, or an empty status if the issue isn't in the project, or its
+// item has none.
+func (g realGHClient) GetProjectStatus(number int, board cfg.ProjectBoard) (string, error) {
+	log := g.config.GetLogger()
+
+	owner, name := g.GetRepoSplit()
+	var result struct {
+		Repository struct {
+			Issue struct {
+				ProjectItems projectItems `json:"projectItems"`
+			} `json:"issue"`
+		} `json:"repository"`
+	}
+	err := g.graphql(projectStatusQuery, map[string]interface{}{
+		"owner":  owner,
+		"name":   name,
+		"number": number,
+		"field":  board.GetField(),
+	}, &result)
+	if err != nil {
+		log.Errorf("Error retrieving the project status of GitHub issue #%d. Error: %v", number, err)
+		return "", err
+	}
+
+	return result.Repository.Issue.ProjectItems.status(board), nil
+}
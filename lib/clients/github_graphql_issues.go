@@ -0,0 +1,474 @@
+package clients
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// maxGraphQLPageSize is the maximum number of nodes of a connection of the
+// GitHub GraphQL API returned at once.
+const maxGraphQLPageSize = 100
+
+// issuesQuery lists a page of the issues of a repo, along with their first
+// page of comments, the authors of both, if $board is set, their items in
+// GitHub projects (v2) with the value of a single select field, if
+// $parents is set, their parent issue, if $types is set, their native
+// issue type, and if $reasons is set, the reason they were closed.
+const issuesQuery = `query($owner: String!, $name: String!, $first: Int!, $after: String, $since: DateTime, $states: [IssueState!], $board: Boolean!, $field: String!, $parents: Boolean!, $types: Boolean!, $reasons: Boolean!) {
+  repository(owner: $owner, name: $name) {
+    issues(first: $first, after: $after, states: $states, filterBy: {since: $since}, orderBy: {field: CREATED_AT, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        databaseId
+        number
+        title
+        body
+        state
+        url
+        createdAt
+        updatedAt
+        closedAt
+        author { ...actor }
+        labels(first: 100, orderBy: {field: NAME, direction: ASC}) {
+          nodes { name color }
+        }
+        assignees(first: 50) {
+          nodes { login url avatarUrl databaseId name }
+        }
+        milestone { number title description state dueOn }
+        reactions(content: THUMBS_UP) { totalCount }
+        comments(first: 100) {
+          totalCount
+          pageInfo { hasNextPage }
+          nodes {
+            databaseId
+            body
+            url
+            createdAt
+            updatedAt
+            author { ...actor }
+          }
+        }
+        projectItems(first: 50) @include(if: $board) {
+          nodes {
+            project {
+              number
+              owner {
+                ... on Organization { login }
+                ... on User { login }
+              }
+            }
+            fieldValueByName(name: $field) {
+              ... on ProjectV2ItemFieldSingleSelectValue { name }
+            }
+          }
+        }
+        parent @include(if: $parents) { databaseId number url }
+        issueType @include(if: $types) { name }
+        stateReason @include(if: $reasons)
+      }
+    }
+  }
+}
+
+fragment actor on Actor {
+  __typename
+  login
+  url
+  avatarUrl
+  ... on User { databaseId name }
+}`
+
+// graphqlActor is the author of an issue or comment, or an assignee. Only
+// users have an ID and a name; bots and deleted users don't.
+type graphqlActor struct {
+	Type       string  `json:"__typename"`
+	Login      string  `json:"login"`
+	URL        string  `json:"url"`
+	AvatarURL  string  `json:"avatarUrl"`
+	DatabaseID *int    `json:"databaseId"`
+	Name       *string `json:"name"`
+}
+
+// user returns the actor as the REST API would. The author of the issues
+// and comments of deleted users is null, where the REST API returns the
+// ghost user, and the logins of bots lack the [bot] suffix of REST.
+func (a *graphqlActor) user() *github.User {
+	if a == nil {
+		login := "ghost"
+		return &github.User{Login: &login}
+	}
+	login := a.Login
+	if a.Type == "Bot" {
+		login += "[bot]"
+	}
+	return &github.User{
+		ID:        a.DatabaseID,
+		Login:     &login,
+		Name:      a.Name,
+		HTMLURL:   &a.URL,
+		AvatarURL: &a.AvatarURL,
+	}
+}
+
+// graphqlComment is a comment of an issue, as selected by issuesQuery.
+type graphqlComment struct {
+	DatabaseID int           `json:"databaseId"`
+	Body       string        `json:"body"`
+	URL        string        `json:"url"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	Author     *graphqlActor `json:"author"`
+}
+
+// graphqlIssue is an issue, as selected by issuesQuery.
+type graphqlIssue struct {
+	DatabaseID int           `json:"databaseId"`
+	Number     int           `json:"number"`
+	Title      string        `json:"title"`
+	Body       string        `json:"body"`
+	State      string        `json:"state"`
+	URL        string        `json:"url"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	UpdatedAt  time.Time     `json:"updatedAt"`
+	ClosedAt   *time.Time    `json:"closedAt"`
+	Author     *graphqlActor `json:"author"`
+	Labels     struct {
+		Nodes []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		Nodes []*graphqlActor `json:"nodes"`
+	} `json:"assignees"`
+	Milestone *struct {
+		Number      int        `json:"number"`
+		Title       string     `json:"title"`
+		Description *string    `json:"description"`
+		State       string     `json:"state"`
+		DueOn       *time.Time `json:"dueOn"`
+	} `json:"milestone"`
+	Reactions struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"reactions"`
+	Comments struct {
+		TotalCount int `json:"totalCount"`
+		PageInfo   struct {
+			HasNextPage bool `json:"hasNextPage"`
+		} `json:"pageInfo"`
+		Nodes []graphqlComment `json:"nodes"`
+	} `json:"comments"`
+	ProjectItems projectItems      `json:"projectItems"`
+	Parent       *graphqlParent    `json:"parent"`
+	IssueType    *graphqlIssueType `json:"issueType"`
+	StateReason  *string           `json:"stateReason"`
+}
+
+// issue returns the issue as the REST API would.
+func (i graphqlIssue) issue() github.Issue {
+	state := strings.ToLower(i.State)
+	issue := github.Issue{
+		ID:        &i.DatabaseID,
+		Number:    &i.Number,
+		Title:     &i.Title,
+		Body:      &i.Body,
+		State:     &state,
+		HTMLURL:   &i.URL,
+		CreatedAt: &i.CreatedAt,
+		UpdatedAt: &i.UpdatedAt,
+		ClosedAt:  i.ClosedAt,
+		User:      i.Author.user(),
+		Comments:  &i.Comments.TotalCount,
+		Reactions: &github.Reactions{PlusOne: &i.Reactions.TotalCount},
+	}
+	for _, l := range i.Labels.Nodes {
+		name, color := l.Name, l.Color
+		issue.Labels = append(issue.Labels, github.Label{Name: &name, Color: &color})
+	}
+	for _, a := range i.Assignees.Nodes {
+		issue.Assignees = append(issue.Assignees, a.user())
+	}
+	if len(issue.Assignees) > 0 {
+		issue.Assignee = issue.Assignees[0]
+	}
+	if m := i.Milestone; m != nil {
+		state := strings.ToLower(m.State)
+		issue.Milestone = &github.Milestone{
+			Number:      &m.Number,
+			Title:       &m.Title,
+			Description: m.Description,
+			State:       &state,
+			DueOn:       m.DueOn,
+		}
+	}
+	return issue
+}
+
+// comments returns the comments of the issue as the REST API would.
+func (i graphqlIssue) comments() []*github.IssueComment {
+	comments := make([]*github.IssueComment, len(i.Comments.Nodes))
+	for n := range i.Comments.Nodes {
+		c := &i.Comments.Nodes[n]
+		comments[n] = &github.IssueComment{
+			ID:        &c.DatabaseID,
+			Body:      &c.Body,
+			HTMLURL:   &c.URL,
+			CreatedAt: &c.CreatedAt,
+			UpdatedAt: &c.UpdatedAt,
+			User:      c.Author.user(),
+		}
+	}
+	return comments
+}
+
+// graphqlCache keeps what the GraphQL listing of the issues returned
+// beyond the issues themselves: their comments, the status of their
+// project item, their parent issue, their native type, the reason they
+// were closed, and their users. All but the users are only served once, so
+// that a long-lived client doesn't return them stale.
+type graphqlCache struct {
+	mu       sync.Mutex
+	comments map[int][]*github.IssueComment
+	statuses map[int]string
+	board    *cfg.ProjectBoard
+	parents  map[int]*github.Issue
+	types    map[int]string
+	reasons  map[int]string
+	users    map[string]github.User
+}
+
+// graphqlGHClient is an implementation of GitHubClient which lists the
+// issues through the GitHub GraphQL API, along with their comments, the
+// status of their project item, their parent issue, their native type,
+// the reason they were closed, and their users, which it then serves
+// without further requests. Everything else is left to the REST client,
+// or the dry-run one, it wraps.
+type graphqlGHClient struct {
+	GitHubClient
+	real  realGHClient
+	cache *graphqlCache
+}
+
+// newGraphQLGHClient wraps a GitHub client, which makes the requests with
+// the real client.
+func newGraphQLGHClient(client GitHubClient, real realGHClient) graphqlGHClient {
+	return graphqlGHClient{
+		GitHubClient: client,
+		real:         real,
+		cache:        &graphqlCache{},
+	}
+}
+
+// ListIssues returns the list of GitHub issues since the last successful
+// sync of the repository, like realGHClient.ListIssues, and keeps their
+// comments, the status of their project item, their parent issue, if
+// sub-issues are synced, their native type, if issue types are mapped, the
+// reason they were closed, if it's mapped to resolutions, and their users.
+func (g graphqlGHClient) ListIssues() ([]github.Issue, error) {
+	config := g.real.config
+	log := config.GetLogger()
+
+	repo := g.GetRepo()
+	owner, name := g.GetRepoSplit()
+	board, hasBoard := config.GetProjectBoard(repo)
+	hasParents := config.GetSubIssues() != ""
+	hasTypes := config.UsesIssueTypes()
+	hasReasons := config.UsesResolutions()
+
+	first := config.GetGitHubPageSize()
+	if first > maxGraphQLPageSize {
+		first = maxGraphQLPageSize
+	}
+	vars := map[string]interface{}{
+		"owner":   owner,
+		"name":    name,
+		"first":   first,
+		"board":   hasBoard,
+		"field":   board.GetField(),
+		"parents": hasParents,
+		"types":   hasTypes,
+		"reasons": hasReasons,
+	}
+	if since := config.GetProjectSince(repo); !since.IsZero() {
+		vars["since"] = since.Format(time.RFC3339)
+	}
+	switch config.GetListedStates(repo) {
+	case "open":
+		vars["states"] = []string{"OPEN"}
+	case "closed":
+		vars["states"] = []string{"CLOSED"}
+	}
+
+	cache := graphqlCache{
+		comments: make(map[int][]*github.IssueComment),
+		statuses: make(map[int]string),
+		parents:  make(map[int]*github.Issue),
+		types:    make(map[int]string),
+		reasons:  make(map[int]string),
+		users:    make(map[string]github.User),
+	}
+	if hasBoard {
+		cache.board = &board
+	}
+
+	var issues []github.Issue
+
+	// Walk the pages until GitHub doesn't report a next one.
+	for {
+		var result struct {
+			Repository struct {
+				Issues struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []graphqlIssue `json:"nodes"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+		if err := g.real.graphql(issuesQuery, vars, &result); err != nil {
+			log.Errorf("Error retrieving GitHub issues through GraphQL. Error: %v", err)
+			return nil, err
+		}
+
+		for _, i := range result.Repository.Issues.Nodes {
+			issue := i.issue()
+			issues = append(issues, issue)
+
+			// Issues with more comments than the first page get them from
+			// the REST API.
+			if !i.Comments.PageInfo.HasNextPage {
+				cache.comments[i.Number] = i.comments()
+			}
+			if hasBoard {
+				cache.statuses[i.Number] = i.ProjectItems.status(board)
+			}
+			if hasParents {
+				cache.parents[i.Number] = i.Parent.issue()
+			}
+			if hasTypes {
+				cache.types[i.Number] = i.IssueType.name()
+			}
+			if hasReasons {
+				cache.reasons[i.Number] = stateReason(i.StateReason)
+			}
+			cache.addUser(i.Author)
+			for _, a := range i.Assignees.Nodes {
+				cache.addUser(a)
+			}
+			for _, c := range i.Comments.Nodes {
+				cache.addUser(c.Author)
+			}
+		}
+
+		if !result.Repository.Issues.PageInfo.HasNextPage {
+			break
+		}
+		vars["after"] = result.Repository.Issues.PageInfo.EndCursor
+	}
+
+	g.cache.mu.Lock()
+	g.cache.comments, g.cache.statuses, g.cache.board, g.cache.parents, g.cache.types, g.cache.reasons, g.cache.users = cache.comments, cache.statuses, cache.board, cache.parents, cache.types, cache.reasons, cache.users
+	g.cache.mu.Unlock()
+
+	log.Debug("Collected all GitHub issues")
+
+	return issues, nil
+}
+
+// addUser keeps an actor, if it's a user.
+func (c *graphqlCache) addUser(a *graphqlActor) {
+	if a != nil && a.DatabaseID != nil {
+		c.users[a.Login] = *a.user()
+	}
+}
+
+// ListComments returns the comments of a GitHub issue listed by ListIssues,
+// or else retrieves them through the REST API.
+func (g graphqlGHClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+	g.cache.mu.Lock()
+	comments, ok := g.cache.comments[issue.GetNumber()]
+	delete(g.cache.comments, issue.GetNumber())
+	g.cache.mu.Unlock()
+
+	if ok {
+		return comments, nil
+	}
+	return g.GitHubClient.ListComments(issue)
+}
+
+// GetUser returns a user of the issues listed by ListIssues, or else
+// retrieves it through the REST API.
+func (g graphqlGHClient) GetUser(login string) (github.User, error) {
+	g.cache.mu.Lock()
+	user, ok := g.cache.users[login]
+	g.cache.mu.Unlock()
+
+	if ok {
+		return user, nil
+	}
+	return g.GitHubClient.GetUser(login)
+}
+
+// GetProjectStatus returns the status of the item of a GitHub issue listed
+// by ListIssues in the GitHub project, or else retrieves it.
+func (g graphqlGHClient) GetProjectStatus(number int, board cfg.ProjectBoard) (string, error) {
+	g.cache.mu.Lock()
+	status, ok := g.cache.statuses[number]
+	ok = ok && g.cache.board != nil && g.cache.board.Number == board.Number &&
+		strings.EqualFold(g.cache.board.Owner, board.Owner) && g.cache.board.GetField() == board.GetField()
+	delete(g.cache.statuses, number)
+	g.cache.mu.Unlock()
+
+	if ok {
+		return status, nil
+	}
+	return g.GitHubClient.GetProjectStatus(number, board)
+}
+
+// GetParentIssue returns the parent issue of a GitHub issue listed by
+// ListIssues, or else retrieves it.
+func (g graphqlGHClient) GetParentIssue(number int) (*github.Issue, error) {
+	g.cache.mu.Lock()
+	parent, ok := g.cache.parents[number]
+	delete(g.cache.parents, number)
+	g.cache.mu.Unlock()
+
+	if ok {
+		return parent, nil
+	}
+	return g.GitHubClient.GetParentIssue(number)
+}
+
+// GetIssueType returns the native type of a GitHub issue listed by
+// ListIssues, or else retrieves it.
+func (g graphqlGHClient) GetIssueType(number int) (string, error) {
+	g.cache.mu.Lock()
+	t, ok := g.cache.types[number]
+	delete(g.cache.types, number)
+	g.cache.mu.Unlock()
+
+	if ok {
+		return t, nil
+	}
+	return g.GitHubClient.GetIssueType(number)
+}
+
+// GetStateReason returns the reason a GitHub issue listed by ListIssues was
+// closed, or else retrieves it.
+func (g graphqlGHClient) GetStateReason(number int) (string, error) {
+	g.cache.mu.Lock()
+	reason, ok := g.cache.reasons[number]
+	delete(g.cache.reasons, number)
+	g.cache.mu.Unlock()
+
+	if ok {
+		return reason, nil
+	}
+	return g.GitHubClient.GetStateReason(number)
+}
@@ -0,0 +1,80 @@
+package clients
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// maxImageSize is the size above which embedded images aren't downloaded,
+// which is the default maximum size of JIRA attachments.
+const maxImageSize = 10 << 20
+
+// githubImageHost is the suffix of the hosts GitHub serves the images
+// uploaded to issues from, before they moved to github.com/user-attachments.
+const githubImageHost = "user-images.githubusercontent.com"
+
+// regexGitHubImagePath matches the paths of the images uploaded to issues on
+// the web host of GitHub, or of GitHub Enterprise Server.
+var regexGitHubImagePath = regexp.MustCompile(`^/(user-attachments/|storage/user/|[^/]+/[^/]+/assets/)`)
+
+// IsGitHubImage returns whether a URL is that of an image uploaded to a
+// GitHub issue or comment, which DownloadImage can download. Only HTTPS
+// URLs are, as DownloadImage sends the GitHub token along.
+func IsGitHubImage(config cfg.Config, uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	if u.Host == githubImageHost || strings.HasSuffix(u.Host, "-"+githubImageHost) {
+		return true
+	}
+	web, err := url.Parse(config.GetGitHubWebURL())
+	return err == nil && u.Host == web.Host && regexGitHubImagePath.MatchString(u.Path)
+}
+
+// DownloadImage returns the content and the media type of an image uploaded
+// to a GitHub issue or comment. The request is authenticated, as images of
+// private repos are only served to their members; the token isn't sent
+// along to the storage GitHub redirects to.
+func (g realGHClient) DownloadImage(uri string) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	ts, err := githubTokenSource(g.config)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := ts.Token()
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "token "+token.AccessToken)
+
+	client := http.Client{Timeout: g.config.GetTimeout()}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("downloading %s failed: %s", uri, res.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxImageSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(b) > maxImageSize {
+		return nil, "", fmt.Errorf("image %s is larger than %d bytes", uri, maxImageSize)
+	}
+
+	return b, res.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,47 @@
+package clients
+
+// pinnedQuery returns the issues pinned to a repo; GitHub pins at most
+// three.
+const pinnedQuery = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    pinnedIssues(first: 3) {
+      nodes {
+        issue { databaseId }
+      }
+    }
+  }
+}`
+
+// ListPinnedIssues returns the IDs of the GitHub issues pinned to the repo.
+// The version of the GitHub library we use can't list them, so they're
+// queried through GraphQL.
+func (g realGHClient) ListPinnedIssues() ([]int, error) {
+	log := g.config.GetLogger()
+
+	owner, name := g.GetRepoSplit()
+	var result struct {
+		Repository struct {
+			PinnedIssues struct {
+				Nodes []struct {
+					Issue struct {
+						DatabaseID int `json:"databaseId"`
+					} `json:"issue"`
+				} `json:"nodes"`
+			} `json:"pinnedIssues"`
+		} `json:"repository"`
+	}
+	err := g.graphql(pinnedQuery, map[string]interface{}{
+		"owner": owner,
+		"name":  name,
+	}, &result)
+	if err != nil {
+		log.Errorf("Error retrieving the pinned issues of GitHub repo %s. Error: %v", g.GetRepo(), err)
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(result.Repository.PinnedIssues.Nodes))
+	for _, node := range result.Repository.PinnedIssues.Nodes {
+		ids = append(ids, node.Issue.DatabaseID)
+	}
+	return ids, nil
+}
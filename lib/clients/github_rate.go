@@ -0,0 +1,100 @@
+package clients
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// rateLimitReserve is the number of remaining GitHub requests below which
+// requests are slowed down, so that the budget lasts until it's reset.
+const rateLimitReserve = 100
+
+// abuseRetryDelay is how long to wait after hitting a secondary rate limit
+// when GitHub doesn't say how long to wait.
+const abuseRetryDelay = time.Minute
+
+// maxRateLimitWaits is the number of times a single request waits for a rate
+// limit before the error is returned.
+const maxRateLimitWaits = 5
+
+// rateThrottle paces the requests of a GitHub client according to the rate
+// limit headers of the responses it has received.
+type rateThrottle struct {
+	mu   sync.Mutex
+	rate github.Rate
+}
+
+// newRateThrottle creates a rateThrottle which doesn't know the rate limit
+// yet, so it doesn't delay the first request.
+func newRateThrottle() *rateThrottle {
+	return &rateThrottle{}
+}
+
+// update records the rate limit reported by a response.
+func (t *rateThrottle) update(res *github.Response) {
+	if res == nil || res.Rate.Limit == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rate = res.Rate
+}
+
+// delay returns how long to wait before the next request. If the budget is
+// exhausted, it is the time until it's reset; if it's low, the remaining time
+// until the reset is spread over the remaining requests.
+func (t *rateThrottle) delay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rate.Limit == 0 || t.rate.Remaining >= rateLimitReserve {
+		return 0
+	}
+
+	untilReset := time.Until(t.rate.Reset.Time)
+	if untilReset <= 0 {
+		return 0
+	}
+	if t.rate.Remaining == 0 {
+		return untilReset
+	}
+	return untilReset / time.Duration(t.rate.Remaining)
+}
+
+// wait sleeps for the delay required by the rate limit, if any, unless
+// issue-sync is shut down first.
+func (t *rateThrottle) wait(config cfg.Config, log logrus.Entry) {
+	d := t.delay()
+	if d <= 0 {
+		return
+	}
+	if d > time.Second {
+		log.Infof("GitHub rate limit is low; pausing for %v", d.Round(time.Second))
+	}
+	sleep(config, d)
+}
+
+// rateLimitDelay returns how long to wait before retrying a request which
+// failed because of a primary or secondary rate limit, and whether it failed
+// because of one at all.
+func rateLimitDelay(err error) (time.Duration, bool) {
+	switch e := err.(type) {
+	case *github.RateLimitError:
+		if d := time.Until(e.Rate.Reset.Time); d > 0 {
+			return d, true
+		}
+		return 0, true
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			return *e.RetryAfter, true
+		}
+		return abuseRetryDelay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package clients
+
+import (
+	"strings"
+)
+
+// stateReasonQuery returns the reason an issue was closed.
+const stateReasonQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    issue(number: $number) {
+      stateReason
+    }
+  }
+}`
+
+// GetStateReason returns the reason a GitHub issue was closed, as the REST
+// API gives it: completed, not_planned, or duplicate, or reopened if it was
+// reopened. It's empty for issues which were never closed, and for older
+// issues. The version of the GitHub library we use doesn't decode it, so
+// it's queried through GraphQL.
+func (g realGHClient) GetStateReason(number int) (string, error) {
+	log := g.config.GetLogger()
+
+	owner, name := g.GetRepoSplit()
+	var result struct {
+		Repository struct {
+			Issue struct {
+				StateReason *string `json:"stateReason"`
+			} `json:"issue"`
+		} `json:"repository"`
+	}
+	err := g.graphql(stateReasonQuery, map[string]interface{}{
+		"owner":  owner,
+		"name":   name,
+		"number": number,
+	}, &result)
+	if err != nil {
+		log.Errorf("Error retrieving the state reason of GitHub issue #%d. Error: %v", number, err)
+		return "", err
+	}
+
+	return stateReason(result.Repository.Issue.StateReason), nil
+}
+
+// stateReason returns a reason of the GraphQL API, such as NOT_PLANNED, as
+// the REST API gives it.
+func stateReason(reason *string) string {
+	if reason == nil {
+		return ""
+	}
+	return strings.ToLower(*reason)
+}
@@ -0,0 +1,73 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// ownerRepo is a repository of a GitHub organization or user. The version of
+// the GitHub library we use doesn't tell whether repositories are archived,
+// nor their topics, so the repositories are decoded here.
+type ownerRepo struct {
+	FullName string   `json:"full_name"`
+	Archived bool     `json:"archived"`
+	Topics   []string `json:"topics"`
+}
+
+// ListOwnerRepos returns the repos, in owner/repo form, of a GitHub
+// organization or, if there's no organization of that name, of a user,
+// mapped to their topics. Archived repos are left out, as their issues
+// can't change.
+func (g realGHClient) ListOwnerRepos(owner string) (map[string][]string, error) {
+	log := g.config.GetLogger()
+
+	repos, err := g.listOwnerRepos(fmt.Sprintf("orgs/%s/repos", owner))
+	if IsGone(err) {
+		repos, err = g.listOwnerRepos(fmt.Sprintf("users/%s/repos", owner))
+	}
+	if err != nil {
+		log.Errorf("Error listing the GitHub repos of %s. Error: %v", owner, err)
+		return nil, err
+	}
+
+	return repos, nil
+}
+
+// listOwnerRepos walks the pages of a list of repos of the GitHub API. The
+// topics require a preview media type on older GitHub Enterprise servers.
+func (g realGHClient) listOwnerRepos(path string) (map[string][]string, error) {
+	ctx := conditional(g.config.Context())
+
+	repos := make(map[string][]string)
+	page := 1
+	for {
+		req, err := g.client.NewRequest("GET", fmt.Sprintf("%s?per_page=%d&page=%d", path, g.config.GetGitHubPageSize(), page), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github.mercy-preview+json")
+
+		var list []ownerRepo
+		_, res, err := g.request(func() (interface{}, *github.Response, error) {
+			res, err := g.client.Do(ctx, req, &list)
+			return nil, res, err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range list {
+			if !r.Archived {
+				repos[r.FullName] = r.Topics
+			}
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		page = res.NextPage
+	}
+
+	return repos, nil
+}
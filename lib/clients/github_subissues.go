@@ -0,0 +1,62 @@
+package clients
+
+import (
+	"github.com/google/go-github/github"
+)
+
+// parentQuery returns the parent issue of an issue, of which it's a
+// sub-issue.
+const parentQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    issue(number: $number) {
+      parent { databaseId number url }
+    }
+  }
+}`
+
+// graphqlParent is the parent issue of an issue. It may be in another repo.
+type graphqlParent struct {
+	DatabaseID int    `json:"databaseId"`
+	Number     int    `json:"number"`
+	URL        string `json:"url"`
+}
+
+// issue returns the parent issue as the REST API would, with only its ID,
+// its number, and its URL, or nil if there's no parent.
+func (p *graphqlParent) issue() *github.Issue {
+	if p == nil {
+		return nil
+	}
+	return &github.Issue{
+		ID:      &p.DatabaseID,
+		Number:  &p.Number,
+		HTMLURL: &p.URL,
+	}
+}
+
+// GetParentIssue returns the parent issue of a GitHub issue, of which it's
+// a sub-issue, or nil if it has none. The parent only has its ID, its
+// number, and its URL.
+func (g realGHClient) GetParentIssue(number int) (*github.Issue, error) {
+	log := g.config.GetLogger()
+
+	owner, name := g.GetRepoSplit()
+	var result struct {
+		Repository struct {
+			Issue struct {
+				Parent *graphqlParent `json:"parent"`
+			} `json:"issue"`
+		} `json:"repository"`
+	}
+	err := g.graphql(parentQuery, map[string]interface{}{
+		"owner":  owner,
+		"name":   name,
+		"number": number,
+	}, &result)
+	if err != nil {
+		log.Errorf("Error retrieving the parent of GitHub issue #%d. Error: %v", number, err)
+		return nil, err
+	}
+
+	return result.Repository.Issue.Parent.issue(), nil
+}
@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// teamCache holds the members of the GitHub teams of a client, so that each
+// team is only listed once per run.
+type teamCache struct {
+	mu      sync.Mutex
+	members map[string][]string
+}
+
+// newTeamCache creates an empty teamCache.
+func newTeamCache() *teamCache {
+	return &teamCache{
+		members: make(map[string][]string),
+	}
+}
+
+// ListTeamMembers returns the logins of the members of a team of a GitHub
+// organization, given by its slug.
+func (g realGHClient) ListTeamMembers(org, team string) ([]string, error) {
+	log := g.config.GetLogger()
+
+	key := strings.ToLower(org + "/" + team)
+
+	g.teams.mu.Lock()
+	defer g.teams.mu.Unlock()
+
+	if members, ok := g.teams.members[key]; ok {
+		return members, nil
+	}
+
+	ctx := conditional(g.config.Context())
+
+	var members []string
+	for page := 1; page != 0; {
+		uri := fmt.Sprintf("orgs/%s/teams/%s/members?per_page=%d&page=%d", org, team, g.config.GetGitHubPageSize(), page)
+		req, err := g.client.NewRequest("GET", uri, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var users []*github.User
+		_, res, err := g.request(func() (interface{}, *github.Response, error) {
+			res, err := g.client.Do(ctx, req, &users)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error retrieving the members of GitHub team %s/%s. Error: %v", org, team, err)
+			return nil, err
+		}
+
+		for _, u := range users {
+			members = append(members, u.GetLogin())
+		}
+		page = res.NextPage
+	}
+
+	g.teams.members[key] = members
+
+	return members, nil
+}
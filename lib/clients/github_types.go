@@ -0,0 +1,53 @@
+package clients
+
+// issueTypeQuery returns the native type of an issue, such as Bug or
+// Feature.
+const issueTypeQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) {
+    issue(number: $number) {
+      issueType { name }
+    }
+  }
+}`
+
+// graphqlIssueType is the native type of an issue, which the issue types of
+// the organization of its repo define.
+type graphqlIssueType struct {
+	Name string `json:"name"`
+}
+
+// name returns the name of the issue type, or an empty name if the issue
+// has none.
+func (t *graphqlIssueType) name() string {
+	if t == nil {
+		return ""
+	}
+	return t.Name
+}
+
+// GetIssueType returns the name of the native type of a GitHub issue, such
+// as Bug or Feature, or an empty name if it has none. The version of the
+// GitHub library we use doesn't decode it, so it's queried through GraphQL.
+func (g realGHClient) GetIssueType(number int) (string, error) {
+	log := g.config.GetLogger()
+
+	owner, name := g.GetRepoSplit()
+	var result struct {
+		Repository struct {
+			Issue struct {
+				IssueType *graphqlIssueType `json:"issueType"`
+			} `json:"issue"`
+		} `json:"repository"`
+	}
+	err := g.graphql(issueTypeQuery, map[string]interface{}{
+		"owner":  owner,
+		"name":   name,
+		"number": number,
+	}, &result)
+	if err != nil {
+		log.Errorf("Error retrieving the type of GitHub issue #%d. Error: %v", number, err)
+		return "", err
+	}
+
+	return result.Repository.Issue.IssueType.name(), nil
+}
@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// users holds the profiles of the GitHub users looked up by the clients
+// of the process, by lowercase login, so that the authors of comments are
+// only looked up once. The profiles are also kept in the state, so that
+// the next runs don't look them up again either.
+var users = struct {
+	sync.Mutex
+	profiles map[string]cfg.CachedUser
+}{profiles: make(map[string]cfg.CachedUser)}
+
+// cachedUser returns the profile of a GitHub user looked up by this
+// process, or by an earlier run, less than cfg.CachedUserTTL ago.
+func cachedUser(config cfg.Config, login string) (github.User, bool) {
+	users.Lock()
+	u, ok := users.profiles[strings.ToLower(login)]
+	users.Unlock()
+
+	if !ok || time.Since(u.Fetched) > cfg.CachedUserTTL {
+		if u, ok = config.GetState().GetUser(login); !ok {
+			return github.User{}, false
+		}
+		users.Lock()
+		users.profiles[strings.ToLower(login)] = u
+		users.Unlock()
+	}
+
+	user := github.User{Login: &login}
+	if u.ID != 0 {
+		user.ID = &u.ID
+	}
+	if u.Name != "" {
+		user.Name = &u.Name
+	}
+	if u.Email != "" {
+		user.Email = &u.Email
+	}
+	if u.HTMLURL != "" {
+		user.HTMLURL = &u.HTMLURL
+	}
+	if u.AvatarURL != "" {
+		user.AvatarURL = &u.AvatarURL
+	}
+	return user, true
+}
+
+// cacheUser records the profile of a GitHub user which was looked up.
+func cacheUser(config cfg.Config, user github.User) {
+	u := cfg.CachedUser{
+		ID:        user.GetID(),
+		Name:      user.GetName(),
+		Email:     user.GetEmail(),
+		HTMLURL:   user.GetHTMLURL(),
+		AvatarURL: user.GetAvatarURL(),
+		Fetched:   time.Now(),
+	}
+
+	users.Lock()
+	users.profiles[strings.ToLower(user.GetLogin())] = u
+	users.Unlock()
+
+	config.GetState().SetUser(user.GetLogin(), u)
+}
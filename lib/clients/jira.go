@@ -1,24 +1,22 @@
 package clients
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
 
-	"time"
-
 	"github.com/andygrunwald/go-jira"
-	"github.com/cenkalti/backoff"
 	"github.com/coreos/issue-sync/cfg"
 	"github.com/google/go-github/github"
+	"golang.org/x/oauth2"
 )
 
-// commentDateFormat is the format used in the headers of JIRA comments.
-const commentDateFormat = "15:04 PM, January 2 2006"
-
 // maxJQLIssueLength is the maximum number of GitHub issues we can
 // use before we need to stop using JQL and filter issues ourself.
 const maxJQLIssueLength = 100
@@ -30,6 +28,9 @@ const maxJQLIssueLength = 100
 // further reading.
 func getErrorBody(config cfg.Config, res *jira.Response) error {
 	log := config.GetLogger()
+	if res == nil || res.Response == nil {
+		return errors.New("no response was received from JIRA")
+	}
 	defer res.Body.Close()
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -40,17 +41,152 @@ func getErrorBody(config cfg.Config, res *jira.Response) error {
 	return errors.New(string(body))
 }
 
+// jiraTransition is a transition available on a JIRA issue. Unlike jira.Transition,
+// it includes the status the transition leads to.
+type jiraTransition struct {
+	ID     string                     `json:"id"`
+	Name   string                     `json:"name"`
+	To     jira.Status                `json:"to"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+// hasResolution returns whether the screen of a transition has the
+// resolution field, which can then be set by the transition.
+func (t jiraTransition) hasResolution() bool {
+	_, ok := t.Fields["resolution"]
+	return ok
+}
+
+// MatchesStatus reports whether a JIRA status matches the target of a transition
+// mapping, by ID or by (case-insensitive) name.
+func MatchesStatus(status *jira.Status, target string) bool {
+	if status == nil {
+		return false
+	}
+	return status.ID == target || strings.EqualFold(status.Name, target)
+}
+
+// IsDone reports whether a JIRA status is in the "Done" status category,
+// which is the JIRA counterpart of the closed state of GitHub issues.
+func IsDone(status *jira.Status) bool {
+	return status != nil && status.StatusCategory.Key == "done"
+}
+
+// listTransitions returns the transitions available on the issue.
+func listTransitions(config cfg.Config, client jira.Client, issue jira.Issue) ([]jiraTransition, error) {
+	log := config.GetLogger()
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/transitions?expand=transitions.fields", issue.Key), nil)
+	if err != nil {
+		return nil, err
+	}
+	result := new(struct {
+		Transitions []jiraTransition `json:"transitions"`
+	})
+	res, err := client.Do(req, result)
+	if err != nil {
+		log.Errorf("Error retrieving transitions of JIRA issue %s: %v", issue.Key, err)
+		return nil, getErrorBody(config, res)
+	}
+
+	return result.Transitions, nil
+}
+
+// findTransition returns the first transition available on the issue which
+// matches the target by its ID or name, or by its destination status. It
+// returns nil if the issue is already in the target status, or if no
+// available transition matches, which is logged as a warning since it's
+// either a misspelled target or one the workflow doesn't allow from the
+// current status of the issue.
+func findTransition(config cfg.Config, client jira.Client, issue jira.Issue, target string) (*jiraTransition, error) {
+	log := config.GetLogger()
+
+	if issue.Fields != nil && MatchesStatus(issue.Fields.Status, target) {
+		return nil, nil
+	}
+
+	transitions, err := listTransitions(config, client, issue)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range transitions {
+		if t.ID == target || strings.EqualFold(t.Name, target) {
+			return &t, nil
+		}
+	}
+	for _, t := range transitions {
+		if MatchesStatus(&t.To, target) {
+			return &t, nil
+		}
+	}
+
+	status := ""
+	if issue.Fields != nil && issue.Fields.Status != nil {
+		status = issue.Fields.Status.Name
+	}
+	log.Warnf("No transition of JIRA issue %s from status %q matches %q; check `transitions`", issue.Key, status, target)
+
+	return nil, nil
+}
+
+// findCategoryTransition returns the first transition available on the issue
+// which leads to a status in the "Done" category if done is set, or to one
+// in any other category if it isn't. It returns nil if the status of the
+// issue is in such a category already, or if no available transition leads
+// to one.
+func findCategoryTransition(config cfg.Config, client jira.Client, issue jira.Issue, done bool) (*jiraTransition, error) {
+	log := config.GetLogger()
+
+	if issue.Fields != nil && issue.Fields.Status != nil && IsDone(issue.Fields.Status) == done {
+		return nil, nil
+	}
+
+	transitions, err := listTransitions(config, client, issue)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range transitions {
+		if IsDone(&t.To) == done {
+			return &t, nil
+		}
+	}
+
+	log.Debugf("No transition of JIRA issue %s leads to a status which is done: %t", issue.Key, done)
+
+	return nil, nil
+}
+
 // JIRAClient is a wrapper around the JIRA API clients library we
 // use. It allows us to hide implementation details such as backoff
 // as well as swap in other implementations, such as for dry run
 // or test mocking.
 type JIRAClient interface {
 	ListIssues(ids []int) ([]jira.Issue, error)
+	ListSyncedIssues() ([]jira.Issue, error)
+	ListLinkedIssues() ([]jira.Issue, error)
 	GetIssue(key string) (jira.Issue, error)
 	CreateIssue(issue jira.Issue) (jira.Issue, error)
+	CreateIssues(issues []jira.Issue) ([]jira.Issue, []error)
 	UpdateIssue(issue jira.Issue) (jira.Issue, error)
 	CreateComment(issue jira.Issue, comment github.IssueComment, github GitHubClient) (jira.Comment, error)
 	UpdateComment(issue jira.Issue, id string, comment github.IssueComment, github GitHubClient) (jira.Comment, error)
+	AddComment(issue jira.Issue, body string) (jira.Comment, error)
+	TransitionIssue(issue jira.Issue, target string) error
+	TransitionIssueCategory(issue jira.Issue, done bool) error
+	ResolveIssue(issue jira.Issue, target, resolution string) error
+	ListCommentIDs(issue jira.Issue) (map[string]CommentRef, error)
+	SetCommentID(comment jira.Comment, id int) error
+	SyncVersion(name, description string) (jira.FixVersion, error)
+	SyncEpic(name, description string) (string, error)
+	CreateSubtask(parent jira.Issue, summary string) (jira.Issue, error)
+	FindGitHubIssue(id int) (string, error)
+	LinkIssue(issue jira.Issue, linkType, key string) error
+	UnlinkIssue(issue jira.Issue, linkType, key string) error
+	AddWatchers(issue jira.Issue, users []string) error
+	AddAttachment(issue jira.Issue, name string, content []byte) (jira.Attachment, error)
+	SyncRemoteLink(issue jira.Issue, repo string, ghIssue github.Issue) error
 	GetClient() jira.Client
 }
 
@@ -61,10 +197,15 @@ type JIRAClient interface {
 func NewJIRAClient(config cfg.Config, project jira.Project) (JIRAClient, error) {
 	log := config.GetLogger()
 
-	var oauth *http.Client
+	var httpClient *http.Client
 	var err error
-	if !config.IsBasicAuth() {
-		oauth, err = newJIRAHTTPClient(config)
+	if config.IsPATAuth() {
+		// JIRA Data Center accepts personal access tokens as bearer tokens.
+		httpClient = oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: config.GetConfigString("jira-pat")},
+		))
+	} else if !config.IsBasicAuth() {
+		httpClient, err = newJIRAHTTPClient(config)
 		if err != nil {
 			log.Errorf("Error getting OAuth config: %v", err)
 			return dryrunJIRAClient{}, err
@@ -73,7 +214,12 @@ func NewJIRAClient(config cfg.Config, project jira.Project) (JIRAClient, error)
 
 	var j JIRAClient
 
-	client, err := jira.NewClient(oauth, config.GetConfigString("jira-uri"))
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	httpClient.Transport = newAuditTransport(config, newContextTransport(config.Context(), newUsageTransport(httpClient.Transport, false)), "jira", jiraActor(config))
+
+	client, err := jira.NewClient(httpClient, config.GetConfigString("jira-uri"))
 	if err != nil {
 		log.Errorf("Error initializing JIRA clients; check your base URI. Error: %v", err)
 		return dryrunJIRAClient{}, err
@@ -87,21 +233,43 @@ func NewJIRAClient(config cfg.Config, project jira.Project) (JIRAClient, error)
 
 	if config.IsDryRun() {
 		j = dryrunJIRAClient{
-			config: config,
-			client: *client,
-			project: project,
+			config:   config,
+			client:   *client,
+			project:  project,
+			versions: newVersionCache(),
+			epics:    newEpicCache(),
+			meta:     newMetaCache(),
 		}
 	} else {
 		j = realJIRAClient{
-			config: config,
-			client: *client,
-			project: project,
+			config:   config,
+			client:   *client,
+			project:  project,
+			versions: newVersionCache(),
+			epics:    newEpicCache(),
 		}
 	}
 
 	return j, nil
 }
 
+// LoadJIRAInstances loads the JIRA configuration of the projects of each
+// JIRA instance of `jira-instances`, as LoadJIRAConfig does for those of the
+// default instance.
+func LoadJIRAInstances(config cfg.Config) error {
+	for _, name := range config.GetJIRAInstances() {
+		instance := config.ForInstance(name)
+		client, err := NewJIRAClient(instance, jira.Project{})
+		if err != nil {
+			return fmt.Errorf("JIRA instance %s: %v", name, err)
+		}
+		if err := instance.LoadJIRAConfig(client.GetClient()); err != nil {
+			return fmt.Errorf("JIRA instance %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
 // GetClient returns the underlying JIRA API client used by our client.
 // It's made available for the configuration object to use, since it
 // can't import this class due to circular dependencies. If you think
@@ -114,43 +282,25 @@ func (j realJIRAClient) GetClient() jira.Client {
 // of the requests against the JIRA REST API. It is the canonical
 // implementation of JIRAClient.
 type realJIRAClient struct {
-	config cfg.Config
-	client jira.Client
-	project jira.Project
+	config   cfg.Config
+	client   jira.Client
+	project  jira.Project
+	versions *versionCache
+	epics    *epicCache
 }
 
 // ListIssues returns a list of JIRA issues on the configured project which
 // have GitHub IDs in the provided list. `ids` should be a comma-separated
 // list of GitHub IDs.
 func (j realJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
-	log := j.config.GetLogger()
-
 	idStrs := make([]string, len(ids))
 	for i, v := range ids {
 		idStrs[i] = fmt.Sprint(v)
 	}
 
-	var jql string
-	// If the list of IDs is too long, we get a 414 Request-URI Too Large, so in that case,
-	// we'll need to do the filtering ourselves.
-	if len(ids) < maxJQLIssueLength {
-		jql = fmt.Sprintf("project='%s' AND cf[%s] in (%s)",
-			j.project.Key, j.config.GetFieldID(cfg.GitHubID), strings.Join(idStrs, ","))
-	} else {
-		jql = fmt.Sprintf("project='%s'", j.project.Key)
-	}
-
-	ji, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		return j.client.Issue.Search(jql, nil)
-	})
+	jiraIssues, err := searchIssues(j.config, j.client, j.request, listIssuesJQL(j.config, j.project, idStrs))
 	if err != nil {
-		log.Errorf("Error retrieving JIRA issues: %v", err)
-		return nil, getErrorBody(j.config, res)
-	}
-	jiraIssues, ok := ji.([]jira.Issue)
-	if !ok {
-		log.Errorf("Get JIRA issues did not return issues! Got: %v", ji)
-		return nil, fmt.Errorf("get JIRA issues failed: expected []jira.Issue; got %T", ji)
+		return nil, err
 	}
 
 	var issues []jira.Issue
@@ -239,9 +389,27 @@ func (j realJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	return *is, nil
 }
 
-// maxBodyLength is the maximum length of a JIRA comment body, which is currently
-// 2^15-1.
-const maxBodyLength = 1 << 15
+// jiraCommentBody returns the body of the JIRA comment for a GitHub comment:
+// a header rendered with the `comment-template`, which links to the comment
+// and its author by default, followed by the body of the GitHub comment.
+func jiraCommentBody(config cfg.Config, comment github.IssueComment, user github.User) (string, error) {
+	header, err := config.RenderCommentHeader(cfg.CommentHeader{
+		ID:      comment.GetID(),
+		URL:     comment.GetHTMLURL(),
+		Created: comment.GetCreatedAt(),
+		Updated: comment.GetUpdatedAt(),
+		User: cfg.GitHubUser{
+			Login:     user.GetLogin(),
+			Name:      user.GetName(),
+			URL:       user.GetHTMLURL(),
+			AvatarURL: user.GetAvatarURL(),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error rendering the header of comment %d: %v", comment.GetID(), err)
+	}
+	return fmt.Sprintf("%s\n\n%s", header, comment.GetBody()), nil
+}
 
 // CreateComment adds a comment to the provided JIRA issue using the fields from
 // the provided GitHub comment. It then returns the created comment.
@@ -253,24 +421,14 @@ func (j realJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComm
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment [(ID %d)|%s]", comment.GetID(), comment.GetHTMLURL())
-	body = fmt.Sprintf("%s from GitHub user [%s|%s]", body, user.GetLogin(), user.GetHTMLURL())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
-	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
-
-	if len(body) >= maxBodyLength {
-		body = body[:maxBodyLength]
+	body, err := jiraCommentBody(j.config, comment, user)
+	if err != nil {
+		return jira.Comment{}, err
 	}
+	parts := commentParts(j.config, comment, body)
 
 	jComment := jira.Comment{
-		Body: body,
+		Body: parts[0],
 	}
 
 	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
@@ -285,6 +443,18 @@ func (j realJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComm
 		log.Errorf("Create JIRA comment did not return comment! Got: %v", com)
 		return jira.Comment{}, fmt.Errorf("Create JIRA comment failed: expected *jira.Comment; got %T", com)
 	}
+
+	if err := j.SetCommentID(*co, comment.GetID()); err != nil {
+		log.Errorf("Error recording the GitHub ID of JIRA comment %s. Error: %v", co.ID, err)
+	}
+
+	if len(parts) > 1 {
+		if err := j.syncContinuations(issue, comment, parts); err != nil {
+			log.Errorf("Error adding the continuations of JIRA comment %s. Error: %v", co.ID, err)
+			return *co, err
+		}
+	}
+
 	return *co, nil
 }
 
@@ -299,22 +469,34 @@ func (j realJIRAClient) UpdateComment(issue jira.Issue, id string, comment githu
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment [(ID %d)|%s]", comment.GetID(), comment.GetHTMLURL())
-	body = fmt.Sprintf("%s from GitHub user [%s|%s]", body, user.GetLogin(), user.GetHTMLURL())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
+	body, err := jiraCommentBody(j.config, comment, user)
+	if err != nil {
+		return jira.Comment{}, err
 	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
+	parts := commentParts(j.config, comment, body)
 
-	if len(body) > maxBodyLength {
-		body = body[:maxBodyLength]
+	co, err := j.updateCommentBody(issue, id, parts[0])
+	if err != nil {
+		return jira.Comment{}, err
 	}
 
+	// The continuations are synced even if there's no part left, so that
+	// those of a longer version of the comment are deleted.
+	if j.config.SplitsComments() {
+		if err := j.syncContinuations(issue, comment, parts); err != nil {
+			log.Errorf("Error syncing the continuations of JIRA comment %s. Error: %v", id, err)
+			return co, err
+		}
+	}
+
+	return co, nil
+}
+
+// updateCommentBody sets the body of a comment (identified by the `id`
+// parameter) on a given JIRA issue, and returns the updated comment.
+func (j realJIRAClient) updateCommentBody(issue jira.Issue, id, body string) (jira.Comment, error) {
+	log := j.config.GetLogger()
+
 	// As it is, the JIRA API we're using doesn't have any way to update comments natively.
 	// So, we have to build the request ourselves.
 	request := struct {
@@ -329,55 +511,115 @@ func (j realJIRAClient) UpdateComment(issue jira.Issue, id string, comment githu
 		return jira.Comment{}, err
 	}
 
-	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		res, err := j.client.Do(req, nil)
+	co := new(jira.Comment)
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Do(req, co)
 		return nil, res, err
 	})
 	if err != nil {
 		log.Errorf("Error updating comment: %v", err)
 		return jira.Comment{}, getErrorBody(j.config, res)
 	}
-	co, ok := com.(*jira.Comment)
-	if !ok {
-		log.Errorf("Update JIRA comment did not return comment! Got: %v", com)
-		return jira.Comment{}, fmt.Errorf("Update JIRA comment failed: expected *jira.Comment; got %T", com)
-	}
 	return *co, nil
 }
 
-// request takes an API function from the JIRA library
-// and calls it with exponential backoff. If the function succeeds, it
-// returns the expected value and the JIRA API response, as well as a nil
-// error. If it continues to fail until a maximum time is reached, it returns
-// a nil result as well as the returned HTTP response and a timeout error.
-func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error) {
+// TransitionIssue moves a JIRA issue through the first available transition
+// which matches the target, either by the ID or (localized) name of the
+// transition, or by the ID or name of its destination status. If no
+// available transition matches, the issue is left as is.
+func (j realJIRAClient) TransitionIssue(issue jira.Issue, target string) error {
+	transition, err := findTransition(j.config, j.client, issue, target)
+	if err != nil || transition == nil {
+		return err
+	}
+
+	return j.doTransition(issue, *transition, "")
+}
+
+// findResolveTransition returns the transition of a JIRA issue which
+// matches the target, or, if the target is empty, the first one leading to
+// a status in the "Done" category.
+func findResolveTransition(config cfg.Config, client jira.Client, issue jira.Issue, target string) (*jiraTransition, error) {
+	if target == "" {
+		return findCategoryTransition(config, client, issue, true)
+	}
+	return findTransition(config, client, issue, target)
+}
+
+// ResolveIssue moves a JIRA issue through the transition which matches the
+// target, or, if the target is empty, to a status in the "Done" category,
+// like TransitionIssue and TransitionIssueCategory, and sets its
+// resolution, if the screen of the transition has the resolution field.
+func (j realJIRAClient) ResolveIssue(issue jira.Issue, target, resolution string) error {
+	transition, err := findResolveTransition(j.config, j.client, issue, target)
+	if err != nil || transition == nil {
+		return err
+	}
+
+	return j.doTransition(issue, *transition, resolution)
+}
+
+// TransitionIssueCategory moves a JIRA issue through the first available
+// transition to a status in the "Done" category if done is set, or to a
+// status in any other category if it isn't. If no available transition
+// leads to such a status, the issue is left as is.
+func (j realJIRAClient) TransitionIssueCategory(issue jira.Issue, done bool) error {
+	transition, err := findCategoryTransition(j.config, j.client, issue, done)
+	if err != nil || transition == nil {
+		return err
+	}
+
+	return j.doTransition(issue, *transition, "")
+}
+
+// doTransition moves a JIRA issue through a transition, setting its
+// resolution, if one is given and the screen of the transition has it.
+func (j realJIRAClient) doTransition(issue jira.Issue, transition jiraTransition, resolution string) error {
 	log := j.config.GetLogger()
 
-	var ret interface{}
-	var res *jira.Response
+	if resolution != "" && !transition.hasResolution() {
+		log.Debugf("Transition %s of JIRA issue %s has no resolution field; not setting resolution %s", transition.Name, issue.Key, resolution)
+		resolution = ""
+	}
 
-	op := func() error {
-		var err error
-		ret, res, err = f()
-		if err != nil {
-			body, _ := ioutil.ReadAll(res.Body)
-			log.Debug(string(body))
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		if resolution == "" {
+			res, err := j.client.Issue.DoTransition(issue.ID, transition.ID)
+			return nil, res, err
 		}
-		return err
+		res, err := j.client.Issue.DoTransitionWithPayload(issue.ID, map[string]interface{}{
+			"transition": map[string]string{"id": transition.ID},
+			"fields":     map[string]interface{}{"resolution": map[string]string{"name": resolution}},
+		})
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error transitioning JIRA issue %s: %v", issue.Key, err)
+		return getErrorBody(j.config, res)
 	}
 
-	b := backoff.NewExponentialBackOff()
-	b.MaxElapsedTime = j.config.GetTimeout()
+	log.Debugf("Transitioned JIRA issue %s with %s to %s", issue.Key, transition.Name, transition.To.Name)
 
-	backoffErr := backoff.RetryNotify(op, b, func(err error, duration time.Duration) {
-		// Round to a whole number of milliseconds
-		duration /= retryBackoffRoundRatio // Convert nanoseconds to milliseconds
-		duration *= retryBackoffRoundRatio // Convert back so it appears correct
+	return nil
+}
 
-		log.Errorf("Error performing operation; retrying in %v: %v", duration, err)
+// request takes an API function from the JIRA library
+// and calls it with exponential backoff (see retry). If the function succeeds,
+// it returns the expected value and the JIRA API response, as well as a nil
+// error. If it fails with an error which isn't transient, or continues to fail
+// until the retries are exhausted, it returns a nil result as well as the
+// returned HTTP response and the last error.
+func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error) {
+	var ret interface{}
+	var res *jira.Response
+
+	err := retry(j.config, func() retryResult {
+		var err error
+		ret, res, err = f()
+		return jiraRetryResult(j.config, res, err)
 	})
 
-	return ret, res, backoffErr
+	return ret, res, err
 }
 
 // dryrunJIRAClient is an implementation of JIRAClient which performs all
@@ -385,9 +627,12 @@ func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (
 // unsafe requests which may modify server data, instead printing out the
 // actions it is asked to perform without making the request.
 type dryrunJIRAClient struct {
-	config cfg.Config
-	client jira.Client
-	project jira.Project
+	config   cfg.Config
+	client   jira.Client
+	project  jira.Project
+	versions *versionCache
+	epics    *epicCache
+	meta     *metaCache
 }
 
 // newlineReplaceRegex is a regex to match both "\r\n" and just "\n" newline styles,
@@ -423,34 +668,14 @@ func (j dryrunJIRAClient) GetClient() jira.Client {
 //
 // This function is identical to that in realJIRAClient.
 func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
-	log := j.config.GetLogger()
-
 	idStrs := make([]string, len(ids))
 	for i, v := range ids {
 		idStrs[i] = fmt.Sprint(v)
 	}
 
-	var jql string
-	// If the list of IDs is too long, we get a 414 Request-URI Too Large, so in that case,
-	// we'll need to do the filtering ourselves.
-	if len(ids) < maxJQLIssueLength {
-		jql = fmt.Sprintf("project='%s' AND cf[%s] in (%s)",
-			j.project.Key, j.config.GetFieldID(cfg.GitHubID), strings.Join(idStrs, ","))
-	} else {
-		jql = fmt.Sprintf("project='%s'", j.project.Key)
-	}
-
-	ji, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		return j.client.Issue.Search(jql, nil)
-	})
+	jiraIssues, err := searchIssues(j.config, j.client, j.request, listIssuesJQL(j.config, j.project, idStrs))
 	if err != nil {
-		log.Errorf("Error retrieving JIRA issues: %v", err)
-		return nil, getErrorBody(j.config, res)
-	}
-	jiraIssues, ok := ji.([]jira.Issue)
-	if !ok {
-		log.Errorf("Get JIRA issues did not return issues! Got: %v", ji)
-		return nil, fmt.Errorf("get JIRA issues failed: expected []jira.Issue; got %T", ji)
+		return nil, err
 	}
 
 	var issues []jira.Issue
@@ -497,92 +722,279 @@ func (j dryrunJIRAClient) GetIssue(key string) (jira.Issue, error) {
 	return *issue, nil
 }
 
-// CreateIssue prints out the fields that would be set on a new issue were
-// it to be created according to the provided issue object. It returns the
-// provided issue object as-is.
+// CreateIssue prints out a diff of the fields that would be set on a new
+// issue were it to be created according to the provided issue object. It
+// returns the provided issue object as-is.
 func (j dryrunJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 	log := j.config.GetLogger()
 
 	fields := issue.Fields
 
-	log.Info("")
-	log.Info("Create new JIRA issue:")
-	log.Infof("  Summary: %s", fields.Summary)
-	log.Infof("  Description: %s", truncate(fields.Description, 50))
-	log.Infof("  GitHub ID: %d", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubID)])
-	log.Infof("  GitHub Number: %d", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubNumber)])
-	log.Infof("  Labels: %s", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubLabels)])
-	log.Infof("  State: %s", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubStatus)])
-	log.Infof("  Reporter: %s", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubReporter)])
-	log.Info("")
+	printDiff(os.Stdout, fmt.Sprintf("Create new JIRA issue for GitHub #%v:", fields.Unknowns[j.config.GetFieldKey(cfg.GitHubNumber)]),
+		j.issueDiff(jira.IssueFields{}, *fields))
+
+	meta, err := j.meta.createFields(j.config, j.client, j.project, fields.Type.Name)
+	if err == nil {
+		j.logProblems(fields, meta, true)
+	} else {
+		log.Errorf("  Invalid: %v", err)
+	}
+
+	j.recordPlan(PlanAction{Action: PlanCreateIssue, Issue: &issue})
 
 	return issue, nil
 }
 
-// UpdateIssue prints out the fields that would be set on a JIRA issue
-// (identified by issue.Key) were it to be updated according to the issue
-// object. It then returns the provided issue object as-is.
+// UpdateIssue prints out a diff of the fields of a JIRA issue (identified
+// by issue.Key) as they are and as they would be set were it to be updated
+// according to the issue object. It then returns the provided issue object
+// as-is.
 func (j dryrunJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	log := j.config.GetLogger()
 
 	fields := issue.Fields
 
-	log.Info("")
-	log.Infof("Update JIRA issue %s:", issue.Key)
-	log.Infof("  Summary: %s", fields.Summary)
-	log.Infof("  Description: %s", truncate(fields.Description, 50))
-	key := j.config.GetFieldKey(cfg.GitHubLabels)
-	if labels, err := fields.Unknowns.String(key); err == nil {
-		log.Infof("  Labels: %s", labels)
+	current := jira.IssueFields{}
+	if i, err := j.GetIssue(issue.Key); err == nil && i.Fields != nil {
+		current = *i.Fields
+	} else {
+		log.Errorf("  Could not retrieve JIRA issue %s to compare: %v", issue.Key, err)
 	}
-	key = j.config.GetFieldKey(cfg.GitHubStatus)
-	if state, err := fields.Unknowns.String(key); err == nil {
-		log.Infof("  State: %s", state)
+
+	printDiff(os.Stdout, fmt.Sprintf("Update JIRA issue %s:", issue.Key), j.issueDiff(current, *fields))
+
+	meta, err := editFields(j.config, j.client, issue.Key)
+	if err == nil {
+		j.logProblems(fields, meta, false)
+	} else {
+		log.Errorf("  Could not retrieve edit metadata: %v", err)
 	}
-	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanUpdateIssue, Key: issue.Key, ID: issue.ID, Issue: &issue})
 
 	return issue, nil
 }
 
+// issueDiff returns the synchronized fields of a JIRA issue as they are,
+// and as they would be set. Custom fields which aren't set are empty.
+func (j dryrunJIRAClient) issueDiff(old, new jira.IssueFields) []fieldDiff {
+	unknown := func(fields jira.IssueFields, key string) string {
+		if fields.Unknowns == nil {
+			return ""
+		}
+		if v, ok := fields.Unknowns[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	labels := func(fields jira.IssueFields) string {
+		if v, ok := fields.Unknowns["labels"].([]string); ok {
+			return strings.Join(v, ", ")
+		}
+		return strings.Join(fields.Labels, ", ")
+	}
+	components := func(fields jira.IssueFields) string {
+		list := fields.Components
+		if v, ok := fields.Unknowns["components"].([]*jira.Component); ok {
+			list = v
+		}
+		names := make([]string, len(list))
+		for i, c := range list {
+			names[i] = c.Name
+		}
+		return strings.Join(names, ", ")
+	}
+	assignee := func(fields jira.IssueFields) string {
+		if v, ok := fields.Unknowns["assignee"].(map[string]string); ok {
+			return v["accountId"] + v["name"]
+		}
+		if fields.Assignee != nil {
+			if fields.Assignee.Name != "" {
+				return fields.Assignee.Name
+			}
+			return fields.Assignee.DisplayName
+		}
+		return ""
+	}
+	users := func(fields jira.IssueFields, key string) string {
+		var names []string
+		switch v := fields.Unknowns[key].(type) {
+		case []map[string]string:
+			for _, u := range v {
+				names = append(names, u["accountId"]+u["name"])
+			}
+		case []interface{}:
+			for _, item := range v {
+				u, _ := item.(map[string]interface{})
+				if id, ok := u["accountId"].(string); ok && id != "" {
+					names = append(names, id)
+				} else if name, ok := u["name"].(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	estimate := func(fields jira.IssueFields) string {
+		if fields.TimeTracking == nil {
+			return ""
+		}
+		return fields.TimeTracking.OriginalEstimate
+	}
+	versions := func(fields jira.IssueFields) string {
+		names := make([]string, len(fields.FixVersions))
+		for i, v := range fields.FixVersions {
+			names[i] = v.Name
+		}
+		return strings.Join(names, ", ")
+	}
+
+	diffs := []fieldDiff{
+		{"Summary", old.Summary, new.Summary},
+		{"Description", old.Description, new.Description},
+		{"Labels", unknown(old, j.config.GetFieldKey(cfg.GitHubLabels)), unknown(new, j.config.GetFieldKey(cfg.GitHubLabels))},
+	}
+	if j.config.UseNativeLabels() {
+		diffs = append(diffs, fieldDiff{"JIRA Labels", labels(old), labels(new)})
+	}
+	// With native status, the state is only reflected by transitions.
+	if !j.config.UseNativeStatus() {
+		diffs = append(diffs, fieldDiff{"Status", unknown(old, j.config.GetFieldKey(cfg.GitHubStatus)), unknown(new, j.config.GetFieldKey(cfg.GitHubStatus))})
+	}
+	diffs = append(diffs, fieldDiff{"Reporter", unknown(old, j.config.GetFieldKey(cfg.GitHubReporter)), unknown(new, j.config.GetFieldKey(cfg.GitHubReporter))})
+	if j.config.SyncsReactions() {
+		diffs = append(diffs, fieldDiff{"Votes", unknown(old, j.config.GetFieldKey(cfg.GitHubVotes)), unknown(new, j.config.GetFieldKey(cfg.GitHubVotes))})
+	}
+	if j.config.UsesStoryPoints() {
+		diffs = append(diffs, fieldDiff{"Story Points", unknown(old, j.config.GetFieldKey(cfg.StoryPoints)), unknown(new, j.config.GetFieldKey(cfg.StoryPoints))})
+	}
+	// The original estimate is only set when the issue is created.
+	if j.config.UsesEstimateLabels() && new.TimeTracking != nil {
+		diffs = append(diffs, fieldDiff{"Original Estimate", estimate(old), estimate(new)})
+	}
+	if j.config.UsesComponents() {
+		diffs = append(diffs, fieldDiff{"Components", components(old), components(new)})
+	}
+	switch j.config.GetAssigneeMode() {
+	case cfg.AssigneeFirst, cfg.AssigneeRoundRobin:
+		diffs = append(diffs, fieldDiff{"Assignee", assignee(old), assignee(new)})
+	case cfg.AssigneeField:
+		key := j.config.GetFieldKey(cfg.AssigneesField)
+		diffs = append(diffs, fieldDiff{"Assignees", users(old, key), users(new, key)})
+	}
+	// The fix versions are only set if milestones are mapped to versions.
+	if new.FixVersions != nil {
+		diffs = append(diffs, fieldDiff{"Fix Versions", versions(old), versions(new)})
+	}
+	if j.config.SetsEpics() {
+		diffs = append(diffs, fieldDiff{"Epic", GetEpic(j.config, old), GetEpic(j.config, new)})
+	}
+
+	return diffs
+}
+
+// logProblems validates the fields of an issue which would be created or
+// updated against the JIRA metadata, and prints out every problem which
+// would make a real run fail.
+func (j dryrunJIRAClient) logProblems(fields *jira.IssueFields, meta map[string]metaField, create bool) {
+	log := j.config.GetLogger()
+
+	problems, err := validatePayload(fields, meta, create)
+	if err != nil {
+		log.Errorf("  Could not validate fields: %v", err)
+		return
+	}
+	for _, problem := range problems {
+		log.Errorf("  Invalid: %s", problem)
+	}
+}
+
 // CreateComment prints the body that would be set on a new comment if it were
 // to be created according to the fields of the provided GitHub comment. It then
 // returns a comment object containing the body that would be used.
 func (j dryrunJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComment, github GitHubClient) (jira.Comment, error) {
-	log := j.config.GetLogger()
-
 	user, err := github.GetUser(comment.User.GetLogin())
 	if err != nil {
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment (ID %d) from GitHub user %s", comment.GetID(), user.GetLogin())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
+	body, err := jiraCommentBody(j.config, comment, user)
+	if err != nil {
+		return jira.Comment{}, err
 	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
+	parts := commentParts(j.config, comment, body)
 
-	log.Info("")
-	log.Infof("Create comment on JIRA issue %s:", issue.Key)
-	log.Infof("  GitHub ID: %d", comment.GetID())
-	if user.GetName() != "" {
-		log.Infof("  User: %s (%s)", user.GetLogin(), user.GetName())
-	} else {
-		log.Infof("  User: %s", user.GetLogin())
-	}
-	log.Infof("  Posted at: %s", comment.CreatedAt.Format(commentDateFormat))
-	log.Infof("  Body: %s", truncate(comment.GetBody(), 100))
-	log.Info("")
+	printDiff(os.Stdout, fmt.Sprintf("Create comment on JIRA issue %s:", issue.Key), partDiffs(nil, parts))
+
+	j.recordPlan(PlanAction{Action: PlanCreateComment, Key: issue.Key, ID: issue.ID, Comment: &comment})
 
 	return jira.Comment{
-		Body: body,
+		Body: parts[0],
 	}, nil
 }
 
+// partDiffs returns the diffs of the parts of a JIRA comment, as they are
+// and as they would be set: the comment, and its continuations, if it's
+// split with the `oversized-text` split.
+func partDiffs(current, parts []string) []fieldDiff {
+	var diffs []fieldDiff
+	for i := 0; i < len(current) || i < len(parts); i++ {
+		name := "Comment"
+		if i > 0 {
+			name = fmt.Sprintf("Continuation %d", i)
+		}
+		diff := fieldDiff{name, "", ""}
+		if i < len(current) {
+			diff.old = current[i]
+		}
+		if i < len(parts) {
+			diff.new = parts[i]
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// currentParts returns the bodies of the parts of the JIRA comment of a
+// GitHub comment, as they are: the comment, and its continuations, if it's
+// split with the `oversized-text` split. Continuations which are missing
+// have an empty body.
+func (j dryrunJIRAClient) currentParts(issue jira.Issue, id string, comment github.IssueComment) ([]string, error) {
+	jiraIDs := []string{id}
+	if j.config.SplitsComments() {
+		ids, err := j.ListCommentIDs(issue)
+		if err != nil {
+			return []string{""}, err
+		}
+		for jiraID, ref := range ids {
+			if ref.GitHubID != comment.GetID() || ref.Part == 0 {
+				continue
+			}
+			for len(jiraIDs) <= ref.Part {
+				jiraIDs = append(jiraIDs, "")
+			}
+			jiraIDs[ref.Part] = jiraID
+		}
+	}
+
+	current := make([]string, len(jiraIDs))
+	for i, jiraID := range jiraIDs {
+		if jiraID == "" {
+			continue
+		}
+		req, err := j.client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, jiraID), nil)
+		if err != nil {
+			return current, err
+		}
+		c := new(jira.Comment)
+		if _, err := j.client.Do(req, c); err != nil {
+			return current, err
+		}
+		current[i] = c.Body
+	}
+	return current, nil
+}
+
 // UpdateComment prints the body that would be set on a comment were it to be
 // updated according to the provided GitHub comment. It then returns a comment
 // object containing the body that would be used.
@@ -594,68 +1006,104 @@ func (j dryrunJIRAClient) UpdateComment(issue jira.Issue, id string, comment git
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment (ID %d) from GitHub user %s", comment.GetID(), user.GetLogin())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
+	body, err := jiraCommentBody(j.config, comment, user)
+	if err != nil {
+		return jira.Comment{}, err
 	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
+	parts := commentParts(j.config, comment, body)
 
-	log.Info("")
-	log.Infof("Update JIRA comment %s on issue %s:", id, issue.Key)
-	log.Infof("  GitHub ID: %d", comment.GetID())
-	if user.GetName() != "" {
-		log.Infof("  User: %s (%s)", user.GetLogin(), user.GetName())
-	} else {
-		log.Infof("  User: %s", user.GetLogin())
+	current, err := j.currentParts(issue, id, comment)
+	if err != nil {
+		log.Errorf("  Could not retrieve JIRA comment %s to compare: %v", id, err)
 	}
-	log.Infof("  Posted at: %s", comment.CreatedAt.Format(commentDateFormat))
-	log.Infof("  Body: %s", truncate(comment.GetBody(), 100))
-	log.Info("")
+
+	printDiff(os.Stdout, fmt.Sprintf("Update JIRA comment %s on issue %s:", id, issue.Key), partDiffs(current, parts))
+
+	j.recordPlan(PlanAction{Action: PlanUpdateComment, Key: issue.Key, ID: issue.ID, CommentID: id, Comment: &comment})
 
 	return jira.Comment{
 		ID:   id,
-		Body: body,
+		Body: parts[0],
 	}, nil
 }
 
+// TransitionIssue prints the transition which would be performed on the
+// JIRA issue to reach the target, without performing it.
+func (j dryrunJIRAClient) TransitionIssue(issue jira.Issue, target string) error {
+	transition, err := findTransition(j.config, j.client, issue, target)
+	if err != nil || transition == nil {
+		return err
+	}
+
+	j.printTransition(issue, *transition, "")
+
+	return nil
+}
+
+// ResolveIssue prints the transition which would be performed on the JIRA
+// issue, with the resolution which would be set, without performing it.
+func (j dryrunJIRAClient) ResolveIssue(issue jira.Issue, target, resolution string) error {
+	transition, err := findResolveTransition(j.config, j.client, issue, target)
+	if err != nil || transition == nil {
+		return err
+	}
+
+	if !transition.hasResolution() {
+		resolution = ""
+	}
+	j.printTransition(issue, *transition, resolution)
+
+	return nil
+}
+
+// TransitionIssueCategory prints the transition which would be performed on
+// the JIRA issue to reach a status in the "Done" category, or in any other
+// category, without performing it.
+func (j dryrunJIRAClient) TransitionIssueCategory(issue jira.Issue, done bool) error {
+	transition, err := findCategoryTransition(j.config, j.client, issue, done)
+	if err != nil || transition == nil {
+		return err
+	}
+
+	j.printTransition(issue, *transition, "")
+
+	return nil
+}
+
+// printTransition prints a transition which would be performed on a JIRA
+// issue, with the resolution it would set, if any.
+func (j dryrunJIRAClient) printTransition(issue jira.Issue, transition jiraTransition, resolution string) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Transition JIRA issue %s:", issue.Key)
+	log.Infof("  Transition: %s (ID %s)", transition.Name, transition.ID)
+	log.Infof("  Status: %s", transition.To.Name)
+	if resolution != "" {
+		log.Infof("  Resolution: %s", resolution)
+	}
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanTransition, Key: issue.Key, ID: issue.ID, Transition: transition.ID, Resolution: resolution})
+}
+
 // request takes an API function from the JIRA library
-// and calls it with exponential backoff. If the function succeeds, it
-// returns the expected value and the JIRA API response, as well as a nil
-// error. If it continues to fail until a maximum time is reached, it returns
-// a nil result as well as the returned HTTP response and a timeout error.
+// and calls it with exponential backoff (see retry). If the function succeeds,
+// it returns the expected value and the JIRA API response, as well as a nil
+// error. If it fails with an error which isn't transient, or continues to fail
+// until the retries are exhausted, it returns a nil result as well as the
+// returned HTTP response and the last error.
 //
 // This function is identical to that in realJIRAClient.
 func (j dryrunJIRAClient) request(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error) {
-	log := j.config.GetLogger()
-
 	var ret interface{}
 	var res *jira.Response
 
-	op := func() error {
+	err := retry(j.config, func() retryResult {
 		var err error
 		ret, res, err = f()
-		if err != nil {
-			body, _ := ioutil.ReadAll(res.Body)
-			log.Debug(string(body))
-		}
-		return err
-	}
-
-	b := backoff.NewExponentialBackOff()
-	b.MaxElapsedTime = j.config.GetTimeout()
-
-	backoffErr := backoff.RetryNotify(op, b, func(err error, duration time.Duration) {
-		// Round to a whole number of milliseconds
-		duration /= retryBackoffRoundRatio // Convert nanoseconds to milliseconds
-		duration *= retryBackoffRoundRatio // Convert back so it appears correct
-
-		log.Errorf("Error performing operation; retrying in %v: %v", duration, err)
+		return jiraRetryResult(j.config, res, err)
 	})
 
-	return ret, res, backoffErr
+	return ret, res, err
 }
@@ -0,0 +1,49 @@
+package clients
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// AddAttachment attaches a file to a JIRA issue, and returns the attachment.
+func (j realJIRAClient) AddAttachment(issue jira.Issue, name string, content []byte) (jira.Attachment, error) {
+	log := j.config.GetLogger()
+
+	a, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		return j.client.Issue.PostAttachment(issue.ID, bytes.NewReader(content), name)
+	})
+	if err != nil {
+		log.Errorf("Error attaching %s to JIRA issue %s. Error: %v", name, issue.Key, err)
+		return jira.Attachment{}, getErrorBody(j.config, res)
+	}
+	attachments, ok := a.(*[]jira.Attachment)
+	if !ok || len(*attachments) == 0 {
+		log.Errorf("Attach JIRA file did not return attachment! Got: %v", a)
+		return jira.Attachment{}, fmt.Errorf("attach JIRA file failed: expected *[]jira.Attachment; got %T", a)
+	}
+
+	log.Debugf("Attached %s to JIRA issue %s", name, issue.Key)
+
+	return (*attachments)[0], nil
+}
+
+// AddAttachment prints the file that would be attached to the JIRA issue,
+// and returns an attachment with its name and size.
+func (j dryrunJIRAClient) AddAttachment(issue jira.Issue, name string, content []byte) (jira.Attachment, error) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Attach file to JIRA issue %s:", issue.Key)
+	log.Infof("  Name: %s", name)
+	log.Infof("  Size: %d bytes", len(content))
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanAddAttachment, Key: issue.Key, ID: issue.ID, Name: name, Content: content})
+
+	return jira.Attachment{
+		Filename: name,
+		Size:     len(content),
+	}, nil
+}
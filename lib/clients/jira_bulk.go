@@ -0,0 +1,120 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// MaxBulkIssues is the maximum number of issues JIRA creates in a single
+// bulk request.
+const MaxBulkIssues = 50
+
+// bulkIssues is the body of a bulk creation request.
+type bulkIssues struct {
+	IssueUpdates []jira.Issue `json:"issueUpdates"`
+}
+
+// bulkResult is the response to a bulk creation request: the issues which
+// were created, in order, and the errors of the others, identified by their
+// index in the request.
+type bulkResult struct {
+	Issues []jira.Issue `json:"issues"`
+	Errors []struct {
+		ElementErrors struct {
+			ErrorMessages []string          `json:"errorMessages"`
+			Errors        map[string]string `json:"errors"`
+		} `json:"elementErrors"`
+		FailedElementNumber int `json:"failedElementNumber"`
+	} `json:"errors"`
+}
+
+// itemErrors returns the error of each issue of a bulk creation request of
+// n issues, which is nil for those which were created.
+func (r bulkResult) itemErrors(n int) []error {
+	errs := make([]error, n)
+	for _, e := range r.Errors {
+		if e.FailedElementNumber < 0 || e.FailedElementNumber >= n {
+			continue
+		}
+		messages := append([]string{}, e.ElementErrors.ErrorMessages...)
+		var fields []string
+		for field, message := range e.ElementErrors.Errors {
+			fields = append(fields, fmt.Sprintf("%s: %s", field, message))
+		}
+		sort.Strings(fields)
+		messages = append(messages, fields...)
+		if len(messages) == 0 {
+			messages = []string{"unknown error"}
+		}
+		errs[e.FailedElementNumber] = errors.New(strings.Join(messages, "; "))
+	}
+	return errs
+}
+
+// CreateIssues creates JIRA issues with a single request, for at most
+// MaxBulkIssues issues. It returns, for each issue, in order, either the
+// issue which was created, with only its ID and key, or the error it failed
+// with.
+func (j realJIRAClient) CreateIssues(issues []jira.Issue) ([]jira.Issue, []error) {
+	log := j.config.GetLogger()
+
+	created := make([]jira.Issue, len(issues))
+	failed := func(err error) ([]jira.Issue, []error) {
+		errs := make([]error, len(issues))
+		for i := range errs {
+			errs[i] = err
+		}
+		return created, errs
+	}
+
+	result := new(bulkResult)
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		req, err := j.client.NewRequest("POST", "rest/api/2/issue/bulk", bulkIssues{issues})
+		if err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, result)
+		return nil, res, err
+	})
+	if err != nil {
+		// JIRA responds with an error if none of the issues was created, in
+		// which case the body still has the errors of each of them.
+		body := getErrorBody(j.config, res)
+		if json.Unmarshal([]byte(body.Error()), result) != nil || len(result.Errors) == 0 {
+			log.Errorf("Error creating JIRA issues: %v", err)
+			return failed(body)
+		}
+	}
+
+	errs := result.itemErrors(len(issues))
+	next := 0
+	for i := range issues {
+		if errs[i] != nil {
+			continue
+		}
+		if next >= len(result.Issues) {
+			errs[i] = errors.New("create JIRA issues failed: issue missing from the response")
+			continue
+		}
+		created[i] = result.Issues[next]
+		next++
+	}
+
+	return created, errs
+}
+
+// CreateIssues prints out the fields of each JIRA issue which would be
+// created, as CreateIssue does, and returns them as-is.
+func (j dryrunJIRAClient) CreateIssues(issues []jira.Issue) ([]jira.Issue, []error) {
+	created := make([]jira.Issue, len(issues))
+	errs := make([]error, len(issues))
+	for i, issue := range issues {
+		created[i], errs[i] = j.CreateIssue(issue)
+	}
+	return created, errs
+}
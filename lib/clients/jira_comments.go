@@ -0,0 +1,253 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// commentPropertyKey is the key of the entity property of JIRA comments
+// which records the GitHub comment they're synced from, so that comments are
+// matched even if their text is edited.
+const commentPropertyKey = "issue-sync"
+
+// commentProperty is the value of the entity property of synced comments.
+type commentProperty struct {
+	GitHubID int `json:"github-id"`
+	Part     int `json:"part,omitempty"`
+}
+
+// CommentRef is the GitHub comment a JIRA comment is synced from, and which
+// part of it the JIRA comment is, if it's split across several JIRA
+// comments with the `oversized-text` split: 0 for the first part, which is
+// the one matched, and then 1, 2, and so on for its continuations.
+type CommentRef struct {
+	GitHubID int
+	Part     int
+}
+
+// commentPage is a page of the comments of a JIRA issue, with their entity
+// properties.
+type commentPage struct {
+	StartAt    int `json:"startAt"`
+	MaxResults int `json:"maxResults"`
+	Total      int `json:"total"`
+	Comments   []struct {
+		ID         string `json:"id"`
+		Properties []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		} `json:"properties"`
+	} `json:"comments"`
+}
+
+// listCommentIDs returns the GitHub comments which the comments of a JIRA
+// issue are synced from, by JIRA comment ID, as recorded in their entity
+// property, or else in the state. Other comments aren't included.
+func listCommentIDs(config cfg.Config, client jira.Client, request jiraRequester, issue jira.Issue) (map[string]CommentRef, error) {
+	log := config.GetLogger()
+
+	ids := make(map[string]CommentRef)
+	for startAt, total := 0, 1; startAt < total; {
+		uri := fmt.Sprintf("rest/api/2/issue/%s/comment?expand=properties&startAt=%d&maxResults=%d",
+			issue.Key, startAt, config.GetJIRAPageSize())
+		req, err := client.NewRequest("GET", uri, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page commentPage
+		_, res, err := request(func() (interface{}, *jira.Response, error) {
+			res, err := client.Do(req, &page)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error retrieving the comments of JIRA issue %s. Error: %v", issue.Key, err)
+			return nil, getErrorBody(config, res)
+		}
+
+		for _, comment := range page.Comments {
+			for _, property := range comment.Properties {
+				var value commentProperty
+				if property.Key == commentPropertyKey && json.Unmarshal(property.Value, &value) == nil && value.GitHubID != 0 {
+					ids[comment.ID] = CommentRef{GitHubID: value.GitHubID, Part: value.Part}
+				}
+			}
+			if _, ok := ids[comment.ID]; !ok {
+				if id, part, ok := config.GetState().GetCommentPart(comment.ID); ok {
+					ids[comment.ID] = CommentRef{GitHubID: id, Part: part}
+				}
+			}
+		}
+
+		if len(page.Comments) == 0 {
+			break
+		}
+		startAt, total = page.StartAt+len(page.Comments), page.Total
+	}
+
+	return ids, nil
+}
+
+// ListCommentIDs returns the GitHub comments which the comments of a JIRA
+// issue are synced from, by JIRA comment ID.
+func (j realJIRAClient) ListCommentIDs(issue jira.Issue) (map[string]CommentRef, error) {
+	return listCommentIDs(j.config, j.client, j.request, issue)
+}
+
+// ListCommentIDs returns the GitHub comments which the comments of a JIRA
+// issue are synced from, by JIRA comment ID.
+func (j dryrunJIRAClient) ListCommentIDs(issue jira.Issue) (map[string]CommentRef, error) {
+	return listCommentIDs(j.config, j.client, j.request, issue)
+}
+
+// setCommentPart records the GitHub comment a JIRA comment is synced from,
+// and which part of it the JIRA comment is, in the entity property of the
+// comment.
+func (j realJIRAClient) setCommentPart(comment jira.Comment, id, part int) error {
+	uri := fmt.Sprintf("rest/api/2/comment/%s/properties/%s", comment.ID, commentPropertyKey)
+	req, err := j.client.NewRequest("PUT", uri, commentProperty{GitHubID: id, Part: part})
+	if err != nil {
+		return err
+	}
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		return getErrorBody(j.config, res)
+	}
+	return nil
+}
+
+// SetCommentID records the GitHub comment a JIRA comment is synced from in
+// the entity property of the comment, or, if it can't be set, in the state.
+func (j realJIRAClient) SetCommentID(comment jira.Comment, id int) error {
+	log := j.config.GetLogger()
+
+	if err := j.setCommentPart(comment, id, 0); err != nil {
+		log.Warnf("Error recording the GitHub ID of JIRA comment %s; recording it in the state instead. Error: %v", comment.ID, err)
+		j.config.GetState().SetComment(comment.ID, id)
+	}
+	return nil
+}
+
+// commentParts returns the parts of the JIRA comment of a GitHub comment:
+// the whole body, or, if it's too long for JIRA, the body truncated with a
+// link to the comment, or split across several parts with the
+// `oversized-text` split.
+func commentParts(config cfg.Config, comment github.IssueComment, body string) []string {
+	if config.SplitsComments() {
+		return config.SplitJIRAText(body)
+	}
+	return []string{config.TruncateJIRAText(body, comment.GetHTMLURL())}
+}
+
+// syncContinuations makes the continuations of the JIRA comment of a GitHub
+// comment split with the `oversized-text` split those of the parts: the
+// existing ones are updated, the missing ones added, and the extra ones,
+// left by a longer version of the comment, deleted.
+func (j realJIRAClient) syncContinuations(issue jira.Issue, comment github.IssueComment, parts []string) error {
+	log := j.config.GetLogger()
+
+	continuations := make(map[int]string)
+	ids, err := listCommentIDs(j.config, j.client, j.request, issue)
+	if err != nil {
+		return err
+	}
+	for jiraID, ref := range ids {
+		if ref.GitHubID == comment.GetID() && ref.Part > 0 {
+			continuations[ref.Part] = jiraID
+		}
+	}
+
+	for part := 1; part < len(parts); part++ {
+		if jiraID, ok := continuations[part]; ok {
+			if _, err := j.updateCommentBody(issue, jiraID, parts[part]); err != nil {
+				return err
+			}
+			delete(continuations, part)
+			continue
+		}
+		co, err := j.AddComment(issue, parts[part])
+		if err != nil {
+			return err
+		}
+		if err := j.setCommentPart(co, comment.GetID(), part); err != nil {
+			log.Warnf("Error recording the GitHub ID of JIRA comment %s; recording it in the state instead. Error: %v", co.ID, err)
+			j.config.GetState().SetCommentPart(co.ID, comment.GetID(), part)
+		}
+	}
+
+	for _, jiraID := range continuations {
+		req, err := j.client.NewRequest("DELETE", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, jiraID), nil)
+		if err != nil {
+			return err
+		}
+		_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+			res, err := j.client.Do(req, nil)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error deleting JIRA comment %s. Error: %v", jiraID, err)
+			return getErrorBody(j.config, res)
+		}
+		j.config.GetState().DeleteComment(jiraID)
+	}
+
+	return nil
+}
+
+// SetCommentID prints the GitHub comment which would be recorded in the
+// entity property of a JIRA comment.
+func (j dryrunJIRAClient) SetCommentID(comment jira.Comment, id int) error {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Record GitHub comment %d in JIRA comment %s", id, comment.ID)
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanSetCommentID, CommentID: comment.ID, Comment: &github.IssueComment{ID: &id}})
+
+	return nil
+}
+
+// AddComment adds a comment which isn't synced from GitHub, such as a notice
+// from issue-sync, to a JIRA issue.
+func (j realJIRAClient) AddComment(issue jira.Issue, body string) (jira.Comment, error) {
+	log := j.config.GetLogger()
+
+	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		return j.client.Issue.AddComment(issue.ID, &jira.Comment{Body: j.config.LimitJIRAText(body)})
+	})
+	if err != nil {
+		log.Errorf("Error adding comment to JIRA issue %s. Error: %v", issue.Key, err)
+		return jira.Comment{}, getErrorBody(j.config, res)
+	}
+	comment, ok := com.(*jira.Comment)
+	if !ok {
+		log.Errorf("Add JIRA comment did not return comment! Got: %v", com)
+		return jira.Comment{}, fmt.Errorf("add JIRA comment failed: expected *jira.Comment; got %T", com)
+	}
+
+	return *comment, nil
+}
+
+// AddComment prints the comment which would be added to the JIRA issue, and
+// returns it.
+func (j dryrunJIRAClient) AddComment(issue jira.Issue, body string) (jira.Comment, error) {
+	log := j.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Add comment to JIRA issue %s:", issue.Key)
+	log.Infof("  Body: %s", truncate(body, 50))
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanAddComment, Key: issue.Key, ID: issue.ID, Body: body})
+
+	return jira.Comment{Body: body}, nil
+}
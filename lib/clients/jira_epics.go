@@ -0,0 +1,202 @@
+package clients
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// epicIssueType is the name of the JIRA issue type of epics.
+const epicIssueType = "Epic"
+
+// jiraEpic is the key and the description of an epic.
+type jiraEpic struct {
+	key         string
+	description string
+}
+
+// epicCache holds the epics of the JIRA project of a client, by their
+// summary, so that each is only searched for once per run.
+type epicCache struct {
+	mu     sync.Mutex
+	byName map[string]jiraEpic
+}
+
+// newEpicCache creates an empty epicCache.
+func newEpicCache() *epicCache {
+	return &epicCache{
+		byName: make(map[string]jiraEpic),
+	}
+}
+
+// findEpic searches the JIRA project for an epic whose summary is the given
+// name, and returns it, or an epic with an empty key if there's none. The text
+// search of JIRA is fuzzy, so the summaries of the results are compared exactly.
+func findEpic(config cfg.Config, client jira.Client, request jiraRequester, project jira.Project, name string) (jiraEpic, error) {
+	// Quotes and backslashes can't be escaped in a phrase of a text search,
+	// so they are left out of it.
+	phrase := strings.NewReplacer(`"`, " ", `\`, " ").Replace(name)
+	jql := fmt.Sprintf(`project='%s' AND issuetype=%s AND summary ~ "\"%s\""`, project.Key, epicIssueType, phrase)
+
+	issues, err := searchIssues(config, client, request, jql)
+	if err != nil {
+		return jiraEpic{}, err
+	}
+	for _, issue := range issues {
+		if issue.Fields != nil && issue.Fields.Summary == name {
+			return jiraEpic{issue.Key, issue.Fields.Description}, nil
+		}
+	}
+	return jiraEpic{}, nil
+}
+
+// SetEpic sets the fields of an issue which add it to the epic with the given
+// key, or remove it from its epic if the key is empty. JIRA Cloud sets the
+// epic as the parent of the issue, and JIRA Server in the "Epic Link" field.
+func SetEpic(config cfg.Config, fields *jira.IssueFields, key string) {
+	if fields.Unknowns == nil {
+		fields.Unknowns = map[string]interface{}{}
+	}
+
+	if config.IsJIRACloud() {
+		if key == "" {
+			fields.Unknowns["parent"] = nil
+		} else {
+			fields.Unknowns["parent"] = map[string]string{"key": key}
+		}
+		return
+	}
+
+	if key == "" {
+		fields.Unknowns[config.GetFieldKey(cfg.EpicLink)] = nil
+	} else {
+		fields.Unknowns[config.GetFieldKey(cfg.EpicLink)] = key
+	}
+}
+
+// GetEpic returns the key of the epic of an issue, as set by SetEpic or as
+// returned by JIRA, or an empty key if it isn't in any epic.
+func GetEpic(config cfg.Config, fields jira.IssueFields) string {
+	if config.IsJIRACloud() {
+		switch parent := fields.Unknowns["parent"].(type) {
+		case map[string]string:
+			return parent["key"]
+		case nil:
+			if _, ok := fields.Unknowns["parent"]; ok {
+				return ""
+			}
+		}
+		if fields.Parent != nil {
+			return fields.Parent.Key
+		}
+		return ""
+	}
+
+	key, _ := fields.Unknowns[config.GetFieldKey(cfg.EpicLink)].(string)
+	return key
+}
+
+// SyncEpic ensures that an epic with the given name exists in the JIRA
+// project, creating it as needed, and returns its key. The description of
+// the epic is kept in sync with the given description.
+func (j realJIRAClient) SyncEpic(name, description string) (string, error) {
+	log := j.config.GetLogger()
+
+	j.epics.mu.Lock()
+	defer j.epics.mu.Unlock()
+
+	epic, ok := j.epics.byName[name]
+	if !ok {
+		var err error
+		if epic, err = findEpic(j.config, j.client, j.request, j.project, name); err != nil {
+			log.Errorf("Error searching for JIRA epic %s: %v", name, err)
+			return "", err
+		}
+	}
+
+	if epic.key != "" && epic.description != description {
+		_, err := j.UpdateIssue(jira.Issue{
+			Key:    epic.key,
+			Fields: &jira.IssueFields{Description: description},
+		})
+		if err != nil {
+			log.Errorf("Error updating the description of JIRA epic %s: %v", epic.key, err)
+			return "", err
+		}
+		epic.description = description
+		log.Debugf("Updated the description of JIRA epic %s for milestone %s", epic.key, name)
+	}
+
+	if epic.key == "" {
+		fields := &jira.IssueFields{
+			Type:        jira.IssueType{Name: epicIssueType},
+			Project:     j.project,
+			Summary:     name,
+			Description: description,
+			Unknowns:    map[string]interface{}{},
+		}
+		if j.config.GetFieldID(cfg.EpicName) != "" {
+			fields.Unknowns[j.config.GetFieldKey(cfg.EpicName)] = name
+		}
+
+		i, res, err := j.request(func() (interface{}, *jira.Response, error) {
+			return j.client.Issue.Create(&jira.Issue{Fields: fields})
+		})
+		if err != nil {
+			log.Errorf("Error creating JIRA epic %s: %v", name, err)
+			return "", getErrorBody(j.config, res)
+		}
+		created, ok := i.(*jira.Issue)
+		if !ok {
+			log.Errorf("Create JIRA epic did not return issue! Got: %v", i)
+			return "", fmt.Errorf("create JIRA epic failed: expected *jira.Issue; got %T", i)
+		}
+		epic = jiraEpic{created.Key, description}
+		log.Debugf("Created JIRA epic %s for milestone %s", epic.key, name)
+	}
+
+	j.epics.byName[name] = epic
+
+	return epic.key, nil
+}
+
+// SyncEpic prints out the epic which would be created for the given name,
+// if the JIRA project has none yet, or updated to match the given
+// description. It returns the key of the existing epic, or a placeholder
+// naming the epic which would be created.
+func (j dryrunJIRAClient) SyncEpic(name, description string) (string, error) {
+	j.epics.mu.Lock()
+	defer j.epics.mu.Unlock()
+
+	epic, ok := j.epics.byName[name]
+	if !ok {
+		var err error
+		if epic, err = findEpic(j.config, j.client, j.request, j.project, name); err != nil {
+			return "", err
+		}
+	}
+	if epic.key != "" && epic.description == description {
+		j.epics.byName[name] = epic
+		return epic.key, nil
+	}
+
+	log := j.config.GetLogger()
+	log.Info("")
+	if epic.key != "" {
+		log.Infof("Update JIRA epic %s:", epic.key)
+	} else {
+		log.Infof("Create JIRA epic %s:", name)
+		epic.key = newEpicPlaceholder(name)
+	}
+	log.Infof("  Description: %s", truncate(description, 50))
+	log.Info("")
+	j.recordPlan(PlanAction{Action: PlanSyncEpic, Name: name, Description: description})
+
+	epic.description = description
+	j.epics.byName[name] = epic
+
+	return epic.key, nil
+}
@@ -0,0 +1,169 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// Names of the JIRA issue link types of the references between issues: one
+// which closes another blocks it, and other references relate them. The
+// duplicate JIRA issues of a GitHub issue are linked to its canonical one as
+// duplicates, and sub-issues block their parent.
+const (
+	RelatesLinkType   = "Relates"
+	BlocksLinkType    = "Blocks"
+	DuplicateLinkType = "Duplicate"
+	SubIssueLinkType  = BlocksLinkType
+)
+
+// findGitHubIssue returns the key of the JIRA issue of the GitHub issue with
+// the given ID in any project of the JIRA instance, or an empty key if it
+// isn't synced. GitHub IDs are unique across repos, unlike their numbers.
+func findGitHubIssue(config cfg.Config, client jira.Client, request jiraRequester, id int) (string, error) {
+	jql := fmt.Sprintf("cf[%s] = %d", config.GetFieldID(cfg.GitHubID), id)
+
+	issues, err := searchIssues(config, client, request, jql)
+	if err != nil || len(issues) == 0 {
+		return "", err
+	}
+	return issues[0].Key, nil
+}
+
+// findIssueLink returns the link of the given type between a JIRA issue and
+// another one, in either direction, or nil if they aren't linked.
+func findIssueLink(issue jira.Issue, linkType, key string) *jira.IssueLink {
+	if issue.Fields == nil {
+		return nil
+	}
+	for _, link := range issue.Fields.IssueLinks {
+		if link.Type.Name != linkType {
+			continue
+		}
+		if (link.OutwardIssue != nil && link.OutwardIssue.Key == key) || (link.InwardIssue != nil && link.InwardIssue.Key == key) {
+			return link
+		}
+	}
+	return nil
+}
+
+// hasIssueLink returns whether a JIRA issue is linked to another one with a
+// link of the given type, in either direction.
+func hasIssueLink(issue jira.Issue, linkType, key string) bool {
+	return findIssueLink(issue, linkType, key) != nil
+}
+
+// newIssueLink returns a link of the given type from a JIRA issue to another
+// one. JIRA gives the outward description of the type, such as "blocks", to
+// the inward issue of the link.
+func newIssueLink(issue jira.Issue, linkType, key string) *jira.IssueLink {
+	return &jira.IssueLink{
+		Type:         jira.IssueLinkType{Name: linkType},
+		InwardIssue:  &jira.Issue{Key: issue.Key},
+		OutwardIssue: &jira.Issue{Key: key},
+	}
+}
+
+// FindGitHubIssue returns the key of the JIRA issue of the GitHub issue with
+// the given ID, or an empty key if it isn't synced.
+func (j realJIRAClient) FindGitHubIssue(id int) (string, error) {
+	return findGitHubIssue(j.config, j.client, j.request, id)
+}
+
+// LinkIssue links a JIRA issue to the one with the given key, with a link of
+// the given type, unless they're linked already.
+func (j realJIRAClient) LinkIssue(issue jira.Issue, linkType, key string) error {
+	log := j.config.GetLogger()
+
+	if hasIssueLink(issue, linkType, key) {
+		return nil
+	}
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Issue.AddLink(newIssueLink(issue, linkType, key))
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error linking JIRA issue %s to %s: %v", issue.Key, key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	log.Debugf("Linked JIRA issue %s to %s (%s)", issue.Key, key, linkType)
+
+	return nil
+}
+
+// UnlinkIssue removes the link of the given type between a JIRA issue and
+// the one with the given key, if they're linked.
+func (j realJIRAClient) UnlinkIssue(issue jira.Issue, linkType, key string) error {
+	log := j.config.GetLogger()
+
+	link := findIssueLink(issue, linkType, key)
+	if link == nil {
+		return nil
+	}
+
+	req, err := j.client.NewRequest("DELETE", fmt.Sprintf("rest/api/2/issueLink/%s", link.ID), nil)
+	if err != nil {
+		return err
+	}
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error unlinking JIRA issue %s from %s: %v", issue.Key, key, err)
+		return getErrorBody(j.config, res)
+	}
+
+	log.Debugf("Unlinked JIRA issue %s from %s (%s)", issue.Key, key, linkType)
+
+	return nil
+}
+
+// FindGitHubIssue returns the key of the JIRA issue of the GitHub issue with
+// the given ID, or an empty key if it isn't synced.
+func (j dryrunJIRAClient) FindGitHubIssue(id int) (string, error) {
+	return findGitHubIssue(j.config, j.client, j.request, id)
+}
+
+// LinkIssue prints the link which would be added between the JIRA issues,
+// unless they're linked already.
+func (j dryrunJIRAClient) LinkIssue(issue jira.Issue, linkType, key string) error {
+	log := j.config.GetLogger()
+
+	if hasIssueLink(issue, linkType, key) {
+		return nil
+	}
+
+	log.Info("")
+	log.Infof("Link JIRA issue %s:", issue.Key)
+	log.Infof("  Type: %s", linkType)
+	log.Infof("  Issue: %s", key)
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanLinkIssue, Key: issue.Key, ID: issue.ID, LinkType: linkType, Target: key})
+
+	return nil
+}
+
+// UnlinkIssue prints the link which would be removed between the JIRA
+// issues, if they're linked.
+func (j dryrunJIRAClient) UnlinkIssue(issue jira.Issue, linkType, key string) error {
+	log := j.config.GetLogger()
+
+	if !hasIssueLink(issue, linkType, key) {
+		return nil
+	}
+
+	log.Info("")
+	log.Infof("Unlink JIRA issue %s:", issue.Key)
+	log.Infof("  Type: %s", linkType)
+	log.Infof("  Issue: %s", key)
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanUnlinkIssue, Key: issue.Key, ID: issue.ID, LinkType: linkType, Target: key})
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+)
+
+// Icons of the remote links to GitHub issues, and of the state of the issues.
+const (
+	openIssueIconURL   = "https://raw.githubusercontent.com/primer/octicons/main/icons/issue-opened-16.svg"
+	closedIssueIconURL = "https://raw.githubusercontent.com/primer/octicons/main/icons/issue-closed-16.svg"
+)
+
+// remoteLinkIcon is the icon of a remote link, or of the status of its object.
+type remoteLinkIcon struct {
+	URL   string `json:"url16x16"`
+	Title string `json:"title"`
+}
+
+// remoteLink is a link of a JIRA issue to an object of another system, such
+// as a GitHub issue. Links are identified by their global ID, so that saving
+// a link with the same global ID updates it.
+type remoteLink struct {
+	GlobalID    string `json:"globalId"`
+	Application struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"application"`
+	Relationship string `json:"relationship"`
+	Object       struct {
+		URL     string         `json:"url"`
+		Title   string         `json:"title"`
+		Summary string         `json:"summary"`
+		Icon    remoteLinkIcon `json:"icon"`
+		Status  struct {
+			Resolved bool           `json:"resolved"`
+			Icon     remoteLinkIcon `json:"icon"`
+		} `json:"status"`
+	} `json:"object"`
+}
+
+// newRemoteLink returns the remote link of a JIRA issue to the GitHub issue
+// of the repo, whose icon shows the state of the GitHub issue.
+func newRemoteLink(webURL, repo string, issue github.Issue) remoteLink {
+	var link remoteLink
+
+	link.GlobalID = fmt.Sprintf("github-issue-%d", issue.GetID())
+	link.Application.Type = "com.github"
+	link.Application.Name = "GitHub"
+	link.Relationship = "GitHub issue"
+	link.Object.URL = issue.GetHTMLURL()
+	link.Object.Title = fmt.Sprintf("%s#%d", repo, issue.GetNumber())
+	link.Object.Summary = issue.GetTitle()
+	link.Object.Icon = remoteLinkIcon{webURL + "favicon.ico", "GitHub"}
+
+	if issue.GetState() == "closed" {
+		link.Object.Status.Resolved = true
+		link.Object.Status.Icon = remoteLinkIcon{closedIssueIconURL, "Closed"}
+	} else {
+		link.Object.Status.Icon = remoteLinkIcon{openIssueIconURL, "Open"}
+	}
+
+	return link
+}
+
+// SyncRemoteLink creates or updates the remote link of a JIRA issue to the
+// GitHub issue of the repo it's synced with.
+func (j realJIRAClient) SyncRemoteLink(issue jira.Issue, repo string, ghIssue github.Issue) error {
+	log := j.config.GetLogger()
+
+	link := newRemoteLink(j.config.GetGitHubWebURL(), repo, ghIssue)
+
+	req, err := j.client.NewRequest("POST", fmt.Sprintf("rest/api/2/issue/%s/remotelink", issue.Key), link)
+	if err != nil {
+		log.Errorf("Error creating remote link request: %s", err)
+		return err
+	}
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error linking JIRA issue %s to %s: %v", issue.Key, link.Object.URL, err)
+		return getErrorBody(j.config, res)
+	}
+
+	log.Debugf("Linked JIRA issue %s to %s", issue.Key, link.Object.URL)
+
+	return nil
+}
+
+// SyncRemoteLink prints the remote link of the JIRA issue to the GitHub
+// issue which would be created or updated.
+func (j dryrunJIRAClient) SyncRemoteLink(issue jira.Issue, repo string, ghIssue github.Issue) error {
+	log := j.config.GetLogger()
+
+	link := newRemoteLink(j.config.GetGitHubWebURL(), repo, ghIssue)
+
+	log.Info("")
+	log.Infof("Link JIRA issue %s to GitHub issue:", issue.Key)
+	log.Infof("  URL: %s", link.Object.URL)
+	log.Infof("  Title: %s", link.Object.Title)
+	log.Infof("  State: %s", ghIssue.GetState())
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanSyncLink, Repo: repo, Key: issue.Key, ID: issue.ID, GitHubIssue: &ghIssue})
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// metaField is the create or edit metadata of a single JIRA issue field.
+// For an example of its structure, make a request to
+// `${jira-uri}/rest/api/2/issue/createmeta?expand=projects.issuetypes.fields`.
+type metaField struct {
+	Required        bool   `json:"required"`
+	Name            string `json:"name"`
+	HasDefaultValue bool   `json:"hasDefaultValue"`
+	AllowedValues   []struct {
+		ID    string `json:"id"`
+		Key   string `json:"key"`
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		// AccountID identifies the users of JIRA Cloud, which have no
+		// usernames.
+		AccountID string `json:"accountId"`
+	} `json:"allowedValues"`
+}
+
+// allows reports whether the field accepts the given value. Fields without
+// a list of allowed values accept anything.
+func (f metaField) allows(value string) bool {
+	if len(f.AllowedValues) == 0 {
+		return true
+	}
+	for _, a := range f.AllowedValues {
+		if value == a.ID || strings.EqualFold(value, a.Key) ||
+			strings.EqualFold(value, a.Name) || strings.EqualFold(value, a.Value) ||
+			(a.AccountID != "" && value == a.AccountID) {
+			return true
+		}
+	}
+	return false
+}
+
+// metaCache holds the create metadata of the JIRA project of a dry-run
+// client, indexed by issue type name, so it's only requested once per run.
+type metaCache struct {
+	mu     sync.Mutex
+	create map[string]map[string]metaField
+}
+
+// newMetaCache creates an empty metaCache.
+func newMetaCache() *metaCache {
+	return &metaCache{}
+}
+
+// createFields returns the create metadata of the fields of an issue type.
+func (m *metaCache) createFields(config cfg.Config, client jira.Client, project jira.Project, issueType string) (map[string]metaField, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.create == nil {
+		req, err := client.NewRequest("GET", fmt.Sprintf(
+			"rest/api/2/issue/createmeta?projectKeys=%s&expand=projects.issuetypes.fields", project.Key), nil)
+		if err != nil {
+			return nil, err
+		}
+		meta := new(struct {
+			Projects []struct {
+				Key        string `json:"key"`
+				IssueTypes []struct {
+					Name   string               `json:"name"`
+					Fields map[string]metaField `json:"fields"`
+				} `json:"issuetypes"`
+			} `json:"projects"`
+		})
+		res, err := client.Do(req, meta)
+		if err != nil {
+			return nil, getErrorBody(config, res)
+		}
+
+		m.create = make(map[string]map[string]metaField)
+		for _, p := range meta.Projects {
+			for _, t := range p.IssueTypes {
+				m.create[strings.ToLower(t.Name)] = t.Fields
+			}
+		}
+	}
+
+	fields, ok := m.create[strings.ToLower(issueType)]
+	if !ok {
+		return nil, fmt.Errorf("issue type %q cannot be created in project %s", issueType, project.Key)
+	}
+	return fields, nil
+}
+
+// editFields returns the edit metadata of the fields of an existing issue.
+func editFields(config cfg.Config, client jira.Client, key string) (map[string]metaField, error) {
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/editmeta", key), nil)
+	if err != nil {
+		return nil, err
+	}
+	meta := new(struct {
+		Fields map[string]metaField `json:"fields"`
+	})
+	res, err := client.Do(req, meta)
+	if err != nil {
+		return nil, getErrorBody(config, res)
+	}
+	return meta.Fields, nil
+}
+
+// payloadValues returns the string representations of a field value of
+// an issue payload which can be checked against allowed values: plain
+// values, or the ID, key, name, value, or account ID of objects, and of lists
+// of them.
+func payloadValues(v interface{}) []string {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, k := range []string{"id", "key", "name", "value", "accountId"} {
+			if s, ok := value[k].(string); ok && s != "" {
+				return []string{s}
+			}
+		}
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			values = append(values, payloadValues(item)...)
+		}
+		return values
+	case string:
+		return []string{value}
+	}
+	return nil
+}
+
+// isEmptyValue reports whether a field value of an issue payload is unset.
+func isEmptyValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// validatePayload checks the fields of an issue which would be sent to JIRA
+// against the create or edit metadata of the issue, returning the problems
+// which would make JIRA reject the request: required fields which are missing,
+// fields which aren't on the screen, and values which aren't allowed.
+func validatePayload(fields *jira.IssueFields, meta map[string]metaField, create bool) ([]string, error) {
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	payload := make(map[string]interface{})
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+
+	if create {
+		for key, f := range meta {
+			if f.Required && !f.HasDefaultValue && isEmptyValue(payload[key]) {
+				problems = append(problems, fmt.Sprintf("required field %s (%s) is missing", f.Name, key))
+			}
+		}
+	}
+
+	for key, v := range payload {
+		if isEmptyValue(v) {
+			continue
+		}
+		f, ok := meta[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("field %s cannot be set; it is not on the appropriate screen", key))
+			continue
+		}
+		for _, value := range payloadValues(v) {
+			if !f.allows(value) {
+				problems = append(problems, fmt.Sprintf("value %q is not allowed for field %s (%s)", value, f.Name, key))
+			}
+		}
+	}
+
+	sort.Strings(problems)
+
+	return problems, nil
+}
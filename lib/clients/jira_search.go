@@ -0,0 +1,173 @@
+package clients
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// searchFields are the fields of the JIRA issues returned by searches.
+var searchFields = []string{"*navigable"}
+
+// jiraRequester runs a JIRA API call, retrying it like the request methods
+// of the JIRA clients do.
+type jiraRequester func(f func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error)
+
+// cloudSearchResult is a page of the results of the search API of JIRA
+// Cloud, which is paginated with tokens rather than offsets.
+type cloudSearchResult struct {
+	Issues        []jira.Issue `json:"issues"`
+	NextPageToken string       `json:"nextPageToken"`
+	IsLast        bool         `json:"isLast"`
+}
+
+// listIssuesJQL returns the JQL query of the JIRA issues of the project with
+// the GitHub IDs, restricted by the `jql-filter` of the project, if any. If
+// the list of IDs is too long, we get a 414 Request-URI Too Large, so in that
+// case, all the issues of the project are queried, and the caller has to
+// filter them.
+func listIssuesJQL(config cfg.Config, project jira.Project, ids []string) string {
+	jql := fmt.Sprintf("project='%s'", project.Key)
+	if len(ids) < maxJQLIssueLength {
+		jql = fmt.Sprintf("%s AND cf[%s] in (%s)", jql, config.GetFieldID(cfg.GitHubID), strings.Join(ids, ","))
+	}
+	if filter := config.GetJQLFilter(project.Key); filter != "" {
+		jql = fmt.Sprintf("%s AND (%s)", jql, filter)
+	}
+	return jql
+}
+
+// OrphanLabel is the JIRA label of the issues whose GitHub issue was deleted
+// or transferred, when they're flagged as orphaned.
+const OrphanLabel = "github-orphaned"
+
+// syncedIssuesJQL returns the JQL query of the JIRA issues of the project
+// which are synced from a GitHub issue, and are neither done nor flagged as
+// orphaned, restricted by the `jql-filter` of the project, if any.
+func syncedIssuesJQL(config cfg.Config, project jira.Project) string {
+	jql := fmt.Sprintf("project='%s' AND cf[%s] is not EMPTY AND statusCategory != Done AND (labels is EMPTY OR labels != '%s')",
+		project.Key, config.GetFieldID(cfg.GitHubID), OrphanLabel)
+	if filter := config.GetJQLFilter(project.Key); filter != "" {
+		jql = fmt.Sprintf("%s AND (%s)", jql, filter)
+	}
+	return jql
+}
+
+// linkedIssuesJQL returns the JQL query of all the JIRA issues of the project
+// which are linked to a GitHub issue, restricted by the `jql-filter` of the
+// project, if any.
+func linkedIssuesJQL(config cfg.Config, project jira.Project) string {
+	jql := fmt.Sprintf("project='%s' AND cf[%s] is not EMPTY", project.Key, config.GetFieldID(cfg.GitHubID))
+	if filter := config.GetJQLFilter(project.Key); filter != "" {
+		jql = fmt.Sprintf("%s AND (%s)", jql, filter)
+	}
+	return jql
+}
+
+// ListSyncedIssues returns the JIRA issues of the project which are synced
+// from a GitHub issue, and are neither done nor flagged as orphaned.
+func (j realJIRAClient) ListSyncedIssues() ([]jira.Issue, error) {
+	return searchIssues(j.config, j.client, j.request, syncedIssuesJQL(j.config, j.project))
+}
+
+// ListSyncedIssues returns the JIRA issues of the project which are synced
+// from a GitHub issue, and are neither done nor flagged as orphaned.
+func (j dryrunJIRAClient) ListSyncedIssues() ([]jira.Issue, error) {
+	return searchIssues(j.config, j.client, j.request, syncedIssuesJQL(j.config, j.project))
+}
+
+// searchIssues returns all the JIRA issues matching a JQL query, walking the
+// pages of results. JIRA Cloud, which removed the search API of JIRA Server,
+// is searched with its enhanced search API.
+func searchIssues(config cfg.Config, client jira.Client, request jiraRequester, jql string) ([]jira.Issue, error) {
+	if config.IsJIRACloud() {
+		return searchCloudIssues(config, client, request, jql)
+	}
+
+	log := config.GetLogger()
+
+	var jiraIssues []jira.Issue
+	for total := 1; len(jiraIssues) < total; {
+		ji, res, err := request(func() (interface{}, *jira.Response, error) {
+			return client.Issue.Search(jql, &jira.SearchOptions{
+				StartAt:    len(jiraIssues),
+				MaxResults: config.GetJIRAPageSize(),
+				Fields:     searchFields,
+			})
+		})
+		if err != nil {
+			log.Errorf("Error retrieving JIRA issues: %v", err)
+			return nil, getErrorBody(config, res)
+		}
+		page, ok := ji.([]jira.Issue)
+		if !ok {
+			log.Errorf("Get JIRA issues did not return issues! Got: %v", ji)
+			return nil, fmt.Errorf("get JIRA issues failed: expected []jira.Issue; got %T", ji)
+		}
+		if len(page) == 0 {
+			break
+		}
+
+		jiraIssues = append(jiraIssues, page...)
+		total = res.Total
+	}
+
+	return jiraIssues, nil
+}
+
+// searchCloudIssues returns all the JIRA Cloud issues matching a JQL query,
+// following the tokens of the pages of results.
+func searchCloudIssues(config cfg.Config, client jira.Client, request jiraRequester, jql string) ([]jira.Issue, error) {
+	log := config.GetLogger()
+
+	var jiraIssues []jira.Issue
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("maxResults", fmt.Sprint(config.GetJIRAPageSize()))
+		query.Set("fields", strings.Join(searchFields, ","))
+		if token != "" {
+			query.Set("nextPageToken", token)
+		}
+
+		req, err := client.NewRequest("GET", "rest/api/2/search/jql?"+query.Encode(), nil)
+		if err != nil {
+			log.Errorf("Error creating JIRA search request: %v", err)
+			return nil, err
+		}
+
+		page := new(cloudSearchResult)
+		_, res, err := request(func() (interface{}, *jira.Response, error) {
+			res, err := client.Do(req, page)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error retrieving JIRA issues: %v", err)
+			return nil, getErrorBody(config, res)
+		}
+
+		jiraIssues = append(jiraIssues, page.Issues...)
+		if page.IsLast || page.NextPageToken == "" || len(page.Issues) == 0 {
+			break
+		}
+		token = page.NextPageToken
+	}
+
+	return jiraIssues, nil
+}
+
+// ListLinkedIssues returns all the JIRA issues of the project which are
+// linked to a GitHub issue, including those which are done.
+func (j realJIRAClient) ListLinkedIssues() ([]jira.Issue, error) {
+	return searchIssues(j.config, j.client, j.request, linkedIssuesJQL(j.config, j.project))
+}
+
+// ListLinkedIssues returns all the JIRA issues of the project which are
+// linked to a GitHub issue, including those which are done.
+func (j dryrunJIRAClient) ListLinkedIssues() ([]jira.Issue, error) {
+	return searchIssues(j.config, j.client, j.request, linkedIssuesJQL(j.config, j.project))
+}
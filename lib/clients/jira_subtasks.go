@@ -0,0 +1,80 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// subtaskType returns the sub-task issue type of the JIRA project, whose
+// name depends on the JIRA instance and its language.
+func subtaskType(project jira.Project) (jira.IssueType, error) {
+	for _, t := range project.IssueTypes {
+		if t.Subtask {
+			return t, nil
+		}
+	}
+	return jira.IssueType{}, fmt.Errorf("JIRA project %s has no sub-task issue type", project.Key)
+}
+
+// CreateSubtask creates a sub-task of a JIRA issue with the given summary,
+// and returns it.
+func (j realJIRAClient) CreateSubtask(parent jira.Issue, summary string) (jira.Issue, error) {
+	log := j.config.GetLogger()
+
+	issueType, err := subtaskType(j.project)
+	if err != nil {
+		return jira.Issue{}, err
+	}
+
+	i, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		return j.client.Issue.Create(&jira.Issue{
+			Fields: &jira.IssueFields{
+				Type:    issueType,
+				Project: j.project,
+				Parent:  &jira.Parent{Key: parent.Key},
+				Summary: summary,
+			},
+		})
+	})
+	if err != nil {
+		log.Errorf("Error creating sub-task of JIRA issue %s: %v", parent.Key, err)
+		return jira.Issue{}, getErrorBody(j.config, res)
+	}
+	subtask, ok := i.(*jira.Issue)
+	if !ok {
+		log.Errorf("Create JIRA sub-task did not return issue! Got: %v", i)
+		return jira.Issue{}, fmt.Errorf("create JIRA sub-task failed: expected *jira.Issue; got %T", i)
+	}
+
+	log.Debugf("Created sub-task %s of JIRA issue %s", subtask.Key, parent.Key)
+
+	return *subtask, nil
+}
+
+// CreateSubtask prints the sub-task that would be created on the JIRA issue,
+// and returns it without a key.
+func (j dryrunJIRAClient) CreateSubtask(parent jira.Issue, summary string) (jira.Issue, error) {
+	log := j.config.GetLogger()
+
+	issueType, err := subtaskType(j.project)
+	if err != nil {
+		return jira.Issue{}, err
+	}
+
+	log.Info("")
+	log.Infof("Create sub-task of JIRA issue %s:", parent.Key)
+	log.Infof("  Type: %s", issueType.Name)
+	log.Infof("  Summary: %s", truncate(summary, 50))
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanCreateSubtask, Key: parent.Key, ID: parent.ID, Summary: summary})
+
+	return jira.Issue{
+		Fields: &jira.IssueFields{
+			Type:    issueType,
+			Parent:  &jira.Parent{Key: parent.Key},
+			Summary: summary,
+		},
+	}, nil
+}
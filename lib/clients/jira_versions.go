@@ -0,0 +1,149 @@
+package clients
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// jiraVersion represents a version of a JIRA project. Unlike jira.Version,
+// it includes the description of the version.
+type jiraVersion struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description"`
+	Project     string `json:"project,omitempty"`
+}
+
+// versionCache holds the versions of the JIRA project of a client, so that
+// they are only listed once per run.
+type versionCache struct {
+	mu     sync.Mutex
+	loaded bool
+	byName map[string]jiraVersion
+}
+
+// newVersionCache creates an empty versionCache.
+func newVersionCache() *versionCache {
+	return &versionCache{
+		byName: make(map[string]jiraVersion),
+	}
+}
+
+// load lists the versions of the project into the cache, if they
+// haven't been already. The caller must hold the lock.
+func (v *versionCache) load(config cfg.Config, client jira.Client, project jira.Project) error {
+	log := config.GetLogger()
+
+	if v.loaded {
+		return nil
+	}
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/project/%s/versions", project.Key), nil)
+	if err != nil {
+		return err
+	}
+	versions := new([]jiraVersion)
+	res, err := client.Do(req, versions)
+	if err != nil {
+		log.Errorf("Error retrieving versions of JIRA project %s: %v", project.Key, err)
+		return getErrorBody(config, res)
+	}
+
+	for _, version := range *versions {
+		v.byName[version.Name] = version
+	}
+	v.loaded = true
+
+	return nil
+}
+
+// SyncVersion ensures that a version with the given name exists in the JIRA
+// project, with the given description, creating or updating it as needed.
+// It returns the version, suitable for use as a fix version of an issue.
+func (j realJIRAClient) SyncVersion(name, description string) (jira.FixVersion, error) {
+	log := j.config.GetLogger()
+
+	j.versions.mu.Lock()
+	defer j.versions.mu.Unlock()
+
+	if err := j.versions.load(j.config, j.client, j.project); err != nil {
+		return jira.FixVersion{}, err
+	}
+
+	version, ok := j.versions.byName[name]
+	if ok && version.Description == description {
+		return jira.FixVersion{ID: version.ID, Name: version.Name}, nil
+	}
+
+	var req interface{}
+	var method, url string
+	if ok {
+		method, url = "PUT", fmt.Sprintf("rest/api/2/version/%s", version.ID)
+		req = struct {
+			Description string `json:"description"`
+		}{description}
+	} else {
+		method, url = "POST", "rest/api/2/version"
+		req = jiraVersion{
+			Name:        name,
+			Description: description,
+			Project:     j.project.Key,
+		}
+	}
+
+	r, err := j.client.NewRequest(method, url, req)
+	if err != nil {
+		return jira.FixVersion{}, err
+	}
+	updated := new(jiraVersion)
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Do(r, updated)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error saving JIRA version %s: %v", name, err)
+		return jira.FixVersion{}, getErrorBody(j.config, res)
+	}
+
+	j.versions.byName[name] = *updated
+	log.Debugf("Saved JIRA version %s (ID %s)", updated.Name, updated.ID)
+
+	return jira.FixVersion{ID: updated.ID, Name: updated.Name}, nil
+}
+
+// SyncVersion prints out the version which would be created or updated to
+// match the given name and description. It returns the version as it would
+// be referenced as a fix version.
+func (j dryrunJIRAClient) SyncVersion(name, description string) (jira.FixVersion, error) {
+	log := j.config.GetLogger()
+
+	j.versions.mu.Lock()
+	defer j.versions.mu.Unlock()
+
+	if err := j.versions.load(j.config, j.client, j.project); err != nil {
+		return jira.FixVersion{}, err
+	}
+
+	version, ok := j.versions.byName[name]
+	if ok && version.Description == description {
+		return jira.FixVersion{ID: version.ID, Name: version.Name}, nil
+	}
+
+	log.Info("")
+	if ok {
+		log.Infof("Update JIRA version %s:", name)
+	} else {
+		log.Infof("Create JIRA version %s:", name)
+	}
+	log.Infof("  Description: %s", truncate(description, 50))
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanSyncVersion, Name: name, Description: description})
+
+	j.versions.byName[name] = jiraVersion{ID: version.ID, Name: name, Description: description}
+
+	return jira.FixVersion{ID: version.ID, Name: name}, nil
+}
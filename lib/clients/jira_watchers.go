@@ -0,0 +1,110 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// watcherList is the list of the watchers of a JIRA issue: JIRA Cloud users
+// are identified by account ID, and JIRA Server users by username.
+type watcherList struct {
+	Watchers []struct {
+		AccountID string `json:"accountId"`
+		Name      string `json:"name"`
+	} `json:"watchers"`
+}
+
+// missingWatchers returns the users, account IDs on JIRA Cloud or usernames
+// on JIRA Server, who don't watch a JIRA issue yet.
+func missingWatchers(config cfg.Config, client jira.Client, request jiraRequester, issue jira.Issue, users []string) ([]string, error) {
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/watchers", issue.Key), nil)
+	if err != nil {
+		return nil, err
+	}
+	var list watcherList
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		res, err := client.Do(req, &list)
+		return nil, res, err
+	})
+	if err != nil {
+		return nil, getErrorBody(config, res)
+	}
+
+	watching := make(map[string]bool)
+	for _, w := range list.Watchers {
+		if config.IsJIRACloud() {
+			watching[w.AccountID] = true
+		} else {
+			watching[w.Name] = true
+		}
+	}
+	var missing []string
+	for _, user := range users {
+		if !watching[user] {
+			missing = append(missing, user)
+		}
+	}
+	return missing, nil
+}
+
+// AddWatchers adds the users, account IDs on JIRA Cloud or usernames on JIRA
+// Server, to the watchers of a JIRA issue, unless they watch it already.
+func (j realJIRAClient) AddWatchers(issue jira.Issue, users []string) error {
+	log := j.config.GetLogger()
+
+	missing, err := missingWatchers(j.config, j.client, j.request, issue, users)
+	if err != nil {
+		log.Errorf("Error retrieving the watchers of JIRA issue %s. Error: %v", issue.Key, err)
+		return err
+	}
+
+	for _, user := range missing {
+		req, err := j.client.NewRequest("POST", fmt.Sprintf("rest/api/2/issue/%s/watchers", issue.Key), user)
+		if err != nil {
+			return err
+		}
+		_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+			res, err := j.client.Do(req, nil)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error adding watcher %s to JIRA issue %s. Error: %v", user, issue.Key, err)
+			return getErrorBody(j.config, res)
+		}
+
+		log.Debugf("Added watcher %s to JIRA issue %s", user, issue.Key)
+	}
+
+	return nil
+}
+
+// AddWatchers prints the users who would be added to the watchers of a JIRA
+// issue, unless they watch it already.
+func (j dryrunJIRAClient) AddWatchers(issue jira.Issue, users []string) error {
+	log := j.config.GetLogger()
+
+	missing := users
+	if issue.Key != "" {
+		var err error
+		if missing, err = missingWatchers(j.config, j.client, j.request, issue, users); err != nil {
+			log.Errorf("Error retrieving the watchers of JIRA issue %s. Error: %v", issue.Key, err)
+			return err
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	log.Info("")
+	log.Infof("Add watchers to JIRA issue %s:", issue.Key)
+	for _, user := range missing {
+		log.Infof("  %s", user)
+	}
+	log.Info("")
+
+	j.recordPlan(PlanAction{Action: PlanAddWatchers, Key: issue.Key, ID: issue.ID, Users: missing})
+
+	return nil
+}
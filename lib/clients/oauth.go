@@ -134,3 +134,15 @@ func jiraTokenFromWeb(config oauth1.Config) (*oauth1.Token, error) {
 
 	return oauth1.NewToken(accessToken, accessSecret), nil
 }
+
+// JIRATokenFromWeb performs the OAuth handshake with JIRA interactively,
+// using the consumer key and private key of the configuration, and returns
+// the access token obtained.
+func JIRATokenFromWeb(config cfg.Config) (*oauth1.Token, error) {
+	oauthConfig, err := oauthConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return jiraTokenFromWeb(oauthConfig)
+}
@@ -0,0 +1,427 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// The actions of a plan, one for each change a dry run would make.
+const (
+	PlanCreateIssue   = "create-issue"
+	PlanUpdateIssue   = "update-issue"
+	PlanCreateComment = "create-comment"
+	PlanUpdateComment = "update-comment"
+	PlanAddComment    = "add-comment"
+	PlanSetCommentID  = "set-comment-id"
+	PlanTransition    = "transition-issue"
+	PlanSyncVersion   = "sync-version"
+	PlanSyncEpic      = "sync-epic"
+	PlanCreateSubtask = "create-subtask"
+	PlanLinkIssue     = "link-issue"
+	PlanUnlinkIssue   = "unlink-issue"
+	PlanAddWatchers   = "add-watchers"
+	PlanAddAttachment = "add-attachment"
+	PlanSyncLink      = "sync-remote-link"
+	PlanEditGitHub    = "edit-github-issue"
+)
+
+// PlanAction is a change a dry run would have made to JIRA or GitHub, with
+// everything needed to make it later. JIRA actions are identified by the
+// JIRA project and instance they target, GitHub ones by their repo.
+type PlanAction struct {
+	Action   string `json:"action"`
+	Project  string `json:"project,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Repo     string `json:"repo,omitempty"`
+
+	// Key and ID identify the JIRA issue the action is performed on, or
+	// the parent of a sub-task.
+	Key string `json:"key,omitempty"`
+	ID  string `json:"id,omitempty"`
+
+	// Issue is the issue to create, or the fields to update.
+	Issue *jira.Issue `json:"issue,omitempty"`
+
+	// Comment is the GitHub comment a JIRA comment is created or updated
+	// from, and CommentID the JIRA comment which is updated.
+	Comment   *github.IssueComment `json:"comment,omitempty"`
+	CommentID string               `json:"comment-id,omitempty"`
+
+	// Body is the body of a comment, and Summary that of a sub-task.
+	Body    string `json:"body,omitempty"`
+	Summary string `json:"summary,omitempty"`
+
+	// Transition is the ID of the transition performed on the issue, and
+	// Resolution the resolution it sets, if any.
+	Transition string `json:"transition,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+
+	// Name is the name of a version, an epic, or an attachment, and
+	// Description that of a version or an epic.
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Content     []byte `json:"content,omitempty"`
+
+	// LinkType and Target are the type of an issue link, and the key of the
+	// issue it links to.
+	LinkType string `json:"link-type,omitempty"`
+	Target   string `json:"target,omitempty"`
+
+	// Users are the JIRA users added to the watchers of the issue.
+	Users []string `json:"users,omitempty"`
+
+	// GitHubIssue is the GitHub issue a remote link points to.
+	GitHubIssue *github.Issue `json:"github-issue,omitempty"`
+
+	// Number and Request are the GitHub issue which is edited, and the
+	// edit.
+	Number  int                  `json:"number,omitempty"`
+	Request *github.IssueRequest `json:"request,omitempty"`
+}
+
+// Plan is the list of the actions of a dry run, in the order they would
+// have been made, as written by `--plan-out` and executed by `--apply`.
+type Plan struct {
+	Created time.Time `json:"created"`
+	// State is the fingerprint of the state file the plan was made against
+	// (see cfg.State.Fingerprint), and Applied when the plan was applied.
+	State   string       `json:"state,omitempty"`
+	Applied *time.Time   `json:"applied,omitempty"`
+	Actions []PlanAction `json:"actions"`
+}
+
+// plan accumulates the actions of the dry run, when they're written to a
+// plan.
+var plan = struct {
+	sync.Mutex
+	actions []PlanAction
+}{}
+
+// recordPlan adds an action to the plan of the dry run, if it's written
+// to one.
+func recordPlan(config cfg.Config, action PlanAction) {
+	if config.GetPlanFile() == "" {
+		return
+	}
+
+	plan.Lock()
+	defer plan.Unlock()
+
+	plan.actions = append(plan.actions, action)
+}
+
+// recordPlan adds an action on the JIRA project of the client to the
+// plan of the dry run.
+func (j dryrunJIRAClient) recordPlan(action PlanAction) {
+	action.Project = j.project.Key
+	action.Instance = j.config.GetJIRAInstance()
+	recordPlan(j.config, action)
+}
+
+// SavePlan writes the actions of the dry run to the plan file, if any.
+func SavePlan(config cfg.Config) error {
+	log := config.GetLogger()
+
+	path := config.GetPlanFile()
+	if path == "" {
+		return nil
+	}
+
+	plan.Lock()
+	p := Plan{Created: time.Now(), State: config.GetState().Fingerprint(), Actions: plan.actions}
+	plan.actions = nil
+	plan.Unlock()
+
+	if p.Actions == nil {
+		p.Actions = []PlanAction{}
+	}
+	if err := writePlan(path, p); err != nil {
+		return err
+	}
+
+	log.Infof("Wrote %d planned actions to %s", len(p.Actions), path)
+
+	return nil
+}
+
+// writePlan writes a plan to the file at the path.
+func writePlan(path string, p Plan) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// LoadPlan reads a plan written by a dry run.
+func LoadPlan(path string) (Plan, error) {
+	var p Plan
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("invalid plan %s: %v", path, err)
+	}
+	return p, nil
+}
+
+// newEpicPlaceholder returns the key a dry run gives to an epic it would
+// create, which the issues linked to it reference in the plan.
+func newEpicPlaceholder(name string) string {
+	return fmt.Sprintf("<new epic %s>", name)
+}
+
+// planApplier executes the actions of a plan, with a client for each JIRA
+// project and GitHub repo they target.
+type planApplier struct {
+	config  cfg.Config
+	jira    map[string]JIRAClient
+	github  map[string]GitHubClient
+	epics   map[string]string
+	applied int
+}
+
+// ApplyPlan executes the actions of the plan file in order, exactly as they
+// were planned. It stops at the first action which fails, as those after
+// it may depend on it. The actions which follow from the issues the plan
+// creates, such as their comments, are left to the next synchronization.
+// A plan can only be applied once, and not after the state file changed
+// since it was made, as a synchronization may have made its actions
+// already; the plan is marked as applied, and the state saved, once it
+// was applied, even partially.
+func ApplyPlan(config cfg.Config) error {
+	log := config.GetLogger()
+
+	path := config.GetApplyFile()
+	p, err := LoadPlan(path)
+	if err != nil {
+		return err
+	}
+	if p.Applied != nil {
+		return fmt.Errorf("plan %s was already applied at %s", path, p.Applied.Format(time.RFC3339))
+	}
+	if p.State != config.GetState().Fingerprint() {
+		return fmt.Errorf("plan %s is stale: the state file changed since it was made at %s; make a new plan", path, p.Created.Format(time.RFC3339))
+	}
+
+	log.Infof("Applying %d actions planned at %s", len(p.Actions), p.Created.Format(time.RFC3339))
+
+	a := &planApplier{
+		config: config,
+		jira:   make(map[string]JIRAClient),
+		github: make(map[string]GitHubClient),
+		epics:  make(map[string]string),
+	}
+	err = a.applyAll(p, path)
+	if a.applied > 0 {
+		applied := time.Now()
+		p.Applied = &applied
+		if err := writePlan(path, p); err != nil {
+			log.Errorf("Error marking plan %s as applied: %v", path, err)
+		}
+		if err := config.GetState().Save(); err != nil {
+			log.Errorf("Error saving state: %v", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Applied %d actions", a.applied)
+
+	return nil
+}
+
+// applyAll executes the actions of the plan at the path in order, until one
+// fails.
+func (a *planApplier) applyAll(p Plan, path string) error {
+	for i, action := range p.Actions {
+		if err := a.config.Context().Err(); err != nil {
+			return err
+		}
+		if err := a.apply(action); err != nil {
+			return fmt.Errorf("action %d of %s (%s) failed after %d actions were applied: %v", i+1, path, action.Action, a.applied, err)
+		}
+		a.applied++
+	}
+	return nil
+}
+
+// jiraClient returns the client of the JIRA project the action targets,
+// which is that of the first configured repo synchronized to it.
+func (a *planApplier) jiraClient(action PlanAction) (JIRAClient, error) {
+	id := action.Instance + "/" + action.Project
+	if client, ok := a.jira[id]; ok {
+		return client, nil
+	}
+
+	repos := a.config.GetRepoList()
+	sort.Strings(repos)
+	for _, repo := range repos {
+		if a.config.GetProjectKey(repo) != action.Project || a.config.GetProjectInstance(repo) != action.Instance {
+			continue
+		}
+		config := a.config.ForProject(repo)
+		client, err := NewJIRAClient(config, config.GetProject(repo))
+		if err != nil {
+			return nil, err
+		}
+		a.jira[id] = client
+		return client, nil
+	}
+
+	return nil, fmt.Errorf("no configured repo is synchronized to JIRA project %s", action.Project)
+}
+
+// githubClient returns the client of a GitHub repo.
+func (a *planApplier) githubClient(repo string) (GitHubClient, error) {
+	if client, ok := a.github[repo]; ok {
+		return client, nil
+	}
+
+	client, err := NewGitHubClient(a.config, repo)
+	if err != nil {
+		return nil, err
+	}
+	a.github[repo] = client
+	return client, nil
+}
+
+// resolveEpics replaces the placeholders of the epics created by the plan
+// in the fields of an issue with their keys. The parent of the issue is
+// read back into its Parent, which would send an empty ID, so it's set by
+// key again.
+func (a *planApplier) resolveEpics(fields *jira.IssueFields) {
+	if fields == nil {
+		return
+	}
+	if fields.Parent != nil && fields.Parent.ID == "" {
+		key := fields.Parent.Key
+		if k, ok := a.epics[key]; ok {
+			key = k
+		}
+		if fields.Unknowns == nil {
+			fields.Unknowns = map[string]interface{}{}
+		}
+		fields.Unknowns["parent"] = map[string]string{"key": key}
+		fields.Parent = nil
+	}
+	for k, v := range fields.Unknowns {
+		switch v := v.(type) {
+		case string:
+			if key, ok := a.epics[v]; ok {
+				fields.Unknowns[k] = key
+			}
+		case map[string]interface{}:
+			if key, ok := a.epics[fmt.Sprint(v["key"])]; ok {
+				v["key"] = key
+			}
+		}
+	}
+}
+
+// apply executes an action of the plan.
+func (a *planApplier) apply(action PlanAction) error {
+	if action.Action == PlanEditGitHub {
+		ghClient, err := a.githubClient(action.Repo)
+		if err != nil {
+			return err
+		}
+		if action.Request == nil {
+			return fmt.Errorf("no edit of GitHub issue #%d", action.Number)
+		}
+		_, err = ghClient.EditIssue(action.Number, *action.Request)
+		return err
+	}
+
+	jClient, err := a.jiraClient(action)
+	if err != nil {
+		return err
+	}
+	issue := jira.Issue{Key: action.Key, ID: action.ID}
+
+	switch action.Action {
+	case PlanCreateIssue, PlanUpdateIssue:
+		if action.Issue == nil {
+			return fmt.Errorf("no issue to %s", action.Action)
+		}
+		a.resolveEpics(action.Issue.Fields)
+		if action.Action == PlanCreateIssue {
+			_, err = jClient.CreateIssue(*action.Issue)
+		} else {
+			_, err = jClient.UpdateIssue(*action.Issue)
+		}
+	case PlanCreateComment, PlanUpdateComment:
+		if action.Comment == nil {
+			return fmt.Errorf("no comment to %s", action.Action)
+		}
+		// The GitHub client only looks up the authors of the comments.
+		var ghClient GitHubClient
+		if ghClient, err = a.githubClient(""); err != nil {
+			return err
+		}
+		if action.Action == PlanCreateComment {
+			_, err = jClient.CreateComment(issue, *action.Comment, ghClient)
+		} else {
+			_, err = jClient.UpdateComment(issue, action.CommentID, *action.Comment, ghClient)
+		}
+	case PlanAddComment:
+		_, err = jClient.AddComment(issue, action.Body)
+	case PlanSetCommentID:
+		if action.Comment == nil {
+			return fmt.Errorf("no comment to %s", action.Action)
+		}
+		err = jClient.SetCommentID(jira.Comment{ID: action.CommentID}, action.Comment.GetID())
+	case PlanTransition:
+		// The current issue tells whether it was already transitioned.
+		if issue, err = jClient.GetIssue(action.Key); err == nil {
+			if action.Resolution != "" {
+				err = jClient.ResolveIssue(issue, action.Transition, action.Resolution)
+			} else {
+				err = jClient.TransitionIssue(issue, action.Transition)
+			}
+		}
+	case PlanSyncVersion:
+		_, err = jClient.SyncVersion(action.Name, action.Description)
+	case PlanSyncEpic:
+		var key string
+		if key, err = jClient.SyncEpic(action.Name, action.Description); err == nil {
+			a.epics[newEpicPlaceholder(action.Name)] = key
+		}
+	case PlanCreateSubtask:
+		_, err = jClient.CreateSubtask(issue, action.Summary)
+	case PlanLinkIssue:
+		// The current issue tells whether it's already linked.
+		if issue, err = jClient.GetIssue(action.Key); err == nil {
+			err = jClient.LinkIssue(issue, action.LinkType, action.Target)
+		}
+	case PlanUnlinkIssue:
+		// The current issue tells which link to remove.
+		if issue, err = jClient.GetIssue(action.Key); err == nil {
+			err = jClient.UnlinkIssue(issue, action.LinkType, action.Target)
+		}
+	case PlanAddWatchers:
+		err = jClient.AddWatchers(issue, action.Users)
+	case PlanAddAttachment:
+		_, err = jClient.AddAttachment(issue, action.Name, action.Content)
+	case PlanSyncLink:
+		if action.GitHubIssue == nil {
+			return fmt.Errorf("no GitHub issue to link JIRA issue %s to", action.Key)
+		}
+		err = jClient.SyncRemoteLink(issue, action.Repo, *action.GitHubIssue)
+	default:
+		return fmt.Errorf("unknown action %q", action.Action)
+	}
+
+	return err
+}
@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// projectTopicPrefix is the prefix of the repo topics which name the JIRA
+// project of a repo, e.g. `jira-sync` for the project SYNC.
+const projectTopicPrefix = "jira-"
+
+// InferProjectKeys resolves the repo patterns of the projects to the repos
+// of their owners, then infers the JIRA project key of every configured
+// repo whose project has none: first from the `project-mapping-url`
+// service, if configured, then from a `jira-KEY` topic of the repo, if
+// `project-key-topics` is set. The keys are set on the configuration,
+// which must be done before LoadJIRAConfig.
+func InferProjectKeys(config cfg.Config) error {
+	log := config.GetLogger()
+
+	repos, err := ListPatternRepos(config)
+	if err != nil {
+		return err
+	}
+	if repos != nil {
+		if err := config.ResolveRepoPatterns(repos); err != nil {
+			return err
+		}
+	}
+
+	for _, repo := range config.GetUnmappedRepos() {
+		key, err := mappedProjectKey(config, repo)
+		if err != nil {
+			return err
+		}
+
+		if key == "" && config.UsesProjectKeyTopics() {
+			key, err = topicProjectKey(config, repo)
+			if err != nil {
+				return err
+			}
+		}
+
+		if key == "" {
+			return fmt.Errorf("unable to infer the JIRA project key of %s", repo)
+		}
+
+		log.Debugf("Inferred JIRA project %s for %s", key, repo)
+		config.SetProjectKey(repo, key)
+	}
+
+	return nil
+}
+
+// ListPatternRepos returns the repos of the owners of the repo patterns of
+// the projects, such as those of myorg for "myorg/*", mapped to their
+// topics, or nil if there are no patterns.
+func ListPatternRepos(config cfg.Config) (map[string][]string, error) {
+	owners := config.GetRepoPatternOwners()
+	if len(owners) == 0 {
+		return nil, nil
+	}
+
+	ghClient, err := NewGitHubClient(config, "")
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[string][]string)
+	for _, owner := range owners {
+		r, err := ghClient.ListOwnerRepos(owner)
+		if err != nil {
+			return nil, err
+		}
+		for repo, topics := range r {
+			repos[repo] = topics
+		}
+	}
+	return repos, nil
+}
+
+// mappedProjectKey asks the `project-mapping-url` service for the JIRA
+// project key of a repo, by sending a GET request with the repo in the
+// `repo` query parameter. The service must respond with a JSON object
+// with a `key` field, or with 404 Not Found if it doesn't know the repo,
+// in which case the key is empty.
+func mappedProjectKey(config cfg.Config, repo string) (string, error) {
+	mappingURL := config.GetConfigString("project-mapping-url")
+	if mappingURL == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(mappingURL)
+	if err != nil {
+		return "", fmt.Errorf("project mapping URL must be a valid URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("repo", repo)
+	u.RawQuery = q.Encode()
+
+	client := http.Client{Timeout: config.GetTimeout()}
+	res, err := client.Get(u.String())
+	if err != nil {
+		return "", fmt.Errorf("unable to reach project mapping service: %v", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("project mapping service returned %s for %s", res.Status, repo)
+	}
+
+	mapping := new(struct {
+		Key string `json:"key"`
+	})
+	if err := json.NewDecoder(res.Body).Decode(mapping); err != nil {
+		return "", fmt.Errorf("unable to parse project mapping of %s: %v", repo, err)
+	}
+
+	return mapping.Key, nil
+}
+
+// topicProjectKey returns the JIRA project key named by the first `jira-KEY`
+// topic of a repo. Topics are lower case, so the key is upper-cased.
+func topicProjectKey(config cfg.Config, repo string) (string, error) {
+	ghClient, err := NewGitHubClient(config, repo)
+	if err != nil {
+		return "", err
+	}
+
+	topics, err := ghClient.ListTopics()
+	if err != nil {
+		return "", err
+	}
+
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, projectTopicPrefix) && len(topic) > len(projectTopicPrefix) {
+			return strings.ToUpper(strings.TrimPrefix(topic, projectTopicPrefix)), nil
+		}
+	}
+
+	return "", nil
+}
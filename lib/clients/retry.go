@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/cenkalti/backoff"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+const retryBackoffRoundRatio = time.Millisecond / time.Nanosecond
+
+// retryResult is the outcome of one attempt of an API call.
+type retryResult struct {
+	// err is the error of the attempt, or nil if it succeeded.
+	err error
+	// retryable is whether the call may succeed if it's attempted again.
+	retryable bool
+	// after is the delay the server asked for before the next attempt,
+	// from its Retry-After header, or 0.
+	after time.Duration
+}
+
+// retry calls op until it succeeds, with jittered exponential backoff
+// between the attempts. It gives up, returning the last error, when op
+// fails with an error which isn't retryable, when `retry-max-attempts`
+// attempts have been made, or when `timeout` has elapsed. If the server
+// asked for a longer delay than the backoff, it's respected.
+func retry(config cfg.Config, op func() retryResult) error {
+	log := config.GetLogger()
+
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = config.GetTimeout()
+	b.Reset()
+
+	for attempt := 1; ; attempt++ {
+		r := op()
+		if r.err == nil || !r.retryable || config.Context().Err() != nil {
+			return r.err
+		}
+		if max := config.GetRetryMaxAttempts(); max > 0 && attempt >= max {
+			return r.err
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return r.err
+		}
+		if r.after > next {
+			next = r.after
+		}
+
+		// Round to a whole number of milliseconds
+		next /= retryBackoffRoundRatio // Convert nanoseconds to milliseconds
+		next *= retryBackoffRoundRatio // Convert back so it appears correct
+
+		log.Errorf("Error performing operation; retrying in %v: %v", next, r.err)
+		if !sleep(config, next) {
+			return r.err
+		}
+	}
+}
+
+// sleep waits for the duration, unless issue-sync is shut down first. It
+// returns whether it waited for the whole duration.
+func sleep(config cfg.Config, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-config.Context().Done():
+		return false
+	}
+}
+
+// isRetryableStatus reports whether a request which failed with the HTTP
+// status may succeed if it's sent again: server errors, timeouts, and rate
+// limits. Other client errors, such as 400 or 404, won't.
+func isRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// retryAfter parses the Retry-After header of a response, which is a number
+// of seconds or an HTTP date. It returns 0 if there's none.
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+	h := res.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(h); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// githubRetryResult classifies the outcome of a GitHub API call. Errors
+// without a response (e.g. network errors) are retryable.
+func githubRetryResult(res *github.Response, err error) retryResult {
+	if err == nil {
+		return retryResult{}
+	}
+	if e, ok := err.(*github.ErrorResponse); ok && e.Response != nil {
+		return retryResult{
+			err:       err,
+			retryable: isRetryableStatus(e.Response.StatusCode),
+			after:     retryAfter(e.Response),
+		}
+	}
+	var r *http.Response
+	if res != nil {
+		r = res.Response
+	}
+	return retryResult{err: err, retryable: true, after: retryAfter(r)}
+}
+
+// jiraRetryResult classifies the outcome of a JIRA API call. Errors without
+// a response (e.g. network errors) are retryable. The body of a failed
+// response is logged, and kept readable for getErrorBody.
+func jiraRetryResult(config cfg.Config, res *jira.Response, err error) retryResult {
+	log := config.GetLogger()
+
+	if err == nil {
+		return retryResult{}
+	}
+	if res == nil || res.Response == nil {
+		return retryResult{err: err, retryable: true}
+	}
+
+	if res.Body != nil {
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		log.Debug(string(body))
+	}
+
+	return retryResult{
+		err:       err,
+		retryable: isRetryableStatus(res.StatusCode),
+		after:     retryAfter(res.Response),
+	}
+}
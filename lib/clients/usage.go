@@ -0,0 +1,185 @@
+package clients
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// Usage is the number of API calls made by the clients, and the number
+// of bytes they transferred, since the last call to ResetUsage.
+// GitHubNotModified is how many of the REST calls were served from the
+// `github-etag-cache`.
+type Usage struct {
+	GitHubREST        int
+	GitHubGraphQL     int
+	GitHubNotModified int
+	GitHubBytes       int64
+	JIRA              int
+	JIRABytes         int64
+
+	// RateLimit, RateRemaining, and RateReset are the GitHub rate limit as
+	// reported by the latest responses, and RateUsed is how much of it was
+	// consumed since the usage was reset, as far as the headers tell.
+	RateLimit     int
+	RateRemaining int
+	RateReset     time.Time
+	RateUsed      int
+}
+
+// usage accumulates the Usage of all the clients of the process.
+var usage = struct {
+	sync.Mutex
+	Usage
+}{}
+
+// ResetUsage resets the API usage counters, e.g. at the start of a run.
+func ResetUsage() {
+	usage.Lock()
+	defer usage.Unlock()
+
+	usage.Usage = Usage{}
+}
+
+// GetUsage returns the API usage since the counters were last reset.
+func GetUsage() Usage {
+	usage.Lock()
+	defer usage.Unlock()
+
+	return usage.Usage
+}
+
+// LogUsage logs the API usage since the counters were last reset, so that
+// tokens, schedules, and concurrency can be sized from it.
+func LogUsage(config cfg.Config) {
+	log := config.GetLogger()
+
+	u := GetUsage()
+
+	fields := logrus.Fields{
+		"github-rest-calls":    u.GitHubREST,
+		"github-graphql-calls": u.GitHubGraphQL,
+		"github-bytes":         u.GitHubBytes,
+		"jira-calls":           u.JIRA,
+		"jira-bytes":           u.JIRABytes,
+	}
+	if config.UsesETagCache() {
+		fields["github-not-modified"] = u.GitHubNotModified
+	}
+	if u.RateLimit > 0 {
+		fields["github-rate-used"] = u.RateUsed
+		fields["github-rate-remaining"] = u.RateRemaining
+		fields["github-rate-limit"] = u.RateLimit
+		fields["github-rate-reset"] = u.RateReset.Format(time.RFC3339)
+	}
+
+	log.WithFields(fields).Info("API usage for this run")
+}
+
+// usageTransport is an http.RoundTripper which counts the requests made
+// through it and the bytes transferred in the Usage of the process.
+type usageTransport struct {
+	base   http.RoundTripper
+	github bool
+}
+
+// newUsageTransport wraps a transport so that its usage is counted, as a
+// GitHub or JIRA API transport. A nil base is the default transport.
+func newUsageTransport(base http.RoundTripper, github bool) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return usageTransport{base: base, github: github}
+}
+
+// RoundTrip performs the request with the base transport, counting it.
+func (t usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+
+	usage.Lock()
+	defer usage.Unlock()
+
+	sent := req.ContentLength
+	if sent < 0 {
+		sent = 0
+	}
+
+	if t.github {
+		if strings.HasSuffix(req.URL.Path, "/graphql") {
+			usage.GitHubGraphQL++
+		} else {
+			usage.GitHubREST++
+		}
+		usage.GitHubBytes += sent
+	} else {
+		usage.JIRA++
+		usage.JIRABytes += sent
+	}
+
+	if err != nil {
+		return res, err
+	}
+
+	if t.github {
+		updateRateUsage(res.Header)
+	}
+	res.Body = &countingBody{ReadCloser: res.Body, github: t.github}
+
+	return res, nil
+}
+
+// updateRateUsage records the GitHub rate limit reported by the headers
+// of a response. The caller must hold the usage lock.
+func updateRateUsage(h http.Header) {
+	limit, err := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	resetTime := time.Unix(reset, 0)
+
+	if usage.RateLimit != 0 && resetTime.Equal(usage.RateReset) {
+		// Responses may arrive out of order, so only the lowest remaining
+		// count of the rate limit window is kept.
+		if remaining < usage.RateRemaining {
+			usage.RateUsed += usage.RateRemaining - remaining
+			usage.RateRemaining = remaining
+		}
+		return
+	}
+
+	// This is the first response, or the limit was reset since the
+	// previous one; this call consumed one request of the new window.
+	usage.RateUsed++
+	usage.RateLimit = limit
+	usage.RateRemaining = remaining
+	usage.RateReset = resetTime
+}
+
+// countingBody counts the bytes read from a response body in the usage.
+type countingBody struct {
+	io.ReadCloser
+	github bool
+}
+
+// Read reads from the body, counting the bytes read.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	usage.Lock()
+	if b.github {
+		usage.GitHubBytes += int64(n)
+	} else {
+		usage.JIRABytes += int64(n)
+	}
+	usage.Unlock()
+
+	return n, err
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// regexCode matches the fenced code blocks of a Markdown body, along with
+// the language of their info string, if any, and their content: \1 and \2
+// for those fenced with backticks, and \3 and \4 for those fenced with
+// tildes.
+var regexCode = regexp.MustCompile("(?ms)^```[ \\t]*([^`\\s]*)[^\\n]*\\n(.*?)\\n?^```[ \\t]*$|^~~~[ \\t]*(\\S*)[^\\n]*\\n(.*?)\\n?^~~~[ \\t]*$")
+
+// jiraCodeLanguages are the languages the JIRA {code} macro highlights.
+// Code in other languages is translated to a {noformat} macro, as JIRA
+// shows an error in place of a {code} macro with an unknown language.
+var jiraCodeLanguages = map[string]bool{
+	"actionscript": true, "ada": true, "applescript": true, "bash": true,
+	"c": true, "c#": true, "c++": true, "cpp": true, "css": true,
+	"erlang": true, "go": true, "groovy": true, "haskell": true,
+	"html": true, "java": true, "javascript": true, "js": true,
+	"json": true, "lua": true, "objc": true, "perl": true, "php": true,
+	"python": true, "r": true, "ruby": true, "scala": true, "sh": true,
+	"sql": true, "swift": true, "visualbasic": true, "xml": true,
+	"yaml": true,
+}
+
+// codeLanguageAliases maps the GitHub fence languages which JIRA doesn't
+// know to the JIRA language closest to them.
+var codeLanguageAliases = map[string]string{
+	"golang":      "go",
+	"ts":          "javascript",
+	"typescript":  "javascript",
+	"jsx":         "javascript",
+	"tsx":         "javascript",
+	"mjs":         "javascript",
+	"node":        "javascript",
+	"shell":       "bash",
+	"console":     "bash",
+	"shellscript": "bash",
+	"zsh":         "bash",
+	"ksh":         "bash",
+	"yml":         "yaml",
+	"py":          "python",
+	"python3":     "python",
+	"rb":          "ruby",
+	"cs":          "c#",
+	"csharp":      "c#",
+	"cc":          "cpp",
+	"cxx":         "cpp",
+	"hpp":         "cpp",
+	"h":           "c",
+	"objective-c": "objc",
+	"objectivec":  "objc",
+	"pl":          "perl",
+	"vb":          "visualbasic",
+	"vbnet":       "visualbasic",
+	"hs":          "haskell",
+	"erl":         "erlang",
+	"htm":         "html",
+	"xhtml":       "html",
+	"svg":         "xml",
+	"plist":       "xml",
+	"jsonc":       "json",
+	"json5":       "json",
+	"postgresql":  "sql",
+	"mysql":       "sql",
+	"as":          "actionscript",
+}
+
+// jiraCodeLanguage returns the JIRA {code} language of a GitHub fence
+// language, and whether JIRA highlights it.
+func jiraCodeLanguage(language string) (string, bool) {
+	language = strings.ToLower(language)
+	if alias, ok := codeLanguageAliases[language]; ok {
+		language = alias
+	}
+	return language, jiraCodeLanguages[language]
+}
+
+// translateCodeBlocks translates the fenced code blocks of a Markdown body
+// to JIRA {code} macros, or to {noformat} macros if they have no language,
+// or one JIRA doesn't highlight. Code which contains the closing tag of the
+// macro gets the other macro instead, or, if it contains both, the closing
+// tags are escaped. The macros are set aside, so that their content isn't
+// translated.
+func translateCodeBlocks(body string, a *asides) string {
+	return regexCode.ReplaceAllStringFunc(body, func(s string) string {
+		m := regexCode.FindStringSubmatch(s)
+		language, code := m[1], m[2]
+		if strings.HasPrefix(s, "~~~") {
+			language, code = m[3], m[4]
+		}
+
+		hasCode, hasNoFormat := strings.Contains(code, "{code}"), strings.Contains(code, "{noformat}")
+		if hasCode && hasNoFormat {
+			code = strings.NewReplacer("{code}", `\{code\}`, "{noformat}", `\{noformat\}`).Replace(code)
+			hasCode, hasNoFormat = false, false
+		}
+
+		language, highlighted := jiraCodeLanguage(language)
+		switch {
+		case highlighted && !hasCode:
+			return a.aside("{code:" + language + "}\n" + code + "\n{code}")
+		case hasNoFormat:
+			return a.aside("{code}\n" + code + "\n{code}")
+		}
+		return a.aside("{noformat}\n" + code + "\n{noformat}")
+	})
+}
@@ -3,39 +3,41 @@ package lib
 import (
 	"regexp"
 	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/go-jira"
 	"github.com/coreos/issue-sync/cfg"
 	"github.com/coreos/issue-sync/lib/clients"
 	"github.com/google/go-github/github"
 )
 
-// jCommentRegex matches a generated JIRA comment. It has matching groups to retrieve the
-// GitHub Comment ID (\1), the GitHub username (\2), the GitHub real name (\3, if it exists),
-// the time the comment was posted (\3 or \4), and the body of the comment (\4 or \5).
-var jCommentRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|.*?] from GitHub user \\[(\\w+)\\|.*?]\\s*(\\(.+\\))? at (.+):\\n+((?s:.*))\\n*$")
-
-// jCommentIDRegex just matches the beginning of a generated JIRA comment. It's a smaller,
-// simpler, and more efficient regex, to quickly filter only generated comments and retrieve
-// just their GitHub ID for matching.
+// jCommentIDRegex matches the beginning of a JIRA comment generated with the former
+// default `comment-template`, which embedded the GitHub ID, to retrieve it for matching
+// comments which were synced before the ID was recorded in their entity property.
 var jCommentIDRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|")
 
 // CompareComments takes a GitHub issue, and retrieves all of its comments. It then
 // matches each one to a comment in `existing`. If it finds a match, it calls
-// UpdateComment; if it doesn't, it calls CreateComment.
+// UpdateComment; if it doesn't, it calls CreateComment. The comments of the
+// users in `ignore-comment-authors`, such as bots, are skipped. The comments are
+// translated and compared in parallel, up to `comment-concurrency` at a time.
+// The creation of the missing ones is deliberately left sequential, in the order
+// of the GitHub comments: JIRA orders comments by their creation time, so
+// creating them in parallel would shuffle them.
 func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
-	log := config.GetLogger()
+	log := issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key)
 
+	// The watchers are synced along with the comments, so that the
+	// comments are only listed once.
 	if ghIssue.GetComments() == 0 {
+		syncWatchers(config, ghIssue, nil, jIssue, jClient)
 		log.Debugf("Issue #%d has no comments, skipping.", *ghIssue.Number)
 		return nil
 	}
 
-	ghComments, err := ghClient.ListComments(github.Issue(ghIssue))
-	if err != nil {
-		return err
-	}
-
 	var jComments []jira.Comment
 	if jIssue.Fields.Comments == nil {
 		log.Debugf("JIRA issue %s has no comments.", jIssue.Key)
@@ -48,49 +50,215 @@ func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue,
 		log.Debugf("JIRA issue %s has %d comments", jIssue.Key, len(jComments))
 	}
 
-	for _, ghComment := range ghComments {
-		found := false
-		for _, jComment := range jComments {
-			if !jCommentIDRegex.MatchString(jComment.Body) {
-				continue
-			}
-			// matches[0] is the whole string, matches[1] is the ID
-			matches := jCommentIDRegex.FindStringSubmatch(jComment.Body)
-			id, _ := strconv.Atoi(matches[1])
-			if *ghComment.ID != id {
-				continue
-			}
-			found = true
+	// The GitHub comments and the IDs of the JIRA comments are retrieved
+	// at the same time.
+	var ghComments []*github.IssueComment
+	ids := make(map[string]clients.CommentRef)
+	var ghErr, jErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ghComments, ghErr = ghClient.ListComments(github.Issue(ghIssue))
+	}()
+	if len(jComments) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids, jErr = jClient.ListCommentIDs(jIssue)
+		}()
+	}
+	wg.Wait()
+	if ghErr != nil {
+		return ghErr
+	}
+	if jErr != nil {
+		return jErr
+	}
+	syncWatchers(config, ghIssue, ghComments, jIssue, jClient)
 
-			UpdateComment(config, *ghComment, jComment, jIssue, ghClient, jClient)
-			break
+	// Each GitHub comment which has no JIRA comment yet is sent on its
+	// channel once it's translated, and nil is sent for the others.
+	missing := make([]chan *github.IssueComment, len(ghComments))
+	for i := range missing {
+		missing[i] = make(chan *github.IssueComment, 1)
+	}
+
+	refs := newReferenceResolver(config, ghClient, jClient)
+	go ForEach(config.GetCommentConcurrency(), len(ghComments), func(i int) {
+		c := ghComments[i]
+		if config.Context().Err() != nil {
+			missing[i] <- nil
+			return
+		}
+		if config.IgnoresCommentAuthor(c.User.GetLogin()) {
+			log.Debugf("Comment %d is from ignored user %s; skipping.", c.GetID(), c.User.GetLogin())
+			missing[i] <- nil
+			return
+		}
+		// Comment bodies are translated the same way as issue bodies.
+		ghComment := translateComment(config, log, *c)
+		if config.UseReferenceKeys() {
+			body := refs.rewrite(ghComment.GetBody())
+			ghComment.Body = &body
+		}
+		if config.UseReferenceLinks() {
+			refs.link(jIssue, c.GetBody())
 		}
-		if found {
+
+		if jComment, marked, ok := findComment(config, ghComment, jComments, ids); ok {
+			// The ID of comments only matched by the marker in their body
+			// is recorded, so that they're still matched once it's edited.
+			if marked {
+				if err := jClient.SetCommentID(jComment, ghComment.GetID()); err != nil {
+					log.Errorf("Error recording the GitHub ID of JIRA comment %s. Error: %v", jComment.ID, err)
+				}
+			}
+			// A comment split across several JIRA comments is compared as a
+			// whole.
+			jComment.Body = commentText(jComment, jComments, ids)
+			UpdateComment(config, ghComment, jComment, jIssue, ghClient, jClient)
+			missing[i] <- nil
+			return
+		}
+		missing[i] <- &ghComment
+	})
+
+	// Once a comment fails to be created, the following ones aren't, so
+	// that they're still created in order by the next run.
+	var err error
+	for _, ch := range missing {
+		ghComment := <-ch
+		if ghComment == nil || err != nil {
 			continue
 		}
 
-		comment, err := jClient.CreateComment(jIssue, *ghComment, ghClient)
-		if err != nil {
-			return err
+		var comment jira.Comment
+		if comment, err = jClient.CreateComment(jIssue, *ghComment, ghClient); err != nil {
+			continue
 		}
 
 		log.Debugf("Created JIRA comment %s.", comment.ID)
+		emitCommentEvent(config, ghClient.GetRepo(), *ghComment, jIssue, comment)
+	}
+	if err == nil {
+		err = config.Context().Err()
+	}
+	if err != nil {
+		return err
 	}
 
 	log.Debugf("Copied comments from GH issue #%d to JIRA issue %s.", *ghIssue.Number, jIssue.Key)
 	return nil
 }
 
-// UpdateComment compares the body of a GitHub comment with the body (minus header)
-// of the JIRA comment, and updates the JIRA comment if necessary.
-func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment jira.Comment, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
-	log := config.GetLogger()
+// findComment returns the JIRA comment a GitHub comment is synced to, if
+// any, given the GitHub IDs of the JIRA comments recorded in their entity
+// properties, or else in the state. Comments synced by earlier versions of
+// issue-sync are matched by the ID in their header, in which case it also
+// returns that their ID isn't recorded yet. The continuations of comments
+// split across several JIRA comments aren't matched.
+func findComment(config cfg.Config, ghComment github.IssueComment, jComments []jira.Comment, ids map[string]clients.CommentRef) (jira.Comment, bool, bool) {
+	for _, jComment := range jComments {
+		ref, ok := ids[jComment.ID]
+		if ok && ref.Part > 0 {
+			continue
+		}
+		id := ref.GitHubID
+		if !ok {
+			id, ok = config.GetState().GetComment(jComment.ID)
+		}
+		marked := false
+		if !ok {
+			// matches[0] is the whole string, matches[1] is the ID
+			matches := jCommentIDRegex.FindStringSubmatch(jComment.Body)
+			if matches == nil {
+				continue
+			}
+			id, _ = strconv.Atoi(matches[1])
+			marked = true
+		}
+		if ghComment.GetID() == id {
+			return jComment, marked, true
+		}
+	}
+	return jira.Comment{}, false, false
+}
+
+// commentText returns the body of a JIRA comment, along with those of its
+// continuations, if it's split across several JIRA comments, put back
+// together.
+func commentText(jComment jira.Comment, jComments []jira.Comment, ids map[string]clients.CommentRef) string {
+	ref, ok := ids[jComment.ID]
+	if !ok {
+		return jComment.Body
+	}
+
+	parts := map[int]string{0: jComment.Body}
+	for _, c := range jComments {
+		if r, ok := ids[c.ID]; ok && r.GitHubID == ref.GitHubID && r.Part > 0 {
+			parts[r.Part] = c.Body
+		}
+	}
+	if len(parts) == 1 {
+		return jComment.Body
+	}
+
+	var bodies []string
+	for part := 0; part < len(parts); part++ {
+		body, ok := parts[part]
+		if !ok {
+			break
+		}
+		bodies = append(bodies, body)
+	}
+	return cfg.JoinJIRAText(bodies)
+}
+
+// translateComment returns a copy of a GitHub comment with its body translated
+// to JIRA markup.
+func translateComment(config cfg.Config, log *logrus.Entry, comment github.IssueComment) github.IssueComment {
+	body := translateBody(config, log.WithField("github-comment", comment.GetID()), comment.GetBody())
+	comment.Body = &body
+	return comment
+}
+
+// commentBodyMatches returns whether the body of a JIRA comment is that of
+// the (translated) GitHub comment, after its header.
+func commentBodyMatches(config cfg.Config, jBody, ghBody string) bool {
+	if strings.HasSuffix(jBody, "\n\n"+ghBody) {
+		return true
+	}
 
-	// fields[0] is the whole body, 1 is the ID, 2 is the username, 3 is the real name (or "" if none)
-	// 4 is the date, and 5 is the real body
-	fields := jCommentRegex.FindStringSubmatch(jComment.Body)
+	// Comments too long for JIRA are truncated, so only their beginning can
+	// be compared. They end with a notice linking to the rest on GitHub,
+	// unless they were hard truncated, to the limit, by earlier versions of
+	// issue-sync. As the header may span several paragraphs, the body may
+	// start after any of them.
+	jBody, truncated := cfg.TrimTruncationNotice(jBody)
+	if limit := config.GetJIRATextLimit(); !truncated && (limit <= 0 || utf8.RuneCountInString(jBody) < limit) {
+		return false
+	}
+	for i := strings.Index(jBody, "\n\n"); i >= 0; {
+		if body := jBody[i+2:]; body != "" && strings.HasPrefix(ghBody, body) {
+			return true
+		}
+		next := strings.Index(jBody[i+1:], "\n\n")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
+
+// UpdateComment compares the (translated) body of a GitHub comment with the body
+// (minus header) of the JIRA comment, and updates the JIRA comment if necessary.
+func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment jira.Comment, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	number, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubNumber))
+	log := issueLogger(config, ghClient.GetRepo(), int(number), jIssue.Key).WithField("github-comment", ghComment.GetID())
 
-	if fields[5] == ghComment.GetBody() {
+	if commentBodyMatches(config, jComment.Body, ghComment.GetBody()) {
 		return nil
 	}
 
@@ -99,7 +267,8 @@ func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment ji
 		return err
 	}
 
-	log.Debug("Updated JIRA comment %s.", comment.ID)
+	log.Debugf("Updated JIRA comment %s.", comment.ID)
+	emitCommentEvent(config, ghClient.GetRepo(), ghComment, jIssue, comment)
 
 	return nil
 }
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// componentPaths returns the paths of a GitHub issue which `components`
+// maps: its labels, and the paths listed in the section of its issue form
+// set in `component-heading`, separated by commas or lines.
+func componentPaths(config cfg.Config, ghIssue TranslatedIssue) []string {
+	var paths []string
+	for _, l := range ghIssue.Labels {
+		paths = append(paths, l.GetName())
+	}
+
+	heading := config.GetComponentHeading()
+	if heading == "" {
+		return paths
+	}
+	for _, section := range parseFormSections(ghIssue.GetBody()) {
+		if !strings.EqualFold(section.heading, heading) || section.content == noResponse {
+			continue
+		}
+		for _, p := range strings.FieldsFunc(section.content, func(r rune) bool { return r == ',' || r == '\n' }) {
+			p = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(p), "- "))
+			paths = append(paths, strings.Trim(p, "`"))
+		}
+	}
+	return paths
+}
+
+// issueComponents returns the JIRA components of the JIRA issue of a GitHub
+// issue of the repo, mapped from its paths by `components`. Components the
+// JIRA project doesn't have are left out, with a warning.
+func issueComponents(config cfg.Config, log *logrus.Entry, repo string, ghIssue TranslatedIssue) []*jira.Component {
+	existing := make(map[string]string)
+	for _, c := range config.GetProject(repo).Components {
+		existing[strings.ToLower(c.Name)] = c.Name
+	}
+
+	components := []*jira.Component{}
+	for _, name := range config.GetComponents(componentPaths(config, ghIssue)) {
+		found, ok := existing[strings.ToLower(name)]
+		if !ok {
+			log.Warnf("JIRA project %s has no component %s; leaving it out for #%d", config.GetProjectKey(repo), name, ghIssue.GetNumber())
+			continue
+		}
+		components = append(components, &jira.Component{Name: found})
+	}
+	return components
+}
+
+// componentsDiffer returns whether two lists of JIRA components have
+// different names, regardless of their order.
+func componentsDiffer(a, b []*jira.Component) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	names := func(components []*jira.Component) []string {
+		n := make([]string, len(components))
+		for i, c := range components {
+			n[i] = c.Name
+		}
+		sort.Strings(n)
+		return n
+	}
+	na, nb := names(a), names(b)
+	for i := range na {
+		if na[i] != nb[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package lib
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// jiraTimeFormat is the format of the times of JIRA, such as the last update
+// of an issue; their milliseconds are parsed although it doesn't have them.
+const jiraTimeFormat = "2006-01-02T15:04:05-0700"
+
+// conflictSkew is how much later than its last synchronization a JIRA issue
+// may be updated without it being a change by a JIRA user, as issue-sync
+// itself updates it until the end of the synchronization.
+const conflictSkew = time.Minute
+
+// conflictLabel is the JIRA label of the issues with a conflict to be
+// resolved by a user, under the manual `conflict-strategy`.
+const conflictLabel = "github-conflict"
+
+// conflict is a GitHub issue found to conflict with its JIRA issue during a
+// run, and the strategy it was resolved with.
+type conflict struct {
+	repo     string
+	number   int
+	key      string
+	decision string
+}
+
+// conflicts accumulates the conflicts of the run, which are reported at its
+// end.
+var conflicts = struct {
+	sync.Mutex
+	list []conflict
+}{}
+
+// recordConflict adds a conflict to the report of the run.
+func recordConflict(c conflict) {
+	conflicts.Lock()
+	defer conflicts.Unlock()
+
+	conflicts.list = append(conflicts.list, c)
+}
+
+// LogConflicts logs the conflicts found since it was last called, with how
+// each of them was resolved, and forgets them.
+func LogConflicts(config cfg.Config) {
+	log := config.GetLogger()
+
+	conflicts.Lock()
+	defer conflicts.Unlock()
+
+	counts := make(map[string]int)
+	for _, c := range conflicts.list {
+		counts[c.decision]++
+		log.WithFields(logrus.Fields{
+			"repo":          c.repo,
+			"github-number": c.number,
+			"jira-key":      c.key,
+			"decision":      c.decision,
+		}).Info("Conflict in this run")
+	}
+	if len(conflicts.list) > 0 {
+		fields := logrus.Fields{"conflicts": len(conflicts.list)}
+		for decision, n := range counts {
+			fields[decision] = n
+		}
+		log.WithFields(fields).Info("Conflicts for this run")
+	}
+
+	conflicts.list = nil
+}
+
+// lastSynced returns when the GitHub issue was last synchronized to the JIRA
+// issue: as recorded in the state, or else in its Last IS Update field.
+func lastSynced(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue) (time.Time, bool) {
+	if state, ok := config.GetState().GetIssue(ghIssue.GetID()); ok && !state.Synced.IsZero() {
+		return state.Synced, true
+	}
+	s, _ := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.LastISUpdate)].(string)
+	t, err := time.Parse(jiraTimeFormat, s)
+	return t, err == nil
+}
+
+// resolveConflict returns the strategy the changes to the GitHub issue are
+// synchronized to the JIRA issue with, and whether they conflict, which is
+// when both were changed since they were last synchronized. GitHub wins if
+// they don't conflict. Under the newest-wins strategy, the side updated last
+// wins; under the manual one, the issue is skipped whilst its JIRA issue is
+// flagged, and GitHub wins once a user removes the flag.
+func resolveConflict(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue) (string, bool) {
+	strategy := config.GetConflictStrategy()
+	if strategy == cfg.ConflictGitHubWins {
+		return cfg.ConflictGitHubWins, false
+	}
+
+	if strategy == cfg.ConflictManual {
+		if state, ok := config.GetState().GetIssue(ghIssue.GetID()); ok && state.Conflict {
+			if hasLabel(jIssue.Fields.Labels, conflictLabel) {
+				return cfg.ConflictManual, true
+			}
+			return cfg.ConflictGitHubWins, false
+		}
+	}
+
+	synced, ok := lastSynced(config, ghIssue, jIssue)
+	if !ok {
+		return cfg.ConflictGitHubWins, false
+	}
+	updated, err := time.Parse(jiraTimeFormat, jIssue.Fields.Updated)
+	if err != nil || !updated.After(synced.Add(conflictSkew)) || !ghIssue.GetUpdatedAt().After(synced) {
+		return cfg.ConflictGitHubWins, false
+	}
+
+	if strategy == cfg.ConflictNewestWins {
+		if updated.After(ghIssue.GetUpdatedAt()) {
+			return cfg.ConflictJIRAWins, true
+		}
+		return cfg.ConflictGitHubWins, true
+	}
+	return strategy, true
+}
+
+// hasLabel returns whether a label is one of the labels of a JIRA issue.
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// flagConflict flags the JIRA issue for a user to resolve its conflict with
+// the GitHub issue, unless it's flagged already. The issue is synchronized
+// again once the flag is removed.
+func flagConflict(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key)
+
+	if hasLabel(jIssue.Fields.Labels, conflictLabel) {
+		log.Debugf("JIRA issue %s is flagged for a conflict; skipping.", jIssue.Key)
+		return nil
+	}
+
+	log.Infof("Flagging JIRA issue %s, which conflicts with GitHub issue #%d", jIssue.Key, ghIssue.GetNumber())
+
+	labels := append(append([]string{}, jIssue.Fields.Labels...), conflictLabel)
+	_, err := jClient.UpdateIssue(jira.Issue{
+		Fields: &jira.IssueFields{
+			Type:     jIssue.Fields.Type,
+			Unknowns: map[string]interface{}{"labels": labels},
+		},
+		Key: jIssue.Key,
+		ID:  jIssue.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	comment := fmt.Sprintf("This issue and the GitHub issue %s#%d were both changed since they were last synchronized. Remove the %s label to synchronize it from GitHub again.",
+		ghClient.GetRepo(), ghIssue.GetNumber(), conflictLabel)
+	if _, err := jClient.AddComment(jIssue, comment); err != nil {
+		return err
+	}
+
+	state, _ := config.GetState().GetIssue(ghIssue.GetID())
+	state.JIRAKey = jIssue.Key
+	state.JIRAID = jIssue.ID
+	state.Conflict = true
+	config.GetState().SetIssue(ghIssue.GetID(), state)
+
+	return nil
+}
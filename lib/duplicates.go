@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// matchIssue returns the JIRA issue of the list whose GitHub ID is that of
+// the GitHub issue of the repo, if any. If several have it, such as after a crash in the
+// middle of a creation, the one which was created first is kept, and the
+// others are resolved as its duplicates.
+func matchIssue(config cfg.Config, repo string, ghIssue TranslatedIssue, jIssues []jira.Issue, jClient clients.JIRAClient) (jira.Issue, bool) {
+	var matches []jira.Issue
+	for _, jIssue := range jIssues {
+		id, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+		if int64(ghIssue.GetID()) == id {
+			matches = append(matches, jIssue)
+		}
+	}
+	if len(matches) == 0 {
+		return jira.Issue{}, false
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return createdBefore(matches[i], matches[j])
+	})
+	for _, duplicate := range matches[1:] {
+		if err := resolveDuplicate(config, repo, ghIssue, duplicate, matches[0], jClient); err != nil {
+			issueLogger(config, repo, ghIssue.GetNumber(), duplicate.Key).
+				Errorf("Error resolving duplicate JIRA issue %s. Error: %v", duplicate.Key, err)
+		}
+	}
+
+	return matches[0], true
+}
+
+// createdBefore returns whether a JIRA issue was created before another one,
+// which is when its ID is lower, as JIRA assigns them in sequence.
+func createdBefore(a, b jira.Issue) bool {
+	idA, errA := strconv.Atoi(a.ID)
+	idB, errB := strconv.Atoi(b.ID)
+	if errA != nil || errB != nil || idA == idB {
+		return a.Key < b.Key
+	}
+	return idA < idB
+}
+
+// resolveDuplicate links a duplicate JIRA issue of the GitHub issue to its
+// canonical one, explains why in a comment, closes it, and clears its GitHub
+// ID, so that it's no longer synchronized.
+func resolveDuplicate(config cfg.Config, repo string, ghIssue TranslatedIssue, duplicate, canonical jira.Issue, jClient clients.JIRAClient) error {
+	log := issueLogger(config, repo, ghIssue.GetNumber(), duplicate.Key)
+
+	log.Warnf("JIRA issue %s duplicates %s; closing it", duplicate.Key, canonical.Key)
+
+	if err := jClient.LinkIssue(duplicate, clients.DuplicateLinkType, canonical.Key); err != nil {
+		return err
+	}
+
+	comment := fmt.Sprintf("This issue duplicates %s, which the GitHub issue %s#%d is synchronized to.", canonical.Key, repo, ghIssue.GetNumber())
+	if _, err := jClient.AddComment(duplicate, comment); err != nil {
+		return err
+	}
+
+	if err := jClient.TransitionIssueCategory(duplicate, true); err != nil {
+		return err
+	}
+
+	_, err := jClient.UpdateIssue(jira.Issue{
+		Fields: &jira.IssueFields{
+			Type:     duplicate.Fields.Type,
+			Unknowns: map[string]interface{}{config.GetFieldKey(cfg.GitHubID): nil},
+		},
+		Key: duplicate.Key,
+		ID:  duplicate.ID,
+	})
+	return err
+}
@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// digestPeriod is how long the runs of a daily digest cover before it's
+// sent.
+const digestPeriod = 24 * time.Hour
+
+// memoryDigest is the digest of the runs not yet sent if there's no
+// `report-file` to keep it next to, in which case it's lost on exit.
+var memoryDigest = struct {
+	sync.Mutex
+	Report
+}{}
+
+// add adds the issues of a run to a digest of several runs.
+func (r *Report) add(run Report) {
+	if r.Runs == 0 {
+		r.Started = run.Started
+	}
+	r.Finished = run.Finished
+	r.Issues = append(r.Issues, run.Issues...)
+	for _, repo := range run.FailedRepos {
+		if !containsString(r.FailedRepos, repo) {
+			r.FailedRepos = append(r.FailedRepos, repo)
+		}
+	}
+	r.Runs++
+}
+
+// containsString returns whether the string is one of the list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// forRepos returns the part of the report about the repos.
+func (r Report) forRepos(repos []string) Report {
+	filtered := r
+	filtered.Issues = nil
+	filtered.FailedRepos = nil
+	for _, e := range r.Issues {
+		if containsString(repos, e.Repo) {
+			filtered.Issues = append(filtered.Issues, e)
+		}
+	}
+	for _, repo := range r.FailedRepos {
+		if containsString(repos, repo) {
+			filtered.FailedRepos = append(filtered.FailedRepos, repo)
+		}
+	}
+	return filtered
+}
+
+// emailReports returns the report to send to each recipient: the whole of
+// it to the recipients of `email-to`, and the part about their projects to
+// the recipients of projects.
+func emailReports(config cfg.Config, r Report) map[string]Report {
+	repos := make(map[string][]string)
+	for repo := range config.GetProjects() {
+		for _, recipient := range config.GetProjectEmailRecipients(repo) {
+			repos[recipient] = append(repos[recipient], repo)
+		}
+	}
+
+	reports := make(map[string]Report)
+	for recipient, rs := range repos {
+		reports[recipient] = r.forRepos(rs)
+	}
+	for _, recipient := range config.GetEmailRecipients() {
+		reports[recipient] = r
+	}
+	return reports
+}
+
+// NotifyEmail sends the report of the run by email to the `email-to`
+// recipients, and to those of its projects. Under the errors `email-notify`
+// mode, only the reports with failures are sent; under the daily one, the
+// run is added to a digest, which is sent once it covers a day. Dry runs
+// aren't notified.
+func NotifyEmail(config cfg.Config, r Report) error {
+	if !config.SendsEmail() || config.IsDryRun() {
+		return nil
+	}
+
+	mode := config.GetEmailNotify()
+	subject := "issue-sync run"
+	if mode == cfg.NotifyDaily {
+		digest, ok, err := addToDigest(config, r)
+		if err != nil || !ok {
+			return err
+		}
+		r = digest
+		subject = "issue-sync daily digest"
+	}
+
+	var errs []string
+	for recipient, report := range emailReports(config, r) {
+		if mode == cfg.NotifyErrors && !report.Failed() {
+			continue
+		}
+		if err := sendReport(config, recipient, subject+": "+report.summary(), report); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", recipient, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to send the report to %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// addToDigest adds the run to the digest, and returns the digest, and
+// whether it's due to be sent, in which case the next one is started. The
+// digest is kept next to the `report-file`, so that runs started by cron
+// share it.
+func addToDigest(config cfg.Config, r Report) (Report, bool, error) {
+	path := config.GetReportFile()
+	if path == "" {
+		memoryDigest.Lock()
+		defer memoryDigest.Unlock()
+
+		memoryDigest.add(r)
+		if r.Finished.Sub(memoryDigest.Started) < digestPeriod {
+			return Report{}, false, nil
+		}
+		digest := memoryDigest.Report
+		memoryDigest.Report = Report{}
+		return digest, true, nil
+	}
+
+	path += ".digest"
+	var digest Report
+	if b, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(b, &digest); err != nil {
+			return Report{}, false, fmt.Errorf("unable to parse digest %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return Report{}, false, err
+	}
+
+	digest.add(r)
+	if r.Finished.Sub(digest.Started) >= digestPeriod {
+		return digest, true, os.Remove(path)
+	}
+
+	b, err := json.Marshal(digest)
+	if err != nil {
+		return Report{}, false, err
+	}
+	return Report{}, false, ioutil.WriteFile(path, b, 0644)
+}
+
+// sendReport sends the HTML report to the recipient through the SMTP
+// server, authenticating if an `smtp-user` is configured.
+func sendReport(config cfg.Config, recipient, subject string, r Report) error {
+	from := config.GetEmailFrom()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n\r\n")
+	if err := r.WriteHTML(&msg); err != nil {
+		return err
+	}
+
+	addr := config.GetSMTPAddress()
+	var auth smtp.Auth
+	if user := config.GetConfigString("smtp-user"); user != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", user, config.GetConfigString("smtp-pass"), host)
+	}
+
+	// The envelope takes the bare addresses, without their names.
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(recipient)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(addr, auth, sender.Address, []string{to.Address}, msg.Bytes())
+}
@@ -0,0 +1,240 @@
+package lib
+
+import (
+	"regexp"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// regexShortcode matches a GitHub emoji shortcode, such as :rocket:.
+var regexShortcode = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+
+// emojis maps the most common GitHub emoji shortcodes to their Unicode
+// emoji. Other shortcodes are left as they are.
+var emojis = map[string]string{
+	"+1":                          "\U0001F44D",
+	"-1":                          "\U0001F44E",
+	"thumbsup":                    "\U0001F44D",
+	"thumbsdown":                  "\U0001F44E",
+	"ok_hand":                     "\U0001F44C",
+	"clap":                        "\U0001F44F",
+	"wave":                        "\U0001F44B",
+	"pray":                        "\U0001F64F",
+	"muscle":                      "\U0001F4AA",
+	"raised_hands":                "\U0001F64C",
+	"point_up":                    "☝️",
+	"point_right":                 "\U0001F449",
+	"point_left":                  "\U0001F448",
+	"eyes":                        "\U0001F440",
+	"smile":                       "\U0001F604",
+	"smiley":                      "\U0001F603",
+	"grinning":                    "\U0001F600",
+	"grin":                        "\U0001F601",
+	"laughing":                    "\U0001F606",
+	"joy":                         "\U0001F602",
+	"rofl":                        "\U0001F923",
+	"sweat_smile":                 "\U0001F605",
+	"wink":                        "\U0001F609",
+	"blush":                       "\U0001F60A",
+	"slightly_smiling_face":       "\U0001F642",
+	"upside_down_face":            "\U0001F643",
+	"heart_eyes":                  "\U0001F60D",
+	"sunglasses":                  "\U0001F60E",
+	"thinking":                    "\U0001F914",
+	"neutral_face":                "\U0001F610",
+	"expressionless":              "\U0001F611",
+	"confused":                    "\U0001F615",
+	"worried":                     "\U0001F61F",
+	"disappointed":                "\U0001F61E",
+	"cry":                         "\U0001F622",
+	"sob":                         "\U0001F62D",
+	"angry":                       "\U0001F620",
+	"rage":                        "\U0001F621",
+	"scream":                      "\U0001F631",
+	"open_mouth":                  "\U0001F62E",
+	"astonished":                  "\U0001F632",
+	"flushed":                     "\U0001F633",
+	"sleeping":                    "\U0001F634",
+	"mask":                        "\U0001F637",
+	"nerd_face":                   "\U0001F913",
+	"hugs":                        "\U0001F917",
+	"facepalm":                    "\U0001F926",
+	"shrug":                       "\U0001F937",
+	"tada":                        "\U0001F389",
+	"confetti_ball":               "\U0001F38A",
+	"rocket":                      "\U0001F680",
+	"fire":                        "\U0001F525",
+	"sparkles":                    "✨",
+	"star":                        "⭐",
+	"star2":                       "\U0001F31F",
+	"zap":                         "⚡",
+	"boom":                        "\U0001F4A5",
+	"100":                         "\U0001F4AF",
+	"heart":                       "❤️",
+	"broken_heart":                "\U0001F494",
+	"green_heart":                 "\U0001F49A",
+	"blue_heart":                  "\U0001F499",
+	"purple_heart":                "\U0001F49C",
+	"yellow_heart":                "\U0001F49B",
+	"white_check_mark":            "✅",
+	"heavy_check_mark":            "✔️",
+	"ballot_box_with_check":       "☑️",
+	"x":                           "❌",
+	"negative_squared_cross_mark": "❎",
+	"heavy_multiplication_x":      "✖️",
+	"warning":                     "⚠️",
+	"no_entry":                    "⛔",
+	"no_entry_sign":               "\U0001F6AB",
+	"stop_sign":                   "\U0001F6D1",
+	"construction":                "\U0001F6A7",
+	"rotating_light":              "\U0001F6A8",
+	"exclamation":                 "❗",
+	"heavy_exclamation_mark":      "❗",
+	"question":                    "❓",
+	"grey_question":               "❔",
+	"bangbang":                    "‼️",
+	"information_source":          "ℹ️",
+	"bulb":                        "\U0001F4A1",
+	"bug":                         "\U0001F41B",
+	"beetle":                      "\U0001FAB2",
+	"wrench":                      "\U0001F527",
+	"hammer":                      "\U0001F528",
+	"hammer_and_wrench":           "\U0001F6E0️",
+	"gear":                        "⚙️",
+	"lock":                        "\U0001F512",
+	"unlock":                      "\U0001F513",
+	"key":                         "\U0001F511",
+	"mag":                         "\U0001F50D",
+	"link":                        "\U0001F517",
+	"paperclip":                   "\U0001F4CE",
+	"pushpin":                     "\U0001F4CC",
+	"memo":                        "\U0001F4DD",
+	"pencil":                      "\U0001F4DD",
+	"pencil2":                     "✏️",
+	"book":                        "\U0001F4D6",
+	"books":                       "\U0001F4DA",
+	"bookmark":                    "\U0001F516",
+	"package":                     "\U0001F4E6",
+	"truck":                       "\U0001F69A",
+	"calendar":                    "\U0001F4C6",
+	"date":                        "\U0001F4C5",
+	"clock1":                      "\U0001F550",
+	"hourglass":                   "⌛",
+	"alarm_clock":                 "⏰",
+	"chart_with_upwards_trend":    "\U0001F4C8",
+	"chart_with_downwards_trend":  "\U0001F4C9",
+	"bar_chart":                   "\U0001F4CA",
+	"computer":                    "\U0001F4BB",
+	"iphone":                      "\U0001F4F1",
+	"floppy_disk":                 "\U0001F4BE",
+	"cd":                          "\U0001F4BF",
+	"email":                       "\U0001F4E7",
+	"envelope":                    "✉️",
+	"bell":                        "\U0001F514",
+	"no_bell":                     "\U0001F515",
+	"loudspeaker":                 "\U0001F4E2",
+	"mega":                        "\U0001F4E3",
+	"speech_balloon":              "\U0001F4AC",
+	"thought_balloon":             "\U0001F4AD",
+	"recycle":                     "♻️",
+	"arrow_up":                    "⬆️",
+	"arrow_down":                  "⬇️",
+	"arrow_left":                  "⬅️",
+	"arrow_right":                 "➡️",
+	"arrows_counterclockwise":     "\U0001F504",
+	"white_circle":                "⚪",
+	"black_circle":                "⚫",
+	"red_circle":                  "\U0001F534",
+	"large_blue_circle":           "\U0001F535",
+	"green_circle":                "\U0001F7E2",
+	"yellow_circle":               "\U0001F7E1",
+	"lipstick":                    "\U0001F484",
+	"art":                         "\U0001F3A8",
+	"trophy":                      "\U0001F3C6",
+	"medal_sports":                "\U0001F3C5",
+	"checkered_flag":              "\U0001F3C1",
+	"triangular_flag_on_post":     "\U0001F6A9",
+	"dart":                        "\U0001F3AF",
+	"gift":                        "\U0001F381",
+	"balloon":                     "\U0001F388",
+	"coffee":                      "☕",
+	"beer":                        "\U0001F37A",
+	"beers":                       "\U0001F37B",
+	"cake":                        "\U0001F370",
+	"pizza":                       "\U0001F355",
+	"sunny":                       "☀️",
+	"cloud":                       "☁️",
+	"umbrella":                    "☔",
+	"snowflake":                   "❄️",
+	"rainbow":                     "\U0001F308",
+	"earth_americas":              "\U0001F30E",
+	"globe_with_meridians":        "\U0001F310",
+	"seedling":                    "\U0001F331",
+	"evergreen_tree":              "\U0001F332",
+	"cactus":                      "\U0001F335",
+	"cat":                         "\U0001F431",
+	"dog":                         "\U0001F436",
+	"octocat":                     "\U0001F419",
+	"octopus":                     "\U0001F419",
+	"penguin":                     "\U0001F427",
+	"snake":                       "\U0001F40D",
+	"turtle":                      "\U0001F422",
+	"unicorn":                     "\U0001F984",
+	"robot":                       "\U0001F916",
+	"ghost":                       "\U0001F47B",
+	"skull":                       "\U0001F480",
+	"poop":                        "\U0001F4A9",
+	"shipit":                      "\U0001F43F️",
+	"squirrel":                    "\U0001F43F️",
+	"see_no_evil":                 "\U0001F648",
+	"hear_no_evil":                "\U0001F649",
+	"speak_no_evil":               "\U0001F64A",
+	"lock_with_ink_pen":           "\U0001F50F",
+	"closed_lock_with_key":        "\U0001F510",
+	"ambulance":                   "\U0001F691",
+	"fire_engine":                 "\U0001F692",
+	"police_car":                  "\U0001F693",
+	"ok":                          "\U0001F197",
+	"new":                         "\U0001F195",
+	"free":                        "\U0001F193",
+	"up":                          "\U0001F199",
+	"cool":                        "\U0001F192",
+	"sos":                         "\U0001F198",
+	"heavy_plus_sign":             "➕",
+	"heavy_minus_sign":            "➖",
+	"bookmark_tabs":               "\U0001F4D1",
+	"clipboard":                   "\U0001F4CB",
+	"card_index":                  "\U0001F4C7",
+	"file_folder":                 "\U0001F4C1",
+	"open_file_folder":            "\U0001F4C2",
+	"wastebasket":                 "\U0001F5D1️",
+	"lips":                        "\U0001F444",
+	"zzz":                         "\U0001F4A4",
+	"dizzy":                       "\U0001F4AB",
+	"sweat_drops":                 "\U0001F4A6",
+	"dash":                        "\U0001F4A8",
+	"hourglass_flowing_sand":      "⏳",
+	"white_flag":                  "\U0001F3F3️",
+	"busts_in_silhouette":         "\U0001F465",
+	"bust_in_silhouette":          "\U0001F464",
+	"man_technologist":            "\U0001F468‍\U0001F4BB",
+	"woman_technologist":          "\U0001F469‍\U0001F4BB",
+}
+
+// translateEmojis replaces the GitHub emoji shortcodes of a body, such as
+// :rocket:, by their Unicode emoji, as JIRA shows shortcodes as they are.
+// Unknown shortcodes, and shortcodes in code, are left as they are.
+func translateEmojis(config cfg.Config, body string) string {
+	if !config.UseEmojis() {
+		return body
+	}
+
+	return outsideCode(regexCodeSpan, body, func(s string) string {
+		return regexShortcode.ReplaceAllStringFunc(s, func(s string) string {
+			if emoji, ok := emojis[regexShortcode.FindStringSubmatch(s)[1]]; ok {
+				return emoji
+			}
+			return s
+		})
+	})
+}
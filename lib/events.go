@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// The sync events posted to the `event-webhook-url`.
+const (
+	EventIssueCreated  = "issue.created"
+	EventIssueUpdated  = "issue.updated"
+	EventCommentSynced = "comment.synced"
+	EventSyncFailed    = "sync.failed"
+)
+
+// maxQueuedEvents is how many events can wait to be posted; the events
+// emitted once the queue is full are dropped, so that a slow endpoint
+// doesn't hold up the synchronization.
+const maxQueuedEvents = 1000
+
+// Event is the JSON payload of a sync event. The fields which don't apply
+// to the event are left out.
+type Event struct {
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	Repo          string    `json:"repo"`
+	Number        int       `json:"github-number,omitempty"`
+	GitHubURL     string    `json:"github-url,omitempty"`
+	JIRAKey       string    `json:"jira-key,omitempty"`
+	JIRAURL       string    `json:"jira-url,omitempty"`
+	CommentID     int       `json:"github-comment-id,omitempty"`
+	JIRACommentID string    `json:"jira-comment-id,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// delivery is an event to post to the endpoint of the configuration.
+type delivery struct {
+	config cfg.Config
+	event  Event
+}
+
+// events is the queue of the events to post, which are posted one at a
+// time, in order, by a single worker.
+var events = struct {
+	start   sync.Once
+	queue   chan delivery
+	pending sync.WaitGroup
+}{queue: make(chan delivery, maxQueuedEvents)}
+
+// jiraURL returns the URL of the JIRA issue with the key.
+func jiraURL(config cfg.Config, key string) string {
+	if key == "" {
+		return ""
+	}
+	return strings.TrimSuffix(config.GetConfigString("jira-uri"), "/") + "/browse/" + key
+}
+
+// emitEvent queues the event to be posted to the `event-webhook-url`, if
+// any. Dry runs don't emit events.
+func emitEvent(config cfg.Config, e Event) {
+	log := config.GetLogger()
+
+	if config.GetEventWebhookURL() == "" || config.IsDryRun() {
+		return
+	}
+
+	events.start.Do(func() {
+		go func() {
+			for d := range events.queue {
+				if err := postEvent(d.config, d.event); err != nil {
+					log := d.config.GetLogger()
+					log.Errorf("Error posting %s event. Error: %v", d.event.Event, err)
+				}
+				events.pending.Done()
+			}
+		}()
+	})
+
+	e.Time = time.Now()
+	events.pending.Add(1)
+	select {
+	case events.queue <- delivery{config, e}:
+	default:
+		events.pending.Done()
+		log.Warningf("Too many events are waiting to be posted; dropping %s event", e.Event)
+	}
+}
+
+// emitCommentEvent emits the event of a GitHub comment synchronized to the
+// JIRA comment.
+func emitCommentEvent(config cfg.Config, repo string, ghComment github.IssueComment, jIssue jira.Issue, jComment jira.Comment) {
+	emitEvent(config, Event{
+		Event:         EventCommentSynced,
+		Repo:          repo,
+		GitHubURL:     ghComment.GetHTMLURL(),
+		JIRAKey:       jIssue.Key,
+		JIRAURL:       jiraURL(config, jIssue.Key),
+		CommentID:     ghComment.GetID(),
+		JIRACommentID: jComment.ID,
+	})
+}
+
+// EmitSyncFailed emits the event of a repo which failed to synchronize as
+// a whole.
+func EmitSyncFailed(config cfg.Config, repo string, err error) {
+	emitEvent(config, Event{
+		Event: EventSyncFailed,
+		Repo:  repo,
+		Error: err.Error(),
+	})
+}
+
+// postEvent posts the event to the `event-webhook-url`. If an
+// `event-webhook-secret` is configured, the payload is signed with it, as
+// GitHub signs its webhooks: the X-Issue-Sync-Signature header holds the
+// HMAC-SHA256 of the payload, as "sha256=<hex>".
+func postEvent(config cfg.Config, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", config.GetEventWebhookURL(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Issue-Sync-Event", e.Event)
+	if secret := config.GetConfigString("event-webhook-secret"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(b)
+		req.Header.Set("X-Issue-Sync-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := &http.Client{Timeout: config.GetTimeout()}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("event webhook returned %s", res.Status)
+	}
+	return nil
+}
+
+// FlushEvents waits for the queued events to be posted, for up to the
+// `timeout`, such as before issue-sync exits.
+func FlushEvents(config cfg.Config) {
+	log := config.GetLogger()
+
+	done := make(chan struct{})
+	go func() {
+		events.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(config.GetTimeout()):
+		log.Warning("Timed out posting the remaining events")
+	}
+}
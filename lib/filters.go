@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// matchesUsers reports whether any of the logins is one of the users of a
+// filter, given by login or as the members of a team (@org/team).
+func matchesUsers(ghClient clients.GitHubClient, users []string, logins []string) (bool, error) {
+	for _, user := range users {
+		members := []string{user}
+		if org, team, ok := cfg.ParseTeam(user); ok {
+			var err error
+			if members, err = ghClient.ListTeamMembers(org, team); err != nil {
+				return false, err
+			}
+		}
+		for _, member := range members {
+			for _, login := range logins {
+				if strings.EqualFold(member, login) {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// isClosedOutsideFilter returns whether a GitHub issue without a JIRA issue
+// is a closed one, listed with `state-filter: open` only so that the JIRA
+// issues of the issues closed since the last sync are closed; it isn't
+// created.
+func isClosedOutsideFilter(config cfg.Config, repo string, ghIssue github.Issue) bool {
+	return config.GetStateFilter(repo) == "open" && ghIssue.GetState() == "closed"
+}
+
+// isFiltered reports whether a GitHub issue of the repo of the client is
+// left out of the synchronization by the filters of the configuration, and
+// logs why. Issues whose users can't be checked are left out as well.
+func isFiltered(config cfg.Config, ghClient clients.GitHubClient, ghIssue github.Issue) bool {
+	repo := ghClient.GetRepo()
+	log := issueLogger(config, repo, ghIssue.GetNumber(), "")
+
+	labels := make([]string, len(ghIssue.Labels))
+	for i, l := range ghIssue.Labels {
+		labels[i] = l.GetName()
+	}
+	if !config.MatchesLabels(repo, labels) {
+		log.Debugf("#%d is filtered out by its labels; skipping.", ghIssue.GetNumber())
+		return true
+	}
+	if !config.MatchesMilestone(repo, ghIssue.Milestone.GetTitle()) {
+		log.Debugf("#%d is filtered out by its milestone; skipping.", ghIssue.GetNumber())
+		return true
+	}
+
+	if authors := config.GetAuthorFilter(repo); len(authors) > 0 {
+		matched, err := matchesUsers(ghClient, authors, []string{ghIssue.User.GetLogin()})
+		if err != nil {
+			log.Errorf("Error checking the author of #%d; skipping. Error: %v", ghIssue.GetNumber(), err)
+			return true
+		}
+		if !matched {
+			log.Debugf("#%d is filtered out by its author; skipping.", ghIssue.GetNumber())
+			return true
+		}
+	}
+
+	if assignees := config.GetAssigneeFilter(repo); len(assignees) > 0 {
+		logins := make([]string, len(ghIssue.Assignees))
+		for i, a := range ghIssue.Assignees {
+			logins[i] = a.GetLogin()
+		}
+		matched, err := matchesUsers(ghClient, assignees, logins)
+		if err != nil {
+			log.Errorf("Error checking the assignees of #%d; skipping. Error: %v", ghIssue.GetNumber(), err)
+			return true
+		}
+		if !matched {
+			log.Debugf("#%d is filtered out by its assignees; skipping.", ghIssue.GetNumber())
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// regexFormHeading matches the level 3 headings GitHub titles the sections
+// of the bodies of issues created from issue forms with.
+var regexFormHeading = regexp.MustCompile(`(?m)^### +(.+?)\s*$`)
+
+// noResponse is the content GitHub gives the sections of the optional
+// fields of issue forms which were left empty.
+const noResponse = "_No response_"
+
+// formSection is a section of the body of a GitHub issue created from an
+// issue form, from its heading to the next one.
+type formSection struct {
+	heading    string
+	content    string
+	start, end int
+}
+
+// parseFormSections returns the sections of the body of a GitHub issue
+// titled by level 3 headings, in order.
+func parseFormSections(body string) []formSection {
+	locs := regexFormHeading.FindAllStringSubmatchIndex(body, -1)
+	sections := make([]formSection, len(locs))
+	for i, loc := range locs {
+		end := len(body)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		sections[i] = formSection{
+			heading: body[loc[2]:loc[3]],
+			content: strings.TrimSpace(body[loc[1]:end]),
+			start:   loc[0],
+			end:     end,
+		}
+	}
+	return sections
+}
+
+// formContents returns the contents of the sections of the body of a GitHub
+// issue which are written to JIRA fields by `issue-form-fields`, by their
+// field, and the body without those sections. Empty sections are left out
+// of the contents, but still removed from the body.
+func formContents(config cfg.Config, body string) (map[string]string, string) {
+	forms := config.GetFormFields()
+	if len(forms) == 0 {
+		return nil, body
+	}
+
+	contents := make(map[string]string)
+	var rest strings.Builder
+	last := 0
+	for _, section := range parseFormSections(body) {
+		field, ok := forms[strings.ToLower(section.heading)]
+		if !ok {
+			continue
+		}
+		rest.WriteString(body[last:section.start])
+		last = section.end
+		if section.content != "" && section.content != noResponse {
+			contents[field.ID] = section.content
+		}
+	}
+	rest.WriteString(body[last:])
+
+	return contents, strings.TrimSpace(rest.String())
+}
+
+// formBody returns the body of a GitHub issue without the sections written
+// to JIRA fields by `issue-form-fields`, which is what its description is
+// made of.
+func formBody(config cfg.Config, body string) string {
+	_, rest := formContents(config, body)
+	return rest
+}
+
+// formValues returns the values of the JIRA fields the sections of the body
+// of a GitHub issue are written to by `issue-form-fields`, by field ID, in
+// the form their type requires. Lists are separated by commas or lines.
+func formValues(config cfg.Config, log *logrus.Entry, ghIssue TranslatedIssue) map[string]interface{} {
+	contents, _ := formContents(config, ghIssue.GetBody())
+
+	values := make(map[string]interface{})
+	for _, field := range config.GetFormFields() {
+		content, ok := contents[field.ID]
+		if !ok {
+			continue
+		}
+		switch field.Type {
+		case "number":
+			n, err := strconv.ParseFloat(content, 64)
+			if err != nil {
+				log.Debugf("Section of field %s of #%d isn't a number: %q", field.ID, ghIssue.GetNumber(), content)
+				continue
+			}
+			values[field.ID] = n
+		case "option":
+			values[field.ID] = map[string]string{"value": content}
+		case "array":
+			var items []interface{}
+			for _, item := range strings.FieldsFunc(content, func(r rune) bool { return r == ',' || r == '\n' }) {
+				item = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(item), "- "))
+				if item == "" {
+					continue
+				}
+				switch field.Items {
+				case "string":
+					items = append(items, item)
+				case "option":
+					items = append(items, map[string]string{"value": item})
+				default:
+					items = append(items, map[string]string{"name": item})
+				}
+			}
+			values[field.ID] = items
+		default:
+			values[field.ID] = config.LimitJIRAText(translateBody(config, log, content))
+		}
+	}
+	return values
+}
+
+// formValueString returns a value of a JIRA field as a string, so that the
+// values read from JIRA can be compared to those written: options are
+// compared by value, versions and components by name, and lists as a whole.
+func formValueString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]string:
+		if name, ok := v["name"]; ok {
+			return name
+		}
+		return v["value"]
+	case map[string]interface{}:
+		if name, ok := v["name"]; ok {
+			return fmt.Sprint(name)
+		}
+		return fmt.Sprint(v["value"])
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formValueString(item)
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
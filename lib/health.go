@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// Health tracks the state of a long-running issue-sync process, and serves
+// the /healthz (liveness) and /readyz (readiness) endpoints.
+type Health struct {
+	config     cfg.Config
+	ghClient   clients.GitHubClient
+	jiraClient clients.JIRAClient
+
+	mu        sync.Mutex
+	lastCycle time.Time
+}
+
+// NewHealth creates a Health object with the clients used to check
+// connectivity to GitHub and JIRA when readiness is probed.
+func NewHealth(config cfg.Config, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) *Health {
+	return &Health{
+		config:     config,
+		ghClient:   ghClient,
+		jiraClient: jiraClient,
+		lastCycle:  time.Now(),
+	}
+}
+
+// SetConfig replaces the configuration, after it has been reloaded.
+func (h *Health) SetConfig(config cfg.Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config = config
+}
+
+// MarkCycle records that a synchronization cycle has just completed.
+func (h *Health) MarkCycle() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCycle = time.Now()
+}
+
+// Register adds the /healthz and /readyz handlers to the mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.serveHealthz)
+	mux.HandleFunc("/readyz", h.serveReadyz)
+}
+
+// serveHealthz reports whether the process is alive. In daemon mode, it
+// fails if no cycle has completed within a few periods, which means the
+// sync loop is stuck.
+func (h *Health) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	config := h.config
+	since := time.Since(h.lastCycle)
+	h.mu.Unlock()
+
+	if config.IsDaemon() {
+		// A cycle may legitimately take a while if the APIs need retries.
+		limit := 3*config.GetDaemonPeriod() + config.GetTimeout()
+		if since > limit {
+			http.Error(w, fmt.Sprintf("no sync cycle completed in %v", since), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReadyz reports whether issue-sync can currently reach both GitHub
+// and JIRA with its credentials.
+func (h *Health) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	log := h.config.GetLogger()
+	h.mu.Unlock()
+
+	if _, err := h.ghClient.GetRateLimits(); err != nil {
+		log.Errorf("Readiness check failed to reach GitHub: %v", err)
+		http.Error(w, fmt.Sprintf("GitHub unreachable: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	client := h.jiraClient.GetClient()
+	req, err := client.NewRequest("GET", "rest/api/2/serverInfo", nil)
+	if err == nil {
+		var res *jira.Response
+		res, err = client.Do(req, nil)
+		if res != nil {
+			res.Body.Close()
+		}
+	}
+	if err != nil {
+		log.Errorf("Readiness check failed to reach JIRA: %v", err)
+		http.Error(w, fmt.Sprintf("JIRA unreachable: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// ServeHealth starts an HTTP server on the address which serves only the
+// health endpoints. Errors are logged, as the sync loop keeps running.
+func ServeHealth(config cfg.Config, addr string, health *Health) {
+	log := config.GetLogger()
+
+	mux := http.NewServeMux()
+	health.Register(mux)
+
+	go func() {
+		log.Infof("Serving health endpoints on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Errorf("Health endpoint server failed: %v", err)
+		}
+	}()
+}
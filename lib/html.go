@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// HTML elements
+var regexHTMLComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+var regexHTMLDetails = regexp.MustCompile(`(?is)<details[^>]*>\s*(?:<summary[^>]*>(.*?)</summary>)?(.*?)</details>`)
+var regexHTMLTable = regexp.MustCompile(`(?is)<table[^>]*>(.*?)</table>`)
+var regexHTMLRow = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+var regexHTMLCell = regexp.MustCompile(`(?is)<(th|td)[^>]*>(.*?)</t[hd]>`)
+var regexHTMLPre = regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`)
+var regexHTMLHeading = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+var regexHTMLLink = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+var regexHTMLImage = regexp.MustCompile(`(?is)<img\s[^>]*>`)
+var regexHTMLAttribute = regexp.MustCompile(`(?is)\s([a-z-]+)\s*=\s*["']([^"']*)["']`)
+var regexHTMLList = regexp.MustCompile(`(?is)<(ul|ol)(?:\s[^>]*)?>|</(?:ul|ol)>|<li(?:\s[^>]*)?>|</li>`)
+var regexHTMLBreak = regexp.MustCompile(`(?i)<br\s*/?>`)
+var regexHTMLRule = regexp.MustCompile(`(?i)<hr\s*/?>`)
+var regexHTMLParagraph = regexp.MustCompile(`(?i)</?p(?:\s[^>]*)?>`)
+var regexHTMLQuote = regexp.MustCompile(`(?i)</?blockquote(?:\s[^>]*)?>`)
+var regexHTMLTag = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9]*(?:\s[^>]*)?/?>`)
+
+// htmlEffects maps the HTML elements of text effects to the JIRA markers of
+// the effects, which open and close them.
+var htmlEffects = []struct {
+	tags          []string
+	open, closing string
+}{
+	{[]string{"code", "kbd", "tt", "samp"}, "{{", "}}"},
+	{[]string{"b", "strong"}, "*", "*"},
+	{[]string{"i", "em"}, "_", "_"},
+	{[]string{"del", "s", "strike"}, "-", "-"},
+	{[]string{"ins", "u"}, "+", "+"},
+	{[]string{"sup"}, "^", "^"},
+	{[]string{"sub"}, "~", "~"},
+	{[]string{"cite"}, "??", "??"},
+}
+
+// htmlEffectRegexes are the regular expressions matching each element of
+// htmlEffects, in order.
+var htmlEffectRegexes = func() [][]*regexp.Regexp {
+	regexes := make([][]*regexp.Regexp, len(htmlEffects))
+	for i, effect := range htmlEffects {
+		for _, tag := range effect.tags {
+			regexes[i] = append(regexes[i], regexp.MustCompile(`(?is)<`+tag+`(?:\s[^>]*)?>(.*?)</`+tag+`>`))
+		}
+	}
+	return regexes
+}()
+
+// htmlStrippedTags are the HTML elements whose tags are stripped, keeping
+// their content, once the elements JIRA has an equivalent for are
+// translated. Other tags, which may be text such as generic types
+// (`Vec<String>`), are left as they are.
+var htmlStrippedTags = map[string]bool{
+	"div": true, "span": true, "center": true, "font": true, "small": true,
+	"big": true, "section": true, "article": true, "header": true,
+	"footer": true, "nav": true, "main": true, "figure": true,
+	"figcaption": true, "picture": true, "source": true, "video": true,
+	"thead": true, "tbody": true, "tfoot": true, "summary": true,
+	"details": true, "dl": true, "dt": true, "dd": true, "abbr": true,
+	"mark": true, "q": true, "var": true, "a": true, "html": true,
+	"body": true, "colgroup": true, "col": true, "caption": true,
+}
+
+// htmlText returns the text of an HTML fragment on a single line, with its
+// tags stripped.
+func htmlText(fragment string) string {
+	fragment = regexHTMLTag.ReplaceAllString(fragment, "")
+	return strings.Join(strings.Fields(fragment), " ")
+}
+
+// translateHTML translates the HTML fragments of a GitHub body to JIRA
+// markup: <details> to {expand} macros, <img> to images, <table> to JIRA
+// tables, as well as links, headings, lists, line breaks, and text effects.
+// The tags of other common elements are stripped, and comments removed.
+// HTML in code is left as it is. The translated elements which mustn't be
+// translated any further are set aside.
+func translateHTML(body string, a *asides) string {
+	return outsideCode(regexCodeSpan, body, func(s string) string {
+		s = regexHTMLComment.ReplaceAllString(s, "")
+
+		s = regexHTMLPre.ReplaceAllStringFunc(s, func(s string) string {
+			return a.aside("{noformat}\n" + strings.Trim(regexHTMLPre.FindStringSubmatch(s)[1], "\n") + "\n{noformat}")
+		})
+		for i, effect := range htmlEffects {
+			for _, regex := range htmlEffectRegexes[i] {
+				s = regex.ReplaceAllStringFunc(s, func(s string) string {
+					text := strings.TrimSpace(regex.FindStringSubmatch(s)[1])
+					if text == "" {
+						return ""
+					}
+					return a.aside(effect.open + text + effect.closing)
+				})
+			}
+		}
+
+		s = regexHTMLImage.ReplaceAllStringFunc(s, func(s string) string {
+			attributes := make(map[string]string)
+			for _, m := range regexHTMLAttribute.FindAllStringSubmatch(s, -1) {
+				attributes[strings.ToLower(m[1])] = m[2]
+			}
+			if attributes["src"] == "" {
+				return ""
+			}
+			image := "!" + attributes["src"]
+			if width := attributes["width"]; width != "" {
+				image += "|width=" + strings.TrimSuffix(width, "px")
+			}
+			return a.aside(image + "!")
+		})
+		s = regexHTMLLink.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexHTMLLink.FindStringSubmatch(s)
+			if text := htmlText(m[2]); text != "" && text != m[1] {
+				return a.aside("[" + text + "|" + m[1] + "]")
+			}
+			return a.aside("[" + m[1] + "]")
+		})
+
+		s = regexHTMLDetails.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexHTMLDetails.FindStringSubmatch(s)
+			title := strings.NewReplacer("|", "", "}", "", "{", "").Replace(htmlText(m[1]))
+			macro := "{expand}"
+			if title != "" {
+				macro = "{expand:" + title + "}"
+			}
+			return "\n" + macro + "\n" + strings.Trim(m[2], "\n") + "\n{expand}\n"
+		})
+		s = regexHTMLTable.ReplaceAllStringFunc(s, func(s string) string {
+			var rows []string
+			for _, row := range regexHTMLRow.FindAllStringSubmatch(regexHTMLTable.FindStringSubmatch(s)[1], -1) {
+				var cells []string
+				header := true
+				for _, cell := range regexHTMLCell.FindAllStringSubmatch(row[1], -1) {
+					header = header && strings.ToLower(cell[1]) == "th"
+					cells = append(cells, htmlText(regexHTMLBreak.ReplaceAllString(cell[2], ` \\ `)))
+				}
+				if len(cells) == 0 {
+					continue
+				}
+				separator := "|"
+				if header {
+					separator = "||"
+				}
+				rows = append(rows, tableRow(cells, len(cells), separator))
+			}
+			return "\n" + strings.Join(rows, "\n") + "\n"
+		})
+		s = regexHTMLHeading.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexHTMLHeading.FindStringSubmatch(s)
+			return "\nh" + m[1] + ". " + htmlText(m[2]) + "\n"
+		})
+
+		var markers []string
+		s = regexHTMLList.ReplaceAllStringFunc(s, func(s string) string {
+			tag := strings.ToLower(s)
+			switch {
+			case strings.HasPrefix(tag, "<ul"):
+				markers = append(markers, bulletPlaceholder)
+			case strings.HasPrefix(tag, "<ol"):
+				markers = append(markers, numberedPlaceholder)
+			case strings.HasPrefix(tag, "</ul") || strings.HasPrefix(tag, "</ol"):
+				if len(markers) > 0 {
+					markers = markers[:len(markers)-1]
+				}
+				return "\n"
+			case strings.HasPrefix(tag, "<li") && len(markers) > 0:
+				return "\n" + strings.Join(markers, "") + " "
+			}
+			return ""
+		})
+
+		s = regexHTMLBreak.ReplaceAllString(s, `\\`)
+		s = regexHTMLRule.ReplaceAllString(s, "\n----\n")
+		s = regexHTMLParagraph.ReplaceAllString(s, "\n")
+		s = regexHTMLQuote.ReplaceAllString(s, "\n{quote}\n")
+
+		return regexHTMLTag.ReplaceAllStringFunc(s, func(tag string) string {
+			name := strings.ToLower(strings.Trim(strings.Fields(tag)[0], "</>"))
+			if htmlStrippedTags[name] {
+				return ""
+			}
+			return tag
+		})
+	})
+}
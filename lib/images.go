@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// regexImageRef matches the images of a JIRA body: their URL or attachment
+// name (\1), and their options, such as |width=600 (\2).
+var regexImageRef = regexp.MustCompile(`!([^!|\s]+)(\|[^!\n]*)?!`)
+
+// imageExtensions are the extensions given to the names of attachments of
+// images whose URL has none, by media type.
+var imageExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/svg+xml":   ".svg",
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+}
+
+// imageName returns the name of the attachment of an image, from the last
+// element of its URL, such as the ID of the images uploaded to
+// github.com/user-attachments, which have no extension.
+func imageName(uri string) string {
+	if u, err := url.Parse(uri); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(uri)
+}
+
+// imageExtension returns the extension of the name of the attachment of an
+// image of the media type, or an empty string if it's unknown.
+func imageExtension(mediaType string) string {
+	mediaType, _, _ = mime.ParseMediaType(mediaType)
+	if ext, ok := imageExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// findAttachment returns the name of the attachment of a JIRA issue which an
+// image was rehosted as, if any.
+func findAttachment(jIssue jira.Issue, name string) (string, bool) {
+	if jIssue.Fields == nil {
+		return "", false
+	}
+	for _, a := range jIssue.Fields.Attachments {
+		if a.Filename == name || (path.Ext(name) == "" && strings.TrimSuffix(a.Filename, path.Ext(a.Filename)) == name) {
+			return a.Filename, true
+		}
+	}
+	return "", false
+}
+
+// rehostImages returns the translated body of a GitHub issue, with the images
+// it embeds from GitHub replaced by attachments of the JIRA issue, downloading and attaching those which
+// aren't attached already. Images which can't be rehosted are logged and
+// left as they are.
+func rehostImages(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) string {
+	log := issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key)
+
+	// Images repeated in the body are only attached once.
+	attached := make(map[string]string)
+
+	return outsideCode(regexJiraCodeSpan, ghIssue.GetTranslatedBody(), func(s string) string {
+		return regexImageRef.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexImageRef.FindStringSubmatch(s)
+			uri := m[1]
+			if !clients.IsGitHubImage(config, uri) {
+				return s
+			}
+
+			name, ok := attached[uri]
+			if !ok {
+				name, ok = findAttachment(jIssue, imageName(uri))
+			}
+			if !ok {
+				content, mediaType, err := ghClient.DownloadImage(uri)
+				if err != nil {
+					log.Warnf("Error downloading image %s; leaving it as a link. Error: %v", uri, err)
+					return s
+				}
+				name = imageName(uri)
+				if path.Ext(name) == "" {
+					name += imageExtension(mediaType)
+				}
+				a, err := jClient.AddAttachment(jIssue, name, content)
+				if err != nil {
+					log.Warnf("Error attaching image %s; leaving it as a link. Error: %v", uri, err)
+					return s
+				}
+				name = a.Filename
+			}
+
+			attached[uri] = name
+			return "!" + name + m[2] + "!"
+		})
+	})
+}
@@ -1,9 +1,15 @@
 package lib
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/go-jira"
 	"github.com/coreos/issue-sync/cfg"
 	"github.com/coreos/issue-sync/lib/clients"
@@ -14,10 +20,32 @@ import (
 // dateFormat is the format used for the Last IS Update field
 const dateFormat = "2006-01-02T15:04:05.0-0700"
 
+// issueLogger returns the application logger with fields identifying the
+// issue being synchronized, so structured log output can be filtered by
+// repository, GitHub number, or JIRA key. Empty values are omitted.
+func issueLogger(config cfg.Config, repo string, number int, key string) *logrus.Entry {
+	log := config.GetLogger()
+
+	fields := logrus.Fields{}
+	if repo != "" {
+		fields["repo"] = repo
+	}
+	if number != 0 {
+		fields["github-number"] = number
+	}
+	if key != "" {
+		fields["jira-key"] = key
+	}
+
+	return log.WithFields(fields)
+}
+
 // CompareIssues gets the list of GitHub issues updated since the `since` date,
 // gets the list of JIRA issues which have GitHub ID custom fields in that list,
 // then matches each one. If a JIRA issue already exists for a given GitHub issue,
 // it calls UpdateIssue; if no JIRA issue already exists, it calls CreateIssue.
+// The JIRA issues of the pinned GitHub issues are then marked, and those
+// whose GitHub issue is gone reconciled.
 func CompareIssues(config cfg.Config, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
 	log := config.GetLogger()
 
@@ -29,12 +57,66 @@ func CompareIssues(config cfg.Config, ghClient clients.GitHubClient, jiraClient
 	}
 
 	if len(ghIssues) == 0 {
-		log.Info("There are no GitHub issues; exiting")
-		return nil
+		log.Info("There are no GitHub issues")
+	} else if err := syncIssues(config, ghIssues, ghClient, jiraClient); err != nil {
+		return err
+	}
+
+	if err := syncPinned(config, ghClient, jiraClient); err != nil {
+		return err
 	}
 
-	ids := make([]int, len(ghIssues))
-	for i, v := range ghIssues {
+	return reconcileOrphans(config, ghIssues, ghClient, jiraClient)
+}
+
+// syncIssues gets the list of JIRA issues which have GitHub ID custom fields
+// in the provided list of GitHub issues, then matches each one, calling
+// UpdateIssue or CreateIssue as appropriate. Errors on individual issues are
+// logged rather than returned. Once issue-sync is shut down, no other issue
+// is started, and the error of the context is returned.
+func syncIssues(config cfg.Config, ghIssues []github.Issue, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	defer saveState(config)
+
+	// Issues which are in the state are retrieved by key, so only the
+	// others need to be searched for.
+	var unknown []github.Issue
+	var unknownLock sync.Mutex
+	ForEachSlot(config.IssueSlots(), len(ghIssues), func(i int) {
+		ghIssue := ghIssues[i]
+		if config.Context().Err() != nil {
+			return
+		}
+		if isFiltered(config, ghClient, ghIssue) {
+			return
+		}
+		ghTranslatedIssue := NewTranslatedIssue(config, ghClient.GetRepo(), ghIssue)
+		if isUnchanged(config, ghTranslatedIssue) {
+			issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), "").
+				Debugf("#%d has not changed since it was last synchronized; skipping.", ghIssue.GetNumber())
+			state, _ := config.GetState().GetIssue(ghIssue.GetID())
+			recordOutcome(config, ghClient.GetRepo(), ghTranslatedIssue, state.JIRAKey, OutcomeSkipped, nil)
+			return
+		}
+		if jIssue, ok := knownIssue(config, ghTranslatedIssue, jiraClient); ok {
+			if err := UpdateIssue(config, ghTranslatedIssue, jIssue, ghClient, jiraClient); err != nil {
+				issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key).
+					Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
+			}
+			return
+		}
+		unknownLock.Lock()
+		unknown = append(unknown, ghIssue)
+		unknownLock.Unlock()
+	})
+
+	if err := config.Context().Err(); err != nil || len(unknown) == 0 {
+		return err
+	}
+
+	ids := make([]int, len(unknown))
+	for i, v := range unknown {
 		ids[i] = v.GetID()
 	}
 
@@ -45,49 +127,305 @@ func CompareIssues(config cfg.Config, ghClient clients.GitHubClient, jiraClient
 
 	log.Debug("Collected all JIRA issues")
 
-	for _, ghIssue := range ghIssues {
-		found := false
-		ghTranslatedIssue := NewTranslatedIssue(ghIssue)
-		for _, jIssue := range jiraIssues {
-			id, _ := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
-			if int64(*ghIssue.ID) == id {
-				found = true
-				if err := UpdateIssue(config, ghTranslatedIssue, jIssue, ghClient, jiraClient); err != nil {
-					log.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
-				}
-				break
-			}
+	// The issues which don't have a JIRA issue yet are created together,
+	// in bulk.
+	var missing []TranslatedIssue
+	var missingLock sync.Mutex
+	ForEachSlot(config.IssueSlots(), len(unknown), func(i int) {
+		ghIssue := unknown[i]
+		if config.Context().Err() != nil {
+			return
 		}
-		if !found {
-			if err := CreateIssue(config, ghTranslatedIssue, ghClient, jiraClient); err != nil {
-				log.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
-			}
+		ghTranslatedIssue := NewTranslatedIssue(config, ghClient.GetRepo(), ghIssue)
+		jIssue, ok := matchIssue(config, ghClient.GetRepo(), ghTranslatedIssue, jiraIssues, jiraClient)
+		if !ok && isClosedOutsideFilter(config, ghClient.GetRepo(), ghIssue) {
+			issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), "").
+				Debugf("#%d is closed and was never synchronized; skipping.", ghIssue.GetNumber())
+			return
+		}
+		if !ok {
+			missingLock.Lock()
+			missing = append(missing, ghTranslatedIssue)
+			missingLock.Unlock()
+			return
+		}
+		if err := UpdateIssue(config, ghTranslatedIssue, jIssue, ghClient, jiraClient); err != nil {
+			issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key).
+				Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
 		}
+	})
+
+	if len(missing) > 0 {
+		createIssues(config, missing, ghClient, jiraClient)
 	}
 
-	return nil
+	return config.Context().Err()
+}
+
+// SyncIssue synchronizes a single GitHub issue, without listing the rest of the
+// repository. It looks up the JIRA issue in the state, or else the one with the
+// matching GitHub ID custom field; if one exists, it calls UpdateIssue, otherwise
+// it calls CreateIssue.
+func SyncIssue(config cfg.Config, ghIssue github.Issue, ghClient clients.GitHubClient, jiraClient clients.JIRAClient) error {
+	log := issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), "")
+
+	if ghIssue.PullRequestLinks != nil {
+		log.Debugf("#%d is a pull request; skipping.", ghIssue.GetNumber())
+		return nil
+	}
+
+	if isFiltered(config, ghClient, ghIssue) {
+		return nil
+	}
+
+	defer saveState(config)
+
+	ghTranslatedIssue := NewTranslatedIssue(config, ghClient.GetRepo(), ghIssue)
+	if isUnchanged(config, ghTranslatedIssue) {
+		log.Debugf("#%d has not changed since it was last synchronized; skipping.", ghIssue.GetNumber())
+		return nil
+	}
+	if jIssue, ok := knownIssue(config, ghTranslatedIssue, jiraClient); ok {
+		return UpdateIssue(config, ghTranslatedIssue, jIssue, ghClient, jiraClient)
+	}
+
+	jiraIssues, err := jiraClient.ListIssues([]int{ghIssue.GetID()})
+	if err != nil {
+		return err
+	}
+
+	if jIssue, ok := matchIssue(config, ghClient.GetRepo(), ghTranslatedIssue, jiraIssues, jiraClient); ok {
+		return UpdateIssue(config, ghTranslatedIssue, jIssue, ghClient, jiraClient)
+	}
+
+	if isClosedOutsideFilter(config, ghClient.GetRepo(), ghIssue) {
+		log.Debugf("#%d is closed and was never synchronized; skipping.", ghIssue.GetNumber())
+		return nil
+	}
+	return CreateIssue(config, ghTranslatedIssue, ghClient, jiraClient)
+}
+
+// issueHash returns a hash of the fields of a GitHub issue which are
+// synchronized to JIRA. The reactions are left out unless they're synced,
+// so that enabling it doesn't change the hash of issues without any, and
+// so are the sections of issue forms unless they're written to fields.
+func issueHash(config cfg.Config, ghIssue TranslatedIssue) string {
+	labels := make([]string, len(ghIssue.Labels))
+	for i, l := range ghIssue.Labels {
+		labels[i] = l.GetName()
+	}
+
+	votes := 0
+	if config.SyncsReactions() {
+		votes = thumbsUp(ghIssue)
+	}
+	forms, _ := formContents(config, ghIssue.GetBody())
+
+	h := sha256.New()
+	json.NewEncoder(h).Encode(struct {
+		Title     string
+		Body      string
+		State     string
+		Reporter  string
+		Labels    []string
+		Milestone string
+		Votes     int               `json:",omitempty"`
+		Forms     map[string]string `json:",omitempty"`
+	}{
+		ghIssue.GetSummary(),
+		ghIssue.GetTranslatedBody(),
+		ghIssue.GetState(),
+		ghIssue.User.GetLogin(),
+		labels,
+		ghIssue.Milestone.GetTitle(),
+		votes,
+		forms,
+	})
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pushedHash returns a hash of the summary, the description, and the labels
+// written to the JIRA issue of a GitHub issue, given its summary. They're
+// compared to what was last written rather than to the JIRA issue, which
+// doesn't return them exactly as they were sent, such as with their line
+// endings or trailing spaces.
+func pushedHash(ghIssue TranslatedIssue, summary string) string {
+	labels := make([]string, len(ghIssue.Labels))
+	for i, l := range ghIssue.Labels {
+		labels[i] = l.GetName()
+	}
+
+	h := sha256.New()
+	json.NewEncoder(h).Encode(struct {
+		Summary     string
+		Description string
+		Labels      []string
+	}{
+		summary,
+		ghIssue.GetTranslatedBody(),
+		labels,
+	})
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// thumbsUp returns the number of thumbs up (+1) reactions to a GitHub issue.
+func thumbsUp(ghIssue TranslatedIssue) int {
+	if ghIssue.Reactions == nil {
+		return 0
+	}
+	return ghIssue.Reactions.GetPlusOne()
+}
+
+// storyPoints returns the story points of a GitHub issue, as given by
+// its labels in `story-points`, and whether it has any.
+func storyPoints(config cfg.Config, ghIssue TranslatedIssue) (float64, bool) {
+	labels := make([]string, len(ghIssue.Labels))
+	for i, l := range ghIssue.Labels {
+		labels[i] = l.GetName()
+	}
+	return config.GetStoryPoints(labels)
+}
+
+// issueEstimate returns the original estimate of the JIRA issue of a GitHub
+// issue, from its labels, with `estimate-labels`, and whether it has one.
+func issueEstimate(config cfg.Config, ghIssue TranslatedIssue) (string, bool) {
+	if !config.UsesEstimateLabels() {
+		return "", false
+	}
+	labels := make([]string, len(ghIssue.Labels))
+	for i, l := range ghIssue.Labels {
+		labels[i] = l.GetName()
+	}
+	return config.GetEstimate(labels)
+}
+
+// issueType returns the JIRA issue type of the JIRA issue of a GitHub issue,
+// mapped from its native type or its labels by `issue-types`. Types which
+// the JIRA project doesn't have are replaced by the default one.
+func issueType(config cfg.Config, log *logrus.Entry, ghIssue TranslatedIssue, ghClient clients.GitHubClient) string {
+	if !config.UsesIssueTypes() {
+		return cfg.DefaultIssueType
+	}
+
+	ghType, err := ghClient.GetIssueType(ghIssue.GetNumber())
+	if err != nil {
+		log.Errorf("Error retrieving the type of GitHub issue #%d; mapping its labels only. Error: %v", ghIssue.GetNumber(), err)
+	}
+	labels := make([]string, len(ghIssue.Labels))
+	for i, l := range ghIssue.Labels {
+		labels[i] = l.GetName()
+	}
+	name := config.GetIssueType(ghType, labels)
+
+	project := config.GetProject(ghClient.GetRepo())
+	if len(project.IssueTypes) == 0 {
+		return name
+	}
+	for _, t := range project.IssueTypes {
+		if strings.EqualFold(t.Name, name) {
+			return t.Name
+		}
+	}
+	log.Warnf("JIRA project %s has no issue type %s; creating a %s for #%d", project.Key, name, cfg.DefaultIssueType, ghIssue.GetNumber())
+	return cfg.DefaultIssueType
+}
+
+// isUnchanged reports whether the GitHub issue is the same as when it
+// was last synchronized, according to the state: it has the same content,
+// and it hasn't been updated (e.g. commented on) since. No issue is
+// unchanged during a resync.
+func isUnchanged(config cfg.Config, ghIssue TranslatedIssue) bool {
+	if config.IsResync() {
+		return false
+	}
+	state, ok := config.GetState().GetIssue(ghIssue.GetID())
+	return ok && state.Hash == issueHash(config, ghIssue) && !ghIssue.GetUpdatedAt().After(state.Synced)
+}
+
+// knownIssue returns the JIRA issue the state maps the GitHub issue to, if
+// any. If the JIRA issue can't be retrieved, the mapping is forgotten and
+// the issue has to be searched for.
+func knownIssue(config cfg.Config, ghIssue TranslatedIssue, jClient clients.JIRAClient) (jira.Issue, bool) {
+	state, ok := config.GetState().GetIssue(ghIssue.GetID())
+	if !ok {
+		return jira.Issue{}, false
+	}
+
+	jIssue, err := jClient.GetIssue(state.JIRAKey)
+	if err != nil {
+		issueLogger(config, issueRepo(ghIssue.Issue), ghIssue.GetNumber(), state.JIRAKey).
+			Debugf("JIRA issue %s could not be retrieved; searching for #%d instead.", state.JIRAKey, ghIssue.GetNumber())
+		config.GetState().DeleteIssue(ghIssue.GetID())
+		return jira.Issue{}, false
+	}
+
+	return jIssue, true
+}
+
+// recordIssue saves in the state that the GitHub issue was synchronized
+// to the JIRA issue, with the hash of what was written to it. Whether the
+// JIRA issue is flagged for a pinned GitHub issue is kept.
+func recordIssue(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, pushed string) {
+	previous, _ := config.GetState().GetIssue(ghIssue.GetID())
+	config.GetState().SetIssue(ghIssue.GetID(), cfg.IssueState{
+		JIRAKey:  jIssue.Key,
+		JIRAID:   jIssue.ID,
+		Repo:     issueRepo(ghIssue.Issue),
+		Hash:     issueHash(config, ghIssue),
+		Pushed:   pushed,
+		Synced:   time.Now(),
+		Pinned:   previous.Pinned && previous.JIRAKey == jIssue.Key,
+		Assignee: recordedAssignee(config, ghIssue, jIssue, previous),
+	})
+}
+
+// saveState writes the state to the state file, unless this is a dry run.
+func saveState(config cfg.Config) {
+	log := config.GetLogger()
+
+	if config.IsDryRun() {
+		return
+	}
+	if err := config.GetState().Save(); err != nil {
+		log.Errorf("Error saving state: %v", err)
+	}
 }
 
 // DidIssueChange tests each of the relevant fields on the provided JIRA and GitHub issue
 // and returns whether or not they differ.
 func DidIssueChange(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue) bool {
-	log := config.GetLogger()
+	log := issueLogger(config, issueRepo(ghIssue.Issue), ghIssue.GetNumber(), jIssue.Key)
 
 	log.Debugf("Comparing GitHub issue #%d and JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
 
 	anyDifferent := false
 
-	anyDifferent = anyDifferent || (ghIssue.GetTitle() != jIssue.Fields.Summary)
-	anyDifferent = anyDifferent || (ghIssue.GetTranslatedBody() != jIssue.Fields.Description)
+	// The summary, the description, and the labels are compared to what was
+	// last written to the JIRA issue, if the state records it, unless every
+	// issue is synchronized again, as the JIRA issue may have lost them.
+	state, pushed := config.GetState().GetIssue(ghIssue.GetID())
+	pushed = pushed && state.Pushed != "" && !config.IsResync()
+	if pushed {
+		anyDifferent = state.Pushed != pushedHash(ghIssue, ghIssue.GetSummary())
+	} else {
+		anyDifferent = anyDifferent || (ghIssue.GetSummary() != jIssue.Fields.Summary)
+		anyDifferent = anyDifferent || (ghIssue.GetTranslatedBody() != jIssue.Fields.Description)
+	}
 
-	key := config.GetFieldKey(cfg.GitHubStatus)
-	field, err := jIssue.Fields.Unknowns.String(key)
-	if err != nil || *ghIssue.State != field {
-		anyDifferent = true
+	// With native status, the state is synced by transitions rather than as
+	// a field.
+	if !config.UseNativeStatus() {
+		key := config.GetFieldKey(cfg.GitHubStatus)
+		field, err := jIssue.Fields.Unknowns.String(key)
+		if err != nil || *ghIssue.State != field {
+			anyDifferent = true
+		}
 	}
 
-	key = config.GetFieldKey(cfg.GitHubReporter)
-	field, err = jIssue.Fields.Unknowns.String(key)
+	key := config.GetFieldKey(cfg.GitHubReporter)
+	field, err := jIssue.Fields.Unknowns.String(key)
 	if err != nil || *ghIssue.User.Login != field {
 		anyDifferent = true
 	}
@@ -97,13 +435,53 @@ func DidIssueChange(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issu
 		labels[i] = *l.Name
 	}
 
-	key = config.GetFieldKey(cfg.GitHubLabels)
-	field, err = jIssue.Fields.Unknowns.String(key)
-	if err != nil && strings.Join(labels, ",") != field {
-		anyDifferent = true
+	if config.GetFieldID(cfg.GitHubLabels) != "" && !pushed {
+		key = config.GetFieldKey(cfg.GitHubLabels)
+		field, err = jIssue.Fields.Unknowns.String(key)
+		if err != nil && strings.Join(labels, ",") != field {
+			anyDifferent = true
+		}
 	}
 
-	log.Debugf("Issues have any differences: %b", anyDifferent)
+	if config.SyncsReactions() {
+		// Numbers are decoded as floats.
+		votes, ok := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubVotes)].(float64)
+		anyDifferent = anyDifferent || !ok || int(votes) != thumbsUp(ghIssue)
+	}
+
+	// The story points are left alone when no label has any, so that those
+	// estimated in JIRA are kept.
+	if points, ok := storyPoints(config, ghIssue); ok {
+		current, set := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.StoryPoints)].(float64)
+		anyDifferent = anyDifferent || !set || current != points
+	}
+
+	for id, value := range formValues(config, log, ghIssue) {
+		anyDifferent = anyDifferent || formValueString(value) != formValueString(jIssue.Fields.Unknowns[id])
+	}
+
+	if config.UsesComponents() {
+		repo, _ := configuredRepo(config, issueRepo(ghIssue.Issue))
+		anyDifferent = anyDifferent || componentsDiffer(issueComponents(config, log, repo, ghIssue), jIssue.Fields.Components)
+	}
+
+	anyDifferent = anyDifferent || assigneeChanged(config, ghIssue, jIssue) || assigneeListChanged(config, ghIssue, jIssue)
+
+	if config.UseNativeLabels() && !pushed {
+		anyDifferent = anyDifferent || labelsDiffer(mergeLabels(config, ghIssue.Labels, jIssue.Fields.Labels), jIssue.Fields.Labels)
+	}
+
+	if m := ghIssue.Milestone; config.UseMilestoneVersions() && m != nil {
+		found := false
+		for _, v := range jIssue.Fields.FixVersions {
+			if v.Name == m.GetTitle() {
+				found = true
+			}
+		}
+		anyDifferent = anyDifferent || !found
+	}
+
+	log.Debugf("Issues have any differences: %t", anyDifferent)
 
 	return anyDifferent
 }
@@ -111,27 +489,124 @@ func DidIssueChange(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issu
 // UpdateIssue compares each field of a GitHub issue to a JIRA issue; if any of them
 // differ, the differing fields of the JIRA issue are updated to match the GitHub
 // issue.
-func UpdateIssue(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
-	log := config.GetLogger()
+func UpdateIssue(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (err error) {
+	log := issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key)
+
+	outcome := OutcomeSkipped
+	conflictDecision := ""
+	defer func() {
+		recordConflictOutcome(config, ghClient.GetRepo(), ghIssue, jIssue.Key, outcome, conflictDecision, err)
+	}()
 
 	log.Debugf("Updating JIRA %s with GitHub #%d", jIssue.Key, *ghIssue.Number)
 
 	var issue jira.Issue
 
-	if DidIssueChange(config, ghIssue, jIssue) {
+	// The version is synced even if the issue didn't change, as the
+	// description of the milestone may have.
+	versions, err := milestoneVersions(config, ghIssue, jClient)
+	if err != nil {
+		return err
+	}
+
+	// The epic is compared apart from the other fields, as its key is only
+	// known once it's synced; the issue is removed from its epic when it
+	// leaves its milestone, or its parent issue.
+	epic, pending, err := issueEpic(config, ghIssue, ghClient, jClient)
+	if err != nil {
+		return err
+	}
+	epicChanged := config.SetsEpics() && epic != clients.GetEpic(config, *jIssue.Fields)
+
+	// The references are rewritten, and the images are rehosted, before the
+	// issues are compared, as the JIRA description references their JIRA
+	// issues and attachments. The state still records the hash of the issue
+	// as translated, which is what later runs compare.
+	refs := newReferenceResolver(config, ghClient, jClient)
+	synced := ghIssue
+	if config.UseReferenceKeys() {
+		body := refs.rewrite(synced.GetTranslatedBody())
+		synced.TranslatedBody = &body
+	}
+	if config.RehostImages() {
+		body := rehostImages(config, synced, jIssue, ghClient, jClient)
+		synced.TranslatedBody = &body
+	}
+
+	// Conflicts are only looked for if the issues differ, as otherwise
+	// neither side's changes are lost.
+	changed := DidIssueChange(config, synced, jIssue) || epicChanged
+	decision := cfg.ConflictGitHubWins
+	if changed {
+		var conflicted bool
+		decision, conflicted = resolveConflict(config, ghIssue, jIssue)
+		if conflicted {
+			log.Warnf("GitHub issue #%d and JIRA issue %s were both changed since they were last synchronized; resolving with %s", ghIssue.GetNumber(), jIssue.Key, decision)
+			recordConflict(conflict{ghClient.GetRepo(), ghIssue.GetNumber(), jIssue.Key, decision})
+			conflictDecision = decision
+		}
+	}
+	if decision == cfg.ConflictManual {
+		return flagConflict(config, ghIssue, jIssue, ghClient, jClient)
+	}
+
+	if changed {
+		outcome = OutcomeUpdated
+	}
+
+	if decision == cfg.ConflictJIRAWins {
+		// The summary, the status, and the description of the JIRA issue
+		// are copied to GitHub instead, and the rest of its changes are kept.
+		if err := UpdateGitHubIssue(config, jIssue, []string{"summary", "status", "description"}, ghClient); err != nil {
+			log.Errorf("Error updating GitHub issue #%d from JIRA issue %s. Error: %v", ghIssue.GetNumber(), jIssue.Key, err)
+		}
+	} else if changed {
 		fields := jira.IssueFields{}
 		fields.Unknowns = map[string]interface{}{}
 
-		fields.Summary = ghIssue.GetTitle()
-		fields.Description = ghIssue.GetTranslatedBody()
-		fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = ghIssue.GetState()
+		fields.Summary = ghIssue.GetSummary()
+		fields.FixVersions = versions
+		if config.SetsEpics() {
+			clients.SetEpic(config, &fields, epic)
+		}
+		fields.Description = synced.GetTranslatedBody()
+		if !config.UseNativeStatus() {
+			fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = ghIssue.GetState()
+		}
 		fields.Unknowns[config.GetFieldKey(cfg.GitHubReporter)] = ghIssue.User.GetLogin()
+		if config.SyncsReactions() {
+			fields.Unknowns[config.GetFieldKey(cfg.GitHubVotes)] = thumbsUp(ghIssue)
+		}
+		if points, ok := storyPoints(config, ghIssue); ok {
+			fields.Unknowns[config.GetFieldKey(cfg.StoryPoints)] = points
+		}
+		for id, value := range formValues(config, log, ghIssue) {
+			fields.Unknowns[id] = value
+		}
 
 		labels := make([]string, len(ghIssue.Labels))
 		for i, l := range ghIssue.Labels {
 			labels[i] = l.GetName()
 		}
-		fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(labels, ",")
+		if config.GetFieldID(cfg.GitHubLabels) != "" {
+			fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(labels, ",")
+		}
+		if config.UseNativeLabels() {
+			// The labels are set as an unknown field, so that they're sent
+			// even if none is left.
+			fields.Unknowns["labels"] = mergeLabels(config, ghIssue.Labels, jIssue.Fields.Labels)
+		}
+		if config.UsesComponents() {
+			// As the labels, the components are sent even if none is left.
+			fields.Unknowns["components"] = issueComponents(config, log, ghClient.GetRepo(), ghIssue)
+		}
+		if user, ok := issueAssignee(config, ghIssue); ok {
+			// A nil assignee is sent, which unassigns the issue.
+			fields.Unknowns["assignee"] = assigneeValue(config, user)
+		}
+		if list, ok := assigneeList(config, ghIssue); ok {
+			fields.Unknowns[config.GetFieldKey(cfg.AssigneesField)] = list
+		}
 
 		// https://developer.atlassian.com/jiradev/jira-apis/about-the-jira-rest-apis/jira-rest-api-tutorials/jira-rest-api-example-create-issue
 		// DateTime has the format 2011-10-19T10:29:29.908+1100
@@ -145,7 +620,6 @@ func UpdateIssue(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue,
 			ID:     jIssue.ID,
 		}
 
-		var err error
 		issue, err = jClient.UpdateIssue(issue)
 		if err != nil {
 			return err
@@ -156,59 +630,176 @@ func UpdateIssue(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue,
 		log.Debugf("JIRA issue %s is already up to date!", jIssue.Key)
 	}
 
-	issue, err := jClient.GetIssue(jIssue.Key)
+	issue, err = jClient.GetIssue(jIssue.Key)
 	if err != nil {
 		log.Debugf("Failed to retrieve JIRA issue %s!", jIssue.Key)
 		return err
 	}
 
+	if config.UseRemoteLinks() {
+		if err := jClient.SyncRemoteLink(issue, ghClient.GetRepo(), ghIssue.Issue); err != nil {
+			log.Errorf("Error linking JIRA issue %s to GitHub issue #%d. Error: %v", issue.Key, ghIssue.GetNumber(), err)
+		}
+	}
+
+	if config.UseReferenceLinks() {
+		refs.link(issue, ghIssue.GetBody())
+	}
+
+	parent, linkPending, err := syncParentLink(config, ghIssue, issue, ghClient, jClient)
+	if err != nil {
+		log.Errorf("Error linking JIRA issue %s to the JIRA issue of its parent. Error: %v", issue.Key, err)
+	}
+
+	if decision != cfg.ConflictJIRAWins {
+		mapped, err := syncProjectStatus(config, ghIssue, issue, ghClient, jClient)
+		if err != nil {
+			log.Errorf("Error syncing the project status of JIRA issue %s. Error: %v", issue.Key, err)
+		}
+		if !mapped {
+			if err := TransitionIssue(config, ghIssue, ghClient, issue, jClient); err != nil {
+				log.Errorf("Error transitioning JIRA issue %s. Error: %v", issue.Key, err)
+			}
+		}
+	}
+
+	if config.UseTaskSubtasks() {
+		if err := syncSubtasks(config, ghIssue, issue, jClient); err != nil {
+			log.Errorf("Error syncing the sub-tasks of JIRA issue %s. Error: %v", issue.Key, err)
+		}
+	}
+
 	if err := CompareComments(config, ghIssue.Issue, issue, ghClient, jClient); err != nil {
 		return err
 	}
 
+	// When JIRA wins, its summary is what's now written on both sides.
+	summary := ghIssue.GetSummary()
+	if decision == cfg.ConflictJIRAWins {
+		summary = jIssue.Fields.Summary
+	}
+	recordIssue(config, ghIssue, issue, pushedHash(synced, summary))
+	recordParent(config, ghIssue, parent, pending || linkPending)
+
 	return nil
 }
 
 // CreateIssue generates a JIRA issue from the various fields on the given GitHub issue, then
 // sends it to the JIRA API.
 func CreateIssue(config cfg.Config, issue TranslatedIssue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
-	log := config.GetLogger()
+	jIssue, synced, refs, err := newJIRAIssue(config, issue, ghClient, jClient)
+	if err != nil {
+		recordOutcome(config, ghClient.GetRepo(), issue, "", OutcomeFailed, err)
+		return err
+	}
+
+	jIssue, err = jClient.CreateIssue(jIssue)
+	if err != nil {
+		recordOutcome(config, ghClient.GetRepo(), issue, "", OutcomeFailed, err)
+		return err
+	}
+
+	return syncCreatedIssue(config, issue, synced, refs, jIssue, ghClient, jClient)
+}
+
+// newJIRAIssue returns the JIRA issue to create from a GitHub issue, along
+// with the GitHub issue as it's synced to it, and the resolver of its
+// references.
+func newJIRAIssue(config cfg.Config, issue TranslatedIssue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (jira.Issue, TranslatedIssue, *referenceResolver, error) {
+	log := issueLogger(config, ghClient.GetRepo(), issue.GetNumber(), "")
 
 	log.Debugf("Creating JIRA issue based on GitHub issue #%d", *issue.Number)
 
 	fields := jira.IssueFields{
 		Type: jira.IssueType{
-			Name: "Task", // TODO: Determine issue type
+			Name: issueType(config, log, issue, ghClient),
 		},
 		Project:     config.GetProject(ghClient.GetRepo()),
-		Summary:     issue.GetTitle(),
+		Summary:     issue.GetSummary(),
 		Description: issue.GetTranslatedBody(),
 		Unknowns:    map[string]interface{}{},
 	}
 
+	// As when the issue is updated, the state records the hash of the issue
+	// as translated, without its references rewritten.
+	refs := newReferenceResolver(config, ghClient, jClient)
+	synced := issue
+	if config.UseReferenceKeys() {
+		body := refs.rewrite(synced.GetTranslatedBody())
+		synced.TranslatedBody = &body
+		fields.Description = body
+	}
+
+	versions, err := milestoneVersions(config, issue, jClient)
+	if err != nil {
+		return jira.Issue{}, synced, nil, err
+	}
+	fields.FixVersions = versions
+
+	epic, pending, err := issueEpic(config, issue, ghClient, jClient)
+	if err != nil {
+		return jira.Issue{}, synced, nil, err
+	}
+	synced.parentPending = pending
+	if epic != "" {
+		clients.SetEpic(config, &fields, epic)
+	}
+
 	fields.Unknowns[config.GetFieldKey(cfg.GitHubID)] = issue.GetID()
 	fields.Unknowns[config.GetFieldKey(cfg.GitHubNumber)] = issue.GetNumber()
-	fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = issue.GetState()
+	if !config.UseNativeStatus() {
+		fields.Unknowns[config.GetFieldKey(cfg.GitHubStatus)] = issue.GetState()
+	}
 	fields.Unknowns[config.GetFieldKey(cfg.GitHubReporter)] = issue.User.GetLogin()
+	if config.SyncsReactions() {
+		fields.Unknowns[config.GetFieldKey(cfg.GitHubVotes)] = thumbsUp(issue)
+	}
+	if points, ok := storyPoints(config, issue); ok {
+		fields.Unknowns[config.GetFieldKey(cfg.StoryPoints)] = points
+	}
+	for id, value := range formValues(config, issueLogger(config, issueRepo(issue.Issue), issue.GetNumber(), ""), issue) {
+		fields.Unknowns[id] = value
+	}
 
 	strs := make([]string, len(issue.Labels))
 	for i, v := range issue.Labels {
 		strs[i] = *v.Name
 	}
-	fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(strs, ",")
+	if config.GetFieldID(cfg.GitHubLabels) != "" {
+		fields.Unknowns[config.GetFieldKey(cfg.GitHubLabels)] = strings.Join(strs, ",")
+	}
+	if config.UseNativeLabels() {
+		fields.Labels = mergeLabels(config, issue.Labels, nil)
+	}
+	if config.UsesComponents() {
+		fields.Components = issueComponents(config, log, ghClient.GetRepo(), issue)
+	}
+	if user, ok := issueAssignee(config, issue); ok && user != "" {
+		fields.Unknowns["assignee"] = assigneeValue(config, user)
+	}
+	if list, ok := assigneeList(config, issue); ok && len(list) > 0 {
+		fields.Unknowns[config.GetFieldKey(cfg.AssigneesField)] = list
+	}
+	if estimate, ok := issueEstimate(config, issue); ok {
+		fields.TimeTracking = &jira.TimeTracking{OriginalEstimate: estimate}
+	}
 
 	// https://developer.atlassian.com/jiradev/jira-apis/about-the-jira-rest-apis/jira-rest-api-tutorials/jira-rest-api-example-create-issue
 	// DateTime has the format 2011-10-19T10:29:29.908+1100
 	fields.Unknowns[config.GetFieldKey(cfg.LastISUpdate)] = time.Now().UTC().Format(dateFormat)
 
-	jIssue := jira.Issue{
-		Fields: &fields,
-	}
+	return jira.Issue{Fields: &fields}, synced, refs, nil
+}
 
-	jIssue, err := jClient.CreateIssue(jIssue)
-	if err != nil {
-		return err
-	}
+// syncCreatedIssue completes the synchronization of a GitHub issue to the
+// JIRA issue created from it: what can only be synced once the JIRA issue
+// exists, such as its links, its status, and the comments.
+func syncCreatedIssue(config cfg.Config, issue, synced TranslatedIssue, refs *referenceResolver, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (err error) {
+	log := issueLogger(config, ghClient.GetRepo(), issue.GetNumber(), "")
+
+	defer func() {
+		recordOutcome(config, ghClient.GetRepo(), issue, jIssue.Key, OutcomeCreated, err)
+	}()
 
 	// If the Issue was not created (for ex. when using dry run), returns now
 	if jIssue.Key == "" {
@@ -220,24 +811,324 @@ func CreateIssue(config cfg.Config, issue TranslatedIssue, ghClient clients.GitH
 		return err
 	}
 
+	log = log.WithField("jira-key", jIssue.Key)
 	log.Debugf("Created JIRA issue %s!", jIssue.Key)
 
+	if config.UseRemoteLinks() {
+		if err := jClient.SyncRemoteLink(jIssue, ghClient.GetRepo(), issue.Issue); err != nil {
+			log.Errorf("Error linking JIRA issue %s to GitHub issue #%d. Error: %v", jIssue.Key, issue.GetNumber(), err)
+		}
+	}
+
+	if config.UseReferenceLinks() {
+		refs.link(jIssue, issue.GetBody())
+	}
+
+	parent, pending, err := syncParentLink(config, issue, jIssue, ghClient, jClient)
+	if err != nil {
+		log.Errorf("Error linking JIRA issue %s to the JIRA issue of its parent. Error: %v", jIssue.Key, err)
+	}
+
+	// The images can only be attached once the issue exists, so the
+	// description is updated to reference them afterwards.
+	if config.RehostImages() {
+		if body := rehostImages(config, synced, jIssue, ghClient, jClient); body != jIssue.Fields.Description {
+			_, err = jClient.UpdateIssue(jira.Issue{
+				Fields: &jira.IssueFields{
+					Type:        jIssue.Fields.Type,
+					Description: body,
+				},
+				Key: jIssue.Key,
+				ID:  jIssue.ID,
+			})
+			if err != nil {
+				log.Errorf("Error referencing the attached images in JIRA issue %s. Error: %v", jIssue.Key, err)
+			} else {
+				synced.TranslatedBody = &body
+			}
+		}
+	}
+
+	mapped, err := syncProjectStatus(config, issue, jIssue, ghClient, jClient)
+	if err != nil {
+		log.Errorf("Error syncing the project status of JIRA issue %s. Error: %v", jIssue.Key, err)
+	}
+	if !mapped {
+		if err := TransitionIssue(config, issue, ghClient, jIssue, jClient); err != nil {
+			log.Errorf("Error transitioning JIRA issue %s. Error: %v", jIssue.Key, err)
+		}
+	}
+
+	if config.UseTaskSubtasks() {
+		if err := syncSubtasks(config, issue, jIssue, jClient); err != nil {
+			log.Errorf("Error syncing the sub-tasks of JIRA issue %s. Error: %v", jIssue.Key, err)
+		}
+	}
+
 	if err := CompareComments(config, issue.Issue, jIssue, ghClient, jClient); err != nil {
 		return err
 	}
 
+	recordIssue(config, issue, jIssue, pushedHash(synced, issue.GetSummary()))
+	recordParent(config, issue, parent, pending || synced.parentPending)
+
 	return nil
 }
 
-type TranslatedIssue struct {
+// milestoneVersions returns the JIRA fix versions for the milestone of the
+// GitHub issue, if milestones are mapped to versions. The description of the
+// version is kept in sync with the (translated) description of the milestone.
+func milestoneVersions(config cfg.Config, ghIssue TranslatedIssue, jClient clients.JIRAClient) ([]*jira.FixVersion, error) {
+	m := ghIssue.Milestone
+	if !config.UseMilestoneVersions() || m == nil {
+		return nil, nil
+	}
+
+	log := config.GetLogger()
+	description := translateBody(config, log.WithField("milestone", m.GetTitle()), m.GetDescription())
+
+	version, err := jClient.SyncVersion(m.GetTitle(), description)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*jira.FixVersion{&version}, nil
+}
+
+// milestoneEpic returns the key of the JIRA epic of the milestone of the
+// GitHub issue, if milestones are mapped to epics, or an empty key if the
+// issue isn't in any milestone. Like the description of a version, the
+// description of the epic is kept in sync with that of the milestone.
+func milestoneEpic(config cfg.Config, ghIssue TranslatedIssue, jClient clients.JIRAClient) (string, error) {
+	m := ghIssue.Milestone
+	if !config.UseMilestoneEpics() || m == nil {
+		return "", nil
+	}
 
+	log := config.GetLogger()
+	description := translateBody(config, log.WithField("milestone", m.GetTitle()), m.GetDescription())
+
+	return jClient.SyncEpic(m.GetTitle(), description)
+}
+
+// TransitionIssue moves the JIRA issue to the transition or status configured
+// for the state of the GitHub issue, if there is one. With native status,
+// states without one move the issue to a status in the "Done" category if
+// the GitHub issue is closed, and out of it if it's open. Closed issues get
+// the resolution of the reason they were closed for, with `resolutions`.
+func TransitionIssue(config cfg.Config, ghIssue TranslatedIssue, ghClient clients.GitHubClient, jIssue jira.Issue, jClient clients.JIRAClient) error {
+	target, ok := config.GetTransition(ghIssue.GetState())
+
+	// The reason is only looked up when the JIRA issue isn't done yet, as
+	// the resolution is only set by the transition.
+	if (ok || config.UseNativeStatus()) && !clients.IsDone(jIssue.Fields.Status) {
+		if resolution, found := closeResolution(config, ghIssue, ghClient); found {
+			return jClient.ResolveIssue(jIssue, target, resolution)
+		}
+	}
+
+	if !ok {
+		if config.UseNativeStatus() {
+			return jClient.TransitionIssueCategory(jIssue, ghIssue.GetState() == "closed")
+		}
+		return nil
+	}
+
+	return jClient.TransitionIssue(jIssue, target)
+}
+
+// closeResolution returns the JIRA resolution of a closed GitHub issue,
+// mapped by `resolutions` from the reason it was closed for, and whether
+// it has one.
+func closeResolution(config cfg.Config, ghIssue TranslatedIssue, ghClient clients.GitHubClient) (string, bool) {
+	if !config.UsesResolutions() || ghIssue.GetState() != "closed" {
+		return "", false
+	}
+
+	reason, err := ghClient.GetStateReason(ghIssue.GetNumber())
+	if err != nil {
+		issueLogger(config, ghClient.GetRepo(), ghIssue.GetNumber(), "").
+			Errorf("Error retrieving the state reason of GitHub issue #%d; not setting its resolution. Error: %v", ghIssue.GetNumber(), err)
+		return "", false
+	}
+	return config.GetResolution(reason)
+}
+
+// UpdateGitHubIssue reflects the changes made by a JIRA user onto the GitHub
+// issue the JIRA issue was created from. Only the summary, the status, and
+// the description (see githubBody) are copied, and only if they are listed
+// in `changed`; fields are only written if they differ, so that updates made
+// by issue-sync itself don't loop back.
+// If the GitHub issue in the repo of `ghClient` doesn't have the GitHub ID
+// recorded on the JIRA issue, nothing is done.
+func UpdateGitHubIssue(config cfg.Config, jIssue jira.Issue, changed []string, ghClient clients.GitHubClient) error {
+	id, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubID))
+	if err != nil {
+		return fmt.Errorf("JIRA issue %s has no GitHub ID", jIssue.Key)
+	}
+	number, err := jIssue.Fields.Unknowns.Int(config.GetFieldKey(cfg.GitHubNumber))
+	if err != nil {
+		return fmt.Errorf("JIRA issue %s has no GitHub number", jIssue.Key)
+	}
+	log := issueLogger(config, ghClient.GetRepo(), int(number), jIssue.Key)
+
+	ghIssue, err := ghClient.GetIssue(int(number))
+	if err != nil {
+		return err
+	}
+	if int64(ghIssue.GetID()) != id {
+		log.Debugf("GitHub issue %s#%d is not linked to JIRA issue %s", ghClient.GetRepo(), number, jIssue.Key)
+		return nil
+	}
+
+	req := github.IssueRequest{}
+	anyDifferent := false
+
+	for _, field := range changed {
+		switch field {
+		case "summary":
+			if jIssue.Fields.Summary == "" {
+				continue
+			}
+			// The title is copied without what the summary template adds.
+			title := config.SummaryTitle(issueData(ghClient.GetRepo(), ghIssue, ""), jIssue.Fields.Summary)
+			if title != ghIssue.GetTitle() {
+				req.Title = &title
+				anyDifferent = true
+			}
+		case "status":
+			if jIssue.Fields.Status == nil {
+				continue
+			}
+			state := "open"
+			if clients.IsDone(jIssue.Fields.Status) {
+				state = "closed"
+			}
+			if state != ghIssue.GetState() {
+				req.State = &state
+				anyDifferent = true
+			}
+		case "description":
+			body, ok := githubBody(config, log, ghClient.GetRepo(), ghIssue, jIssue.Fields.Description)
+			if ok && body != ghIssue.GetBody() {
+				req.Body = &body
+				anyDifferent = true
+			}
+		}
+	}
+
+	if !anyDifferent {
+		log.Debugf("GitHub issue #%d is already up to date with JIRA issue %s", number, jIssue.Key)
+		return nil
+	}
+
+	if _, err := ghClient.EditIssue(int(number), req); err != nil {
+		return err
+	}
+
+	log.Debugf("Updated GitHub issue #%d from JIRA issue %s", number, jIssue.Key)
+
+	return nil
+}
+
+// githubBody returns the GitHub body a JIRA description edited in JIRA
+// translates to (see TranslateJiraBody), and whether it can be copied to the
+// GitHub issue. It can't if the description doesn't match the
+// `description-template`, if it was truncated, if the GitHub body has
+// sections of `issue-form-fields`, which aren't in the description, or if
+// the description is still what the GitHub body translates to, so that
+// bodies which don't translate back exactly aren't rewritten.
+func githubBody(config cfg.Config, log *logrus.Entry, repo string, ghIssue github.Issue, description string) (string, bool) {
+	body := ghIssue.GetBody()
+	if formBody(config, body) != body {
+		return "", false
+	}
+
+	description = strings.Replace(description, "\r\n", "\n", -1)
+	jBody, ok := config.DescriptionBody(issueData(repo, ghIssue, ""), description)
+	if !ok {
+		log.Debugf("Description of the JIRA issue doesn't match the description-template; not copying it")
+		return "", false
+	}
+	if _, truncated := cfg.TrimTruncationNotice(jBody); truncated {
+		return "", false
+	}
+	if jBody == translateBody(config, log, body) {
+		return "", false
+	}
+	return TranslateJiraBody(config, jBody), true
+}
+
+type TranslatedIssue struct {
 	github.Issue
 	TranslatedBody *string
+	// Summary is the summary of the JIRA issue, rendered with the
+	// `summary-template`.
+	Summary string
+
+	// parentPending is whether the parent issue of the GitHub issue wasn't
+	// synced yet when its JIRA issue was created, without its epic.
+	parentPending bool
+}
+
+// maxSummaryLength is the maximum length of the summary of JIRA issues.
+const maxSummaryLength = 255
+
+// issueData returns what the summary and the description of the JIRA issue
+// of a GitHub issue of the repo are rendered from, given its translated body.
+func issueData(repo string, issue github.Issue, body string) cfg.IssueData {
+	labels := make([]string, len(issue.Labels))
+	for i, l := range issue.Labels {
+		labels[i] = l.GetName()
+	}
+
+	return cfg.IssueData{
+		Repo:      repo,
+		Number:    issue.GetNumber(),
+		Title:     issue.GetTitle(),
+		Body:      body,
+		URL:       issue.GetHTMLURL(),
+		State:     issue.GetState(),
+		Labels:    labels,
+		Milestone: issue.Milestone.GetTitle(),
+		Created:   issue.GetCreatedAt(),
+		Updated:   issue.GetUpdatedAt(),
+		User: cfg.GitHubUser{
+			Login:     issue.User.GetLogin(),
+			Name:      issue.User.GetName(),
+			URL:       issue.User.GetHTMLURL(),
+			AvatarURL: issue.User.GetAvatarURL(),
+		},
+	}
 }
 
-func NewTranslatedIssue(issue github.Issue) TranslatedIssue {
-	body := GitHubToJiraBody(*(issue.Body))
-	return TranslatedIssue{issue, &body}
+// NewTranslatedIssue translates the body of a GitHub issue of the repo to
+// JIRA markup, and renders the summary and the description of its JIRA
+// issue. Suspicious translations are logged with the issue reference, as are
+// templates which fail to render, in which case the title and the body are
+// used as they are. Descriptions too long for JIRA are truncated, with a
+// link to the rest on GitHub.
+func NewTranslatedIssue(config cfg.Config, repo string, issue github.Issue) TranslatedIssue {
+	log := issueLogger(config, repo, issue.GetNumber(), "")
+	data := issueData(repo, issue, translateBody(config, log, formBody(config, issue.GetBody())))
+
+	summary, err := config.RenderSummary(data)
+	if err != nil {
+		log.Errorf("Error rendering the summary of #%d; using its title. Error: %v", issue.GetNumber(), err)
+		summary = data.Title
+	}
+	if r := []rune(summary); len(r) > maxSummaryLength {
+		summary = string(r[:maxSummaryLength])
+	}
+
+	body, err := config.RenderDescription(data)
+	if err != nil {
+		log.Errorf("Error rendering the description of #%d; using its body. Error: %v", issue.GetNumber(), err)
+		body = data.Body
+	}
+	body = config.TruncateJIRAText(body, issue.GetHTMLURL())
+
+	return TranslatedIssue{Issue: issue, TranslatedBody: &body, Summary: summary}
 }
 
 func (i *TranslatedIssue) GetTranslatedBody() string {
@@ -247,6 +1138,14 @@ func (i *TranslatedIssue) GetTranslatedBody() string {
 	return *i.TranslatedBody
 }
 
+// GetSummary returns the summary of the JIRA issue of the GitHub issue.
+func (i *TranslatedIssue) GetSummary() string {
+	if i == nil {
+		return ""
+	}
+	return i.Summary
+}
+
 // Headings
 var regexH6 = regexp.MustCompile(`(?m)^###### (.*)$`)
 var regexH5 = regexp.MustCompile(`(?m)^##### (.*)$`)
@@ -256,46 +1155,67 @@ var regexH2 = regexp.MustCompile(`(?m)^## (.*)$`)
 var regexH1 = regexp.MustCompile(`(?m)^# (.*)$`)
 
 // Text Effects
-var regexStrong1 = regexp.MustCompile(`(?U)\*\*([\s\S]*)\*\*`)			// **strong**
-var regexStrong2 = regexp.MustCompile(`(?U)__([\s\S]*)__`)				// __strong__
-var regexEmphasis1 = regexp.MustCompile(`(?U)\*([\s\S]*)\*`)			// *emphasis*
-var regexEmphasis2 = regexp.MustCompile(`(?U)_([\s\S]*)_`)				// _emphasis_
-var regexCitation = regexp.MustCompile(`(?U)<cite>([\s\S]*)<cite>`)		// <cite>citation<cite>
-var regexDeleted = regexp.MustCompile(`(?U)~~([\s\S])~~`)				// ~~deleted~~
-var regexInserted = regexp.MustCompile(`(?U)<ins>([\s\S]*)<ins>`)		// <ins>insertion<ins>
-var regexSuperscript = regexp.MustCompile(`(?U)<sup>([\s\S]*)<sup>`)	// <sup>superscript<sup>
-var regexSubscript = regexp.MustCompile(`(?U)<sub>([\s\S]*)<sub>`)		// <sub>subscript<sub>
-var regexMonospaced = regexp.MustCompile("(?U)`([\\s\\S]*)`")			// `monospaced`
-var regexQuote = regexp.MustCompile(`(?m)^>\s+(.*)$`)					// > quote
+var regexStrong1 = regexp.MustCompile(`(?U)\*\*([\s\S]*)\*\*`)       // **strong**
+var regexStrong2 = regexp.MustCompile(`(?U)__([\s\S]*)__`)           // __strong__
+var regexEmphasis1 = regexp.MustCompile(`(?U)\*([\s\S]*)\*`)         // *emphasis*
+var regexEmphasis2 = regexp.MustCompile(`(?U)_([\s\S]*)_`)           // _emphasis_
+var regexCitation = regexp.MustCompile(`(?U)<cite>([\s\S]*)<cite>`)  // <cite>citation<cite>
+var regexDeleted = regexp.MustCompile(`(?U)~~([\s\S]*)~~`)           // ~~deleted~~
+var regexInserted = regexp.MustCompile(`(?U)<ins>([\s\S]*)<ins>`)    // <ins>insertion<ins>
+var regexSuperscript = regexp.MustCompile(`(?U)<sup>([\s\S]*)<sup>`) // <sup>superscript<sup>
+var regexSubscript = regexp.MustCompile(`(?U)<sub>([\s\S]*)<sub>`)   // <sub>subscript<sub>
+var regexMonospaced = regexp.MustCompile("(?U)`([\\s\\S]*)`")        // `monospaced`
+var regexQuote = regexp.MustCompile(`(?m)^>\s+(.*)$`)                // > quote
 
 // Links
-var regexImage = regexp.MustCompile(`(?U)!\[(.*)\]\((.*)\)`)			// ![alt](url)
-var regexURL = regexp.MustCompile(`(?U)<(.*)>`)							// <url>
-var regexAltURL = regexp.MustCompile(`(?U)\[(.*)\]\((.*)\)`)			// [alt](url)
-
-// Advanced Formatting
-var regexCode = regexp.MustCompile("(?mU)^\\`\\`\\`(\\w+)$\\n([\\s\\S]*)\\n^\\`\\`\\`")
-var regexNoFormat = regexp.MustCompile("(?mU)^\\`\\`\\`$\\n([\\s\\S]*)\\n^\\`\\`\\`")
-
-// TODO: Tables
+var regexImage = regexp.MustCompile(`(?U)!\[(.*)\]\((.*)\)`) // ![alt](url)
+var regexURL = regexp.MustCompile(`(?U)<(.*)>`)              // <url>
+var regexAltURL = regexp.MustCompile(`(?U)\[(.*)\]\((.*)\)`) // [alt](url)
+
+// strongPlaceholder stands for the asterisks of JIRA strong text while the
+// emphasis of a body is translated.
+const strongPlaceholder = "\x03"
+
+// imageAlt returns the alternate text of an image as a JIRA image option,
+// which can't contain the commas separating options or the exclamation
+// marks closing images.
+func imageAlt(alt string) string {
+	return strings.NewReplacer(",", " ", "!", "").Replace(alt)
+}
 
 // JIRA and GitHub (Markdown) have different markups. Translate from GitHub (Markdown) to JIRA.
 // See https://jira.atlassian.com/secure/WikiRendererHelpAction.jspa?section=all
 func GitHubToJiraBody(body string) string {
 
+	// Code blocks are translated before anything else, so that their content
+	// isn't, and then HTML fragments, as the Markdown links would take their
+	// tags for URLs. What they're translated to is set aside, so that it isn't
+	// translated any further.
+	var a asides
+	body = translateCodeBlocks(body, &a)
+	body = translateHTML(body, &a)
+
+	// Tables and task lists are translated first, so that their delimiter
+	// rows and checkboxes are recognized before any other markup is.
+	body = translateTables(body)
+	body = translateTaskLists(body)
+
 	// Headings
 	body = regexH6.ReplaceAllString(body, "h6. $1")
 	body = regexH5.ReplaceAllString(body, "h5. $1")
-	body = regexH4.ReplaceAllString(body, "h3. $1")
+	body = regexH4.ReplaceAllString(body, "h4. $1")
 	body = regexH3.ReplaceAllString(body, "h3. $1")
 	body = regexH2.ReplaceAllString(body, "h2. $1")
 	body = regexH1.ReplaceAllString(body, "h1. $1")
 
 	// Text Effects
-	body = regexStrong1.ReplaceAllString(body, "*$1*")
-	body = regexStrong2.ReplaceAllString(body, "*$1*")
-	body = regexEmphasis1.ReplaceAllString(body, "_$1_")
-	body = regexEmphasis2.ReplaceAllString(body, "_$1_")
+	// Strong text is marked with a placeholder until emphasis is translated,
+	// as the asterisks of JIRA strong text would be taken for emphasis.
+	body = regexStrong1.ReplaceAllString(body, strongPlaceholder+"$1"+strongPlaceholder)
+	body = regexStrong2.ReplaceAllString(body, strongPlaceholder+"$1"+strongPlaceholder)
+	body = regexEmphasis1.ReplaceAllString(body, "_${1}_")
+	body = regexEmphasis2.ReplaceAllString(body, "_${1}_")
+	body = strings.Replace(body, strongPlaceholder, "*", -1)
 	body = regexCitation.ReplaceAllString(body, "??$1??")
 	body = regexDeleted.ReplaceAllString(body, "-$1-")
 	body = regexInserted.ReplaceAllString(body, "+$1+")
@@ -305,14 +1225,17 @@ func GitHubToJiraBody(body string) string {
 	body = regexQuote.ReplaceAllString(body, "bq. $1")
 
 	// Links
-	body = regexImage.ReplaceAllString(body, "!$2|width=600!")
+	body = regexImage.ReplaceAllStringFunc(body, func(s string) string {
+		m := regexImage.FindStringSubmatch(s)
+		if m[1] == "" {
+			return "!" + m[2] + "|width=600!"
+		}
+		return "!" + m[2] + "|alt=" + imageAlt(m[1]) + ",width=600!"
+	})
 	body = regexURL.ReplaceAllString(body, "[$1]")
 	body = regexAltURL.ReplaceAllString(body, "[$1|$2]")
 
-	// Advanced Formatting
-	body = regexCode.ReplaceAllString(body, `{code:$1}\n$2\n{code}`)
-	body = regexNoFormat.ReplaceAllString(body, `{noformat}\n$2\n{noformat}`)
+	body = restoreListMarkers(a.restore(body))
 
 	return body
 }
-
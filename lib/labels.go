@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/google/go-github/github"
+)
+
+// labelSpaceRegex matches the whitespace of GitHub labels, which JIRA labels
+// can't contain.
+var labelSpaceRegex = regexp.MustCompile(`\s+`)
+
+// jiraLabel returns the JIRA label mirroring a GitHub label: its name with
+// the configured prefix, and with whitespace replaced by underscores.
+func jiraLabel(config cfg.Config, name string) string {
+	return config.GetLabelPrefix() + labelSpaceRegex.ReplaceAllString(strings.TrimSpace(name), "_")
+}
+
+// mergeLabels returns the JIRA labels of an issue with the labels of its
+// GitHub issue mirrored: labels with the prefix which the GitHub issue doesn't
+// have anymore are removed, and those it has are added. Other labels, such
+// as labels added by hand in JIRA, are kept. Without a prefix, labels can't
+// be told apart, so none is removed.
+func mergeLabels(config cfg.Config, ghLabels []github.Label, current []string) []string {
+	prefix := config.GetLabelPrefix()
+
+	wanted := make(map[string]bool)
+	var mirrored []string
+	for _, l := range ghLabels {
+		label := jiraLabel(config, l.GetName())
+		if label != prefix && !wanted[label] {
+			wanted[label] = true
+			mirrored = append(mirrored, label)
+		}
+	}
+
+	labels := []string{}
+	present := make(map[string]bool)
+	for _, label := range current {
+		if prefix != "" && strings.HasPrefix(label, prefix) && !wanted[label] {
+			continue
+		}
+		labels = append(labels, label)
+		present[label] = true
+	}
+	for _, label := range mirrored {
+		if !present[label] {
+			labels = append(labels, label)
+		}
+	}
+
+	return labels
+}
+
+// labelsDiffer reports whether two lists of JIRA labels differ, regardless of
+// their order.
+func labelsDiffer(a, b []string) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	set := make(map[string]bool, len(a))
+	for _, label := range a {
+		set[label] = true
+	}
+	for _, label := range b {
+		if !set[label] {
+			return true
+		}
+	}
+	return false
+}
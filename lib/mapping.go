@@ -0,0 +1,207 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// Mapping links a GitHub issue to its JIRA issue, as exported and imported
+// by `issue-sync mapping`. The ID of the GitHub issue may be left out of
+// an imported mapping, in which case it's looked up by its number.
+type Mapping struct {
+	Repo         string `json:"repo"`
+	GitHubNumber int    `json:"github-number"`
+	GitHubID     int    `json:"github-id,omitempty"`
+	JIRAKey      string `json:"jira-key"`
+}
+
+// mappingHeader is the header of the CSV mapping files.
+var mappingHeader = []string{"repo", "github-number", "github-id", "jira-key"}
+
+// ExportMappings returns the mappings of the JIRA issues of the project of
+// the repo which are linked to a GitHub issue, as told by their custom
+// fields. When the project is shared by several repos, the repo of each
+// issue is that recorded in the state, or else that whose GitHub issue
+// has the number and ID; it's empty if none has.
+func ExportMappings(config cfg.Config, ghClient clients.GitHubClient, jClient clients.JIRAClient) ([]Mapping, error) {
+	jIssues, err := jClient.ListLinkedIssues()
+	if err != nil {
+		return nil, err
+	}
+
+	repo := ghClient.GetRepo()
+	repos := append([]string{repo}, sharesProject(config, repo)...)
+	var mappings []Mapping
+	for _, jIssue := range jIssues {
+		if err := config.Context().Err(); err != nil {
+			return nil, err
+		}
+		id, ok := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubID)].(float64)
+		if !ok {
+			continue
+		}
+		number, _ := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubNumber)].(float64)
+
+		m := Mapping{GitHubNumber: int(number), GitHubID: int(id), JIRAKey: jIssue.Key}
+		if state, ok := config.GetState().GetIssue(m.GitHubID); ok && state.Repo != "" && state.JIRAKey == jIssue.Key {
+			m.Repo = state.Repo
+		} else if len(repos) == 1 {
+			m.Repo = repo
+		} else {
+			for _, r := range repos {
+				if issue, err := ghClient.GetRepoIssue(r, m.GitHubNumber); err == nil && issue.GetID() == m.GitHubID {
+					m.Repo = r
+					break
+				}
+			}
+		}
+		mappings = append(mappings, m)
+	}
+
+	return mappings, nil
+}
+
+// WriteMappings writes mappings as JSON or CSV, depending on the format.
+func WriteMappings(w io.Writer, format string, mappings []Mapping) error {
+	switch format {
+	case "json":
+		if mappings == nil {
+			mappings = []Mapping{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(mappings)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(mappingHeader)
+		for _, m := range mappings {
+			id := ""
+			if m.GitHubID != 0 {
+				id = strconv.Itoa(m.GitHubID)
+			}
+			cw.Write([]string{m.Repo, strconv.Itoa(m.GitHubNumber), id, m.JIRAKey})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown mapping format %q; must be json or csv", format)
+	}
+}
+
+// ReadMappings reads mappings written as JSON or CSV, depending on the
+// format. The columns of CSV files are told by their header, and only the
+// GitHub ID may be left out.
+func ReadMappings(r io.Reader, format string) ([]Mapping, error) {
+	var mappings []Mapping
+
+	switch format {
+	case "json":
+		if err := json.NewDecoder(r).Decode(&mappings); err != nil {
+			return nil, fmt.Errorf("invalid mappings: %v", err)
+		}
+	case "csv":
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid mappings: %v", err)
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		columns := make(map[string]int)
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, name := range []string{"repo", "github-number", "jira-key"} {
+			if _, ok := columns[name]; !ok {
+				return nil, fmt.Errorf("invalid mappings: no %s column", name)
+			}
+		}
+		for line, record := range records[1:] {
+			m := Mapping{
+				Repo:    record[columns["repo"]],
+				JIRAKey: record[columns["jira-key"]],
+			}
+			if m.GitHubNumber, err = strconv.Atoi(record[columns["github-number"]]); err != nil {
+				return nil, fmt.Errorf("invalid mappings: line %d: invalid GitHub number", line+2)
+			}
+			if i, ok := columns["github-id"]; ok && record[i] != "" {
+				if m.GitHubID, err = strconv.Atoi(record[i]); err != nil {
+					return nil, fmt.Errorf("invalid mappings: line %d: invalid GitHub ID", line+2)
+				}
+			}
+			mappings = append(mappings, m)
+		}
+	default:
+		return nil, fmt.Errorf("unknown mapping format %q; must be json or csv", format)
+	}
+
+	return mappings, nil
+}
+
+// ImportMapping links the JIRA issue of a mapping to its GitHub issue of the
+// repo of the client: its GitHub ID and number fields are set, unless they
+// already are, and the link is recorded in the state. The issue is then
+// synchronized by the next run, as if issue-sync had created it.
+func ImportMapping(config cfg.Config, m Mapping, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := issueLogger(config, m.Repo, m.GitHubNumber, m.JIRAKey)
+
+	ghIssue, err := ghClient.GetIssue(m.GitHubNumber)
+	if err != nil {
+		return err
+	}
+	if m.GitHubID != 0 && ghIssue.GetID() != m.GitHubID {
+		return fmt.Errorf("GitHub issue %s#%d has ID %d, not %d", m.Repo, m.GitHubNumber, ghIssue.GetID(), m.GitHubID)
+	}
+
+	jIssue, err := jClient.GetIssue(m.JIRAKey)
+	if err != nil {
+		return err
+	}
+	if key := config.GetProjectKey(m.Repo); !strings.HasPrefix(jIssue.Key, key+"-") {
+		return fmt.Errorf("JIRA issue %s isn't in project %s", jIssue.Key, key)
+	}
+
+	id, _ := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubID)].(float64)
+	number, _ := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubNumber)].(float64)
+	if int(id) != ghIssue.GetID() || int(number) != ghIssue.GetNumber() {
+		if id != 0 && int(id) != ghIssue.GetID() {
+			log.Warningf("JIRA issue %s was linked to GitHub issue ID %d; relinking it", jIssue.Key, int(id))
+		}
+		_, err := jClient.UpdateIssue(jira.Issue{
+			Fields: &jira.IssueFields{
+				Type: jIssue.Fields.Type,
+				Unknowns: map[string]interface{}{
+					config.GetFieldKey(cfg.GitHubID):     ghIssue.GetID(),
+					config.GetFieldKey(cfg.GitHubNumber): ghIssue.GetNumber(),
+				},
+			},
+			Key: jIssue.Key,
+			ID:  jIssue.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	state, ok := config.GetState().GetIssue(ghIssue.GetID())
+	if !ok || state.JIRAKey != jIssue.Key {
+		// Without a hash, the issue is synchronized by the next run.
+		state = cfg.IssueState{}
+	}
+	state.JIRAKey = jIssue.Key
+	state.JIRAID = jIssue.ID
+	state.Repo = m.Repo
+	config.GetState().SetIssue(ghIssue.GetID(), state)
+
+	log.Debugf("Linked JIRA issue %s to GitHub issue #%d", jIssue.Key, ghIssue.GetNumber())
+
+	return nil
+}
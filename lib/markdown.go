@@ -0,0 +1,217 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Headings
+var regexJiraHeading = regexp.MustCompile(`(?m)^h([1-6])\.\s+(.*)$`)
+
+// Text Effects
+var regexJiraStrong = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*\n]*[^*\s])?)\*($|[^\w*])`)   // *strong*
+var regexJiraCitation = regexp.MustCompile(`\?\?([^?\n]+)\?\?`)                                  // ??citation??
+var regexJiraDeleted = regexp.MustCompile(`(^|\s)-([^-\s](?:[^-\n]*[^-\s])?)-($|[\s.,;:!?])`)    // -deleted-
+var regexJiraInserted = regexp.MustCompile(`(^|\s)\+([^+\s](?:[^+\n]*[^+\s])?)\+($|[\s.,;:!?])`) // +inserted+
+var regexJiraSuperscript = regexp.MustCompile(`\^([^^\s]+)\^`)                                   // ^superscript^
+var regexJiraSubscript = regexp.MustCompile(`(^|[^~])~([^~\s]+)~($|[^~])`)                       // ~subscript~
+var regexJiraMonospaced = regexp.MustCompile(`\{\{(.+?)\}\}`)                                    // {{monospaced}}
+var regexJiraQuote = regexp.MustCompile(`(?m)^bq\.\s+(.*)$`)                                     // bq. quote
+var regexJiraQuoteBlock = regexp.MustCompile(`(?s)\{quote\}\n?(.*?)\n?\{quote\}`)                // {quote}quote{quote}
+
+// Links
+var regexJiraImage = regexp.MustCompile(`!([^!|\s]*[./][^!|\s]*)(?:\|([^!\n]*))?!`) // !url|options!
+var regexJiraAltURL = regexp.MustCompile(`\[([^|\]\n]+)\|([^\]\n]+)\]`)             // [alt|url]
+var regexJiraURL = regexp.MustCompile(`\[([a-z]+://[^\]\n]+)\]`)                    // [url]
+
+// Lists
+var regexJiraListItem = regexp.MustCompile(`^([*#]+)\s+(.*)$`)
+
+// Advanced Formatting
+var regexJiraCode = regexp.MustCompile(`(?s)\{code(?::([\w+#-]+)[^}]*)?\}\n?(.*?)\n?\{code\}`)
+var regexJiraNoFormat = regexp.MustCompile(`(?s)\{noformat[^}]*\}\n?(.*?)\n?\{noformat\}`)
+
+// asidePlaceholder is the placeholder of the parts of a body which are set
+// aside while the rest of its markup is translated.
+const asidePlaceholder = "\x02%d\x02"
+
+// asides holds the parts of a body set aside during its translation, which
+// mustn't be translated any further.
+type asides []string
+
+// aside sets a translated part of a body aside, and returns its placeholder.
+func (a *asides) aside(s string) string {
+	*a = append(*a, s)
+	return fmt.Sprintf(asidePlaceholder, len(*a)-1)
+}
+
+// restore replaces the placeholders of the body by the parts set aside. As
+// parts may contain the placeholders of parts set aside before them, they're
+// restored in reverse order.
+func (a asides) restore(body string) string {
+	for i := len(a) - 1; i >= 0; i-- {
+		body = strings.Replace(body, fmt.Sprintf(asidePlaceholder, i), a[i], -1)
+	}
+	return body
+}
+
+// JiraToGitHubBody translates a JIRA body (in wiki markup) to GitHub
+// (Markdown). It's the reverse of GitHubToJiraBody, for bodies authored in
+// JIRA: headings, text effects, links, lists, tables, and code blocks are
+// translated, and the content of code blocks and spans is kept verbatim.
+func JiraToGitHubBody(body string) string {
+	body = strings.Replace(body, "\r\n", "\n", -1)
+
+	// Code is set aside first, so that its content isn't translated.
+	var a asides
+	aside := a.aside
+	body = regexJiraCode.ReplaceAllStringFunc(body, func(s string) string {
+		m := regexJiraCode.FindStringSubmatch(s)
+		return aside("```" + m[1] + "\n" + m[2] + "\n```")
+	})
+	body = regexJiraNoFormat.ReplaceAllStringFunc(body, func(s string) string {
+		return aside("```\n" + regexJiraNoFormat.FindStringSubmatch(s)[1] + "\n```")
+	})
+	body = regexJiraMonospaced.ReplaceAllStringFunc(body, func(s string) string {
+		return aside("`" + regexJiraMonospaced.FindStringSubmatch(s)[1] + "`")
+	})
+
+	// Links are set aside as well, as they contain pipes, which separate
+	// table cells, and URLs, which text effects mustn't change.
+	body = regexJiraImage.ReplaceAllStringFunc(body, func(s string) string {
+		m := regexJiraImage.FindStringSubmatch(s)
+		return aside("![" + jiraImageAlt(m[2]) + "](" + m[1] + ")")
+	})
+	body = regexJiraAltURL.ReplaceAllStringFunc(body, func(s string) string {
+		m := regexJiraAltURL.FindStringSubmatch(s)
+		return aside("[" + m[1] + "](" + m[2] + ")")
+	})
+	body = regexJiraURL.ReplaceAllStringFunc(body, func(s string) string {
+		return aside("<" + regexJiraURL.FindStringSubmatch(s)[1] + ">")
+	})
+
+	// Tables and lists are translated line by line, before text effects, so
+	// that their markers aren't taken for emphasis.
+	body = jiraTablesToMarkdown(body)
+	body = jiraListsToMarkdown(body)
+
+	// Headings
+	body = regexJiraHeading.ReplaceAllStringFunc(body, func(s string) string {
+		m := regexJiraHeading.FindStringSubmatch(s)
+		return strings.Repeat("#", int(m[1][0]-'0')) + " " + m[2]
+	})
+
+	// Text Effects
+	body = regexJiraStrong.ReplaceAllString(body, "$1**$2**$3")
+	body = regexJiraCitation.ReplaceAllString(body, "<cite>$1</cite>")
+	body = regexJiraSubscript.ReplaceAllString(body, "$1<sub>$2</sub>$3")
+	body = regexJiraDeleted.ReplaceAllString(body, "$1~~$2~~$3")
+	body = regexJiraInserted.ReplaceAllString(body, "$1<ins>$2</ins>$3")
+	body = regexJiraSuperscript.ReplaceAllString(body, "<sup>$1</sup>")
+	body = regexJiraQuote.ReplaceAllString(body, "> $1")
+	body = regexJiraQuoteBlock.ReplaceAllStringFunc(body, func(s string) string {
+		lines := strings.Split(regexJiraQuoteBlock.FindStringSubmatch(s)[1], "\n")
+		for i, line := range lines {
+			lines[i] = "> " + line
+		}
+		return strings.Join(lines, "\n")
+	})
+
+	return a.restore(body)
+}
+
+// jiraImageAlt returns the alternate text in the options of a JIRA image.
+func jiraImageAlt(options string) string {
+	for _, option := range strings.Split(options, ",") {
+		option = strings.TrimSpace(option)
+		if strings.HasPrefix(option, "alt=") {
+			return strings.TrimPrefix(option, "alt=")
+		}
+	}
+	return ""
+}
+
+// jiraTableCells splits a row of a JIRA table into its cells, and returns
+// whether it's a header row.
+func jiraTableCells(row string) ([]string, bool) {
+	row = strings.TrimSpace(row)
+	header := strings.HasPrefix(row, "||")
+	separator := "|"
+	if header {
+		separator = "||"
+	}
+	row = strings.TrimSuffix(strings.TrimPrefix(row, separator), separator)
+
+	cells := strings.Split(row, separator)
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells, header
+}
+
+// jiraTablesToMarkdown translates the tables of a JIRA body to Markdown
+// pipe tables. Markdown tables must start with a header row, so tables which
+// don't get an empty one.
+func jiraTablesToMarkdown(body string) string {
+	lines := strings.Split(body, "\n")
+
+	var translated []string
+	inTable := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "|") || !strings.HasSuffix(trimmed, "|") || len(trimmed) < 2 {
+			inTable = false
+			translated = append(translated, line)
+			continue
+		}
+
+		cells, header := jiraTableCells(trimmed)
+		row := "| " + strings.Join(cells, " | ") + " |"
+		if !inTable {
+			inTable = true
+			if !header {
+				translated = append(translated, "|"+strings.Repeat("  |", len(cells)))
+			} else {
+				translated = append(translated, row)
+			}
+			translated = append(translated, "|"+strings.Repeat(" --- |", len(cells)))
+			if header {
+				continue
+			}
+		}
+		translated = append(translated, row)
+	}
+
+	return strings.Join(translated, "\n")
+}
+
+// jiraListsToMarkdown translates the lists of a JIRA body to Markdown lists,
+// indented by their nesting level. Items starting with a (x) or (/) emoticon
+// become tasks, as GitHubToJiraBody translates tasks to them.
+func jiraListsToMarkdown(body string) string {
+	lines := strings.Split(body, "\n")
+
+	for i, line := range lines {
+		m := regexJiraListItem.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		markers := m[1]
+		marker := "-"
+		if markers[len(markers)-1] == '#' {
+			marker = "1."
+		}
+		text := m[2]
+		switch {
+		case strings.HasPrefix(text, "(x) "):
+			text = "[ ] " + strings.TrimPrefix(text, "(x) ")
+		case strings.HasPrefix(text, "(/) "):
+			text = "[x] " + strings.TrimPrefix(text, "(/) ")
+		}
+		lines[i] = strings.Repeat("  ", len(markers)-1) + marker + " " + text
+	}
+
+	return strings.Join(lines, "\n")
+}
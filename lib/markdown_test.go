@@ -0,0 +1,84 @@
+package lib
+
+import "testing"
+
+// TestJiraToGitHubBodyRoundTrip checks that Markdown translated to JIRA
+// markup by GitHubToJiraBody translates back to the same Markdown.
+func TestJiraToGitHubBodyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		jira     string
+	}{
+		{"heading 1", "# Title", "h1. Title"},
+		{"heading 2", "## Title", "h2. Title"},
+		{"heading 3", "### Title", "h3. Title"},
+		{"heading 4", "#### Title", "h4. Title"},
+		{"heading 5", "##### Title", "h5. Title"},
+		{"heading 6", "###### Title", "h6. Title"},
+		{"strong", "some **strong** text", "some *strong* text"},
+		{"emphasis", "some _emphasized_ text", "some _emphasized_ text"},
+		{"strong and emphasis", "**strong** and _emphasized_", "*strong* and _emphasized_"},
+		{"citation", "a <cite>citation</cite>", "a ??citation??"},
+		{"deleted", "some ~~deleted~~ text", "some -deleted- text"},
+		{"inserted", "some <ins>inserted</ins> text", "some +inserted+ text"},
+		{"superscript", "x<sup>2</sup>", "x^2^"},
+		{"subscript", "H<sub>2</sub>O", "H~2~O"},
+		{"monospaced", "run `go test`", "run {{go test}}"},
+		{"quote", "> quoted", "bq. quoted"},
+		{"link", "[issue-sync](https://github.com/coreos/issue-sync)", "[issue-sync|https://github.com/coreos/issue-sync]"},
+		{"url", "<https://github.com>", "[https://github.com]"},
+		{"image", "![](https://example.com/a.png)", "!https://example.com/a.png|width=600!"},
+		{"image with alt text", "![a diagram](https://example.com/a.png)", "!https://example.com/a.png|alt=a diagram,width=600!"},
+		{"code block", "```go\nfunc main() {\n\t*p = **q\n}\n```", "{code:go}\nfunc main() {\n\t*p = **q\n}\n{code}"},
+		{"table", "| a | b |\n| --- | --- |\n| 1 | 2 |", "||a||b||\n|1|2|"},
+		{"table with a pipe in code", "| a | b |\n| --- | --- |\n| `x\\|y` | 2 |", "||a||b||\n|{{x\\|y}}|2|"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			jira := GitHubToJiraBody(test.markdown)
+			if jira != test.jira {
+				t.Errorf("GitHubToJiraBody(%q) = %q; want %q", test.markdown, jira, test.jira)
+			}
+			if markdown := JiraToGitHubBody(jira); markdown != test.markdown {
+				t.Errorf("JiraToGitHubBody(%q) = %q; want %q", jira, markdown, test.markdown)
+			}
+		})
+	}
+}
+
+// TestGitHubToJiraBodyTableCodePipe checks that the pipes in the code spans
+// of table cells are escaped, so they don't separate JIRA cells.
+func TestGitHubToJiraBodyTableCodePipe(t *testing.T) {
+	markdown := "| a | b |\n| :-- | --: |\n| `x|y` | 2 |"
+	want := "||a||b||\n|{{x\\|y}}|2|"
+	if jira := GitHubToJiraBody(markdown); jira != want {
+		t.Errorf("GitHubToJiraBody(%q) = %q; want %q", markdown, jira, want)
+	}
+}
+
+// TestGitHubToJiraBodyCodeBlocks checks the translation of the code blocks
+// which don't translate back the same way: those fenced with tildes, and
+// those containing the closing tag of a macro.
+func TestGitHubToJiraBodyCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		jira     string
+	}{
+		{"tildes", "~~~python\nprint(1)\n~~~", "{code:python}\nprint(1)\n{code}"},
+		{"tildes without language", "~~~\n*a*\n~~~", "{noformat}\n*a*\n{noformat}"},
+		{"closing code tag", "```go\n// {code}\n```", "{noformat}\n// {code}\n{noformat}"},
+		{"closing noformat tag", "```\n{noformat}\n```", "{code}\n{noformat}\n{code}"},
+		{"both closing tags", "```\n{code}{noformat}\n```", "{noformat}\n\\{code\\}\\{noformat\\}\n{noformat}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if jira := GitHubToJiraBody(test.markdown); jira != test.jira {
+				t.Errorf("GitHubToJiraBody(%q) = %q; want %q", test.markdown, jira, test.jira)
+			}
+		})
+	}
+}
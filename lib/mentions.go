@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// regexMention matches a GitHub @mention: its preceding character (\1), so
+// that email addresses aren't taken for mentions, the login (\2), and the
+// slash of team mentions, such as @org/team, which aren't translated (\3).
+var regexMention = regexp.MustCompile(`(^|[^\w@/.])@([A-Za-z0-9][A-Za-z0-9-]{0,38})(/)?`)
+
+// regexJiraMention matches a JIRA mention, of a JIRA Cloud account ID (\1),
+// or of a JIRA Server username (\2).
+var regexJiraMention = regexp.MustCompile(`\[~(?:accountid:([^\]\s]+)|([^\]\s]+))\]`)
+
+// regexCodeSpan matches the code blocks and spans of a Markdown body, whose
+// mentions aren't translated.
+var regexCodeSpan = regexp.MustCompile("(?s)```.*?```|~~~.*?~~~|`[^`\n]*`")
+
+// regexJiraCodeSpan matches the code and noformat macros, and the monospaced
+// text, of a JIRA body, whose mentions aren't translated.
+var regexJiraCodeSpan = regexp.MustCompile(`(?s)\{code[^}]*\}.*?\{code\}|\{noformat[^}]*\}.*?\{noformat\}|\{\{.*?\}\}`)
+
+// translateMentions translates the @mentions of a GitHub body, once it's
+// translated to JIRA markup, to JIRA mentions of the users they're mapped to
+// in `user-map`: [~accountid:ID] on JIRA Cloud, and [~username] on JIRA
+// Server. Mentions of users who aren't mapped become links to their GitHub
+// profile. Code isn't translated.
+func translateMentions(config cfg.Config, body string) string {
+	return outsideCode(regexJiraCodeSpan, body, func(s string) string {
+		return regexMention.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexMention.FindStringSubmatch(s)
+			if m[3] != "" {
+				return s
+			}
+			login := m[2]
+			user, ok := config.GetJIRAUser(login)
+			switch {
+			case !ok:
+				return m[1] + "[@" + login + "|" + config.GetGitHubWebURL() + login + "]"
+			case config.IsJIRACloud():
+				return m[1] + "[~accountid:" + user + "]"
+			default:
+				return m[1] + "[~" + user + "]"
+			}
+		})
+	})
+}
+
+// outsideCode applies a translation to the parts of a body which aren't
+// code, as matched by the regular expression.
+func outsideCode(code *regexp.Regexp, body string, translate func(string) string) string {
+	var translated strings.Builder
+	last := 0
+	for _, loc := range code.FindAllStringIndex(body, -1) {
+		translated.WriteString(translate(body[last:loc[0]]))
+		translated.WriteString(body[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	translated.WriteString(translate(body[last:]))
+
+	return translated.String()
+}
+
+// translateJiraMentions translates the JIRA mentions of a body, once it's
+// translated to Markdown, to GitHub @mentions of the logins the users are
+// mapped to in `user-map`. Mentions of users who aren't mapped become links
+// to their JIRA profile. Code isn't translated.
+func translateJiraMentions(config cfg.Config, body string) string {
+	return outsideCode(regexCodeSpan, body, func(s string) string {
+		return regexJiraMention.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexJiraMention.FindStringSubmatch(s)
+			user := m[1] + m[2]
+			if login, ok := config.GetGitHubLogin(user); ok {
+				return "@" + login
+			}
+			return "[" + user + "](" + config.GetJIRAProfileURL(user) + ")"
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// maxNotifiedFailures is how many of the failed issues of a run are listed
+// in a notification; the others are only counted.
+const maxNotifiedFailures = 10
+
+// Failed returns whether any issue, or any repo, of the run failed to
+// synchronize.
+func (r Report) Failed() bool {
+	return len(r.FailedRepos) > 0 || r.Counts()[OutcomeFailed] > 0
+}
+
+// summary returns the number of issues of the run with each outcome, such
+// as "3 created, 12 updated, 40 skipped, 1 failed", followed by the number
+// of conflicts, if any.
+func (r Report) summary() string {
+	counts := r.Counts()
+	parts := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		parts[i] = fmt.Sprintf("%d %s", counts[outcome], outcome)
+	}
+	if n := r.Conflicts(); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflicted", n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// failures returns the failed issues of the run, up to maxNotifiedFailures,
+// and how many others failed.
+func (r Report) failures() ([]ReportEntry, int) {
+	var failed []ReportEntry
+	for _, e := range r.Issues {
+		if e.Outcome == OutcomeFailed {
+			failed = append(failed, e)
+		}
+	}
+	if len(failed) > maxNotifiedFailures {
+		return failed[:maxNotifiedFailures], len(failed) - maxNotifiedFailures
+	}
+	return failed, 0
+}
+
+// slackMessage returns the text of the Slack message summarizing the run,
+// with links to the failed issues.
+func slackMessage(r Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "issue-sync run finished: %s.", r.summary())
+	if len(r.FailedRepos) > 0 {
+		fmt.Fprintf(&b, "\nFailed to synchronize %s.", strings.Join(r.FailedRepos, ", "))
+	}
+	failed, more := r.failures()
+	for _, e := range failed {
+		fmt.Fprintf(&b, "\n- <%s|%s#%d>: %s", e.GitHubURL, e.Repo, e.Number, e.Error)
+	}
+	if more > 0 {
+		fmt.Fprintf(&b, "\n...and %d more.", more)
+	}
+	return b.String()
+}
+
+// NotifySlack posts a summary of the run to the `slack-webhook-url`, if
+// any: the number of issues created, updated, skipped, and failed, and the
+// failures. Under the errors `slack-notify` mode, only the runs with
+// failures are posted. Dry runs aren't posted.
+func NotifySlack(config cfg.Config, r Report) error {
+	url := config.GetSlackWebhookURL()
+	if url == "" || config.IsDryRun() {
+		return nil
+	}
+	if config.GetSlackNotify() == cfg.NotifyErrors && !r.Failed() {
+		return nil
+	}
+
+	b, err := json.Marshal(struct {
+		Text string `json:"text"`
+	}{slackMessage(r)})
+	if err != nil {
+		return err
+	}
+
+	// The run's context isn't used, so that a run interrupted by a signal
+	// is still notified.
+	client := &http.Client{Timeout: config.GetTimeout()}
+	res, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("slack webhook returned %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// issueRepo returns the repo of a GitHub issue from its URL, such as
+// "owner/repo" for https://github.com/owner/repo/issues/12. Issues which
+// were transferred are returned by their new repo, as GitHub redirects
+// their old URL.
+func issueRepo(issue github.Issue) string {
+	u, err := url.Parse(issue.GetHTMLURL())
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 4 {
+		return ""
+	}
+	return parts[len(parts)-4] + "/" + parts[len(parts)-3]
+}
+
+// sharesProject returns the other configured repos whose issues are synced
+// to the JIRA project of the repo.
+func sharesProject(config cfg.Config, repo string) []string {
+	var repos []string
+	for r := range config.GetProjects() {
+		if r != repo && config.GetProjectKey(r) == config.GetProjectKey(repo) && config.GetProjectInstance(r) == config.GetProjectInstance(repo) {
+			repos = append(repos, r)
+		}
+	}
+	return repos
+}
+
+// reconcileOrphans looks for the open JIRA issues of the project of the
+// repo whose GitHub issue was deleted or transferred to another repo, and
+// closes, flags, or relinks them, depending on `orphaned-issues`. The GitHub
+// issues listed by the run exist, so only the others are retrieved, and
+// only every `orphan-check-interval`. Errors on individual issues are logged
+// rather than returned.
+func reconcileOrphans(config cfg.Config, ghIssues []github.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	action := config.GetOrphanAction()
+	if action == "" {
+		return nil
+	}
+
+	repo := ghClient.GetRepo()
+	start := time.Now()
+	if checked, ok := config.GetState().GetOrphansChecked(repo); ok && start.Sub(checked) < config.GetOrphanCheckInterval() {
+		log.Debugf("JIRA issues of %s were checked for orphans at %s; skipping", repo, checked.Format(time.RFC3339))
+		return nil
+	}
+
+	log.Debug("Looking for orphaned JIRA issues")
+
+	jIssues, err := jClient.ListSyncedIssues()
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[int]bool, len(ghIssues))
+	for _, ghIssue := range ghIssues {
+		listed[ghIssue.GetID()] = true
+	}
+
+	others := sharesProject(config, repo)
+	for _, jIssue := range jIssues {
+		if err := config.Context().Err(); err != nil {
+			return err
+		}
+		id, ok := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubID)].(float64)
+		if !ok {
+			continue
+		}
+		number, ok := jIssue.Fields.Unknowns[config.GetFieldKey(cfg.GitHubNumber)].(float64)
+		if !ok || listed[int(id)] {
+			continue
+		}
+		log := issueLogger(config, repo, int(number), jIssue.Key)
+
+		ghIssue, err := ghClient.GetIssue(int(number))
+		if err != nil && !clients.IsGone(err) {
+			log.Errorf("Error retrieving GitHub issue #%d. Error: %v", int(number), err)
+			continue
+		}
+		if err == nil && ghIssue.GetID() != int(id) {
+			// The JIRA issue is that of an issue of another repo synced to
+			// the same project.
+			continue
+		}
+		if err == nil && strings.EqualFold(issueRepo(ghIssue), repo) {
+			continue
+		}
+		if err != nil && belongsToOther(ghClient, others, int(number), int(id)) {
+			continue
+		}
+
+		if err := handleOrphan(config, action, jIssue, int(id), int(number), ghIssue, ghClient, jClient); err != nil {
+			log.Errorf("Error handling orphaned JIRA issue %s. Error: %v", jIssue.Key, err)
+		}
+	}
+	config.GetState().SetOrphansChecked(repo, start)
+
+	return nil
+}
+
+// belongsToOther returns whether the GitHub issue with the ID and number is
+// one of the other repos, rather than one which was deleted.
+func belongsToOther(ghClient clients.GitHubClient, others []string, number, id int) bool {
+	for _, r := range others {
+		if issue, err := ghClient.GetRepoIssue(r, number); err == nil && issue.GetID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// handleOrphan closes, flags, or relinks a JIRA issue whose GitHub issue
+// was deleted, if ghIssue has no ID, or transferred to another repo. Only
+// issues transferred to a repo synced to the same JIRA project are
+// relinked; the others are flagged instead. The comment explaining why is
+// only added once the issue was closed, flagged, or relinked, so that it
+// isn't added again by the next run if that failed.
+func handleOrphan(config cfg.Config, action string, jIssue jira.Issue, id, number int, ghIssue github.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	log := issueLogger(config, ghClient.GetRepo(), number, jIssue.Key)
+
+	newRepo := ""
+	comment := fmt.Sprintf("The GitHub issue %s#%d was deleted.", ghClient.GetRepo(), number)
+	if ghIssue.ID != nil {
+		newRepo = issueRepo(ghIssue)
+		comment = fmt.Sprintf("The GitHub issue %s#%d was transferred to %s#%d.", ghClient.GetRepo(), number, newRepo, ghIssue.GetNumber())
+	}
+
+	if action == cfg.OrphanRelink {
+		if target, ok := configuredRepo(config, newRepo); ok && config.GetProjectKey(target) == config.GetProjectKey(ghClient.GetRepo()) {
+			log.Infof("Relinking JIRA issue %s to GitHub issue %s#%d", jIssue.Key, target, ghIssue.GetNumber())
+			return relinkOrphan(config, jIssue, id, target, ghIssue, jClient, comment)
+		}
+		action = cfg.OrphanFlag
+	}
+
+	if action == cfg.OrphanClose {
+		log.Infof("Closing orphaned JIRA issue %s", jIssue.Key)
+		if err := jClient.TransitionIssueCategory(jIssue, true); err != nil {
+			return err
+		}
+		config.GetState().DeleteIssue(id)
+	} else {
+		log.Infof("Flagging orphaned JIRA issue %s", jIssue.Key)
+		labels := append(append([]string{}, jIssue.Fields.Labels...), clients.OrphanLabel)
+		_, err := jClient.UpdateIssue(jira.Issue{
+			Fields: &jira.IssueFields{
+				Type:     jIssue.Fields.Type,
+				Unknowns: map[string]interface{}{"labels": labels},
+			},
+			Key: jIssue.Key,
+			ID:  jIssue.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := jClient.AddComment(jIssue, comment)
+	return err
+}
+
+// configuredRepo returns the configured repo whose name is the given one,
+// regardless of its case.
+func configuredRepo(config cfg.Config, name string) (string, bool) {
+	for repo := range config.GetProjects() {
+		if strings.EqualFold(repo, name) {
+			return repo, true
+		}
+	}
+	return "", false
+}
+
+// relinkOrphan points a JIRA issue to the GitHub issue its GitHub issue was
+// transferred to, so that it's synchronized from the new repo.
+func relinkOrphan(config cfg.Config, jIssue jira.Issue, id int, repo string, ghIssue github.Issue, jClient clients.JIRAClient, comment string) error {
+	issue, err := jClient.UpdateIssue(jira.Issue{
+		Fields: &jira.IssueFields{
+			Type: jIssue.Fields.Type,
+			Unknowns: map[string]interface{}{
+				config.GetFieldKey(cfg.GitHubID):     ghIssue.GetID(),
+				config.GetFieldKey(cfg.GitHubNumber): ghIssue.GetNumber(),
+			},
+		},
+		Key: jIssue.Key,
+		ID:  jIssue.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := jClient.AddComment(jIssue, comment); err != nil {
+		return err
+	}
+
+	if config.UseRemoteLinks() {
+		if err := jClient.SyncRemoteLink(issue, repo, ghIssue); err != nil {
+			return err
+		}
+	}
+
+	if state, ok := config.GetState().GetIssue(id); ok {
+		state.Repo = repo
+		config.GetState().DeleteIssue(id)
+		config.GetState().SetIssue(ghIssue.GetID(), state)
+	}
+	return nil
+}
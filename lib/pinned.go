@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// syncPinned marks the JIRA issues of the GitHub issues pinned to the repo,
+// with the "Flagged" field or a label, as configured in `pinned-issues`,
+// and unmarks those of the issues which were unpinned, as recorded in the
+// state. Only the issues synchronized by this version of issue-sync, whose
+// repo is in the state, are marked. Errors on individual issues are logged
+// rather than returned.
+func syncPinned(config cfg.Config, ghClient clients.GitHubClient, jClient clients.JIRAClient) error {
+	if config.GetPinnedAction() == "" {
+		return nil
+	}
+
+	ids, err := ghClient.ListPinnedIssues()
+	if err != nil {
+		return err
+	}
+	pinned := make(map[int]bool)
+	for _, id := range ids {
+		pinned[id] = true
+	}
+
+	for id, state := range config.GetState().ListIssues() {
+		if state.Repo != ghClient.GetRepo() || state.Pinned == pinned[id] {
+			continue
+		}
+		log := issueLogger(config, ghClient.GetRepo(), 0, state.JIRAKey)
+
+		jIssue, err := jClient.GetIssue(state.JIRAKey)
+		if err != nil {
+			log.Errorf("Error retrieving JIRA issue %s to mark it as pinned. Error: %v", state.JIRAKey, err)
+			continue
+		}
+		if err := markPinned(config, jIssue, pinned[id], jClient); err != nil {
+			log.Errorf("Error marking JIRA issue %s as pinned. Error: %v", state.JIRAKey, err)
+			continue
+		}
+
+		state.Pinned = pinned[id]
+		config.GetState().SetIssue(id, state)
+	}
+
+	return nil
+}
+
+// markPinned flags or labels a JIRA issue whose GitHub issue is pinned, or
+// removes its flag or its label if it isn't anymore.
+func markPinned(config cfg.Config, jIssue jira.Issue, pinned bool, jClient clients.JIRAClient) error {
+	log := issueLogger(config, "", 0, jIssue.Key)
+
+	fields := &jira.IssueFields{
+		Type:     jIssue.Fields.Type,
+		Unknowns: map[string]interface{}{},
+	}
+
+	if config.GetPinnedAction() == cfg.PinnedFlag {
+		var flag interface{}
+		if pinned {
+			flag = []map[string]string{{"value": cfg.FlaggedValue}}
+		}
+		fields.Unknowns[config.GetFieldKey(cfg.Flagged)] = flag
+	} else {
+		label := config.GetPinnedLabel()
+		labels := []string{}
+		for _, l := range jIssue.Fields.Labels {
+			if l != label {
+				labels = append(labels, l)
+			}
+		}
+		if pinned {
+			labels = append(labels, label)
+		}
+		fields.Unknowns["labels"] = labels
+	}
+
+	if pinned {
+		log.Infof("Marking JIRA issue %s, whose GitHub issue is pinned", jIssue.Key)
+	} else {
+		log.Infof("Unmarking JIRA issue %s, whose GitHub issue was unpinned", jIssue.Key)
+	}
+	_, err := jClient.UpdateIssue(jira.Issue{
+		Fields: fields,
+		Key:    jIssue.Key,
+		ID:     jIssue.ID,
+	})
+	return err
+}
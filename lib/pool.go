@@ -0,0 +1,32 @@
+package lib
+
+import "sync"
+
+// ForEach calls fn with each index from 0 to n-1, running at most `workers`
+// calls at the same time, and returns once all of them have returned.
+func ForEach(workers, n int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	ForEachSlot(make(chan struct{}, workers), n, fn)
+}
+
+// ForEachSlot is like ForEach, but each call takes one of the slots of a
+// pool which may be shared with other callers, so that all of them together
+// run at most its capacity of calls at the same time. fn must not take
+// another slot of the same pool.
+func ForEachSlot(slots chan struct{}, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,154 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// regexReference matches a reference to a GitHub issue, such as #123 or
+// owner/repo#45: its preceding character (\1), so that anchors and HTML
+// entities aren't taken for references, a closing keyword such as "fixes"
+// (\2), the repo if it's another one (\3), and the number of the issue (\4).
+var regexReference = regexp.MustCompile(`(?i)(^|[^\w/#&.:-])((?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+)?([\w.-]+/[\w.-]+)?#([0-9]+)\b`)
+
+// reference is a reference to a GitHub issue in the body of an issue or a
+// comment.
+type reference struct {
+	repo    string
+	number  int
+	closing bool
+}
+
+// findReferences returns the references to GitHub issues of a GitHub
+// (Markdown) body of the repo, outside code.
+func findReferences(repo, body string) []reference {
+	var refs []reference
+	outsideCode(regexCodeSpan, body, func(s string) string {
+		for _, m := range regexReference.FindAllStringSubmatch(s, -1) {
+			ref := reference{repo: m[3], closing: m[2] != ""}
+			if ref.repo == "" {
+				ref.repo = repo
+			}
+			ref.number, _ = strconv.Atoi(m[4])
+			refs = append(refs, ref)
+		}
+		return s
+	})
+	return refs
+}
+
+// referenceResolver finds the JIRA issues of the GitHub issues which bodies
+// reference, keeping those it found.
+type referenceResolver struct {
+	config   cfg.Config
+	ghClient clients.GitHubClient
+	jClient  clients.JIRAClient
+
+	// keys is guarded by mu, as comments are synced in parallel.
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+// newReferenceResolver creates a referenceResolver for the issues of the
+// repo of the GitHub client.
+func newReferenceResolver(config cfg.Config, ghClient clients.GitHubClient, jClient clients.JIRAClient) *referenceResolver {
+	return &referenceResolver{
+		config:   config,
+		ghClient: ghClient,
+		jClient:  jClient,
+		keys:     make(map[string]string),
+	}
+}
+
+// syncedRepo returns the configured repo whose name is that of a reference,
+// regardless of its case, if its project is on the same JIRA instance.
+func (r *referenceResolver) syncedRepo(name string) (string, bool) {
+	repo, ok := configuredRepo(r.config, name)
+	return repo, ok && r.config.GetProjectInstance(repo) == r.config.GetJIRAInstance()
+}
+
+// resolve returns the key of the JIRA issue of the GitHub issue a reference
+// designates, or an empty key if it isn't synced, or the repo of the issue
+// isn't synced to the same JIRA instance.
+func (r *referenceResolver) resolve(ref reference) string {
+	log := r.config.GetLogger()
+
+	repo, ok := r.syncedRepo(ref.repo)
+	if !ok {
+		return ""
+	}
+	name := fmt.Sprintf("%s#%d", repo, ref.number)
+	r.mu.Lock()
+	key, ok := r.keys[name]
+	r.mu.Unlock()
+	if ok {
+		return key
+	}
+
+	if issue, err := r.ghClient.GetRepoIssue(repo, ref.number); err == nil {
+		if state, ok := r.config.GetState().GetIssue(issue.GetID()); ok {
+			key = state.JIRAKey
+		} else if key, err = r.jClient.FindGitHubIssue(issue.GetID()); err != nil {
+			log.Errorf("Error finding the JIRA issue of GitHub issue %s. Error: %v", name, err)
+		}
+	}
+
+	r.mu.Lock()
+	r.keys[name] = key
+	r.mu.Unlock()
+	return key
+}
+
+// rewrite replaces the references to GitHub issues of a body, once it's
+// translated to JIRA markup, by the keys of their JIRA issues, which JIRA
+// links to. References to issues which aren't synced, and code, are left as
+// they are.
+func (r *referenceResolver) rewrite(body string) string {
+	return outsideCode(regexJiraCodeSpan, body, func(s string) string {
+		return regexReference.ReplaceAllStringFunc(s, func(s string) string {
+			m := regexReference.FindStringSubmatch(s)
+			ref := reference{repo: m[3]}
+			if ref.repo == "" {
+				ref.repo = r.ghClient.GetRepo()
+			}
+			ref.number, _ = strconv.Atoi(m[4])
+			if key := r.resolve(ref); key != "" {
+				return m[1] + m[2] + key
+			}
+			return s
+		})
+	})
+}
+
+// link links the JIRA issue to the JIRA issues of the GitHub issues which a
+// GitHub body references: with a "Blocks" link if it closes them, such as
+// with "fixes #67", and with a "Relates" link otherwise. Links aren't
+// removed when the references are.
+func (r *referenceResolver) link(jIssue jira.Issue, body string) {
+	log := r.config.GetLogger()
+
+	linked := make(map[string]bool)
+	for _, ref := range findReferences(r.ghClient.GetRepo(), body) {
+		key := r.resolve(ref)
+		if key == "" || key == jIssue.Key {
+			continue
+		}
+		linkType := clients.RelatesLinkType
+		if ref.closing {
+			linkType = clients.BlocksLinkType
+		}
+		if linked[linkType+" "+key] {
+			continue
+		}
+		linked[linkType+" "+key] = true
+		if err := r.jClient.LinkIssue(jIssue, linkType, key); err != nil {
+			log.Errorf("Error linking JIRA issue %s to %s. Error: %v", jIssue.Key, key, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// The outcomes of the synchronization of a GitHub issue in a report.
+const (
+	OutcomeCreated = "created"
+	OutcomeUpdated = "updated"
+	OutcomeSkipped = "skipped"
+	OutcomeFailed  = "failed"
+)
+
+// outcomes are the outcomes in the order they're summarized in.
+var outcomes = []string{OutcomeCreated, OutcomeUpdated, OutcomeSkipped, OutcomeFailed}
+
+// outcomeEvents maps the outcomes to the events emitted for them.
+var outcomeEvents = map[string]string{
+	OutcomeCreated: EventIssueCreated,
+	OutcomeUpdated: EventIssueUpdated,
+	OutcomeFailed:  EventSyncFailed,
+}
+
+// ReportEntry is the outcome of the synchronization of a GitHub issue, and
+// the `conflict-strategy` decision it was resolved with, if it conflicted
+// with its JIRA issue.
+type ReportEntry struct {
+	Repo      string    `json:"repo"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	GitHubURL string    `json:"github-url"`
+	JIRAKey   string    `json:"jira-key,omitempty"`
+	JIRAURL   string    `json:"jira-url,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Conflict  string    `json:"conflict,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Report is the outcome of each GitHub issue synchronized by a run, which
+// is saved to the `report-file` at the end of the run, and rendered by
+// `issue-sync report`.
+type Report struct {
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Issues   []ReportEntry `json:"issues"`
+	// FailedRepos are the repos which failed to synchronize as a whole,
+	// such as because their issues couldn't be listed.
+	FailedRepos []string `json:"failed-repos,omitempty"`
+	// Runs is the number of runs of a digest, which covers several.
+	Runs int `json:"runs,omitempty"`
+}
+
+// report accumulates the outcomes of the run, from StartReport until
+// FinishReport; outside of a run, such as in the webhook server, nothing is
+// accumulated.
+var report = struct {
+	sync.Mutex
+	started bool
+	Report
+}{}
+
+// StartReport starts accumulating the outcomes of a run.
+func StartReport() {
+	report.Lock()
+	defer report.Unlock()
+
+	report.started = true
+	report.Report = Report{Started: time.Now()}
+}
+
+// recordOutcome adds the outcome of the synchronization of a GitHub issue of
+// the repo to the report of the run, if any, and emits its event; it's a
+// failure if err isn't nil.
+func recordOutcome(config cfg.Config, repo string, ghIssue TranslatedIssue, key, outcome string, err error) {
+	recordConflictOutcome(config, repo, ghIssue, key, outcome, "", err)
+}
+
+// recordConflictOutcome is like recordOutcome, for an issue which conflicted
+// with its JIRA issue, if decision isn't empty, and was resolved with it.
+func recordConflictOutcome(config cfg.Config, repo string, ghIssue TranslatedIssue, key, outcome, decision string, err error) {
+	entry := ReportEntry{
+		Repo:      repo,
+		Number:    ghIssue.GetNumber(),
+		Title:     ghIssue.GetTitle(),
+		GitHubURL: ghIssue.GetHTMLURL(),
+		JIRAKey:   key,
+		JIRAURL:   jiraURL(config, key),
+		Outcome:   outcome,
+		Conflict:  decision,
+		Time:      time.Now(),
+	}
+	if err != nil {
+		entry.Outcome = OutcomeFailed
+		entry.Error = err.Error()
+	}
+	if event, ok := outcomeEvents[entry.Outcome]; ok {
+		emitEvent(config, Event{
+			Event:     event,
+			Repo:      repo,
+			Number:    entry.Number,
+			GitHubURL: entry.GitHubURL,
+			JIRAKey:   key,
+			JIRAURL:   entry.JIRAURL,
+			Error:     entry.Error,
+		})
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	if report.started {
+		report.Issues = append(report.Issues, entry)
+	}
+}
+
+// FinishReport stops accumulating the outcomes of the run, and returns its
+// report, along with the repos which failed to synchronize.
+func FinishReport(failed []string) Report {
+	report.Lock()
+	defer report.Unlock()
+
+	r := report.Report
+	r.Finished = time.Now()
+	r.FailedRepos = failed
+	report.started = false
+	report.Report = Report{}
+	return r
+}
+
+// SaveReport writes the report of a run to the `report-file`, unless none
+// is configured or this is a dry run.
+func SaveReport(config cfg.Config, r Report) error {
+	path := config.GetReportFile()
+	if path == "" || config.IsDryRun() {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// LoadReport reads a report saved by SaveReport.
+func LoadReport(path string) (Report, error) {
+	var r Report
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("unable to parse report %s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Counts returns the number of issues of the report with each outcome.
+func (r Report) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, e := range r.Issues {
+		counts[e.Outcome]++
+	}
+	return counts
+}
+
+// Conflicts returns the number of issues of the report which conflicted with
+// their JIRA issue.
+func (r Report) Conflicts() int {
+	n := 0
+	for _, e := range r.Issues {
+		if e.Conflict != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteCSV writes the issues of the report as CSV, with a header row.
+func (r Report) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"repo", "number", "title", "github-url", "jira-key", "jira-url", "outcome", "conflict", "error", "time"})
+	for _, e := range r.Issues {
+		w.Write([]string{e.Repo, strconv.Itoa(e.Number), e.Title, e.GitHubURL, e.JIRAKey, e.JIRAURL, e.Outcome, e.Conflict, e.Error, e.Time.Format(time.RFC3339)})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// reportTemplate is the HTML rendering of a report, which is self-contained
+// so that it can be sent by email.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>issue-sync report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.failed { color: #b00; }
+</style>
+</head>
+<body>
+<h1>issue-sync report</h1>
+<p>{{if gt .Report.Runs 1}}{{.Report.Runs}} runs{{else}}Run{{end}} from {{.Report.Started.Format "2006-01-02 15:04:05 MST"}} to {{.Report.Finished.Format "2006-01-02 15:04:05 MST"}}.</p>
+<table>
+<tr>{{range .Outcomes}}<th>{{.}}</th>{{end}}</tr>
+<tr>{{range .Outcomes}}<td>{{index $.Counts .}}</td>{{end}}</tr>
+</table>
+{{with .Report.Conflicts}}<p>Conflicts with JIRA issues: {{.}}.</p>
+{{end}}{{if .Report.FailedRepos}}<p class="failed">Failed to synchronize: {{range $i, $r := .Report.FailedRepos}}{{if $i}}, {{end}}{{$r}}{{end}}.</p>
+{{end}}<h2>Issues</h2>
+<table>
+<tr><th>GitHub issue</th><th>Title</th><th>JIRA issue</th><th>Outcome</th><th>Conflict</th><th>Error</th></tr>
+{{range .Report.Issues}}<tr class="{{.Outcome}}">
+<td><a href="{{.GitHubURL}}">{{.Repo}}#{{.Number}}</a></td>
+<td>{{.Title}}</td>
+<td>{{if .JIRAURL}}<a href="{{.JIRAURL}}">{{.JIRAKey}}</a>{{end}}</td>
+<td>{{.Outcome}}</td>
+<td>{{.Conflict}}</td>
+<td>{{.Error}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as an HTML page, with the number of issues
+// with each outcome, and links to the GitHub and JIRA issues.
+func (r Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, struct {
+		Report   Report
+		Outcomes []string
+		Counts   map[string]int
+	}{r, outcomes, r.Counts()})
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// ProjectStatus is the synchronization status of a GitHub repo, as shown by
+// `issue-sync status`.
+type ProjectStatus struct {
+	Repo string
+	// Linked is the number of GitHub issues of the repo which the state maps
+	// to a JIRA issue.
+	Linked int
+	// Pending is the number of GitHub issues updated since the last sync
+	// which aren't in the state, and which the next sync would create,
+	// unless it finds their JIRA issue by searching.
+	Pending int
+	// Drift is the number of linked GitHub issues whose synchronized fields
+	// changed since they were last synchronized.
+	Drift int
+	// LastSync is the time of the last successful sync, if Synced.
+	LastSync time.Time
+	Synced   bool
+}
+
+// GetProjectStatus returns the synchronization status of the repo of the
+// client. Only the GitHub issues updated since the last sync are listed, as
+// the others can't be pending or have drifted; nothing is written, not even
+// to the state.
+func GetProjectStatus(config cfg.Config, ghClient clients.GitHubClient) (ProjectStatus, error) {
+	repo := ghClient.GetRepo()
+	status := ProjectStatus{Repo: repo}
+	status.LastSync, status.Synced = config.GetLastSync(repo)
+
+	states := config.GetState().ListIssues()
+	shared := len(sharesProject(config, repo)) > 0
+	key := config.GetProjectKey(repo)
+	for _, state := range states {
+		if state.Repo != "" && strings.EqualFold(state.Repo, repo) {
+			status.Linked++
+		} else if state.Repo == "" && !shared && strings.HasPrefix(state.JIRAKey, key+"-") {
+			// Earlier versions of issue-sync didn't record the repo, which
+			// is then told by the JIRA project, unless it's shared.
+			status.Linked++
+		}
+	}
+
+	ghIssues, err := ghClient.ListIssues()
+	if err != nil {
+		return status, err
+	}
+
+	var mu sync.Mutex
+	ForEachSlot(config.IssueSlots(), len(ghIssues), func(i int) {
+		ghIssue := ghIssues[i]
+		if isFiltered(config, ghClient, ghIssue) {
+			return
+		}
+		state, ok := states[ghIssue.GetID()]
+		drifted := ok && state.Hash != issueHash(config, NewTranslatedIssue(config, repo, ghIssue))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !ok {
+			status.Pending++
+		} else if drifted {
+			status.Drift++
+		}
+	})
+
+	return status, nil
+}
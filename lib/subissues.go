@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// parentKey returns the key of the JIRA issue of the parent of a GitHub
+// issue, of which it's a sub-issue, if sub-issues are synced and the repo
+// of the parent is synced to the same JIRA instance, or an empty key
+// otherwise. It also returns whether the parent isn't synced yet, in
+// which case the issue has to be synchronized again once it is.
+func parentKey(config cfg.Config, ghIssue TranslatedIssue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, bool, error) {
+	if config.GetSubIssues() == "" {
+		return "", false, nil
+	}
+
+	parent, err := ghClient.GetParentIssue(ghIssue.GetNumber())
+	if err != nil || parent == nil {
+		return "", false, err
+	}
+	repo, ok := configuredRepo(config, issueRepo(*parent))
+	if !ok || config.GetProjectInstance(repo) != config.GetJIRAInstance() {
+		return "", false, nil
+	}
+
+	if state, ok := config.GetState().GetIssue(parent.GetID()); ok {
+		return state.JIRAKey, false, nil
+	}
+	key, err := jClient.FindGitHubIssue(parent.GetID())
+	if err != nil {
+		return "", false, err
+	}
+	return key, key == "", nil
+}
+
+// issueEpic returns the key of the epic of the JIRA issue of a GitHub issue:
+// the JIRA issue of its parent issue, with the epic `sub-issues`, or else
+// the epic of its milestone, with `milestone-epics`. It also returns
+// whether its parent isn't synced yet.
+func issueEpic(config cfg.Config, ghIssue TranslatedIssue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, bool, error) {
+	if config.GetSubIssues() == cfg.SubIssueEpics {
+		parent, pending, err := parentKey(config, ghIssue, ghClient, jClient)
+		if err != nil || parent != "" {
+			return parent, pending, err
+		}
+		epic, err := milestoneEpic(config, ghIssue, jClient)
+		return epic, pending, err
+	}
+
+	epic, err := milestoneEpic(config, ghIssue, jClient)
+	return epic, false, err
+}
+
+// syncParentLink links the JIRA issue of a GitHub issue to the JIRA issue of
+// its parent issue, with the link `sub-issues`, and removes the link to the
+// JIRA issue of its previous parent, as recorded in the state, if it
+// changed. It returns the key of the parent, and whether it isn't synced
+// yet.
+func syncParentLink(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, ghClient clients.GitHubClient, jClient clients.JIRAClient) (string, bool, error) {
+	if config.GetSubIssues() != cfg.SubIssueLinks {
+		return "", false, nil
+	}
+
+	parent, pending, err := parentKey(config, ghIssue, ghClient, jClient)
+	if err != nil {
+		return "", false, err
+	}
+
+	state, _ := config.GetState().GetIssue(ghIssue.GetID())
+	if state.Parent != "" && state.Parent != parent {
+		if err := jClient.UnlinkIssue(jIssue, clients.SubIssueLinkType, state.Parent); err != nil {
+			return state.Parent, false, err
+		}
+	}
+	if parent != "" {
+		if err := jClient.LinkIssue(jIssue, clients.SubIssueLinkType, parent); err != nil {
+			return state.Parent, false, err
+		}
+	}
+	return parent, pending, nil
+}
+
+// recordParent saves in the state the key of the JIRA issue of the parent
+// of a GitHub issue which its JIRA issue is linked to. If the parent isn't
+// synced yet, the hash of the issue is forgotten, so that it's synchronized
+// by the next run.
+func recordParent(config cfg.Config, ghIssue TranslatedIssue, parent string, pending bool) {
+	state, ok := config.GetState().GetIssue(ghIssue.GetID())
+	if !ok {
+		return
+	}
+	state.Parent = parent
+	if pending {
+		state.Hash = ""
+	}
+	config.GetState().SetIssue(ghIssue.GetID(), state)
+}
@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+)
+
+// task is an item of a task list of a GitHub issue body.
+type task struct {
+	text string
+	done bool
+}
+
+// topLevelTasks returns the tasks of the task lists of a GitHub issue body
+// which aren't nested in another list item, in order. Tasks in fenced code
+// blocks are left out.
+func topLevelTasks(body string) []task {
+	var tasks []task
+
+	fenced := false
+	for _, line := range strings.Split(strings.Replace(body, "\r\n", "\n", -1), "\n") {
+		if regexFence.MatchString(line) {
+			fenced = !fenced
+		}
+		if fenced {
+			continue
+		}
+		m := regexListItem.FindStringSubmatch(line)
+		if m == nil || m[1] != "" || m[4] == "" {
+			continue
+		}
+		text := strings.TrimSpace(m[5])
+		if text == "" {
+			continue
+		}
+		if r := []rune(text); len(r) > maxSummaryLength {
+			text = string(r[:maxSummaryLength])
+		}
+		tasks = append(tasks, task{text: text, done: m[4] != " "})
+	}
+
+	return tasks
+}
+
+// syncSubtasks creates a JIRA sub-task of the issue for each top-level task
+// of the GitHub issue body which has none, and moves every sub-task to a
+// status in the "Done" category if its task is checked, or out of it
+// otherwise. Sub-tasks are matched to tasks by their summary; those whose
+// task was removed are left as they are.
+func syncSubtasks(config cfg.Config, ghIssue TranslatedIssue, jIssue jira.Issue, jClient clients.JIRAClient) error {
+	log := config.GetLogger()
+
+	subtasks := make(map[string][]jira.Issue)
+	if jIssue.Fields != nil {
+		for _, s := range jIssue.Fields.Subtasks {
+			fields := s.Fields
+			subtasks[fields.Summary] = append(subtasks[fields.Summary], jira.Issue{ID: s.ID, Key: s.Key, Fields: &fields})
+		}
+	}
+
+	for _, t := range topLevelTasks(ghIssue.GetBody()) {
+		var subtask jira.Issue
+		if found := subtasks[t.text]; len(found) > 0 {
+			subtask, subtasks[t.text] = found[0], found[1:]
+		} else {
+			var err error
+			subtask, err = jClient.CreateSubtask(jIssue, t.text)
+			if err != nil {
+				return err
+			}
+			// New sub-tasks aren't done yet. If the sub-task was not created
+			// (for ex. when using dry run), it can't be transitioned.
+			if !t.done || subtask.Key == "" {
+				continue
+			}
+		}
+
+		if err := jClient.TransitionIssueCategory(subtask, t.done); err != nil {
+			log.Errorf("Error transitioning JIRA sub-task %s. Error: %v", subtask.Key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// Systemd notifies systemd of the state of a daemon running as a service of
+// `Type=notify`, through the socket of $NOTIFY_SOCKET: when it's ready, its
+// status after each cycle, and, if the service has a `WatchdogSec`, that it's
+// still alive. A nil Systemd, as when the daemon isn't run by systemd, does
+// nothing.
+type Systemd struct {
+	config cfg.Config
+	socket string
+
+	mu        sync.Mutex
+	lastCycle time.Time
+}
+
+// NewSystemd creates a Systemd object notifying the socket of systemd, or
+// returns nil if there's none. Watchdog pings are sent at half the interval
+// systemd expects them, as long as cycles are completed.
+func NewSystemd(config cfg.Config) *Systemd {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	s := &Systemd{
+		config:    config,
+		socket:    socket,
+		lastCycle: time.Now(),
+	}
+	if interval, ok := watchdogInterval(); ok {
+		go s.watchdog(interval / 2)
+	}
+	return s
+}
+
+// watchdogInterval returns the interval systemd expects watchdog pings at,
+// if the watchdog is enabled for this process.
+func watchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// notify sends a state, such as "READY=1", to systemd.
+func (s *Systemd) notify(state string) {
+	if s == nil {
+		return
+	}
+	log := s.config.GetLogger()
+
+	// Names starting with "@" are in the abstract namespace, which Go
+	// handles.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: s.socket, Net: "unixgram"})
+	if err != nil {
+		log.Errorf("Error notifying systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Errorf("Error notifying systemd: %v", err)
+	}
+}
+
+// SetConfig replaces the configuration, after it has been reloaded.
+func (s *Systemd) SetConfig(config cfg.Config) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// Ready tells systemd that the daemon has started.
+func (s *Systemd) Ready() {
+	s.notify("READY=1")
+}
+
+// Status sets the status systemd shows for the service.
+func (s *Systemd) Status(status string) {
+	s.notify("STATUS=" + status)
+}
+
+// MarkCycle records that a synchronization cycle has just completed, and
+// sets the status of the service to its result.
+func (s *Systemd) MarkCycle(status string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.lastCycle = time.Now()
+	s.mu.Unlock()
+
+	s.Status(status)
+}
+
+// Stopping tells systemd that the daemon is shutting down.
+func (s *Systemd) Stopping() {
+	s.notify("STOPPING=1")
+}
+
+// watchdog pings systemd at the interval, as long as the daemon completes
+// its cycles, within the same limit as /healthz, so that systemd restarts
+// it if its sync loop is stuck.
+func (s *Systemd) watchdog(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.mu.Lock()
+		config := s.config
+		since := time.Since(s.lastCycle)
+		s.mu.Unlock()
+
+		if since > 3*config.GetDaemonPeriod()+config.GetTimeout() {
+			log := config.GetLogger()
+			log.Warningf("No sync cycle completed in %v; not pinging the systemd watchdog", since)
+			continue
+		}
+		s.notify("WATCHDOG=1")
+	}
+}
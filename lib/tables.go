@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// regexTableDelimiter matches the delimiter row of a Markdown table, which
+// follows its header row, such as `| --- | :---: | ---: |`.
+var regexTableDelimiter = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// regexFence matches the lines opening or closing fenced code blocks, whose
+// content is never translated as tables.
+var regexFence = regexp.MustCompile("^\\s*(```|~~~)")
+
+// tableCells splits a row of a Markdown table into its cells, trimmed of
+// whitespace. Pipes escaped with a backslash, or inside `code spans`, don't
+// separate cells; those inside code spans are escaped, as they would
+// separate the cells of the JIRA table.
+func tableCells(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, `\|`) {
+		row = strings.TrimSuffix(row, "|")
+	}
+
+	var cells []string
+	var cell strings.Builder
+	code := false
+	for i := 0; i < len(row); i++ {
+		switch c := row[i]; {
+		case c == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteString(`\|`)
+			i++
+		case c == '`':
+			code = !code
+			cell.WriteByte(c)
+		case c == '|' && code:
+			cell.WriteString(`\|`)
+		case c == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// tableRow formats cells as a row of a JIRA table, with the separator of
+// header cells (`||`) or of other cells (`|`). The cells of the row are cut
+// or padded to the number of columns of the table, as GitHub does. Empty
+// cells hold a space, as JIRA would otherwise merge their separators.
+func tableRow(cells []string, columns int, separator string) string {
+	row := separator
+	for i := 0; i < columns; i++ {
+		cell := " "
+		if i < len(cells) && cells[i] != "" {
+			cell = cells[i]
+		}
+		row += cell + separator
+	}
+	return row
+}
+
+// translateTables translates the Markdown pipe tables of a body to JIRA
+// tables: the header row to `||heading||` cells, and the other rows to
+// `|cell|` cells. JIRA tables have no column alignment, so the alignment of
+// the delimiter row (`:---`, `:---:`, `---:`) isn't supported, and dropped. Tables in fenced
+// code blocks are left as they are.
+func translateTables(body string) string {
+	lines := strings.Split(body, "\n")
+
+	var translated []string
+	fenced := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if regexFence.MatchString(line) {
+			fenced = !fenced
+		}
+
+		// A table starts with a header row, followed by a delimiter row
+		// with as many cells.
+		if fenced || !strings.Contains(line, "|") || i+1 >= len(lines) ||
+			!regexTableDelimiter.MatchString(lines[i+1]) {
+			translated = append(translated, line)
+			continue
+		}
+		header := tableCells(line)
+		if len(tableCells(lines[i+1])) != len(header) {
+			translated = append(translated, line)
+			continue
+		}
+
+		translated = append(translated, tableRow(header, len(header), "||"))
+		i += 2
+		// The table ends at the first blank line, or line without a pipe.
+		for ; i < len(lines) && strings.Contains(lines[i], "|") && strings.TrimSpace(lines[i]) != ""; i++ {
+			translated = append(translated, tableRow(tableCells(lines[i]), len(header), "|"))
+		}
+		i--
+	}
+
+	return strings.Join(translated, "\n")
+}
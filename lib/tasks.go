@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// regexListItem matches an item of a Markdown list: its indentation (\1),
+// its marker (\2), the state of its checkbox if it's a task (\4), and its
+// text (\5).
+var regexListItem = regexp.MustCompile(`^(\s*)([-+*]|[0-9]+[.)])\s+(\[([ xX])\]\s+)?(.*)$`)
+
+// Placeholders of the JIRA list markers of translated task lists. They're
+// replaced by the JIRA markers once the rest of the markup is translated, so
+// that `*` isn't taken for emphasis, nor `#` for a heading.
+const (
+	bulletPlaceholder   = "\x00"
+	numberedPlaceholder = "\x01"
+)
+
+// taskCheckbox returns the JIRA emoticon for the checkbox of a task: (/) if
+// it's checked, and (x) otherwise.
+func taskCheckbox(state string) string {
+	if state == " " {
+		return "(x)"
+	}
+	return "(/)"
+}
+
+// translateTaskLists translates the Markdown lists which have task items
+// (`- [ ]` and `- [x]`) to JIRA lists, keeping their nesting, with the
+// checkboxes as (x) and (/) emoticons. Lists without tasks, and lists in
+// fenced code blocks, are left as they are. The markers of the translated
+// lists are placeholders, which restoreListMarkers replaces.
+func translateTaskLists(body string) string {
+	lines := strings.Split(body, "\n")
+
+	fenced := false
+	for start := 0; start < len(lines); start++ {
+		if regexFence.MatchString(lines[start]) {
+			fenced = !fenced
+		}
+		if fenced || !regexListItem.MatchString(lines[start]) {
+			continue
+		}
+
+		end := start
+		tasks := false
+		for ; end < len(lines) && regexListItem.MatchString(lines[end]); end++ {
+			tasks = tasks || regexListItem.FindStringSubmatch(lines[end])[4] != ""
+		}
+		if tasks {
+			translateListItems(lines[start:end])
+		}
+		start = end - 1
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// translateListItems translates the items of a Markdown list in place. The
+// nesting level of each item is the number of items above it with less
+// indentation, up to the item at the top level.
+func translateListItems(items []string) {
+	var indents []int
+	var markers []string
+	for i, item := range items {
+		m := regexListItem.FindStringSubmatch(item)
+		indent := len(strings.Replace(m[1], "\t", "    ", -1))
+		for len(indents) > 0 && indents[len(indents)-1] >= indent {
+			indents = indents[:len(indents)-1]
+			markers = markers[:len(markers)-1]
+		}
+
+		marker := bulletPlaceholder
+		if !strings.ContainsAny(m[2], "-+*") {
+			marker = numberedPlaceholder
+		}
+		indents = append(indents, indent)
+		markers = append(markers, marker)
+
+		text := m[5]
+		if m[4] != "" {
+			text = taskCheckbox(m[4]) + " " + text
+		}
+		items[i] = strings.Join(markers, "") + " " + text
+	}
+}
+
+// restoreListMarkers replaces the placeholders of the list markers of the
+// translated task lists by the JIRA markers.
+func restoreListMarkers(body string) string {
+	body = strings.Replace(body, bulletPlaceholder, "*", -1)
+	return strings.Replace(body, numberedPlaceholder, "#", -1)
+}
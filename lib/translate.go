@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/coreos/issue-sync/cfg"
+)
+
+// maxTranslationGrowth is the ratio of the size of a translated body to
+// the size of the original above which the translation is suspicious.
+const maxTranslationGrowth = 2
+
+// minTranslationGrowth is the number of bytes a translated body must grow
+// by before its growth is considered, so short bodies aren't reported.
+const minTranslationGrowth = 1024
+
+// regexMacro matches the JIRA macros which must come in opening and closing pairs.
+var regexMacro = regexp.MustCompile(`\{(code|noformat|quote|panel|color|expand)(:[^}]*)?\}`)
+
+// translationProblems returns the reasons a translated body looks like it
+// was mangled by the translation: JIRA macros which aren't closed, monospace
+// markers which don't match, or a size which grew much more than the markup
+// differences can account for.
+func translationProblems(original, translated string) []string {
+	var problems []string
+
+	counts := make(map[string]int)
+	for _, m := range regexMacro.FindAllStringSubmatch(translated, -1) {
+		counts[m[1]]++
+	}
+	for _, macro := range []string{"code", "noformat", "quote", "panel", "color", "expand"} {
+		if counts[macro]%2 != 0 {
+			problems = append(problems, fmt.Sprintf("unbalanced {%s} macro", macro))
+		}
+	}
+
+	if strings.Count(translated, "{{") != strings.Count(translated, "}}") {
+		problems = append(problems, "unbalanced {{monospace}} markers")
+	}
+
+	growth := len(translated) - len(original)
+	if growth > minTranslationGrowth && len(translated) > maxTranslationGrowth*len(original) {
+		problems = append(problems, fmt.Sprintf("size grew from %d to %d bytes", len(original), len(translated)))
+	}
+
+	return problems
+}
+
+// noFormat wraps a GitHub body in a {noformat} macro, so that it's shown
+// verbatim in JIRA. Macros in the body are escaped so they can't close it.
+func noFormat(body string) string {
+	body = strings.Replace(body, "{noformat}", `\{noformat\}`, -1)
+	return fmt.Sprintf("{noformat}\n%s\n{noformat}", body)
+}
+
+// translateBody translates a GitHub (Markdown) body to JIRA markup, with its
+// @mentions translated to JIRA mentions (see translateMentions), and its emoji
+// shortcodes to Unicode emoji (see translateEmojis). If the
+// translation looks suspicious, a warning with the problems is logged and,
+// if `translation-fallback` is set, the original body is returned wrapped
+// in a {noformat} macro instead.
+func translateBody(config cfg.Config, log *logrus.Entry, body string) string {
+	translated := translateMentions(config, GitHubToJiraBody(translateEmojis(config, body)))
+
+	problems := translationProblems(body, translated)
+	if len(problems) == 0 {
+		return translated
+	}
+
+	log.WithField("translation-problems", strings.Join(problems, "; ")).
+		Warn("Translation of GitHub markdown to JIRA markup looks incorrect")
+
+	if config.UseTranslationFallback() {
+		return noFormat(body)
+	}
+	return translated
+}
+
+// TranslateJiraBody translates a JIRA body to GitHub (Markdown), with its
+// JIRA mentions translated to @mentions (see translateJiraMentions).
+func TranslateJiraBody(config cfg.Config, body string) string {
+	return translateJiraMentions(config, JiraToGitHubBody(body))
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"sort"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// mentionedLogins returns the logins @mentioned in a GitHub (Markdown) body,
+// outside code. Team mentions are left out.
+func mentionedLogins(body string) []string {
+	var logins []string
+	outsideCode(regexCodeSpan, body, func(s string) string {
+		for _, m := range regexMention.FindAllStringSubmatch(s, -1) {
+			if m[3] == "" {
+				logins = append(logins, m[2])
+			}
+		}
+		return s
+	})
+	return logins
+}
+
+// issueWatchers returns the JIRA users, in `user-map`, of the participants
+// of a GitHub issue: its assignees, the users mentioned in its body or its
+// comments, and the authors of its comments. Participants who aren't mapped
+// are left out.
+func issueWatchers(config cfg.Config, ghIssue github.Issue, comments []*github.IssueComment) []string {
+	logins := append(assigneeLogins(ghIssue), mentionedLogins(ghIssue.GetBody())...)
+	for _, c := range comments {
+		logins = append(logins, c.User.GetLogin())
+		logins = append(logins, mentionedLogins(c.GetBody())...)
+	}
+
+	found := make(map[string]bool)
+	var users []string
+	for _, login := range logins {
+		if user, ok := config.GetJIRAUser(login); ok && !found[user] {
+			found[user] = true
+			users = append(users, user)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// syncWatchers adds the JIRA users of the participants of a GitHub issue to
+// the watchers of its JIRA issue, with `sync-watchers`. Watchers are never
+// removed, as users may have chosen to watch the issue.
+func syncWatchers(config cfg.Config, ghIssue github.Issue, comments []*github.IssueComment, jIssue jira.Issue, jClient clients.JIRAClient) {
+	if !config.SyncsWatchers() {
+		return
+	}
+	log := issueLogger(config, issueRepo(ghIssue), ghIssue.GetNumber(), jIssue.Key)
+
+	users := issueWatchers(config, ghIssue, comments)
+	if len(users) == 0 {
+		return
+	}
+	if err := jClient.AddWatchers(jIssue, users); err != nil {
+		log.Errorf("Error adding the watchers of JIRA issue %s. Error: %v", jIssue.Key, err)
+	}
+}
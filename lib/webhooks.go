@@ -0,0 +1,304 @@
+package lib
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/coreos/issue-sync/cfg"
+	"github.com/coreos/issue-sync/lib/clients"
+	"github.com/google/go-github/github"
+)
+
+// webhookQueueLength is the number of webhook-triggered syncs which may be
+// waiting to run before new deliveries are rejected.
+const webhookQueueLength = 100
+
+// RepoClients holds the pair of clients used to synchronize a single
+// GitHub repository with its JIRA project.
+type RepoClients struct {
+	GitHub clients.GitHubClient
+	JIRA   clients.JIRAClient
+}
+
+// SyncQueue runs webhook-triggered syncs one at a time in the background,
+// so that concurrent deliveries for the same issue (from either GitHub or
+// JIRA) can't race each other.
+type SyncQueue struct {
+	syncs chan func()
+	// done is closed once the worker has returned.
+	done chan struct{}
+
+	// mu protects closed, which is set once the queue is drained.
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewSyncQueue creates a SyncQueue and starts the worker which runs the
+// queued syncs. Once issue-sync is shut down, the syncs still queued are
+// dropped rather than started.
+func NewSyncQueue(config cfg.Config) *SyncQueue {
+	q := &SyncQueue{
+		syncs: make(chan func(), webhookQueueLength),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(q.done)
+		for f := range q.syncs {
+			if config.Context().Err() != nil {
+				continue
+			}
+			f()
+		}
+	}()
+
+	return q
+}
+
+// push queues a sync, returning false if the queue is full or drained.
+func (q *SyncQueue) push(f func()) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+	select {
+	case q.syncs <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drain stops accepting syncs, and waits for the queued ones to be run or
+// dropped.
+func (q *SyncQueue) Drain() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.syncs)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+}
+
+// GitHubWebhookHandler is an http.Handler which receives GitHub `issues` and
+// `issue_comment` webhooks and synchronizes only the affected issue.
+type GitHubWebhookHandler struct {
+	config  cfg.Config
+	clients map[string]RepoClients
+	queue   *SyncQueue
+}
+
+// NewGitHubWebhookHandler creates a GitHubWebhookHandler for the configured
+// repositories, which runs its syncs on the provided queue.
+func NewGitHubWebhookHandler(config cfg.Config, repoClients map[string]RepoClients, queue *SyncQueue) GitHubWebhookHandler {
+	return GitHubWebhookHandler{
+		config:  config,
+		clients: repoClients,
+		queue:   queue,
+	}
+}
+
+// ServeHTTP validates and parses a webhook delivery, then queues a sync of
+// the issue it refers to. Events for other repositories or event types are
+// acknowledged and ignored.
+func (h GitHubWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := h.config.GetLogger()
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var payload []byte
+	var err error
+	if secret := h.config.GetConfigString("github-webhook-secret"); secret != "" {
+		payload, err = github.ValidatePayload(r, []byte(secret))
+	} else {
+		payload, err = ioutil.ReadAll(r.Body)
+	}
+	if err != nil {
+		log.Errorf("Error reading GitHub webhook delivery %s: %v", github.DeliveryID(r), err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		log.Debugf("Ignoring GitHub webhook delivery %s: %v", github.DeliveryID(r), err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var issue *github.Issue
+	var repo *github.Repository
+	switch e := event.(type) {
+	case *github.IssuesEvent:
+		issue, repo = e.Issue, e.Repo
+	case *github.IssueCommentEvent:
+		issue, repo = e.Issue, e.Repo
+	default:
+		log.Debugf("Ignoring GitHub webhook event of type %s", github.WebHookType(r))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if issue == nil || repo == nil || issue.PullRequestLinks != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	name := repo.GetFullName()
+	c, ok := h.clients[name]
+	if !ok {
+		log.Debugf("Ignoring GitHub webhook for unconfigured repo %s", name)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	number := issue.GetNumber()
+
+	// The issue is retrieved again from GitHub when the sync runs, so that
+	// out-of-order deliveries can't overwrite JIRA with stale data.
+	queued := h.queue.push(func() {
+		ghIssue, err := c.GitHub.GetIssue(number)
+		if err != nil {
+			log.Errorf("Error retrieving %s#%d for webhook sync: %v", name, number, err)
+			return
+		}
+		if err := SyncIssue(h.config.ForProject(name), ghIssue, c.GitHub, c.JIRA); err != nil {
+			log.Errorf("Error syncing %s#%d: %v", name, number, err)
+		}
+	})
+	if !queued {
+		log.Errorf("Webhook queue is full; dropping sync of %s#%d", name, number)
+		http.Error(w, "sync queue is full", http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Debugf("Queued sync of %s#%d", name, number)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// jiraWebhook is the payload of a JIRA issue webhook. For an example of
+// its structure, see https://developer.atlassian.com/server/jira/platform/webhooks/.
+type jiraWebhook struct {
+	WebhookEvent string     `json:"webhookEvent"`
+	Issue        jira.Issue `json:"issue"`
+	Changelog    struct {
+		Items []jira.ChangelogItems `json:"items"`
+	} `json:"changelog"`
+}
+
+// JIRAWebhookHandler is an http.Handler which receives JIRA `jira:issue_updated`
+// webhooks and reflects the changes onto the linked GitHub issue.
+type JIRAWebhookHandler struct {
+	config  cfg.Config
+	clients map[string]RepoClients
+	queue   *SyncQueue
+}
+
+// NewJIRAWebhookHandler creates a JIRAWebhookHandler for the configured
+// repositories, which runs its syncs on the provided queue.
+func NewJIRAWebhookHandler(config cfg.Config, repoClients map[string]RepoClients, queue *SyncQueue) JIRAWebhookHandler {
+	return JIRAWebhookHandler{
+		config:  config,
+		clients: repoClients,
+		queue:   queue,
+	}
+}
+
+// ServeHTTP checks the shared secret and parses a webhook delivery, then
+// queues an update of the GitHub issue linked to the JIRA issue. JIRA can't
+// sign its deliveries, so the secret is passed in the `secret` query parameter
+// of the webhook URL.
+func (h JIRAWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := h.config.GetLogger()
+
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if secret := h.config.GetConfigString("jira-webhook-secret"); secret != "" {
+		given := r.URL.Query().Get("secret")
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(given)) != 1 {
+			log.Error("JIRA webhook delivery has an invalid secret")
+			http.Error(w, "invalid secret", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var hook jiraWebhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		log.Errorf("Error reading JIRA webhook delivery: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if hook.WebhookEvent != "jira:issue_updated" || hook.Issue.Fields == nil {
+		log.Debugf("Ignoring JIRA webhook event of type %s", hook.WebhookEvent)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	changed := make([]string, len(hook.Changelog.Items))
+	for i, item := range hook.Changelog.Items {
+		changed[i] = item.Field
+	}
+
+	// Webhooks of a JIRA instance of `jira-instances` are told apart by the
+	// name of the instance in the query string.
+	instance := strings.ToLower(r.URL.Query().Get("instance"))
+
+	jIssue := hook.Issue
+	var repos []string
+	for repo, project := range h.config.GetProjects() {
+		if project.Key == jIssue.Fields.Project.Key && h.config.GetProjectInstance(repo) == instance {
+			repos = append(repos, repo)
+		}
+	}
+	if len(repos) == 0 {
+		log.Debugf("Ignoring JIRA webhook for unconfigured project %s", jIssue.Fields.Project.Key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// The payload only tells which issue changed: the issue is retrieved
+	// again from JIRA when the update runs, so that forged or out-of-order
+	// deliveries can't overwrite GitHub with stale data.
+	key := jIssue.Key
+	queued := h.queue.push(func() {
+		for _, repo := range repos {
+			c := h.clients[repo]
+			jIssue, err := c.JIRA.GetIssue(key)
+			if err != nil {
+				log.Errorf("Error retrieving JIRA issue %s for webhook update: %v", key, err)
+				continue
+			}
+			if jIssue.Fields == nil || jIssue.Fields.Project.Key != h.config.GetProjectKey(repo) {
+				log.Debugf("Ignoring JIRA webhook for %s, which isn't in the project of %s", key, repo)
+				continue
+			}
+			if err := UpdateGitHubIssue(h.config.ForProject(repo), jIssue, changed, c.GitHub); err != nil {
+				log.Errorf("Error updating GitHub from JIRA issue %s: %v", key, err)
+			}
+		}
+	})
+	if !queued {
+		log.Errorf("Webhook queue is full; dropping update from JIRA issue %s", key)
+		http.Error(w, "sync queue is full", http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Debugf("Queued update from JIRA issue %s", key)
+	w.WriteHeader(http.StatusAccepted)
+}